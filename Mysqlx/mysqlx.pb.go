@@ -46,6 +46,13 @@ const (
 	ClientMessages_CRUD_DELETE                ClientMessages_Type = 20
 	ClientMessages_EXPECT_OPEN                ClientMessages_Type = 24
 	ClientMessages_EXPECT_CLOSE               ClientMessages_Type = 25
+	ClientMessages_PREPARE_PREPARE            ClientMessages_Type = 40
+	ClientMessages_PREPARE_EXECUTE            ClientMessages_Type = 41
+	ClientMessages_PREPARE_DEALLOCATE         ClientMessages_Type = 42
+	ClientMessages_CURSOR_OPEN                ClientMessages_Type = 43
+	ClientMessages_CURSOR_CLOSE               ClientMessages_Type = 44
+	ClientMessages_CURSOR_FETCH               ClientMessages_Type = 45
+	ClientMessages_COMPRESSION                ClientMessages_Type = 46
 )
 
 var ClientMessages_Type_name = map[int32]string{
@@ -63,6 +70,13 @@ var ClientMessages_Type_name = map[int32]string{
 	20: "CRUD_DELETE",
 	24: "EXPECT_OPEN",
 	25: "EXPECT_CLOSE",
+	40: "PREPARE_PREPARE",
+	41: "PREPARE_EXECUTE",
+	42: "PREPARE_DEALLOCATE",
+	43: "CURSOR_OPEN",
+	44: "CURSOR_CLOSE",
+	45: "CURSOR_FETCH",
+	46: "COMPRESSION",
 }
 var ClientMessages_Type_value = map[string]int32{
 	"CON_CAPABILITIES_GET":       1,
@@ -79,6 +93,13 @@ var ClientMessages_Type_value = map[string]int32{
 	"CRUD_DELETE":                20,
 	"EXPECT_OPEN":                24,
 	"EXPECT_CLOSE":               25,
+	"PREPARE_PREPARE":            40,
+	"PREPARE_EXECUTE":            41,
+	"PREPARE_DEALLOCATE":         42,
+	"CURSOR_OPEN":                43,
+	"CURSOR_CLOSE":               44,
+	"CURSOR_FETCH":               45,
+	"COMPRESSION":                46,
 }
 
 func (x ClientMessages_Type) Enum() *ClientMessages_Type {
@@ -116,6 +137,7 @@ const (
 	ServerMessages_RESULTSET_FETCH_DONE_MORE_RESULTSETS ServerMessages_Type = 16
 	ServerMessages_SQL_STMT_EXECUTE_OK                  ServerMessages_Type = 17
 	ServerMessages_RESULTSET_FETCH_DONE_MORE_OUT_PARAMS ServerMessages_Type = 18
+	ServerMessages_COMPRESSION                          ServerMessages_Type = 19
 )
 
 var ServerMessages_Type_name = map[int32]string{
@@ -132,6 +154,7 @@ var ServerMessages_Type_name = map[int32]string{
 	16: "RESULTSET_FETCH_DONE_MORE_RESULTSETS",
 	17: "SQL_STMT_EXECUTE_OK",
 	18: "RESULTSET_FETCH_DONE_MORE_OUT_PARAMS",
+	19: "COMPRESSION",
 }
 var ServerMessages_Type_value = map[string]int32{
 	"OK":                                   0,
@@ -147,6 +170,7 @@ var ServerMessages_Type_value = map[string]int32{
 	"RESULTSET_FETCH_DONE_MORE_RESULTSETS": 16,
 	"SQL_STMT_EXECUTE_OK":                  17,
 	"RESULTSET_FETCH_DONE_MORE_OUT_PARAMS": 18,
+	"COMPRESSION":                          19,
 }
 
 func (x ServerMessages_Type) Enum() *ServerMessages_Type {
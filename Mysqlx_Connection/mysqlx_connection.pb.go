@@ -14,6 +14,7 @@ It has these top-level messages:
 	CapabilitiesGet
 	CapabilitiesSet
 	Close
+	Compression
 */
 package Mysqlx_Connection
 
@@ -21,6 +22,7 @@ import proto "github.com/golang/protobuf/proto"
 import fmt "fmt"
 import math "math"
 import "github.com/sjmudd/go-mysqlx-driver/Mysqlx_Datatypes"
+import "github.com/sjmudd/go-mysqlx-driver/Mysqlx"
 
 // Reference imports to suppress errors if they are not otherwise used.
 var _ = proto.Marshal
@@ -128,12 +130,59 @@ func (m *Close) String() string            { return proto.CompactTextString(m) }
 func (*Close) ProtoMessage()               {}
 func (*Close) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
 
+// one or more messages compressed with the algorithm chosen with the
+// ``compression`` capability
+//
+// ``payload`` holds the compressed frames, each with its length and
+// type, which uncompress to ``uncompressed_size`` bytes. The message
+// type is only set if all the frames have the same type.
+type Compression struct {
+	UncompressedSize *uint64                     `protobuf:"varint,1,opt,name=uncompressed_size" json:"uncompressed_size,omitempty"`
+	ServerMessages   *Mysqlx.ServerMessages_Type `protobuf:"varint,2,opt,name=server_messages,enum=Mysqlx.ServerMessages_Type" json:"server_messages,omitempty"`
+	ClientMessages   *Mysqlx.ClientMessages_Type `protobuf:"varint,3,opt,name=client_messages,enum=Mysqlx.ClientMessages_Type" json:"client_messages,omitempty"`
+	Payload          []byte                      `protobuf:"bytes,4,req,name=payload" json:"payload,omitempty"`
+	XXX_unrecognized []byte                      `json:"-"`
+}
+
+func (m *Compression) Reset()         { *m = Compression{} }
+func (m *Compression) String() string { return proto.CompactTextString(m) }
+func (*Compression) ProtoMessage()    {}
+
+func (m *Compression) GetUncompressedSize() uint64 {
+	if m != nil && m.UncompressedSize != nil {
+		return *m.UncompressedSize
+	}
+	return 0
+}
+
+func (m *Compression) GetServerMessages() Mysqlx.ServerMessages_Type {
+	if m != nil && m.ServerMessages != nil {
+		return *m.ServerMessages
+	}
+	return Mysqlx.ServerMessages_OK
+}
+
+func (m *Compression) GetClientMessages() Mysqlx.ClientMessages_Type {
+	if m != nil && m.ClientMessages != nil {
+		return *m.ClientMessages
+	}
+	return Mysqlx.ClientMessages_CON_CAPABILITIES_GET
+}
+
+func (m *Compression) GetPayload() []byte {
+	if m != nil {
+		return m.Payload
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*Capability)(nil), "Mysqlx.Connection.Capability")
 	proto.RegisterType((*Capabilities)(nil), "Mysqlx.Connection.Capabilities")
 	proto.RegisterType((*CapabilitiesGet)(nil), "Mysqlx.Connection.CapabilitiesGet")
 	proto.RegisterType((*CapabilitiesSet)(nil), "Mysqlx.Connection.CapabilitiesSet")
 	proto.RegisterType((*Close)(nil), "Mysqlx.Connection.Close")
+	proto.RegisterType((*Compression)(nil), "Mysqlx.Connection.Compression")
 }
 
 var fileDescriptor0 = []byte{
@@ -27,8 +27,8 @@ package Mysqlx_Crud
 import proto "github.com/golang/protobuf/proto"
 import fmt "fmt"
 import math "math"
-import "github.com/sjmudd/mysql/plugin/x/Mysqlx_Expr"
-import "github.com/sjmudd/mysql/plugin/x/Mysqlx_Datatypes"
+import "github.com/sjmudd/go-mysqlx-driver/Mysqlx_Expr"
+import "github.com/sjmudd/go-mysqlx-driver/Mysqlx_Datatypes"
 
 // Reference imports to suppress errors if they are not otherwise used.
 var _ = proto.Marshal
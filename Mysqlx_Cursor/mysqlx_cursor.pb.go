@@ -0,0 +1,209 @@
+// Code generated by protoc-gen-go.
+// source: mysqlx_cursor.proto
+// DO NOT EDIT!
+
+/*
+Package Mysqlx_Cursor is a generated protocol buffer package.
+
+Handling of Cursors
+
+It is generated from these files:
+	mysqlx_cursor.proto
+
+It has these top-level messages:
+	Open
+	Fetch
+	Close
+*/
+package Mysqlx_Cursor
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+import "github.com/sjmudd/go-mysqlx-driver/Mysqlx_Prepare"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+const _ = proto.ProtoPackageIsVersion1
+
+type Open_OneOfMessage_Type int32
+
+const (
+	Open_OneOfMessage_PREPARE_EXECUTE Open_OneOfMessage_Type = 0
+)
+
+var Open_OneOfMessage_Type_name = map[int32]string{
+	0: "PREPARE_EXECUTE",
+}
+var Open_OneOfMessage_Type_value = map[string]int32{
+	"PREPARE_EXECUTE": 0,
+}
+
+func (x Open_OneOfMessage_Type) Enum() *Open_OneOfMessage_Type {
+	p := new(Open_OneOfMessage_Type)
+	*p = x
+	return p
+}
+func (x Open_OneOfMessage_Type) String() string {
+	return proto.EnumName(Open_OneOfMessage_Type_name, int32(x))
+}
+func (x *Open_OneOfMessage_Type) UnmarshalJSON(data []byte) error {
+	value, err := proto.UnmarshalJSONEnum(Open_OneOfMessage_Type_value, data, "Open_OneOfMessage_Type")
+	if err != nil {
+		return err
+	}
+	*x = Open_OneOfMessage_Type(value)
+	return nil
+}
+
+// Open a cursor
+//
+// .. uml::
+//
+//   client -> server: Open
+//   alt Success
+//     ... none or partial Resultsets or full Resultsets
+//     client <- server: StmtExecuteOk
+//  else Failure
+//     client <- server: Error
+//  end
+//
+// :param cursor_id: client side assigned cursor id, the ID is going to represent new cursor and assigned to it statement
+// :param stmt: statement which resultset is going to be iterated through the cursor
+// :param fetch_rows: number of rows which should be retrieved from sequential cursor
+// :Returns: :protobuf:msg:`Mysqlx.Ok::`
+type Open struct {
+	CursorId         *uint32            `protobuf:"varint,1,req,name=cursor_id" json:"cursor_id,omitempty"`
+	Stmt             *Open_OneOfMessage `protobuf:"bytes,4,req,name=stmt" json:"stmt,omitempty"`
+	FetchRows        *uint64            `protobuf:"varint,5,opt,name=fetch_rows" json:"fetch_rows,omitempty"`
+	XXX_unrecognized []byte             `json:"-"`
+}
+
+func (m *Open) Reset()         { *m = Open{} }
+func (m *Open) String() string { return proto.CompactTextString(m) }
+func (*Open) ProtoMessage()    {}
+
+func (m *Open) GetCursorId() uint32 {
+	if m != nil && m.CursorId != nil {
+		return *m.CursorId
+	}
+	return 0
+}
+
+func (m *Open) GetStmt() *Open_OneOfMessage {
+	if m != nil {
+		return m.Stmt
+	}
+	return nil
+}
+
+func (m *Open) GetFetchRows() uint64 {
+	if m != nil && m.FetchRows != nil {
+		return *m.FetchRows
+	}
+	return 0
+}
+
+type Open_OneOfMessage struct {
+	Type             *Open_OneOfMessage_Type `protobuf:"varint,1,req,name=type,enum=Mysqlx.Cursor.Open_OneOfMessage_Type" json:"type,omitempty"`
+	PrepareExecute   *Mysqlx_Prepare.Execute `protobuf:"bytes,2,opt,name=prepare_execute" json:"prepare_execute,omitempty"`
+	XXX_unrecognized []byte                  `json:"-"`
+}
+
+func (m *Open_OneOfMessage) Reset()         { *m = Open_OneOfMessage{} }
+func (m *Open_OneOfMessage) String() string { return proto.CompactTextString(m) }
+func (*Open_OneOfMessage) ProtoMessage()    {}
+
+func (m *Open_OneOfMessage) GetType() Open_OneOfMessage_Type {
+	if m != nil && m.Type != nil {
+		return *m.Type
+	}
+	return Open_OneOfMessage_PREPARE_EXECUTE
+}
+
+func (m *Open_OneOfMessage) GetPrepareExecute() *Mysqlx_Prepare.Execute {
+	if m != nil {
+		return m.PrepareExecute
+	}
+	return nil
+}
+
+// Fetch next portion of data from a cursor
+//
+// .. uml::
+//
+//   client -> server: Fetch
+//   alt Success
+//     ... none or partial Resultsets or full Resultsets
+//     client <- server: StmtExecuteOk
+//   else
+//    client <- server: Error
+//   end
+//
+// :param cursor_id: client side assigned cursor id, must be already open
+// :param fetch_rows: number of rows which should be retrieve from sequential cursor
+type Fetch struct {
+	CursorId         *uint32 `protobuf:"varint,1,req,name=cursor_id" json:"cursor_id,omitempty"`
+	FetchRows        *uint64 `protobuf:"varint,5,opt,name=fetch_rows" json:"fetch_rows,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Fetch) Reset()         { *m = Fetch{} }
+func (m *Fetch) String() string { return proto.CompactTextString(m) }
+func (*Fetch) ProtoMessage()    {}
+
+func (m *Fetch) GetCursorId() uint32 {
+	if m != nil && m.CursorId != nil {
+		return *m.CursorId
+	}
+	return 0
+}
+
+func (m *Fetch) GetFetchRows() uint64 {
+	if m != nil && m.FetchRows != nil {
+		return *m.FetchRows
+	}
+	return 0
+}
+
+// Close cursor
+//
+// .. uml::
+//
+//   client -> server: Close
+//   alt Success
+//     client <- server: Ok
+//   else Failure
+//     client <- server: Error
+//   end
+//
+// :param cursor_id: client side assigned cursor id, must be allocated/open
+// :Returns: :protobuf:msg:`Mysqlx::Ok|Mysqlx::Error`
+type Close struct {
+	CursorId         *uint32 `protobuf:"varint,1,req,name=cursor_id" json:"cursor_id,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Close) Reset()         { *m = Close{} }
+func (m *Close) String() string { return proto.CompactTextString(m) }
+func (*Close) ProtoMessage()    {}
+
+func (m *Close) GetCursorId() uint32 {
+	if m != nil && m.CursorId != nil {
+		return *m.CursorId
+	}
+	return 0
+}
+
+func init() {
+	proto.RegisterType((*Open)(nil), "Mysqlx.Cursor.Open")
+	proto.RegisterType((*Open_OneOfMessage)(nil), "Mysqlx.Cursor.Open.OneOfMessage")
+	proto.RegisterType((*Fetch)(nil), "Mysqlx.Cursor.Fetch")
+	proto.RegisterType((*Close)(nil), "Mysqlx.Cursor.Close")
+	proto.RegisterEnum("Mysqlx.Cursor.Open_OneOfMessage_Type", Open_OneOfMessage_Type_name, Open_OneOfMessage_Type_value)
+}
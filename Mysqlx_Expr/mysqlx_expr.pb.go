@@ -37,7 +37,7 @@ package Mysqlx_Expr
 import proto "github.com/golang/protobuf/proto"
 import fmt "fmt"
 import math "math"
-import "github.com/sjmudd/mysql/plugin/x/Mysqlx_Datatypes"
+import "github.com/sjmudd/go-mysqlx-driver/Mysqlx_Datatypes"
 
 // Reference imports to suppress errors if they are not otherwise used.
 var _ = proto.Marshal
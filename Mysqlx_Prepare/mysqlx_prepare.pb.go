@@ -0,0 +1,261 @@
+// Code generated by protoc-gen-go.
+// source: mysqlx_prepare.proto
+// DO NOT EDIT!
+
+/*
+Package Mysqlx_Prepare is a generated protocol buffer package.
+
+Handling of prepared statments
+
+It is generated from these files:
+	mysqlx_prepare.proto
+
+It has these top-level messages:
+	Prepare
+	Execute
+	Deallocate
+*/
+package Mysqlx_Prepare
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+import "github.com/sjmudd/go-mysqlx-driver/Mysqlx_Sql"
+import "github.com/sjmudd/go-mysqlx-driver/Mysqlx_Crud"
+import "github.com/sjmudd/go-mysqlx-driver/Mysqlx_Datatypes"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+const _ = proto.ProtoPackageIsVersion1
+
+// Determine which of optional fields was set by the client
+// (Workaround for missing "oneof" keyword in pb2.5)
+type Prepare_OneOfMessage_Type int32
+
+const (
+	Prepare_OneOfMessage_FIND   Prepare_OneOfMessage_Type = 0
+	Prepare_OneOfMessage_INSERT Prepare_OneOfMessage_Type = 1
+	Prepare_OneOfMessage_UPDATE Prepare_OneOfMessage_Type = 2
+	Prepare_OneOfMessage_DELETE Prepare_OneOfMessage_Type = 4
+	Prepare_OneOfMessage_STMT   Prepare_OneOfMessage_Type = 5
+)
+
+var Prepare_OneOfMessage_Type_name = map[int32]string{
+	0: "FIND",
+	1: "INSERT",
+	2: "UPDATE",
+	4: "DELETE",
+	5: "STMT",
+}
+var Prepare_OneOfMessage_Type_value = map[string]int32{
+	"FIND":   0,
+	"INSERT": 1,
+	"UPDATE": 2,
+	"DELETE": 4,
+	"STMT":   5,
+}
+
+func (x Prepare_OneOfMessage_Type) Enum() *Prepare_OneOfMessage_Type {
+	p := new(Prepare_OneOfMessage_Type)
+	*p = x
+	return p
+}
+func (x Prepare_OneOfMessage_Type) String() string {
+	return proto.EnumName(Prepare_OneOfMessage_Type_name, int32(x))
+}
+func (x *Prepare_OneOfMessage_Type) UnmarshalJSON(data []byte) error {
+	value, err := proto.UnmarshalJSONEnum(Prepare_OneOfMessage_Type_value, data, "Prepare_OneOfMessage_Type")
+	if err != nil {
+		return err
+	}
+	*x = Prepare_OneOfMessage_Type(value)
+	return nil
+}
+
+// Prepare a new statement
+//
+// .. uml::
+//
+//   client -> server: Prepare
+//   alt Success
+//   client <- server: Ok
+//   else Failure
+//   client <- server: Error
+//   end
+//
+// :param stmt_id: client side assigned statement id, which is going to identify the result of preparation
+// :param stmt: defines one of following messages to be prepared - Crud.Find, Crud.Insert, Crud.Delete, Crud.Upsert, Sql.StmtExecute
+// :Returns: :protobuf:msg:`Mysqlx::Ok|Mysqlx::Error`
+type Prepare struct {
+	StmtId           *uint32               `protobuf:"varint,1,req,name=stmt_id" json:"stmt_id,omitempty"`
+	Stmt             *Prepare_OneOfMessage `protobuf:"bytes,2,req,name=stmt" json:"stmt,omitempty"`
+	XXX_unrecognized []byte                `json:"-"`
+}
+
+func (m *Prepare) Reset()         { *m = Prepare{} }
+func (m *Prepare) String() string { return proto.CompactTextString(m) }
+func (*Prepare) ProtoMessage()    {}
+
+func (m *Prepare) GetStmtId() uint32 {
+	if m != nil && m.StmtId != nil {
+		return *m.StmtId
+	}
+	return 0
+}
+
+func (m *Prepare) GetStmt() *Prepare_OneOfMessage {
+	if m != nil {
+		return m.Stmt
+	}
+	return nil
+}
+
+type Prepare_OneOfMessage struct {
+	Type             *Prepare_OneOfMessage_Type `protobuf:"varint,1,req,name=type,enum=Mysqlx.Prepare.Prepare_OneOfMessage_Type" json:"type,omitempty"`
+	Find             *Mysqlx_Crud.Find          `protobuf:"bytes,2,opt,name=find" json:"find,omitempty"`
+	Insert           *Mysqlx_Crud.Insert        `protobuf:"bytes,3,opt,name=insert" json:"insert,omitempty"`
+	Update           *Mysqlx_Crud.Update        `protobuf:"bytes,4,opt,name=update" json:"update,omitempty"`
+	Delete           *Mysqlx_Crud.Delete        `protobuf:"bytes,5,opt,name=delete" json:"delete,omitempty"`
+	StmtExecute      *Mysqlx_Sql.StmtExecute    `protobuf:"bytes,6,opt,name=stmt_execute" json:"stmt_execute,omitempty"`
+	XXX_unrecognized []byte                     `json:"-"`
+}
+
+func (m *Prepare_OneOfMessage) Reset()         { *m = Prepare_OneOfMessage{} }
+func (m *Prepare_OneOfMessage) String() string { return proto.CompactTextString(m) }
+func (*Prepare_OneOfMessage) ProtoMessage()    {}
+
+func (m *Prepare_OneOfMessage) GetType() Prepare_OneOfMessage_Type {
+	if m != nil && m.Type != nil {
+		return *m.Type
+	}
+	return Prepare_OneOfMessage_FIND
+}
+
+func (m *Prepare_OneOfMessage) GetFind() *Mysqlx_Crud.Find {
+	if m != nil {
+		return m.Find
+	}
+	return nil
+}
+
+func (m *Prepare_OneOfMessage) GetInsert() *Mysqlx_Crud.Insert {
+	if m != nil {
+		return m.Insert
+	}
+	return nil
+}
+
+func (m *Prepare_OneOfMessage) GetUpdate() *Mysqlx_Crud.Update {
+	if m != nil {
+		return m.Update
+	}
+	return nil
+}
+
+func (m *Prepare_OneOfMessage) GetDelete() *Mysqlx_Crud.Delete {
+	if m != nil {
+		return m.Delete
+	}
+	return nil
+}
+
+func (m *Prepare_OneOfMessage) GetStmtExecute() *Mysqlx_Sql.StmtExecute {
+	if m != nil {
+		return m.StmtExecute
+	}
+	return nil
+}
+
+// Execute already prepared statement
+//
+// .. uml::
+//
+//   client -> server: Execute
+//   alt Success
+//     ... Resultsets...
+//     client <- server: StmtExecuteOk
+//   else Failure
+//     client <- server: Error
+//   end
+//
+// :param stmt_id: client side assigned statement id, must be already prepared
+// :param args_list: Arguments to bind to the prepared statement
+// :param compact_metadata: send only type information for :protobuf:msg:`Mysqlx.Resultset::ColumnMetadata`, skipping names and others
+// :Returns: :protobuf:msg:`Mysqlx::Ok`
+type Execute struct {
+	StmtId           *uint32                 `protobuf:"varint,1,req,name=stmt_id" json:"stmt_id,omitempty"`
+	Args             []*Mysqlx_Datatypes.Any `protobuf:"bytes,2,rep,name=args" json:"args,omitempty"`
+	CompactMetadata  *bool                   `protobuf:"varint,3,opt,name=compact_metadata,def=0" json:"compact_metadata,omitempty"`
+	XXX_unrecognized []byte                  `json:"-"`
+}
+
+func (m *Execute) Reset()         { *m = Execute{} }
+func (m *Execute) String() string { return proto.CompactTextString(m) }
+func (*Execute) ProtoMessage()    {}
+
+const Default_Execute_CompactMetadata bool = false
+
+func (m *Execute) GetStmtId() uint32 {
+	if m != nil && m.StmtId != nil {
+		return *m.StmtId
+	}
+	return 0
+}
+
+func (m *Execute) GetArgs() []*Mysqlx_Datatypes.Any {
+	if m != nil {
+		return m.Args
+	}
+	return nil
+}
+
+func (m *Execute) GetCompactMetadata() bool {
+	if m != nil && m.CompactMetadata != nil {
+		return *m.CompactMetadata
+	}
+	return Default_Execute_CompactMetadata
+}
+
+// Deallocate already prepared statement
+//
+// Deallocating the statement.
+//
+// .. uml::
+//
+//   client -> server: Deallocate
+//   alt Success
+//     client <- server: Ok
+//   else Failure
+//     client <- server: Error
+//   end
+//
+// :param stmt_id: client side assigned statement id, must be already prepared
+// :Returns: :protobuf:msg:`Mysqlx::Ok|Mysqlx::Error`
+type Deallocate struct {
+	StmtId           *uint32 `protobuf:"varint,1,req,name=stmt_id" json:"stmt_id,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Deallocate) Reset()         { *m = Deallocate{} }
+func (m *Deallocate) String() string { return proto.CompactTextString(m) }
+func (*Deallocate) ProtoMessage()    {}
+
+func (m *Deallocate) GetStmtId() uint32 {
+	if m != nil && m.StmtId != nil {
+		return *m.StmtId
+	}
+	return 0
+}
+
+func init() {
+	proto.RegisterType((*Prepare)(nil), "Mysqlx.Prepare.Prepare")
+	proto.RegisterType((*Prepare_OneOfMessage)(nil), "Mysqlx.Prepare.Prepare.OneOfMessage")
+	proto.RegisterType((*Execute)(nil), "Mysqlx.Prepare.Execute")
+	proto.RegisterType((*Deallocate)(nil), "Mysqlx.Prepare.Deallocate")
+	proto.RegisterEnum("Mysqlx.Prepare.Prepare_OneOfMessage_Type", Prepare_OneOfMessage_Type_name, Prepare_OneOfMessage_Type_value)
+}
@@ -12,6 +12,7 @@ It has these top-level messages:
 	FetchDoneMoreOutParams
 	FetchDoneMoreResultsets
 	FetchDone
+	FetchSuspended
 	ColumnMetaData
 	Row
 */
@@ -121,6 +122,15 @@ func (m *FetchDone) String() string            { return proto.CompactTextString(
 func (*FetchDone) ProtoMessage()               {}
 func (*FetchDone) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
 
+// cursor is opened; still, the execution of PrepFetch or PrepExecute ended
+type FetchSuspended struct {
+	XXX_unrecognized []byte `json:"-"`
+}
+
+func (m *FetchSuspended) Reset()         { *m = FetchSuspended{} }
+func (m *FetchSuspended) String() string { return proto.CompactTextString(m) }
+func (*FetchSuspended) ProtoMessage()    {}
+
 // meta data of a Column
 //
 // .. note:: the encoding used for the different ``bytes`` fields in the meta data is externally
@@ -576,6 +586,7 @@ func init() {
 	proto.RegisterType((*FetchDoneMoreOutParams)(nil), "Mysqlx.Resultset.FetchDoneMoreOutParams")
 	proto.RegisterType((*FetchDoneMoreResultsets)(nil), "Mysqlx.Resultset.FetchDoneMoreResultsets")
 	proto.RegisterType((*FetchDone)(nil), "Mysqlx.Resultset.FetchDone")
+	proto.RegisterType((*FetchSuspended)(nil), "Mysqlx.Resultset.FetchSuspended")
 	proto.RegisterType((*ColumnMetaData)(nil), "Mysqlx.Resultset.ColumnMetaData")
 	proto.RegisterType((*Row)(nil), "Mysqlx.Resultset.Row")
 	proto.RegisterEnum("Mysqlx.Resultset.ColumnMetaData_FieldType", ColumnMetaData_FieldType_name, ColumnMetaData_FieldType_value)
@@ -0,0 +1,288 @@
+// Go driver for MySQL X Protocol
+//
+// Copyright 2016 Simon J Mudd.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+// This file holds the admin commands of the X plugin. They are sent as
+// a StmtExecute in the mysqlx namespace rather than sql, with the
+// command name as the statement and its arguments as the fields of a
+// single Mysqlx.Datatypes.Object arg:
+//
+//	namespace: "mysqlx"
+//	stmt:      "create_collection"
+//	args:      [{schema: "test", name: "people"}]
+//
+// Commands which return rows, list_objects and list_clients, send a
+// normal result set. The others send only StmtExecuteOk. Servers before
+// 8.0 name the namespace xplugin and take positional args so they are
+// not supported.
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"io"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx"
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Datatypes"
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Sql"
+)
+
+// adminNamespace is the StmtExecute namespace of the admin commands
+const adminNamespace = "mysqlx"
+
+// SchemaObject is a table, view or collection returned by ListObjects
+type SchemaObject struct {
+	Name string
+	Type string // COLLECTION, TABLE, VIEW or COLLECTION_VIEW
+}
+
+// ClientInfo is a client of the X plugin returned by ListClients
+type ClientInfo struct {
+	ID         uint64 // as given to KillClient
+	User       string
+	Host       string
+	SQLSession uint64 // the connection id, as in SHOW PROCESSLIST
+}
+
+// ListObjects returns the tables, views and collections of the schema,
+// the one connected to if it is empty, whose names match the LIKE
+// pattern. An empty pattern matches them all.
+func (s *Session) ListObjects(ctx context.Context, schema, pattern string) ([]SchemaObject, error) {
+	mc, err := s.conn()
+	if err != nil {
+		return nil, err
+	}
+	fields := []*Mysqlx_Datatypes.Object_ObjectField{adminField("schema", mc.adminSchema(schema))}
+	if pattern != "" {
+		fields = append(fields, adminField("pattern", pattern))
+	}
+	rows, err := mc.adminQuery(ctx, "list_objects", 2, fields...)
+	if err != nil {
+		return nil, err
+	}
+	objects := make([]SchemaObject, len(rows))
+	for i, row := range rows {
+		objects[i] = SchemaObject{Name: valueString(row[0]), Type: valueString(row[1])}
+	}
+	return objects, nil
+}
+
+// CreateCollection creates a collection in the schema, the one
+// connected to if it is empty. It fails if the collection exists.
+func (s *Session) CreateCollection(ctx context.Context, schema, name string) error {
+	return s.collectionCommand(ctx, "create_collection", schema, name)
+}
+
+// EnsureCollection creates a collection in the schema, the one
+// connected to if it is empty, unless it exists already
+func (s *Session) EnsureCollection(ctx context.Context, schema, name string) error {
+	return s.collectionCommand(ctx, "ensure_collection", schema, name)
+}
+
+// DropCollection drops a collection from the schema, the one connected
+// to if it is empty
+func (s *Session) DropCollection(ctx context.Context, schema, name string) error {
+	return s.collectionCommand(ctx, "drop_collection", schema, name)
+}
+
+// collectionCommand runs a command taking a schema and collection name
+func (s *Session) collectionCommand(ctx context.Context, command, schema, name string) error {
+	mc, err := s.conn()
+	if err != nil {
+		return err
+	}
+	return mc.adminExec(ctx, command, adminField("schema", mc.adminSchema(schema)), adminField("name", name))
+}
+
+// ListClients returns the clients connected to the X plugin which the
+// user can see: all of them with the PROCESS privilege, otherwise only
+// those of the same user.
+func (s *Session) ListClients(ctx context.Context) ([]ClientInfo, error) {
+	mc, err := s.conn()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := mc.adminQuery(ctx, "list_clients", 4)
+	if err != nil {
+		return nil, err
+	}
+	clients := make([]ClientInfo, len(rows))
+	for i, row := range rows {
+		clients[i] = ClientInfo{
+			ID:         valueUint(row[0]),
+			User:       valueString(row[1]),
+			Host:       valueString(row[2]),
+			SQLSession: valueUint(row[3]),
+		}
+	}
+	return clients, nil
+}
+
+// KillClient disconnects the client with the id given by ListClients
+func (s *Session) KillClient(ctx context.Context, id uint64) error {
+	mc, err := s.conn()
+	if err != nil {
+		return err
+	}
+	return mc.adminExec(ctx, "kill_client", adminField("id", id))
+}
+
+// Ping checks the session still works with a round trip to the server
+func (s *Session) Ping(ctx context.Context) error {
+	mc, err := s.conn()
+	if err != nil {
+		return err
+	}
+	return mc.adminExec(ctx, "ping")
+}
+
+// EnableNotices asks the server to send the named notices, such as
+// warnings, account_expired, generated_insert_id, rows_affected and
+// produced_message, or from 8.0.17 group_replication/membership/quorum_loss,
+// group_replication/membership/view and group_replication/status.
+// Those of the session state such as rows_affected can't be disabled.
+func (s *Session) EnableNotices(ctx context.Context, notices ...string) error {
+	return s.noticesCommand(ctx, "enable_notices", notices)
+}
+
+// DisableNotices asks the server to stop sending the named notices
+func (s *Session) DisableNotices(ctx context.Context, notices ...string) error {
+	return s.noticesCommand(ctx, "disable_notices", notices)
+}
+
+// noticesCommand runs a command taking a list of notices
+func (s *Session) noticesCommand(ctx context.Context, command string, notices []string) error {
+	mc, err := s.conn()
+	if err != nil {
+		return err
+	}
+	return mc.adminExec(ctx, command, adminField("notice", notices))
+}
+
+// adminSchema returns the schema an admin command acts on
+func (mc *mysqlXConn) adminSchema(schema string) string {
+	if schema == "" {
+		return mc.cfg.dbname
+	}
+	return schema
+}
+
+// adminCommand sends an admin command and returns the rows to read its
+// result from. The hooks see the command as an Admin statement.
+func (mc *mysqlXConn) adminCommand(ctx context.Context, command string, fields ...*Mysqlx_Datatypes.Object_ObjectField) (*mysqlXRows, error) {
+	if mc.netConn == nil {
+		mc.log.print(ErrInvalidConn)
+		return nil, driver.ErrBadConn
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	stmtExecute := &Mysqlx_Sql.StmtExecute{
+		Namespace: proto.String(adminNamespace),
+		Stmt:      []byte(command),
+	}
+	if len(fields) > 0 {
+		stmtExecute.Args = []*Mysqlx_Datatypes.Any{{
+			Type: Mysqlx_Datatypes.Any_OBJECT.Enum(),
+			Obj:  &Mysqlx_Datatypes.Object{Fld: fields},
+		}}
+	}
+	if mc.log.on(LogDebug) {
+		mc.log.printf(LogDebug, "mysqlXConn.adminCommand(%s)", stmtExecute.String())
+	}
+
+	mc.notices = nil
+	qh := mc.beforeQuery(ctx, "Admin", Mysqlx.ClientMessages_SQL_STMT_EXECUTE, command, nil)
+	if err := mc.writeStmtExecute(stmtExecute); err != nil {
+		qh.done(mc, err)
+		return nil, fmt.Errorf("mysqlXConn.adminCommand(%s) failed: %v", command, err)
+	}
+	return &mysqlXRows{
+		mc:    mc,
+		state: queryStateWaitingColumnMetaData,
+		hook:  qh,
+	}, nil
+}
+
+// adminExec runs an admin command which returns no rows
+func (mc *mysqlXConn) adminExec(ctx context.Context, command string, fields ...*Mysqlx_Datatypes.Object_ObjectField) error {
+	rows, err := mc.adminCommand(ctx, command, fields...)
+	if err != nil {
+		return err
+	}
+	return rows.Close()
+}
+
+// adminQuery runs an admin command and returns a copy of the rows it
+// returns, checking they have at least the given number of columns
+func (mc *mysqlXConn) adminQuery(ctx context.Context, command string, columns int, fields ...*Mysqlx_Datatypes.Object_ObjectField) ([][]driver.Value, error) {
+	rows, err := mc.adminCommand(ctx, command, fields...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if n := len(rows.Columns()); n < columns {
+		// an error sent instead of the result set is returned by Close
+		if err := rows.Close(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%s: got %d columns, expected %d", command, n, columns)
+	}
+	var result [][]driver.Value
+	for {
+		dest := make([]driver.Value, len(rows.Columns()))
+		err := rows.Next(dest)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		// the data refers to the read buffer so copy it before reading more
+		for i := range dest {
+			if b, ok := dest[i].([]byte); ok {
+				dest[i] = string(b)
+			}
+		}
+		result = append(result, dest)
+	}
+	return result, rows.Close()
+}
+
+// adminField returns a field of the Object arg of an admin command
+// holding a string, uint64 or list of strings
+func adminField(key string, value interface{}) *Mysqlx_Datatypes.Object_ObjectField {
+	any := &Mysqlx_Datatypes.Any{Type: Mysqlx_Datatypes.Any_SCALAR.Enum(), Scalar: scalar(value)}
+	if values, ok := value.([]string); ok {
+		array := make([]*Mysqlx_Datatypes.Any, len(values))
+		for i, v := range values {
+			array[i] = newAnyScalar(Mysqlx_Datatypes.Any_SCALAR, scalar(v))
+		}
+		any = &Mysqlx_Datatypes.Any{
+			Type:  Mysqlx_Datatypes.Any_ARRAY.Enum(),
+			Array: &Mysqlx_Datatypes.Array{Value: array},
+		}
+	}
+	return &Mysqlx_Datatypes.Object_ObjectField{Key: proto.String(key), Value: any}
+}
+
+// valueUint returns an integer value as a uint64
+func valueUint(v driver.Value) uint64 {
+	switch v := v.(type) {
+	case uint64:
+		return v
+	case int64:
+		return uint64(v)
+	}
+	return 0
+}
@@ -0,0 +1,117 @@
+// Go driver for MySQL X Protocol
+//
+// Copyright 2016 Simon J Mudd.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Datatypes"
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Sql"
+	"github.com/sjmudd/go-mysqlx-driver/mysqlxtest"
+)
+
+// the admin commands are sent in the mysqlx namespace with an Object
+// arg and their result sets are decoded
+func TestFakeServerAdmin(t *testing.T) {
+	s := mysqlxtest.NewServer(fakeUser, fakePasswd)
+	defer s.Close()
+	s.Handle("mysqlx.list_objects", &mysqlxtest.Result{
+		Columns: []mysqlxtest.Column{{Name: "name"}, {Name: "type"}},
+		Rows:    [][]interface{}{{"people", "COLLECTION"}, {"orders", "TABLE"}},
+	})
+	s.Handle("mysqlx.list_clients", &mysqlxtest.Result{
+		Columns: []mysqlxtest.Column{{Name: "client_id"}, {Name: "user"}, {Name: "host"}, {Name: "sql_session"}},
+		Rows:    [][]interface{}{{uint64(3), "app", "localhost", uint64(17)}},
+	})
+	s.Handle("mysqlx.drop_collection", &mysqlxtest.Result{Err: &mysqlxtest.Error{Code: 1051, SQLState: "42S02", Msg: "Unknown table 'test.people'"}})
+	var commands []*Mysqlx_Sql.StmtExecute
+	s.HandleFunc(func(stmt *Mysqlx_Sql.StmtExecute) *mysqlxtest.Result {
+		commands = append(commands, stmt)
+		return &mysqlxtest.Result{}
+	})
+
+	client, _ := fakeClient(t, s, ClientOptions{})
+	defer client.Close()
+	ctx := context.Background()
+	session, err := client.Session(ctx)
+	if err != nil {
+		t.Fatalf("TestFakeServerAdmin: Session failed: %v", err)
+	}
+	defer session.Close()
+
+	objects, err := session.ListObjects(ctx, "test", "")
+	if err != nil {
+		t.Fatalf("TestFakeServerAdmin: ListObjects failed: %v", err)
+	}
+	if expected := []SchemaObject{{"people", "COLLECTION"}, {"orders", "TABLE"}}; !reflect.DeepEqual(objects, expected) {
+		t.Errorf("TestFakeServerAdmin: ListObjects returned %v, expected %v", objects, expected)
+	}
+	clients, err := session.ListClients(ctx)
+	if err != nil {
+		t.Fatalf("TestFakeServerAdmin: ListClients failed: %v", err)
+	}
+	if expected := []ClientInfo{{ID: 3, User: "app", Host: "localhost", SQLSession: 17}}; !reflect.DeepEqual(clients, expected) {
+		t.Errorf("TestFakeServerAdmin: ListClients returned %v, expected %v", clients, expected)
+	}
+	if err := session.DropCollection(ctx, "test", "people"); err == nil {
+		t.Error("TestFakeServerAdmin: DropCollection: expected an error")
+	}
+
+	for _, f := range []func() error{
+		func() error { return session.CreateCollection(ctx, "test", "people") },
+		func() error { return session.EnsureCollection(ctx, "test", "people") },
+		func() error { return session.KillClient(ctx, 3) },
+		func() error { return session.EnableNotices(ctx, "warnings", "produced_message") },
+		func() error { return session.Ping(ctx) },
+	} {
+		if err := f(); err != nil {
+			t.Fatalf("TestFakeServerAdmin: command failed: %v", err)
+		}
+	}
+
+	object := func(fields ...*Mysqlx_Datatypes.Object_ObjectField) []*Mysqlx_Datatypes.Any {
+		return []*Mysqlx_Datatypes.Any{{Type: Mysqlx_Datatypes.Any_OBJECT.Enum(), Obj: &Mysqlx_Datatypes.Object{Fld: fields}}}
+	}
+	expected := []struct {
+		command string
+		args    []*Mysqlx_Datatypes.Any
+	}{
+		{"create_collection", object(adminField("schema", "test"), adminField("name", "people"))},
+		{"ensure_collection", object(adminField("schema", "test"), adminField("name", "people"))},
+		{"kill_client", object(adminField("id", uint64(3)))},
+		{"enable_notices", object(adminField("notice", []string{"warnings", "produced_message"}))},
+		{"ping", nil},
+	}
+	if len(commands) != len(expected) {
+		t.Fatalf("TestFakeServerAdmin: the server received %d commands, expected %d", len(commands), len(expected))
+	}
+	for i, stmt := range commands {
+		got := &Mysqlx_Sql.StmtExecute{Namespace: stmt.Namespace, Stmt: stmt.Stmt, Args: stmt.Args}
+		want := &Mysqlx_Sql.StmtExecute{Namespace: proto.String(adminNamespace), Stmt: []byte(expected[i].command), Args: expected[i].args}
+		if !proto.Equal(got, want) {
+			t.Errorf("TestFakeServerAdmin: sent %s, expected %s", proto.CompactTextString(got), proto.CompactTextString(want))
+		}
+	}
+	if array := commands[3].Args[0].GetObj().GetFld()[0].GetValue(); array.GetType() != Mysqlx_Datatypes.Any_ARRAY || len(array.GetArray().GetValue()) != 2 {
+		t.Errorf("TestFakeServerAdmin: the notices were not sent as an array: %s", proto.CompactTextString(array))
+	}
+
+	// a command which sends no result set can't be listed
+	s.Handle("mysqlx.list_objects", &mysqlxtest.Result{})
+	if _, err := session.ListObjects(ctx, "test", "p%"); err == nil {
+		t.Error("TestFakeServerAdmin: ListObjects without a result set: expected an error")
+	}
+	if err := session.Ping(ctx); err != nil {
+		t.Errorf("TestFakeServerAdmin: Ping after an error failed: %v", err)
+	}
+}
@@ -0,0 +1,164 @@
+// Go driver for MySQL X Protocol
+//
+// Copyright 2016 Simon J Mudd.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Datatypes"
+)
+
+// encodeArgs returns the args of a statement as sent in StmtExecute.
+// The server replaces each ? placeholder with the next arg.
+func (mc *mysqlXConn) encodeArgs(args []driver.Value) ([]*Mysqlx_Datatypes.Any, error) {
+	if len(args) == 0 {
+		return nil, nil
+	}
+	encoded := make([]*Mysqlx_Datatypes.Any, len(args))
+	for i, arg := range args {
+		s, err := mc.argScalar(arg)
+		if err != nil {
+			return nil, fmt.Errorf("arg %d: %v", i+1, err)
+		}
+		encoded[i] = newAnyScalar(Mysqlx_Datatypes.Any_SCALAR, s)
+	}
+	return encoded, nil
+}
+
+// valuerType is the type of driver.Valuer
+var valuerType = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+
+// CheckNamedValue implements driver.NamedValueChecker. It lets through
+// the values database/sql would reject or change which the driver can
+// send as they are: uint64 above math.MaxInt64, json.RawMessage and
+// time.Duration. Other values are converted as database/sql does.
+func (mc *mysqlXConn) CheckNamedValue(nv *driver.NamedValue) error {
+	return checkNamedValue(nv)
+}
+
+// checkNamedValue converts the value of an arg for CheckNamedValue
+func checkNamedValue(nv *driver.NamedValue) error {
+	v, err := argValue(nv.Value)
+	if err != nil {
+		return err
+	}
+	nv.Value = v
+	return nil
+}
+
+// argValue converts an arg to the value sent: one of the driver.Value
+// types, uint64, json.RawMessage or time.Duration. A driver.Valuer may
+// return any of those.
+func argValue(arg interface{}) (driver.Value, error) {
+	if valuer, ok := arg.(driver.Valuer); ok {
+		// as database/sql a nil pointer to a type with a value
+		// receiver Value method is NULL rather than a panic
+		if rv := reflect.ValueOf(arg); rv.Kind() == reflect.Ptr && rv.IsNil() && rv.Type().Elem().Implements(valuerType) {
+			return nil, nil
+		}
+		v, err := valuer.Value()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := v.(driver.Valuer); ok {
+			return nil, fmt.Errorf("the Value method of %T returned a driver.Valuer, %T", arg, v)
+		}
+		return argValue(v)
+	}
+
+	switch v := arg.(type) {
+	case json.RawMessage:
+		if v == nil {
+			return nil, nil
+		}
+		return v, nil
+	case time.Duration, uint64:
+		return v, nil
+	}
+	switch rv := reflect.ValueOf(arg); rv.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return rv.Uint(), nil
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return nil, nil
+		}
+		return argValue(rv.Elem().Interface())
+	}
+	return driver.DefaultParameterConverter.ConvertValue(arg)
+}
+
+// argScalar returns a single arg as a scalar. Times are sent as
+// strings in the location of the connection, durations as strings in
+// the format of a TIME and JSON as octets marked as JSON.
+func (mc *mysqlXConn) argScalar(arg driver.Value) (*Mysqlx_Datatypes.Scalar, error) {
+	switch v := arg.(type) {
+	case nil, int64, uint64, float64, bool, []byte:
+		return scalar(v), nil
+	case string:
+		return mc.stringScalar(v)
+	case time.Time:
+		if v.IsZero() {
+			return mc.stringScalar("0000-00-00")
+		}
+		return mc.stringScalar(v.In(mc.cfg.loc).Format(timeFormat))
+	case time.Duration:
+		return mc.stringScalar(formatDuration(v))
+	case json.RawMessage:
+		return &Mysqlx_Datatypes.Scalar{
+			Type:    Mysqlx_Datatypes.Scalar_V_OCTETS.Enum(),
+			VOctets: &Mysqlx_Datatypes.Scalar_Octets{Value: v, ContentType: proto.Uint32(contentTypeJSON)},
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported type %T", arg)
+}
+
+// formatDuration returns a duration as a TIME, [-]h:mm:ss[.ffffff].
+// Nanoseconds are dropped.
+func formatDuration(d time.Duration) string {
+	sign := ""
+	u := uint64(d)
+	if d < 0 {
+		sign = "-"
+		u = uint64(-(d + 1)) + 1
+	}
+	hours := u / uint64(time.Hour)
+	u %= uint64(time.Hour)
+	minutes := u / uint64(time.Minute)
+	u %= uint64(time.Minute)
+	seconds := u / uint64(time.Second)
+	micros := u % uint64(time.Second) / uint64(time.Microsecond)
+	if micros == 0 {
+		return fmt.Sprintf("%s%02d:%02d:%02d", sign, hours, minutes, seconds)
+	}
+	return fmt.Sprintf("%s%02d:%02d:%02d.%06d", sign, hours, minutes, seconds, micros)
+}
+
+// stringScalar returns a string scalar, converted to the character set
+// of the connection collation and sent with it if convertCharset is set
+func (mc *mysqlXConn) stringScalar(s string) (*Mysqlx_Datatypes.Scalar, error) {
+	if !mc.cfg.convertCharset {
+		return scalar(s), nil
+	}
+	collation := uint64(mc.cfg.collation)
+	value, converted, err := encodeText(collation, s)
+	if err != nil {
+		return nil, err
+	}
+	str := &Mysqlx_Datatypes.Scalar_String{Value: value}
+	if converted {
+		str.Collation = proto.Uint64(collation)
+	}
+	return &Mysqlx_Datatypes.Scalar{Type: Mysqlx_Datatypes.Scalar_V_STRING.Enum(), VString: str}, nil
+}
@@ -0,0 +1,190 @@
+// Go driver for MySQL X Protocol
+//
+// Copyright 2016 Simon J Mudd.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Crud"
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Datatypes"
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Sql"
+	"github.com/sjmudd/go-mysqlx-driver/mysqlxtest"
+)
+
+// celsius is a driver.Valuer with a value receiver
+type celsius float64
+
+func (c celsius) Value() (driver.Value, error) {
+	return float64(c), nil
+}
+
+// bigID is a driver.Valuer returning a uint64 database/sql would reject
+type bigID struct{ id uint64 }
+
+func (b bigID) Value() (driver.Value, error) {
+	return b.id, nil
+}
+
+func TestArgValue(t *testing.T) {
+	var nilCelsius *celsius
+	n := int32(7)
+	tests := []struct {
+		arg      interface{}
+		expected driver.Value
+	}{
+		{uint64(18446744073709551615), uint64(18446744073709551615)},
+		{uint8(200), uint64(200)},
+		{json.RawMessage(`{"a": 1}`), json.RawMessage(`{"a": 1}`)},
+		{json.RawMessage(nil), nil},
+		{90 * time.Minute, 90 * time.Minute},
+		{celsius(21.5), 21.5},
+		{nilCelsius, nil},
+		{bigID{1 << 63}, uint64(1 << 63)},
+		{&n, int64(7)},
+		{"text", "text"},
+		{int(-3), int64(-3)},
+	}
+	for _, test := range tests {
+		got, err := argValue(test.arg)
+		if err != nil {
+			t.Errorf("TestArgValue: %T %v: error: %v", test.arg, test.arg, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("TestArgValue: %T %v: got %T %v, expected %T %v", test.arg, test.arg, got, got, test.expected, test.expected)
+		}
+	}
+	if _, err := argValue(struct{}{}); err == nil {
+		t.Error("TestArgValue: struct{}{}: expected an error")
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		d        time.Duration
+		expected string
+	}{
+		{0, "00:00:00"},
+		{90*time.Minute + 5*time.Second, "01:30:05"},
+		{-(838*time.Hour + 59*time.Minute + 59*time.Second), "-838:59:59"},
+		{1500 * time.Millisecond, "00:00:01.500000"},
+		{time.Microsecond + 999, "00:00:00.000001"},
+	}
+	for _, test := range tests {
+		if got := formatDuration(test.d); got != test.expected {
+			t.Errorf("TestFormatDuration: %v: got %q, expected %q", test.d, got, test.expected)
+		}
+	}
+}
+
+// the values database/sql would reject or change reach the server as
+// the scalars they stand for
+func TestFakeServerArgs(t *testing.T) {
+	s := mysqlxtest.NewServer(fakeUser, fakePasswd)
+	defer s.Close()
+	var got *Mysqlx_Sql.StmtExecute
+	s.HandleFunc(func(stmt *Mysqlx_Sql.StmtExecute) *mysqlxtest.Result {
+		got = stmt
+		return &mysqlxtest.Result{RowsAffected: 1}
+	})
+
+	db := fakeDB(t, s, "MYSQL41")
+	defer db.Close()
+	_, err := db.Exec("INSERT INTO t VALUES (?, ?, ?, ?)",
+		uint64(18446744073709551615), json.RawMessage(`{"a": 1}`), 90*time.Minute, bigID{1 << 63})
+	if err != nil {
+		t.Fatalf("TestFakeServerArgs: Exec failed: %v", err)
+	}
+	if got == nil {
+		t.Fatal("TestFakeServerArgs: the server received no statement")
+	}
+
+	expected := []*Mysqlx_Datatypes.Scalar{
+		scalar(uint64(18446744073709551615)),
+		{
+			Type:    Mysqlx_Datatypes.Scalar_V_OCTETS.Enum(),
+			VOctets: &Mysqlx_Datatypes.Scalar_Octets{Value: []byte(`{"a": 1}`), ContentType: proto.Uint32(contentTypeJSON)},
+		},
+		scalar("01:30:00"),
+		scalar(uint64(1 << 63)),
+	}
+	if len(got.Args) != len(expected) {
+		t.Fatalf("TestFakeServerArgs: got %d args, expected %d", len(got.Args), len(expected))
+	}
+	for i, arg := range got.Args {
+		if !proto.Equal(arg.GetScalar(), expected[i]) {
+			t.Errorf("TestFakeServerArgs: arg %d: got %s, expected %s", i+1, proto.CompactTextString(arg), proto.CompactTextString(expected[i]))
+		}
+	}
+
+	// named args are only taken by Find
+	if _, err := db.Exec("SELECT ?", sql.Named("a", 1)); err != errNamedArgs {
+		t.Errorf("TestFakeServerArgs: Exec with a named arg: got error %v, expected %v", err, errNamedArgs)
+	}
+}
+
+// the named args of Find are sent in the order of the placeholders
+func TestFakeServerFindArgs(t *testing.T) {
+	s := mysqlxtest.NewServer(fakeUser, fakePasswd)
+	defer s.Close()
+	var got *Mysqlx_Crud.Find
+	s.HandleFind(func(find *Mysqlx_Crud.Find) *mysqlxtest.Result {
+		got = find
+		return &mysqlxtest.Result{Columns: []mysqlxtest.Column{{Name: "doc"}}}
+	})
+
+	db := fakeDB(t, s, "MYSQL41")
+	defer db.Close()
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("TestFakeServerFindArgs: Conn failed: %v", err)
+	}
+	defer conn.Close()
+
+	find := func(criteria string, args ...interface{}) error {
+		return conn.Raw(func(driverConn interface{}) error {
+			rows, err := driverConn.(XConn).Find(context.Background(), "s", "c", criteria, 0, args...)
+			if err != nil {
+				return err
+			}
+			return rows.Close()
+		})
+	}
+	if err := find("age > :min AND age < :max", sql.Named("max", uint64(1<<63)), sql.Named("min", 18)); err != nil {
+		t.Fatalf("TestFakeServerFindArgs: Find failed: %v", err)
+	}
+	if got == nil {
+		t.Fatal("TestFakeServerFindArgs: the server received no Find")
+	}
+	expected := []*Mysqlx_Datatypes.Scalar{scalar(int64(18)), scalar(uint64(1 << 63))}
+	if len(got.Args) != len(expected) || !proto.Equal(got.Args[0], expected[0]) || !proto.Equal(got.Args[1], expected[1]) {
+		t.Errorf("TestFakeServerFindArgs: got args %v, expected %v", got.Args, expected)
+	}
+
+	for _, test := range []struct {
+		criteria string
+		args     []interface{}
+	}{
+		{"age > :min", nil},
+		{"age > :min", []interface{}{18}},
+		{"age > :min", []interface{}{sql.Named("min", 18), sql.Named("max", 65)}},
+	} {
+		if err := find(test.criteria, test.args...); err == nil {
+			t.Errorf("TestFakeServerFindArgs: %q %v: expected an error", test.criteria, test.args)
+		}
+	}
+}
@@ -41,19 +41,24 @@ func (b *buffer) fill(need int) error {
 		copy(b.buf[0:n], b.buf[b.idx:])
 	}
 
-	// grow buffer if necessary
-	// TODO: let the buffer shrink again at some point
-	//       Maybe keep the org buf slice and swap back?
-	if need > len(b.buf) {
-		// Round up to the next multiple of the default size
-		newBuf := make([]byte, ((need/defaultBufSize)+1)*defaultBufSize)
-		copy(newBuf, b.buf)
-		b.buf = newBuf
-	}
-
 	b.idx = 0
 
 	for {
+		// grow buffer if necessary, doubling it as the data arrives
+		// rather than trusting the length we were sent
+		// TODO: let the buffer shrink again at some point
+		//       Maybe keep the org buf slice and swap back?
+		if n == len(b.buf) && n < need {
+			size := 2 * len(b.buf)
+			if size > need {
+				// Round up to the next multiple of the default size
+				size = ((need / defaultBufSize) + 1) * defaultBufSize
+			}
+			newBuf := make([]byte, size)
+			copy(newBuf, b.buf[:n])
+			b.buf = newBuf
+		}
+
 		nn, err := b.rd.Read(b.buf[n:])
 		n += nn
 
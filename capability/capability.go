@@ -2,8 +2,6 @@ package capability
 
 import (
 	"fmt"
-
-//	"bitbucket.org/sjmudd/go-mysqlx-driver/debug"
 )
 
 // Capabilities are indexed by name and can be one of 7 protobuf types (including nesting...).
@@ -93,7 +91,6 @@ func (sc ServerCapabilities) Values(name string) Values {
 
 // AddScalarString adds the given string value to the named capability
 func (sc ServerCapabilities) AddScalarString(name string, value string) error {
-	// debug.Msg("ServerCapabilities.AddScalarString(%q,%q)", name, value)
 	if sc == nil {
 		return fmt.Errorf("ServerCapabilities.AddScalarString() on nil value")
 	}
@@ -106,7 +103,6 @@ func (sc ServerCapabilities) AddScalarString(name string, value string) error {
 
 // AddScalarBool adds the given boolean value to the named capability
 func (sc ServerCapabilities) AddScalarBool(name string, value bool) error {
-	// debug.Msg("ServerCapabilities.AddScalar(%q,%+v)", name, value)
 	if sc == nil {
 		return fmt.Errorf("ServerCapabilities.AddScalarBool() on nil value")
 	}
@@ -119,7 +115,6 @@ func (sc ServerCapabilities) AddScalarBool(name string, value bool) error {
 
 // AddArrayString adds the given array of strings to the named capability
 func (sc ServerCapabilities) AddArrayString(name string, values []string) error {
-	// debug.Msg("ServerCapabilities.AddArrayString(%q,%+v)", name, values)
 	if sc == nil {
 		return fmt.Errorf("ServerCapabilities.AddArrayString() on nil value")
 	}
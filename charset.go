@@ -0,0 +1,171 @@
+// Go driver for MySQL X Protocol
+//
+// Copyright 2016 Simon J Mudd.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+// This file converts text between UTF-8 and the character set of a
+// collation. The server sends text in the character set given by the
+// collation in the column metadata and takes string args in the one
+// given by the collation sent with them. With convertCharset=true text
+// columns are decoded to UTF-8 and string args are encoded in the
+// character set of the collation DSN parameter and sent with it.
+// Character sets which need no conversion are left as they are and
+// those with no codec, such as armscii8 or swe7, are an error.
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/encoding/unicode/utf32"
+
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Resultset"
+)
+
+// unconvertedCharsets are the character sets which need no conversion
+var unconvertedCharsets = map[string]bool{
+	"utf8":    true,
+	"utf8mb3": true,
+	"utf8mb4": true,
+	"ascii":   true,
+	"binary":  true,
+}
+
+// charsetEncodings maps MySQL character sets to their encoding. Those
+// in unconvertedCharsets are not included. armscii8, dec8, geostd8,
+// hp8, keybcs2, macce and swe7 have no codec so can't be converted.
+var charsetEncodings = map[string]encoding.Encoding{
+	"big5":     traditionalchinese.Big5,
+	"cp850":    charmap.CodePage850,
+	"koi8r":    charmap.KOI8R,
+	"latin1":   charmap.Windows1252, // MySQL's latin1 is cp1252
+	"latin2":   charmap.ISO8859_2,
+	"ujis":     japanese.EUCJP,
+	"sjis":     japanese.ShiftJIS,
+	"hebrew":   charmap.ISO8859_8,
+	"tis620":   charmap.Windows874,
+	"euckr":    korean.EUCKR,
+	"koi8u":    charmap.KOI8U,
+	"gb2312":   simplifiedchinese.GBK,
+	"greek":    charmap.ISO8859_7,
+	"cp1250":   charmap.Windows1250,
+	"gbk":      simplifiedchinese.GBK,
+	"latin5":   charmap.ISO8859_9,
+	"ucs2":     unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM),
+	"cp866":    charmap.CodePage866,
+	"macroman": charmap.Macintosh,
+	"cp852":    charmap.CodePage852,
+	"latin7":   charmap.ISO8859_13,
+	"cp1251":   charmap.Windows1251,
+	"utf16":    unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM),
+	"utf16le":  unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM),
+	"cp1256":   charmap.Windows1256,
+	"cp1257":   charmap.Windows1257,
+	"utf32":    utf32.UTF32(utf32.BigEndian, utf32.IgnoreBOM),
+	"cp932":    japanese.ShiftJIS,
+	"eucjpms":  japanese.EUCJP,
+	"gb18030":  simplifiedchinese.GB18030,
+}
+
+// collationCharsets maps collation ids to their character set, the
+// name of the collation up to the first underscore
+var collationCharsets = make(map[uint64]string, len(collations))
+
+func init() {
+	for name, id := range collations {
+		if i := strings.IndexByte(name, '_'); i > 0 {
+			name = name[:i]
+		}
+		collationCharsets[uint64(id)] = name
+	}
+}
+
+// collationCharset returns the character set of the collation with the
+// given id or an empty string if it is not known. Ids above 255 not in
+// collations.go are taken to be utf8mb4 as all the collations MySQL 8.0
+// added there are.
+func collationCharset(id uint64) string {
+	if charset, found := collationCharsets[id]; found {
+		return charset
+	}
+	if id > 255 {
+		return "utf8mb4"
+	}
+	return ""
+}
+
+// collationEncoding returns the encoding of the character set of the
+// collation, nil if text in it needs no conversion. It is an error if
+// the character set is not known or has no codec.
+func collationEncoding(id uint64) (encoding.Encoding, error) {
+	charset := collationCharset(id)
+	if unconvertedCharsets[charset] {
+		return nil, nil
+	}
+	if enc, found := charsetEncodings[charset]; found {
+		return enc, nil
+	}
+	if charset == "" {
+		return nil, fmt.Errorf("unknown collation %d", id)
+	}
+	return nil, fmt.Errorf("character set %s can not be converted", charset)
+}
+
+// isTextColumn returns true if the values of the column are text in
+// the character set of its collation
+func isTextColumn(column *Mysqlx_Resultset.ColumnMetaData) bool {
+	switch column.GetType() {
+	case Mysqlx_Resultset.ColumnMetaData_BYTES, Mysqlx_Resultset.ColumnMetaData_ENUM:
+		return column.GetCollation() != 0
+	}
+	return false
+}
+
+// decodeText converts the value of a text column to UTF-8
+func decodeText(column *Mysqlx_Resultset.ColumnMetaData, value driver.Value) (driver.Value, error) {
+	b, ok := value.([]byte)
+	if !ok || !isTextColumn(column) {
+		return value, nil
+	}
+	enc, err := collationEncoding(column.GetCollation())
+	if err != nil {
+		return nil, fmt.Errorf("decodeText: %v", err)
+	}
+	if enc == nil {
+		return value, nil
+	}
+	decoded, err := enc.NewDecoder().Bytes(b)
+	if err != nil {
+		return nil, fmt.Errorf("decodeText: %s: %v", collationCharset(column.GetCollation()), err)
+	}
+	return decoded, nil
+}
+
+// encodeText converts a UTF-8 string to the character set of the
+// collation. It returns false if the string was left as it is.
+func encodeText(collation uint64, s string) ([]byte, bool, error) {
+	enc, err := collationEncoding(collation)
+	if err != nil {
+		return nil, false, fmt.Errorf("encodeText: %v", err)
+	}
+	if enc == nil {
+		return []byte(s), false, nil
+	}
+	encoded, err := enc.NewEncoder().Bytes([]byte(s))
+	if err != nil {
+		return nil, false, fmt.Errorf("encodeText: %s: %v", collationCharset(collation), err)
+	}
+	return encoded, true, nil
+}
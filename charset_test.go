@@ -0,0 +1,181 @@
+// Go driver for MySQL X Protocol
+//
+// Copyright 2016 Simon J Mudd.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Resultset"
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Sql"
+	"github.com/sjmudd/go-mysqlx-driver/mysqlxtest"
+)
+
+// test the character set of collations given by id
+func TestCollationCharset(t *testing.T) {
+	tests := []struct {
+		id      uint64
+		charset string
+	}{
+		{8, "latin1"},
+		{10, "swe7"},
+		{33, "utf8"},
+		{63, "binary"},
+		{95, "cp932"},
+		{248, "gb18030"},
+		{255, "utf8mb4"},
+		{309, "utf8mb4"}, // utf8mb4_0900_bin
+		{17, ""},
+	}
+	for _, test := range tests {
+		if charset := collationCharset(test.id); charset != test.charset {
+			t.Errorf("TestCollationCharset: collation %d: got %q, expected %q", test.id, charset, test.charset)
+		}
+	}
+}
+
+// text in odd character sets is decoded to UTF-8 if asked for
+func TestCharsetDecode(t *testing.T) {
+	s := mysqlxtest.NewServer(fakeUser, fakePasswd)
+	defer s.Close()
+
+	values := []struct {
+		collation uint64
+		sent      string
+		decoded   string
+	}{
+		{8, "caf\xe9", "café"},                     // latin1_swedish_ci
+		{51, "\xcf\xf0\xe8\xe2\xe5\xf2", "Привет"}, // cp1251_general_ci
+		{28, "\xd6\xd0\xce\xc4", "中文"},             // gbk_chinese_ci
+		{13, "\x93\xfa\x96\x7b\x8c\xea", "日本語"},    // sjis_japanese_ci
+		{54, "\x65\xe5\x67\x2c", "日本"},             // utf16_general_ci
+		{56, "\xe5\x65\x2c\x67", "日本"},             // utf16le_general_ci
+		{255, "h\xc3\xa9llo", "héllo"},             // utf8mb4_0900_ai_ci
+		{63, "\xff\x00\xfe", "\xff\x00\xfe"},       // binary
+	}
+	result := &mysqlxtest.Result{Rows: [][]interface{}{{}}}
+	for i, v := range values {
+		result.Columns = append(result.Columns, mysqlxtest.Column{
+			Name:      string(rune('a' + i)),
+			Type:      Mysqlx_Resultset.ColumnMetaData_BYTES,
+			Collation: v.collation,
+		})
+		result.Rows[0] = append(result.Rows[0], []byte(v.sent))
+	}
+	s.Handle("SELECT odd", result)
+
+	for _, convert := range []bool{false, true} {
+		db := fakeDB(t, s, "MYSQL41", func(cfg *Config) {
+			cfg.Collation = collations["utf8mb4_general_ci"]
+			cfg.ConvertCharset = convert
+		})
+		dest := make([]interface{}, len(values))
+		got := make([]string, len(values))
+		for i := range dest {
+			dest[i] = &got[i]
+		}
+		if err := db.QueryRow("SELECT odd").Scan(dest...); err != nil {
+			t.Fatalf("TestCharsetDecode: convertCharset=%v: Scan failed: %v", convert, err)
+		}
+		db.Close()
+
+		for i, v := range values {
+			expected := v.sent
+			if convert {
+				expected = v.decoded
+			}
+			if got[i] != expected {
+				t.Errorf("TestCharsetDecode: convertCharset=%v: collation %d: got %q, expected %q", convert, v.collation, got[i], expected)
+			}
+		}
+	}
+}
+
+// text in character sets with no codec is an error if conversion was
+// asked for
+func TestCharsetNoCodec(t *testing.T) {
+	s := mysqlxtest.NewServer(fakeUser, fakePasswd)
+	defer s.Close()
+	s.Handle("SELECT swe7", &mysqlxtest.Result{
+		Columns: []mysqlxtest.Column{{
+			Name:      "a",
+			Type:      Mysqlx_Resultset.ColumnMetaData_BYTES,
+			Collation: 10, // swe7_swedish_ci
+		}},
+		Rows: [][]interface{}{{[]byte("abc")}},
+	})
+
+	for _, convert := range []bool{false, true} {
+		db := fakeDB(t, s, "MYSQL41", func(cfg *Config) {
+			cfg.ConvertCharset = convert
+		})
+		var got string
+		err := db.QueryRow("SELECT swe7").Scan(&got)
+		db.Close()
+		if convert && err == nil {
+			t.Errorf("TestCharsetNoCodec: convertCharset=true: Scan of swe7 column succeeded, expected an error")
+		}
+		if !convert && (err != nil || got != "abc") {
+			t.Errorf("TestCharsetNoCodec: convertCharset=false: got %q, %v, expected \"abc\"", got, err)
+		}
+	}
+
+	for _, collation := range []string{"swe7_swedish_ci", "dec8_swedish_ci"} {
+		cfg := fakeConfig(s, func(cfg *Config) {
+			cfg.Collation = collations[collation]
+			cfg.ConvertCharset = true
+		})
+		if _, err := NewConnector(cfg); err == nil {
+			t.Errorf("TestCharsetNoCodec: %s: NewConnector succeeded, expected an error", collation)
+		}
+	}
+}
+
+// string args are sent in the character set of the collation
+func TestCharsetArgs(t *testing.T) {
+	s := mysqlxtest.NewServer(fakeUser, fakePasswd)
+	defer s.Close()
+	var args []string
+	var argCollations []uint64
+	s.HandleFunc(func(stmt *Mysqlx_Sql.StmtExecute) *mysqlxtest.Result {
+		args, argCollations = nil, nil
+		for _, arg := range stmt.GetArgs() {
+			args = append(args, string(arg.GetScalar().GetVString().GetValue()))
+			argCollations = append(argCollations, arg.GetScalar().GetVString().GetCollation())
+		}
+		return &mysqlxtest.Result{}
+	})
+
+	tests := []struct {
+		collation  string
+		convert    bool
+		arg        string
+		args       []string
+		collations []uint64
+	}{
+		{"latin1_swedish_ci", false, "café", []string{"café"}, []uint64{0}},
+		{"latin1_swedish_ci", true, "café", []string{"caf\xe9"}, []uint64{8}},
+		{"cp1251_general_ci", true, "Привет", []string{"\xcf\xf0\xe8\xe2\xe5\xf2"}, []uint64{51}},
+		{"utf8mb4_general_ci", true, "café", []string{"café"}, []uint64{0}},
+	}
+	for _, test := range tests {
+		db := fakeDB(t, s, "MYSQL41", func(cfg *Config) {
+			cfg.Collation = collations[test.collation]
+			cfg.ConvertCharset = test.convert
+		})
+		if _, err := db.Exec("INSERT INTO t VALUES (?)", test.arg); err != nil {
+			t.Fatalf("TestCharsetArgs: %s: Exec failed: %v", test.collation, err)
+		}
+		db.Close()
+		if !reflect.DeepEqual(args, test.args) || !reflect.DeepEqual(argCollations, test.collations) {
+			t.Errorf("TestCharsetArgs: %s, convertCharset=%v: args %q collations %v, expected %q %v",
+				test.collation, test.convert, args, argCollations, test.args, test.collations)
+		}
+	}
+}
@@ -0,0 +1,541 @@
+// Go driver for MySQL X Protocol
+//
+// Copyright 2016 Simon J Mudd.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+// This file holds Client, a pool of X sessions for programs which use
+// the document and CRUD features of the driver without database/sql.
+//
+// A Session taken from the Client is an authenticated connection.
+// When it is closed the session is not disconnected but returned to
+// the pool with SESS_RESET, which drops its variables, temporary
+// tables, prepared statements and open transaction. The server then
+// expects the user to authenticate again, which is done straight
+// away, so the next Session saves the dial, the TLS handshake and the
+// capabilities exchange.
+//
+// At most MaxSize sessions are open at once. Once they are all in use
+// Session waits for one to be returned, for up to QueueTimeout.
+// Sessions idle for longer than IdleTimeout or open for longer than
+// MaxLifetime are closed rather than handed out again, and an idle
+// session is checked with a round trip before it is handed out.
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx"
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Session"
+	"github.com/sjmudd/go-mysqlx-driver/capability"
+)
+
+// Errors returned by a Client and its Sessions
+var (
+	ErrClientClosed  = errors.New("Client is closed")
+	ErrSessionClosed = errors.New("Session is closed")
+	ErrQueueTimeout  = errors.New("Timed out waiting for a free session")
+)
+
+// defaultMaxSize is the number of sessions a Client holds if
+// ClientOptions.MaxSize is not set
+const defaultMaxSize = 25
+
+// ClientOptions configure the pool of a Client. The zero value gives
+// up to 25 sessions which are kept until the Client is closed.
+type ClientOptions struct {
+	MaxSize      int           // sessions open at once, 25 if 0
+	IdleTimeout  time.Duration // idle sessions are closed after this, 0 for never
+	MaxLifetime  time.Duration // sessions are closed once this old, 0 for never
+	QueueTimeout time.Duration // wait for a free session at most this, 0 for as long as the context allows
+
+	// Idle sessions not used for this long are checked before they are
+	// handed out. 0 checks them every time and a negative value never.
+	HealthCheckInterval time.Duration
+}
+
+// Client holds a pool of authenticated X sessions. It is safe for
+// concurrent use.
+//
+//	client, err := mysql.NewClient(cfg, mysql.ClientOptions{MaxSize: 10, IdleTimeout: time.Minute})
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	defer client.Close()
+//
+//	session, err := client.Session(ctx)
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	defer session.Close()
+//	_, err = session.Insert(ctx, "", "people", `{"name": "Jo", "age": 18}`)
+//	rows, err := session.Find(ctx, "", "people", "age > :age", 0, sql.Named("age", 17))
+type Client struct {
+	connector *connector
+	opts      ClientOptions
+	slots     chan struct{} // holds a value for each session open or being opened
+	stop      chan struct{} // closed to stop the cleaner
+
+	mu     sync.Mutex
+	idle   []*pooledConn // most recently used last
+	closed bool
+}
+
+// pooledConn is a connection held by a Client
+type pooledConn struct {
+	mc       *mysqlXConn
+	created  time.Time
+	returned time.Time // when it was last returned to the pool
+}
+
+// NewClient returns a Client making sessions with the given Config.
+// No connection is made until a Session is asked for. Read/write
+// routing does not apply: sessions are made to the first of the hosts
+// which accepts them.
+func NewClient(cfg *Config, opts ClientOptions) (*Client, error) {
+	dc, err := NewConnector(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("NewClient: %v", err)
+	}
+	if opts.MaxSize < 0 || opts.IdleTimeout < 0 || opts.MaxLifetime < 0 || opts.QueueTimeout < 0 {
+		return nil, fmt.Errorf("NewClient: negative option in %+v", opts)
+	}
+	if opts.MaxSize == 0 {
+		opts.MaxSize = defaultMaxSize
+	}
+
+	c := &Client{
+		connector: dc.(*connector),
+		opts:      opts,
+		slots:     make(chan struct{}, opts.MaxSize),
+		stop:      make(chan struct{}),
+	}
+	if interval := c.cleanInterval(); interval > 0 {
+		go c.cleaner(interval)
+	}
+	return c, nil
+}
+
+// Session returns a session from the pool, opening one if none is idle.
+// If MaxSize sessions are in use it waits for one to be closed. The
+// context limits both the wait and the making of a new connection.
+func (c *Client) Session(ctx context.Context) (*Session, error) {
+	if err := c.acquire(ctx); err != nil {
+		return nil, err
+	}
+
+	for {
+		pc, err := c.takeIdle()
+		if err != nil {
+			<-c.slots
+			return nil, err
+		}
+		if pc == nil {
+			break
+		}
+		if c.healthy(pc) {
+			return &Session{client: c, pc: pc}, nil
+		}
+		pc.mc.cleanup()
+	}
+
+	mc, err := c.connector.connect(ctx)
+	if err != nil {
+		<-c.slots
+		return nil, err
+	}
+	return &Session{client: c, pc: &pooledConn{mc: mc, created: time.Now()}}, nil
+}
+
+// acquire takes a slot for a session, waiting for up to QueueTimeout
+// if they are all taken
+func (c *Client) acquire(ctx context.Context) error {
+	if c.isClosed() {
+		return ErrClientClosed
+	}
+	select {
+	case c.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	var timeout <-chan time.Time
+	if c.opts.QueueTimeout > 0 {
+		timer := time.NewTimer(c.opts.QueueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case c.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timeout:
+		return ErrQueueTimeout
+	case <-c.stop:
+		return ErrClientClosed
+	}
+}
+
+// takeIdle returns the most recently used idle connection, closing
+// those which have expired on the way, or nil if there is none
+func (c *Client) takeIdle() (*pooledConn, error) {
+	var expired []*pooledConn
+	defer func() {
+		for _, pc := range expired {
+			pc.mc.Close()
+		}
+	}()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil, ErrClientClosed
+	}
+	now := time.Now()
+	for len(c.idle) > 0 {
+		pc := c.idle[len(c.idle)-1]
+		c.idle = c.idle[:len(c.idle)-1]
+		if !c.expired(pc, now) {
+			return pc, nil
+		}
+		expired = append(expired, pc)
+	}
+	return nil, nil
+}
+
+// expired returns true if the connection has been idle or open too long
+func (c *Client) expired(pc *pooledConn, now time.Time) bool {
+	if c.opts.MaxLifetime > 0 && now.Sub(pc.created) >= c.opts.MaxLifetime {
+		return true
+	}
+	return c.opts.IdleTimeout > 0 && !pc.returned.IsZero() && now.Sub(pc.returned) >= c.opts.IdleTimeout
+}
+
+// healthy checks an idle connection unless it was used recently
+func (c *Client) healthy(pc *pooledConn) bool {
+	if c.opts.HealthCheckInterval < 0 || time.Since(pc.returned) < c.opts.HealthCheckInterval {
+		return true
+	}
+	if err := pc.mc.ping(); err != nil {
+		if c.connector.log.on(LogDebug) {
+			c.connector.log.printf(LogDebug, "Client.Session: dropping idle session to %s: %v", pc.mc.cfg.addr, err)
+		}
+		return false
+	}
+	return true
+}
+
+// release returns the connection of a session to the pool after
+// resetting it, or closes it if it is no longer wanted or usable
+func (c *Client) release(pc *pooledConn) error {
+	defer func() { <-c.slots }()
+
+	if pc.mc.netConn == nil {
+		return nil // already dropped after an error
+	}
+	if c.isClosed() || c.expired(pc, time.Now()) {
+		return pc.mc.Close()
+	}
+	if err := pc.mc.resetSession(); err != nil {
+		pc.mc.cleanup()
+		return fmt.Errorf("Session.Close: %v", err)
+	}
+
+	pc.returned = time.Now()
+	c.mu.Lock()
+	if !c.closed {
+		c.idle = append(c.idle, pc)
+		pc = nil
+	}
+	c.mu.Unlock()
+	if pc != nil {
+		return pc.mc.Close()
+	}
+	return nil
+}
+
+// Idle returns the number of sessions waiting in the pool
+func (c *Client) Idle() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.idle)
+}
+
+// InUse returns the number of sessions handed out and not yet closed
+func (c *Client) InUse() int {
+	return len(c.slots)
+}
+
+// Close closes the idle sessions and stops new ones being handed out.
+// Sessions in use are closed when they are returned.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	idle := c.idle
+	c.idle = nil
+	close(c.stop)
+	c.mu.Unlock()
+
+	var firstErr error
+	for _, pc := range idle {
+		if err := pc.mc.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// isClosed returns true once Close has been called
+func (c *Client) isClosed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
+
+// cleanInterval returns how often expired idle sessions are looked
+// for, or 0 if sessions never expire
+func (c *Client) cleanInterval() time.Duration {
+	interval := c.opts.IdleTimeout
+	if interval == 0 || (c.opts.MaxLifetime > 0 && c.opts.MaxLifetime < interval) {
+		interval = c.opts.MaxLifetime
+	}
+	if interval > 0 && interval < time.Second {
+		interval = time.Second
+	}
+	return interval
+}
+
+// cleaner closes expired idle sessions until the Client is closed, so
+// they don't hold a connection to the server while the pool is unused
+func (c *Client) cleaner(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case now := <-ticker.C:
+			c.mu.Lock()
+			var expired []*pooledConn
+			kept := c.idle[:0]
+			for _, pc := range c.idle {
+				if c.expired(pc, now) {
+					expired = append(expired, pc)
+				} else {
+					kept = append(kept, pc)
+				}
+			}
+			c.idle = kept
+			c.mu.Unlock()
+			for _, pc := range expired {
+				pc.mc.Close()
+			}
+		}
+	}
+}
+
+// Session is an X session taken from a Client. It is not safe for
+// concurrent use, and the rows it returns must be read or closed before
+// the next statement is run. Close returns it to the Client. Session
+// implements XConn.
+type Session struct {
+	client *Client
+	pc     *pooledConn // nil once closed
+	rows   driver.Rows // the rows last returned, closed before release
+}
+
+// conn returns the connection of the session after closing any rows
+// still open
+func (s *Session) conn() (*mysqlXConn, error) {
+	if s.pc == nil {
+		return nil, ErrSessionClosed
+	}
+	if s.rows != nil {
+		err := s.rows.Close()
+		s.rows = nil
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s.pc.mc, nil
+}
+
+// Query runs a statement which returns rows. The args are those
+// database/sql takes and those described in CheckNamedValue.
+func (s *Session) Query(ctx context.Context, query string, args ...interface{}) (driver.Rows, error) {
+	mc, err := s.conn()
+	if err != nil {
+		return nil, err
+	}
+	values, err := sessionArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := mc.queryContext(ctx, query, values)
+	if err != nil {
+		return nil, err
+	}
+	s.rows = rows
+	return rows, nil
+}
+
+// Exec runs a statement which does not return rows
+func (s *Session) Exec(ctx context.Context, query string, args ...interface{}) (driver.Result, error) {
+	mc, err := s.conn()
+	if err != nil {
+		return nil, err
+	}
+	values, err := sessionArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	return mc.execContext(ctx, query, values)
+}
+
+// Find implements XConn
+func (s *Session) Find(ctx context.Context, schema, collection, criteria string, limit uint64, args ...interface{}) (driver.Rows, error) {
+	mc, err := s.conn()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := mc.Find(ctx, schema, collection, criteria, limit, args...)
+	if err != nil {
+		return nil, err
+	}
+	s.rows = rows
+	return rows, nil
+}
+
+// Insert implements XConn
+func (s *Session) Insert(ctx context.Context, schema, collection string, docs ...interface{}) (driver.Result, error) {
+	mc, err := s.conn()
+	if err != nil {
+		return nil, err
+	}
+	return mc.Insert(ctx, schema, collection, docs...)
+}
+
+// Update implements XConn
+func (s *Session) Update(ctx context.Context, schema, collection, criteria string, set map[string]string, args ...interface{}) (driver.Result, error) {
+	mc, err := s.conn()
+	if err != nil {
+		return nil, err
+	}
+	return mc.Update(ctx, schema, collection, criteria, set, args...)
+}
+
+// Delete implements XConn
+func (s *Session) Delete(ctx context.Context, schema, collection, criteria string, limit uint64, args ...interface{}) (driver.Result, error) {
+	mc, err := s.conn()
+	if err != nil {
+		return nil, err
+	}
+	return mc.Delete(ctx, schema, collection, criteria, limit, args...)
+}
+
+// ServerCapabilities implements XConn
+func (s *Session) ServerCapabilities() capability.ServerCapabilities {
+	if s.pc == nil {
+		return capability.NewServerCapabilities()
+	}
+	return s.pc.mc.ServerCapabilities()
+}
+
+// Notices implements XConn
+func (s *Session) Notices() []Notice {
+	if s.pc == nil {
+		return nil
+	}
+	return s.pc.mc.Notices()
+}
+
+// Stats implements XConn. The counters are those of the connection,
+// which may have served earlier sessions.
+func (s *Session) Stats() Stats {
+	if s.pc == nil {
+		return Stats{}
+	}
+	return s.pc.mc.Stats()
+}
+
+// Close returns the session to its Client. Rows still open are closed
+// first. A session which can not be reset is disconnected instead.
+func (s *Session) Close() error {
+	if s.pc == nil {
+		return nil
+	}
+	var rowsErr error
+	if s.rows != nil {
+		rowsErr = s.rows.Close()
+		s.rows = nil
+	}
+	pc := s.pc
+	s.pc = nil
+	if err := s.client.release(pc); err != nil {
+		return err
+	}
+	return rowsErr
+}
+
+// sessionArgs converts the args of a Session statement as database/sql
+// would. Named args are refused as they are for Query and Exec.
+func sessionArgs(args []interface{}) ([]driver.Value, error) {
+	named := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+		if na, ok := arg.(sql.NamedArg); ok {
+			named[i].Name, named[i].Value = na.Name, na.Value
+		}
+		if err := checkNamedValue(&named[i]); err != nil {
+			return nil, fmt.Errorf("arg %d: %v", i+1, err)
+		}
+	}
+	return values(named)
+}
+
+// resetSession returns the connection to the state of a new one. The
+// server forgets the user on SESS_RESET so we authenticate again and
+// apply the DSN params as when connecting.
+func (mc *mysqlXConn) resetSession() error {
+	if mc.log.on(LogDebug) {
+		mc.log.printf(LogDebug, "mysqlXConn.resetSession: resetting session to %s", mc.cfg.addr)
+	}
+	if err := mc.writeMessage(Mysqlx.ClientMessages_SESS_RESET, new(Mysqlx_Session.Reset)); err != nil {
+		return err
+	}
+	if err := mc.readOk("mysqlXConn.resetSession"); err != nil {
+		return err
+	}
+	mc.notices = nil
+	if err := mc.authenticate(); err != nil {
+		return fmt.Errorf("Authentication failed: %v", err)
+	}
+	if err := mc.handleParams(); err != nil {
+		return err
+	}
+	if mc.cfg.interpolateParams {
+		if err := mc.readSessionVariables(); err != nil {
+			return fmt.Errorf("mysqlXConn.resetSession: %v", err)
+		}
+	}
+	return nil
+}
+
+// ping checks the connection still works with the ping admin command
+func (mc *mysqlXConn) ping() error {
+	return mc.adminExec(context.Background(), "ping")
+}
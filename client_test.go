@@ -0,0 +1,174 @@
+// Go driver for MySQL X Protocol
+//
+// Copyright 2016 Simon J Mudd.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/sjmudd/go-mysqlx-driver/mysqlxtest"
+)
+
+// fakeClient returns a Client for the fake server and a count of the
+// connections it makes
+func fakeClient(t *testing.T, s *mysqlxtest.Server, opts ClientOptions) (*Client, *int32) {
+	dials := new(int32)
+	cfg := fakeConfig(s, func(cfg *Config) {
+		cfg.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			atomic.AddInt32(dials, 1)
+			return s.DialContext(ctx, network, addr)
+		}
+	})
+	client, err := NewClient(cfg, opts)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	return client, dials
+}
+
+// sessions are reset and handed out again rather than reconnected and
+// no more than MaxSize are open at once
+func TestFakeServerClient(t *testing.T) {
+	s := mysqlxtest.NewServer(fakeUser, fakePasswd)
+	defer s.Close()
+	s.Handle("SELECT 1", &mysqlxtest.Result{Columns: []mysqlxtest.Column{{Name: "1"}}, Rows: [][]interface{}{{int64(1)}}})
+	s.HandleCrud(func(msg proto.Message) *mysqlxtest.Result { return &mysqlxtest.Result{RowsAffected: 1} })
+
+	client, dials := fakeClient(t, s, ClientOptions{MaxSize: 1, QueueTimeout: 20 * time.Millisecond})
+	ctx := context.Background()
+
+	query := func(session *Session) error {
+		rows, err := session.Query(ctx, "SELECT 1")
+		if err != nil {
+			return err
+		}
+		dest := make([]driver.Value, 1)
+		if err := rows.Next(dest); err != nil {
+			return err
+		}
+		if dest[0] != int64(1) {
+			t.Errorf("TestFakeServerClient: SELECT 1 returned %v", dest[0])
+		}
+		return rows.Close()
+	}
+
+	first, err := client.Session(ctx)
+	if err != nil {
+		t.Fatalf("TestFakeServerClient: Session failed: %v", err)
+	}
+	if err := query(first); err != nil {
+		t.Fatalf("TestFakeServerClient: Query failed: %v", err)
+	}
+	if _, err := client.Session(ctx); err != ErrQueueTimeout {
+		t.Errorf("TestFakeServerClient: Session with the pool full: got error %v, expected %v", err, ErrQueueTimeout)
+	}
+	if client.InUse() != 1 {
+		t.Errorf("TestFakeServerClient: %d sessions in use, expected 1", client.InUse())
+	}
+
+	// rows left open are closed before the reset
+	if _, err := first.Query(ctx, "SELECT 1"); err != nil {
+		t.Fatalf("TestFakeServerClient: second Query failed: %v", err)
+	}
+	if err := first.Close(); err != nil {
+		t.Fatalf("TestFakeServerClient: Close failed: %v", err)
+	}
+	if client.Idle() != 1 || client.InUse() != 0 {
+		t.Errorf("TestFakeServerClient: after Close %d idle and %d in use, expected 1 and 0", client.Idle(), client.InUse())
+	}
+	if _, err := first.Query(ctx, "SELECT 1"); err != ErrSessionClosed {
+		t.Errorf("TestFakeServerClient: Query after Close: got error %v, expected %v", err, ErrSessionClosed)
+	}
+
+	// the reset session is authenticated again and checked before use
+	second, err := client.Session(ctx)
+	if err != nil {
+		t.Fatalf("TestFakeServerClient: Session after release failed: %v", err)
+	}
+	if err := query(second); err != nil {
+		t.Fatalf("TestFakeServerClient: Query on the reset session failed: %v", err)
+	}
+	if n := atomic.LoadInt32(dials); n != 1 {
+		t.Errorf("TestFakeServerClient: %d connections made, expected 1", n)
+	}
+	pinged := false
+	for _, stmt := range s.Statements() {
+		pinged = pinged || stmt == "mysqlx.ping"
+	}
+	if !pinged {
+		t.Errorf("TestFakeServerClient: the idle session was not checked: %q", s.Statements())
+	}
+	result, err := second.Insert(ctx, "", "people", `{"name": "Jo"}`)
+	if err != nil {
+		t.Fatalf("TestFakeServerClient: Insert failed: %v", err)
+	}
+	if n, _ := result.RowsAffected(); n != 1 {
+		t.Errorf("TestFakeServerClient: Insert affected %d rows, expected 1", n)
+	}
+	second.Close()
+	if _, err := second.Delete(ctx, "", "people", "true", 0); err != ErrSessionClosed {
+		t.Errorf("TestFakeServerClient: Delete after Close: got error %v, expected %v", err, ErrSessionClosed)
+	}
+
+	if err := client.Close(); err != nil {
+		t.Errorf("TestFakeServerClient: Client.Close failed: %v", err)
+	}
+	if _, err := client.Session(ctx); err != ErrClientClosed {
+		t.Errorf("TestFakeServerClient: Session after Close: got error %v, expected %v", err, ErrClientClosed)
+	}
+}
+
+// sessions older than MaxLifetime are closed when returned and those
+// which fail the health check are replaced
+func TestFakeServerClientExpiry(t *testing.T) {
+	s := mysqlxtest.NewServer(fakeUser, fakePasswd)
+	defer s.Close()
+
+	client, dials := fakeClient(t, s, ClientOptions{MaxLifetime: time.Nanosecond})
+	defer client.Close()
+	ctx := context.Background()
+
+	session, err := client.Session(ctx)
+	if err != nil {
+		t.Fatalf("TestFakeServerClientExpiry: Session failed: %v", err)
+	}
+	time.Sleep(time.Millisecond)
+	if err := session.Close(); err != nil {
+		t.Fatalf("TestFakeServerClientExpiry: Close failed: %v", err)
+	}
+	if client.Idle() != 0 {
+		t.Errorf("TestFakeServerClientExpiry: an expired session was kept")
+	}
+
+	// the ping fails so the idle session is dropped
+	s.Handle("mysqlx.ping", &mysqlxtest.Result{Err: &mysqlxtest.Error{Code: 1053, SQLState: "08S01", Msg: "Server shutdown in progress"}})
+	client, dials = fakeClient(t, s, ClientOptions{})
+	defer client.Close()
+	if session, err = client.Session(ctx); err != nil {
+		t.Fatalf("TestFakeServerClientExpiry: Session failed: %v", err)
+	}
+	session.Close()
+	if session, err = client.Session(ctx); err != nil {
+		t.Fatalf("TestFakeServerClientExpiry: Session after a failed check failed: %v", err)
+	}
+	defer session.Close()
+	if n := atomic.LoadInt32(dials); n != 2 {
+		t.Errorf("TestFakeServerClientExpiry: %d connections made, expected 2", n)
+	}
+	if _, err := session.Exec(ctx, "SELECT ?", io.EOF); err == nil {
+		t.Error("TestFakeServerClientExpiry: Exec with an arg of the wrong type: expected an error")
+	}
+}
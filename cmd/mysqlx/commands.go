@@ -0,0 +1,152 @@
+// Go driver for MySQL X Protocol
+//
+// Copyright 2016 Simon J Mudd.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	mysql "github.com/sjmudd/go-mysqlx-driver"
+)
+
+const help = `Commands:
+  \help, \?                          show this help
+  \quit, \q, \exit                   leave
+  \format table|vertical|json|csv    set the output format
+  \capabilities                      show the capabilities of the server
+  \collection find NAME [CRITERIA]   show the documents of a collection,
+                                     NAME may be schema.collection and
+                                     CRITERIA is e.g. age > 18 AND name LIKE 'J%'
+
+Statements end with ; or \g, or \G to show the result vertically.
+`
+
+// command runs a backslash command, returning true to quit
+func (c *client) command(ctx context.Context, line string) (bool, error) {
+	fields := strings.Fields(line)
+	switch fields[0] {
+	case `\help`, `\?`, `\h`:
+		fmt.Fprint(c.info, help)
+	case `\quit`, `\q`, `\exit`:
+		return true, nil
+	case `\format`:
+		if len(fields) != 2 || !validFormat(fields[1]) {
+			return false, errors.New(`usage: \format table|vertical|json|csv`)
+		}
+		c.format = fields[1]
+	case `\capabilities`:
+		return false, c.capabilities(ctx)
+	case `\collection`:
+		// \collection find NAME [CRITERIA]
+		rest := strings.TrimSpace(strings.TrimPrefix(line, `\collection`))
+		if !strings.HasPrefix(rest, "find ") {
+			return false, errors.New(`usage: \collection find NAME [CRITERIA]`)
+		}
+		rest = strings.TrimSpace(strings.TrimPrefix(rest, "find "))
+		name, criteria := rest, ""
+		if i := strings.IndexAny(rest, " \t"); i >= 0 {
+			name, criteria = rest[:i], strings.TrimSpace(rest[i:])
+		}
+		return false, c.find(ctx, name, criteria)
+	default:
+		return false, fmt.Errorf("unknown command %s, see \\help", fields[0])
+	}
+	return false, nil
+}
+
+// xconn runs f with the driver connection
+func (c *client) xconn(ctx context.Context, f func(mysql.XConn) error) error {
+	return c.conn.Raw(func(driverConn interface{}) error {
+		xc, ok := driverConn.(mysql.XConn)
+		if !ok {
+			return fmt.Errorf("connection %T does not implement mysql.XConn", driverConn)
+		}
+		return f(xc)
+	})
+}
+
+// capabilities shows the server capabilities sorted by name
+func (c *client) capabilities(ctx context.Context) error {
+	return c.xconn(ctx, func(xc mysql.XConn) error {
+		caps := xc.ServerCapabilities()
+		names := make([]string, 0, len(caps))
+		for name := range caps {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		res := &result{columns: []string{"name", "type", "value"}}
+		for _, name := range names {
+			values := caps.Values(name)
+			for i := range values {
+				var value interface{} = values[i].String()
+				if values[i].Type() == "bool" {
+					value = values[i].Bool()
+				}
+				res.rows = append(res.rows, []interface{}{name, values[i].Type(), value})
+			}
+		}
+		return c.print(res, nil, 0, c.format)
+	})
+}
+
+// find shows the documents of a collection
+func (c *client) find(ctx context.Context, name, criteria string) error {
+	schema := ""
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		schema, name = name[:i], name[i+1:]
+	}
+	schema, name = strings.Trim(schema, "`"), strings.Trim(name, "`")
+
+	start := time.Now()
+	return c.xconn(ctx, func(xc mysql.XConn) error {
+		rows, err := xc.Find(ctx, schema, name, criteria, 0)
+		if err != nil {
+			return err
+		}
+		res, err := readDriverRows(rows)
+		if err != nil {
+			return err
+		}
+		res.json = map[int]bool{0: true}
+		return c.print(res, xc.Notices(), time.Since(start), c.format)
+	})
+}
+
+// readDriverRows reads all the rows returned by the driver
+func readDriverRows(rows driver.Rows) (*result, error) {
+	defer rows.Close()
+
+	res := &result{columns: rows.Columns()}
+	for {
+		dest := make([]driver.Value, len(res.columns))
+		err := rows.Next(dest)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		row := make([]interface{}, len(dest))
+		for i, v := range dest {
+			if b, ok := v.([]byte); ok {
+				v = append([]byte(nil), b...) // only valid until the next call
+			}
+			row[i] = v
+		}
+		res.rows = append(res.rows, row)
+	}
+	return res, rows.Close()
+}
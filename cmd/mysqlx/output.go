@@ -0,0 +1,336 @@
+// Go driver for MySQL X Protocol
+//
+// Copyright 2016 Simon J Mudd.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	mysql "github.com/sjmudd/go-mysqlx-driver"
+)
+
+// result is a result set read in full
+type result struct {
+	columns []string
+	rows    [][]interface{}
+	json    map[int]bool // columns holding JSON documents
+}
+
+func validFormat(name string) bool {
+	switch name {
+	case "table", "vertical", "json", "csv":
+		return true
+	}
+	return false
+}
+
+// query runs a statement and prints its result
+func (c *client) query(ctx context.Context, query, format string) error {
+	start := time.Now()
+	rows, err := c.conn.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	res := &result{}
+	if res.columns, err = rows.Columns(); err != nil {
+		rows.Close()
+		return err
+	}
+	for rows.Next() {
+		row := make([]interface{}, len(res.columns))
+		dest := make([]interface{}, len(row))
+		for i := range row {
+			dest[i] = &row[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			rows.Close()
+			return err
+		}
+		res.rows = append(res.rows, row)
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	var notices []mysql.Notice
+	if err := c.xconn(ctx, func(xc mysql.XConn) error {
+		notices = xc.Notices()
+		return nil
+	}); err != nil {
+		return err
+	}
+	return c.print(res, notices, time.Since(start), format)
+}
+
+// print shows the result followed by a summary and the notices. For
+// json and csv only the rows go to the output, the notices go to
+// standard error.
+func (c *client) print(res *result, notices []mysql.Notice, elapsed time.Duration, format string) error {
+	var affected, insertID uint64
+	var warnings, others []mysql.Notice
+	for _, n := range notices {
+		switch {
+		case n.Type == mysql.NoticeWarning:
+			warnings = append(warnings, n)
+		case n.Type == mysql.NoticeSessionStateChanged && n.Param == "ROWS_AFFECTED":
+			affected, _ = n.Value.(uint64)
+		case n.Type == mysql.NoticeSessionStateChanged && n.Param == "GENERATED_INSERT_ID":
+			insertID, _ = n.Value.(uint64)
+		default:
+			others = append(others, n)
+		}
+	}
+
+	var summary string
+	if len(res.columns) == 0 {
+		summary = "Query OK, " + plural(affected, "row") + " affected"
+		if insertID != 0 {
+			summary += fmt.Sprintf(", last insert id %d", insertID)
+		}
+	} else {
+		var err error
+		switch format {
+		case "vertical":
+			err = printVertical(c.out, res)
+		case "json":
+			err = printJSON(c.out, res)
+		case "csv":
+			err = printCSV(c.out, res)
+		default:
+			err = printTable(c.out, res)
+		}
+		if err != nil {
+			return err
+		}
+		if format == "json" || format == "csv" {
+			summary = ""
+		} else if len(res.rows) == 0 {
+			summary = "Empty set"
+		} else {
+			summary = plural(uint64(len(res.rows)), "row") + " in set"
+		}
+	}
+
+	if summary != "" {
+		if len(warnings) > 0 {
+			summary += ", " + plural(uint64(len(warnings)), "warning")
+		}
+		if elapsed > 0 {
+			summary += fmt.Sprintf(" (%.2f sec)", elapsed.Seconds())
+		}
+		fmt.Fprintln(c.info, summary)
+	}
+	info := c.info
+	if summary == "" {
+		info = c.errs
+	}
+	for _, n := range append(warnings, others...) {
+		fmt.Fprintln(info, n)
+	}
+	if summary != "" {
+		fmt.Fprintln(c.info)
+	}
+	return nil
+}
+
+func plural(n uint64, word string) string {
+	if n == 1 {
+		return "1 " + word
+	}
+	return fmt.Sprintf("%d %ss", n, word)
+}
+
+// text returns a value as shown in the table and vertical formats
+func text(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case []byte:
+		return string(v)
+	case json.RawMessage:
+		return string(v)
+	case time.Time:
+		return v.Format("2006-01-02 15:04:05.999999")
+	}
+	return fmt.Sprint(v)
+}
+
+// isNumber returns true for values shown right aligned
+func isNumber(v interface{}) bool {
+	switch v.(type) {
+	case int64, uint64, float64, float32:
+		return true
+	}
+	return false
+}
+
+// printTable shows the rows in a table like the mysql client
+func printTable(w io.Writer, res *result) error {
+	widths := make([]int, len(res.columns))
+	for i, name := range res.columns {
+		widths[i] = utf8.RuneCountInString(name)
+	}
+	cells := make([][]string, len(res.rows))
+	for r, row := range res.rows {
+		cells[r] = make([]string, len(row))
+		for i, v := range row {
+			cells[r][i] = strings.Replace(text(v), "\n", `\n`, -1)
+			if n := utf8.RuneCountInString(cells[r][i]); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+
+	var b bytes.Buffer
+	line := func() {
+		for _, width := range widths {
+			b.WriteString("+" + strings.Repeat("-", width+2))
+		}
+		b.WriteString("+\n")
+	}
+	cell := func(s string, width int, right bool) {
+		pad := strings.Repeat(" ", width-utf8.RuneCountInString(s))
+		if right {
+			b.WriteString("| " + pad + s + " ")
+		} else {
+			b.WriteString("| " + s + pad + " ")
+		}
+	}
+
+	line()
+	for i, name := range res.columns {
+		cell(name, widths[i], false)
+	}
+	b.WriteString("|\n")
+	line()
+	for r, row := range cells {
+		for i, s := range row {
+			cell(s, widths[i], isNumber(res.rows[r][i]))
+		}
+		b.WriteString("|\n")
+	}
+	if len(res.rows) > 0 {
+		line()
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// printVertical shows each column of a row on its own line
+func printVertical(w io.Writer, res *result) error {
+	width := 0
+	for _, name := range res.columns {
+		if n := utf8.RuneCountInString(name); n > width {
+			width = n
+		}
+	}
+
+	var b bytes.Buffer
+	for r, row := range res.rows {
+		fmt.Fprintf(&b, "*************************** %d. row ***************************\n", r+1)
+		for i, v := range row {
+			fmt.Fprintf(&b, "%*s: %s\n", width, res.columns[i], text(v))
+		}
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// printJSON shows the rows as a JSON array of objects with the keys
+// in column order
+func printJSON(w io.Writer, res *result) error {
+	var b bytes.Buffer
+	b.WriteString("[")
+	for r, row := range res.rows {
+		if r > 0 {
+			b.WriteString(",")
+		}
+		b.WriteString("\n  {")
+		for i, v := range row {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			key, _ := json.Marshal(res.columns[i])
+			b.Write(key)
+			b.WriteString(": ")
+			value, err := jsonValue(v, res.json[i])
+			if err != nil {
+				return err
+			}
+			b.Write(value)
+		}
+		b.WriteString("}")
+	}
+	if len(res.rows) > 0 {
+		b.WriteString("\n")
+	}
+	b.WriteString("]\n")
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// jsonValue returns the value in JSON. Columns holding documents are
+// included as they are.
+func jsonValue(v interface{}, document bool) ([]byte, error) {
+	switch v := v.(type) {
+	case []byte:
+		if document && json.Valid(v) {
+			var compact bytes.Buffer
+			if err := json.Compact(&compact, v); err == nil {
+				return compact.Bytes(), nil
+			}
+		}
+		return json.Marshal(string(v))
+	case json.RawMessage:
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, v); err != nil {
+			return nil, err
+		}
+		return compact.Bytes(), nil
+	case time.Time, mysql.Geometry:
+		return json.Marshal(text(v))
+	case float64:
+		return []byte(strconv.FormatFloat(v, 'g', -1, 64)), nil
+	}
+	return json.Marshal(v)
+}
+
+// printCSV shows the rows as CSV with a header line. NULL is shown as
+// an empty field.
+func printCSV(w io.Writer, res *result) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(res.columns); err != nil {
+		return err
+	}
+	record := make([]string, len(res.columns))
+	for _, row := range res.rows {
+		for i, v := range row {
+			record[i] = text(v)
+			if v == nil {
+				record[i] = ""
+			}
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,460 @@
+// Go driver for MySQL X Protocol
+//
+// Copyright 2016 Simon J Mudd.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx"
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Connection"
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Crud"
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Datatypes"
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Expect/Mysql_Expect"
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Notice"
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Resultset"
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Session"
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Sql"
+	"github.com/sjmudd/go-mysqlx-driver/wiretap"
+)
+
+const maxHexShown = 64 // bytes of undecodable payloads shown
+
+// decoder turns frames into text. It remembers the column metadata of
+// the current result set so the rows can be decoded.
+type decoder struct {
+	columns []*Mysqlx_Resultset.ColumnMetaData
+	inMeta  bool // the last server message was column metadata
+}
+
+// decode returns a readable form of the frame's payload
+func (d *decoder) decode(r wiretap.Record) string {
+	var (
+		text string
+		err  error
+	)
+	if r.Direction == wiretap.ClientToServer {
+		text, err = d.client(r)
+	} else {
+		text, err = d.server(r)
+	}
+	if err != nil {
+		return fmt.Sprintf("<%v> %s", err, shortHex(r.Payload))
+	}
+	return text
+}
+
+// client decodes a message sent by the client
+func (d *decoder) client(r wiretap.Record) (string, error) {
+	switch Mysqlx.ClientMessages_Type(r.Type) {
+	case Mysqlx.ClientMessages_CON_CAPABILITIES_GET,
+		Mysqlx.ClientMessages_CON_CLOSE,
+		Mysqlx.ClientMessages_SESS_RESET,
+		Mysqlx.ClientMessages_SESS_CLOSE:
+		return "", nil
+
+	case Mysqlx.ClientMessages_CON_CAPABILITIES_SET:
+		m := new(Mysqlx_Connection.CapabilitiesSet)
+		if err := proto.Unmarshal(r.Payload, m); err != nil {
+			return "", err
+		}
+		return capabilities(m.GetCapabilities()), nil
+
+	case Mysqlx.ClientMessages_SESS_AUTHENTICATE_START:
+		m := new(Mysqlx_Session.AuthenticateStart)
+		if err := proto.Unmarshal(r.Payload, m); err != nil {
+			return "", err
+		}
+		text := "mech_name=" + m.GetMechName()
+		if len(m.AuthData) > 0 {
+			text += " auth_data=" + redactAuth(m.AuthData)
+		}
+		if len(m.InitialResponse) > 0 {
+			text += " initial_response=" + redactAuth(m.InitialResponse)
+		}
+		return text, nil
+
+	case Mysqlx.ClientMessages_SESS_AUTHENTICATE_CONTINUE:
+		m := new(Mysqlx_Session.AuthenticateContinue)
+		if err := proto.Unmarshal(r.Payload, m); err != nil {
+			return "", err
+		}
+		return "auth_data=" + redactAuth(m.AuthData), nil
+
+	case Mysqlx.ClientMessages_SQL_STMT_EXECUTE:
+		m := new(Mysqlx_Sql.StmtExecute)
+		if err := proto.Unmarshal(r.Payload, m); err != nil {
+			return "", err
+		}
+		text := fmt.Sprintf("%q", m.Stmt)
+		if m.GetNamespace() != "sql" {
+			text = m.GetNamespace() + " " + text
+		}
+		if len(m.Args) > 0 {
+			args := make([]string, len(m.Args))
+			for i, arg := range m.Args {
+				args[i] = anyString(arg)
+			}
+			text += " args=[" + strings.Join(args, ", ") + "]"
+		}
+		if m.GetCompactMetadata() {
+			text += " compact_metadata"
+		}
+		return text, nil
+
+	case Mysqlx.ClientMessages_CRUD_FIND:
+		return compactText(r.Payload, new(Mysqlx_Crud.Find))
+	case Mysqlx.ClientMessages_CRUD_INSERT:
+		return compactText(r.Payload, new(Mysqlx_Crud.Insert))
+	case Mysqlx.ClientMessages_CRUD_UPDATE:
+		return compactText(r.Payload, new(Mysqlx_Crud.Update))
+	case Mysqlx.ClientMessages_CRUD_DELETE:
+		return compactText(r.Payload, new(Mysqlx_Crud.Delete))
+	case Mysqlx.ClientMessages_EXPECT_OPEN:
+		return compactText(r.Payload, new(Mysqlx_Expect.Open))
+	case Mysqlx.ClientMessages_EXPECT_CLOSE:
+		return compactText(r.Payload, new(Mysqlx_Expect.Close))
+	}
+	return shortHex(r.Payload), nil
+}
+
+// server decodes a message sent by the server
+func (d *decoder) server(r wiretap.Record) (string, error) {
+	msgType := Mysqlx.ServerMessages_Type(r.Type)
+	inMeta := d.inMeta
+	d.inMeta = msgType == Mysqlx.ServerMessages_RESULTSET_COLUMN_META_DATA
+
+	switch msgType {
+	case Mysqlx.ServerMessages_OK:
+		m := new(Mysqlx.Ok)
+		if err := proto.Unmarshal(r.Payload, m); err != nil {
+			return "", err
+		}
+		return m.GetMsg(), nil
+
+	case Mysqlx.ServerMessages_ERROR:
+		m := new(Mysqlx.Error)
+		if err := proto.Unmarshal(r.Payload, m); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s %04d [%s] %s", m.GetSeverity(), m.GetCode(), m.GetSqlState(), m.GetMsg()), nil
+
+	case Mysqlx.ServerMessages_CONN_CAPABILITIES:
+		m := new(Mysqlx_Connection.Capabilities)
+		if err := proto.Unmarshal(r.Payload, m); err != nil {
+			return "", err
+		}
+		return capabilities(m), nil
+
+	case Mysqlx.ServerMessages_SESS_AUTHENTICATE_CONTINUE:
+		m := new(Mysqlx_Session.AuthenticateContinue)
+		if err := proto.Unmarshal(r.Payload, m); err != nil {
+			return "", err
+		}
+		return redacted(m.AuthData), nil
+
+	case Mysqlx.ServerMessages_SESS_AUTHENTICATE_OK:
+		m := new(Mysqlx_Session.AuthenticateOk)
+		if err := proto.Unmarshal(r.Payload, m); err != nil {
+			return "", err
+		}
+		if len(m.AuthData) == 0 {
+			return "", nil
+		}
+		return "auth_data=" + redacted(m.AuthData), nil
+
+	case Mysqlx.ServerMessages_NOTICE:
+		return notice(r.Payload)
+
+	case Mysqlx.ServerMessages_RESULTSET_COLUMN_META_DATA:
+		m := new(Mysqlx_Resultset.ColumnMetaData)
+		if err := proto.Unmarshal(r.Payload, m); err != nil {
+			return "", err
+		}
+		if !inMeta {
+			d.columns = nil
+		}
+		d.columns = append(d.columns, m)
+		return columnMetaData(m), nil
+
+	case Mysqlx.ServerMessages_RESULTSET_ROW:
+		m := new(Mysqlx_Resultset.Row)
+		if err := proto.Unmarshal(r.Payload, m); err != nil {
+			return "", err
+		}
+		return d.row(m), nil
+
+	case Mysqlx.ServerMessages_RESULTSET_FETCH_DONE,
+		Mysqlx.ServerMessages_RESULTSET_FETCH_SUSPENDED,
+		Mysqlx.ServerMessages_RESULTSET_FETCH_DONE_MORE_RESULTSETS,
+		Mysqlx.ServerMessages_RESULTSET_FETCH_DONE_MORE_OUT_PARAMS,
+		Mysqlx.ServerMessages_SQL_STMT_EXECUTE_OK:
+		return "", nil
+	}
+	return shortHex(r.Payload), nil
+}
+
+// compactText unmarshals the payload into m and returns its text form
+func compactText(payload []byte, m proto.Message) (string, error) {
+	if err := proto.Unmarshal(payload, m); err != nil {
+		return "", err
+	}
+	return proto.CompactTextString(m), nil
+}
+
+// capabilities returns the capabilities as name=value pairs
+func capabilities(m *Mysqlx_Connection.Capabilities) string {
+	list := make([]string, len(m.GetCapabilities()))
+	for i, c := range m.GetCapabilities() {
+		list[i] = c.GetName() + "=" + anyString(c.GetValue())
+	}
+	return strings.Join(list, " ")
+}
+
+// notice decodes a notice frame and its payload
+func notice(payload []byte) (string, error) {
+	frame := new(Mysqlx_Notice.Frame)
+	if err := proto.Unmarshal(payload, frame); err != nil {
+		return "", err
+	}
+	scope := strings.ToLower(frame.GetScope().String())
+
+	var text string
+	var err error
+	switch frame.GetType() {
+	case 1:
+		m := new(Mysqlx_Notice.Warning)
+		if err = proto.Unmarshal(frame.Payload, m); err == nil {
+			text = fmt.Sprintf("%s %04d %s", strings.ToLower(m.GetLevel().String()), m.GetCode(), m.GetMsg())
+		}
+	case 2:
+		m := new(Mysqlx_Notice.SessionVariableChanged)
+		if err = proto.Unmarshal(frame.Payload, m); err == nil {
+			text = fmt.Sprintf("session variable %s=%s", m.GetParam(), scalarString(m.GetValue()))
+		}
+	case 3:
+		m := new(Mysqlx_Notice.SessionStateChanged)
+		if err = proto.Unmarshal(frame.Payload, m); err == nil {
+			text = fmt.Sprintf("session state %s=%s", m.GetParam(), scalarString(m.GetValue()))
+		}
+	case 4:
+		text = "group replication state changed " + shortHex(frame.Payload)
+	case 5:
+		text = "server hello"
+	default:
+		text = fmt.Sprintf("type %d %s", frame.GetType(), shortHex(frame.Payload))
+	}
+	if err != nil {
+		return "", err
+	}
+	return scope + " " + text, nil
+}
+
+// columnMetaData returns the interesting fields of the column metadata
+func columnMetaData(m *Mysqlx_Resultset.ColumnMetaData) string {
+	text := fmt.Sprintf("%q %s", m.Name, m.GetType())
+	if len(m.OriginalName) > 0 || len(m.OriginalTable) > 0 {
+		text += fmt.Sprintf(" from %s.%s.%s", m.Schema, m.OriginalTable, m.OriginalName)
+	}
+	if m.Collation != nil {
+		text += fmt.Sprintf(" collation=%d", m.GetCollation())
+	}
+	if m.Length != nil {
+		text += fmt.Sprintf(" length=%d", m.GetLength())
+	}
+	if m.FractionalDigits != nil {
+		text += fmt.Sprintf(" fractional_digits=%d", m.GetFractionalDigits())
+	}
+	if m.Flags != nil {
+		text += fmt.Sprintf(" flags=0x%x", m.GetFlags())
+	}
+	if m.ContentType != nil {
+		text += fmt.Sprintf(" content_type=%d", m.GetContentType())
+	}
+	return text
+}
+
+// row decodes the fields of a row using the column types
+func (d *decoder) row(m *Mysqlx_Resultset.Row) string {
+	values := make([]string, len(m.Field))
+	for i, field := range m.Field {
+		var fieldType Mysqlx_Resultset.ColumnMetaData_FieldType
+		if i < len(d.columns) {
+			fieldType = d.columns[i].GetType()
+		}
+		values[i] = fieldString(fieldType, field)
+	}
+	return "[" + strings.Join(values, ", ") + "]"
+}
+
+// fieldString returns a single field of a row as text. Values of
+// types which are not decoded are shown in hex.
+func fieldString(fieldType Mysqlx_Resultset.ColumnMetaData_FieldType, data []byte) string {
+	if len(data) == 0 {
+		return "NULL"
+	}
+	switch fieldType {
+	case Mysqlx_Resultset.ColumnMetaData_SINT:
+		if v, n := proto.DecodeVarint(data); n == len(data) {
+			return fmt.Sprint(int64(v>>1) ^ -int64(v&1))
+		}
+	case Mysqlx_Resultset.ColumnMetaData_UINT:
+		if v, n := proto.DecodeVarint(data); n == len(data) {
+			return fmt.Sprint(v)
+		}
+	case Mysqlx_Resultset.ColumnMetaData_FLOAT:
+		if len(data) == 4 {
+			return fmt.Sprint(math.Float32frombits(binary.LittleEndian.Uint32(data)))
+		}
+	case Mysqlx_Resultset.ColumnMetaData_DOUBLE:
+		if len(data) == 8 {
+			return fmt.Sprint(math.Float64frombits(binary.LittleEndian.Uint64(data)))
+		}
+	case Mysqlx_Resultset.ColumnMetaData_BYTES, Mysqlx_Resultset.ColumnMetaData_ENUM:
+		return fmt.Sprintf("%q", data[:len(data)-1])
+	case Mysqlx_Resultset.ColumnMetaData_DATETIME:
+		if v, ok := varints(data); ok && len(v) >= 3 {
+			text := fmt.Sprintf("%04d-%02d-%02d", v[0], v[1], v[2])
+			if len(v) > 3 {
+				for len(v) < 6 {
+					v = append(v, 0)
+				}
+				text += fmt.Sprintf(" %02d:%02d:%02d", v[3], v[4], v[5])
+				if len(v) > 6 {
+					text += fmt.Sprintf(".%06d", v[6])
+				}
+			}
+			return text
+		}
+	case Mysqlx_Resultset.ColumnMetaData_TIME:
+		sign := ""
+		if data[0] == 1 {
+			sign = "-"
+		}
+		if v, ok := varints(data[1:]); ok {
+			for len(v) < 3 {
+				v = append(v, 0)
+			}
+			text := fmt.Sprintf("%s%02d:%02d:%02d", sign, v[0], v[1], v[2])
+			if len(v) > 3 {
+				text += fmt.Sprintf(".%06d", v[3])
+			}
+			return text
+		}
+	}
+	return "x'" + hex.EncodeToString(data) + "'"
+}
+
+// varints decodes a sequence of varints
+func varints(data []byte) ([]uint64, bool) {
+	var values []uint64
+	for len(data) > 0 {
+		v, n := proto.DecodeVarint(data)
+		if n == 0 {
+			return nil, false
+		}
+		values = append(values, v)
+		data = data[n:]
+	}
+	return values, true
+}
+
+// anyString returns a Mysqlx.Datatypes.Any as text
+func anyString(a *Mysqlx_Datatypes.Any) string {
+	switch a.GetType() {
+	case Mysqlx_Datatypes.Any_SCALAR:
+		return scalarString(a.GetScalar())
+	case Mysqlx_Datatypes.Any_OBJECT:
+		fields := make([]string, len(a.GetObj().GetFld()))
+		for i, f := range a.GetObj().GetFld() {
+			fields[i] = f.GetKey() + ": " + anyString(f.GetValue())
+		}
+		return "{" + strings.Join(fields, ", ") + "}"
+	case Mysqlx_Datatypes.Any_ARRAY:
+		values := make([]string, len(a.GetArray().GetValue()))
+		for i, v := range a.GetArray().GetValue() {
+			values[i] = anyString(v)
+		}
+		return "[" + strings.Join(values, ", ") + "]"
+	}
+	return "?"
+}
+
+// scalarString returns a Mysqlx.Datatypes.Scalar as text
+func scalarString(s *Mysqlx_Datatypes.Scalar) string {
+	if s == nil {
+		return "NULL"
+	}
+	switch s.GetType() {
+	case Mysqlx_Datatypes.Scalar_V_SINT:
+		return fmt.Sprint(s.GetVSignedInt())
+	case Mysqlx_Datatypes.Scalar_V_UINT:
+		return fmt.Sprint(s.GetVUnsignedInt())
+	case Mysqlx_Datatypes.Scalar_V_NULL:
+		return "NULL"
+	case Mysqlx_Datatypes.Scalar_V_OCTETS:
+		return fmt.Sprintf("%q", s.GetVOctets().GetValue())
+	case Mysqlx_Datatypes.Scalar_V_DOUBLE:
+		return fmt.Sprint(s.GetVDouble())
+	case Mysqlx_Datatypes.Scalar_V_FLOAT:
+		return fmt.Sprint(s.GetVFloat())
+	case Mysqlx_Datatypes.Scalar_V_BOOL:
+		return fmt.Sprint(s.GetVBool())
+	case Mysqlx_Datatypes.Scalar_V_STRING:
+		return fmt.Sprintf("%q", s.GetVString().GetValue())
+	}
+	return "?"
+}
+
+// redactAuth returns authentication data without the secret. The
+// MYSQL41, PLAIN and SHA256_MEMORY mechanisms send schema\0user\0secret
+// so the schema and user are shown.
+func redactAuth(data []byte) string {
+	parts := bytes.SplitN(data, []byte{0}, 3)
+	if len(parts) != 3 {
+		return redacted(data)
+	}
+	return fmt.Sprintf("schema=%q user=%q secret=%s", parts[0], parts[1], redacted(parts[2]))
+}
+
+// redacted describes data without showing it
+func redacted(data []byte) string {
+	return fmt.Sprintf("<%d bytes redacted>", len(data))
+}
+
+// shortHex returns the start of the data in hex
+func shortHex(data []byte) string {
+	if len(data) == 0 {
+		return "-"
+	}
+	if len(data) > maxHexShown {
+		return hex.EncodeToString(data[:maxHexShown]) + fmt.Sprintf("... (%d bytes)", len(data))
+	}
+	return hex.EncodeToString(data)
+}
+
+// setsTLS returns true if the CapabilitiesSet payload enables TLS
+func setsTLS(payload []byte) bool {
+	m := new(Mysqlx_Connection.CapabilitiesSet)
+	if err := proto.Unmarshal(payload, m); err != nil {
+		return false
+	}
+	for _, c := range m.GetCapabilities().GetCapabilities() {
+		if c.GetName() == "tls" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,258 @@
+// Go driver for MySQL X Protocol
+//
+// Copyright 2016 Simon J Mudd.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// xproxy is a debugging proxy for the X protocol. It listens locally,
+// forwards every connection to an X protocol server and prints each
+// frame sent in either direction in a readable form:
+//
+//	xproxy -listen 127.0.0.1:33061 -target 127.0.0.1:33060
+//	xproxy -types SQL_STMT_EXECUTE,ERROR,NOTICE
+//
+// Point the application at the listen address. Passwords and the other
+// authentication data are never printed. Once a client switches to TLS
+// the frames can no longer be decoded and are forwarded unseen.
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx"
+	"github.com/sjmudd/go-mysqlx-driver/wiretap"
+)
+
+const maxFrameSize = 1024 * 1024 * 1024 // largest frame forwarded, the protocol allows up to 4GB
+
+var (
+	listen = flag.String("listen", "127.0.0.1:33061", "address to listen on")
+	target = flag.String("target", "127.0.0.1:33060", "address of the X protocol server")
+	types  = flag.String("types", "", "comma separated message types to print, by name or number, default all")
+	quiet  = flag.Bool("quiet", false, "do not print connections being opened and closed")
+)
+
+func main() {
+	flag.Parse()
+
+	filter, err := parseFilter(*types)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "xproxy: %v\n", err)
+		os.Exit(2)
+	}
+
+	l, err := net.Listen("tcp", *listen)
+	if err != nil {
+		log.Fatalf("listen failed: %v", err)
+	}
+	log.Printf("listening on %s, forwarding to %s", l.Addr(), *target)
+
+	var id uint64
+	for {
+		client, err := l.Accept()
+		if err != nil {
+			log.Fatalf("accept failed: %v", err)
+		}
+		id++
+		go proxy(id, client, filter)
+	}
+}
+
+// parseFilter returns the message types to print, nil meaning all.
+// Names are looked up among both client and server messages.
+func parseFilter(list string) (map[wiretap.Direction]map[int]bool, error) {
+	if list == "" {
+		return nil, nil
+	}
+	filter := map[wiretap.Direction]map[int]bool{
+		wiretap.ClientToServer: {},
+		wiretap.ServerToClient: {},
+	}
+	for _, name := range strings.Split(list, ",") {
+		name = strings.ToUpper(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if n, err := strconv.Atoi(name); err == nil {
+			filter[wiretap.ClientToServer][n] = true
+			filter[wiretap.ServerToClient][n] = true
+			continue
+		}
+		found := false
+		if n, ok := Mysqlx.ClientMessages_Type_value[name]; ok {
+			filter[wiretap.ClientToServer][int(n)] = true
+			found = true
+		}
+		if n, ok := Mysqlx.ServerMessages_Type_value[name]; ok {
+			filter[wiretap.ServerToClient][int(n)] = true
+			found = true
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown message type: %s", name)
+		}
+	}
+	return filter, nil
+}
+
+// session is a single proxied connection
+type session struct {
+	id     uint64
+	filter map[wiretap.Direction]map[int]bool
+	out    sync.Mutex // serialises printing and decoding
+
+	decoder *decoder   // state needed to decode server messages
+	tls     chan bool  // answer to a request to start TLS, true if accepted
+	mu      sync.Mutex // protects tlsWant
+	tlsWant bool       // the client has asked to start TLS
+}
+
+// proxy forwards the client connection to the target until either
+// side closes it
+func proxy(id uint64, client net.Conn, filter map[wiretap.Direction]map[int]bool) {
+	defer client.Close()
+
+	server, err := net.Dial("tcp", *target)
+	if err != nil {
+		log.Printf("[%d] connect to %s failed: %v", id, *target, err)
+		return
+	}
+	defer server.Close()
+	if !*quiet {
+		log.Printf("[%d] %s connected", id, client.RemoteAddr())
+	}
+
+	s := &session{
+		id:      id,
+		filter:  filter,
+		decoder: &decoder{},
+		tls:     make(chan bool, 1),
+	}
+
+	done := make(chan struct{}, 2)
+	go func() {
+		s.forward(client, server, wiretap.ClientToServer)
+		server.Close()
+		done <- struct{}{}
+	}()
+	go func() {
+		s.forward(server, client, wiretap.ServerToClient)
+		client.Close()
+		done <- struct{}{}
+	}()
+	<-done
+	<-done
+	if !*quiet {
+		log.Printf("[%d] closed", id)
+	}
+}
+
+// forward copies frames from src to dst, printing them, until an
+// error or the switch to TLS after which bytes are copied unseen
+func (s *session) forward(src, dst net.Conn, direction wiretap.Direction) {
+	var header [4]byte
+	for {
+		if _, err := io.ReadFull(src, header[:]); err != nil {
+			s.closed(direction, err)
+			return
+		}
+		length := binary.LittleEndian.Uint32(header[:])
+		if length < 1 || length > maxFrameSize {
+			s.printf(direction, "invalid frame length %d, forwarding the rest unseen", length)
+			dst.Write(header[:])
+			io.Copy(dst, src)
+			return
+		}
+		frame := make([]byte, 4+length)
+		copy(frame, header[:])
+		if _, err := io.ReadFull(src, frame[4:]); err != nil {
+			s.closed(direction, err)
+			return
+		}
+		r := wiretap.Record{Direction: direction, Type: int(frame[4]), Payload: frame[5:]}
+		requested := s.requestsTLS(r) // before forwarding so the answer is not missed
+
+		if _, err := dst.Write(frame); err != nil {
+			s.closed(direction, err)
+			return
+		}
+		s.show(r)
+
+		if requested && <-s.tls || s.answersTLS(r) {
+			s.printf(direction, "switching to TLS, no more frames can be decoded")
+			io.Copy(dst, src)
+			return
+		}
+	}
+}
+
+// requestsTLS returns true if the frame is the client asking to start
+// TLS. The client then waits for the server's answer before reading
+// any more from the client as what follows is the TLS handshake.
+func (s *session) requestsTLS(r wiretap.Record) bool {
+	if r.Direction != wiretap.ClientToServer || r.Type != int(Mysqlx.ClientMessages_CON_CAPABILITIES_SET) || !setsTLS(r.Payload) {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tlsWant = true
+	return true
+}
+
+// answersTLS returns true if the frame is the server accepting a
+// request to start TLS, passing on the answer to the client side
+func (s *session) answersTLS(r wiretap.Record) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.Direction != wiretap.ServerToClient || !s.tlsWant {
+		return false
+	}
+	s.tlsWant = false
+	accepted := r.Type == int(Mysqlx.ServerMessages_OK)
+	select {
+	case s.tls <- accepted:
+	default:
+	}
+	return accepted
+}
+
+// show prints the frame unless filtered out. Every frame is decoded
+// to keep track of the column types needed to decode rows.
+func (s *session) show(r wiretap.Record) {
+	s.out.Lock()
+	defer s.out.Unlock()
+	text := s.decoder.decode(r)
+	if s.filter != nil && !s.filter[r.Direction][r.Type] {
+		return
+	}
+	log.Printf("[%d] %s %s %s", s.id, r.Direction, r.TypeName(), text)
+}
+
+// closed reports the end of one direction of the connection
+func (s *session) closed(direction wiretap.Direction, err error) {
+	if err != io.EOF && !errors.Is(err, net.ErrClosed) && !*quiet {
+		s.printf(direction, "%v", err)
+	}
+	// let the other direction stop waiting for a TLS answer
+	select {
+	case s.tls <- false:
+	default:
+	}
+}
+
+func (s *session) printf(direction wiretap.Direction, format string, args ...interface{}) {
+	s.out.Lock()
+	defer s.out.Unlock()
+	log.Printf("[%d] %s "+format, append([]interface{}{s.id, direction}, args...)...)
+}
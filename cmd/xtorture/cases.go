@@ -0,0 +1,205 @@
+// Go driver for MySQL X Protocol
+//
+// Copyright 2016 Simon J Mudd.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx"
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Connection"
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Datatypes"
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Session"
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Sql"
+)
+
+// testCase is something the server should survive. run returns
+// whether the server behaved and what it did.
+type testCase struct {
+	name        string
+	description string
+	auth        bool // log in before running
+	run         func(p *probe, cfg *config) (bool, string)
+}
+
+// unknownType is a client message type which does not exist
+const unknownType = 255
+
+var cases = []testCase{
+	{
+		name:        "oversized-length",
+		description: "a frame of 2^32-1 bytes with an unknown type",
+		run: func(p *probe, cfg *config) (bool, string) {
+			return rejected(p.send([]byte{255, 255, 255, 255, unknownType}))
+		},
+	},
+	{
+		name:        "over-max-packet",
+		description: "a CapabilitiesGet one byte longer than -max-packet",
+		run: func(p *probe, cfg *config) (bool, string) {
+			b := make([]byte, 5)
+			binary.LittleEndian.PutUint32(b, uint32(cfg.maxPacket+1))
+			b[4] = byte(Mysqlx.ClientMessages_CON_CAPABILITIES_GET)
+			return rejected(p.send(b))
+		},
+	},
+	{
+		name:        "zero-length",
+		description: "a frame of 0 bytes, without even a type",
+		run: func(p *probe, cfg *config) (bool, string) {
+			return rejected(p.send([]byte{0, 0, 0, 0}))
+		},
+	},
+	{
+		name:        "unknown-type",
+		description: "a message of an unknown type",
+		run: func(p *probe, cfg *config) (bool, string) {
+			return rejected(p.send(frame(unknownType, []byte("hello"))))
+		},
+	},
+	{
+		name:        "truncated-capabilities-set",
+		description: "a CapabilitiesSet cut off in the middle",
+		run: func(p *probe, cfg *config) (bool, string) {
+			b := message(Mysqlx.ClientMessages_CON_CAPABILITIES_SET, &Mysqlx_Connection.CapabilitiesSet{
+				Capabilities: &Mysqlx_Connection.Capabilities{
+					Capabilities: []*Mysqlx_Connection.Capability{{
+						Name: proto.String("client.pwd_expire_ok"),
+						Value: &Mysqlx_Datatypes.Any{
+							Type:   Mysqlx_Datatypes.Any_SCALAR.Enum(),
+							Scalar: &Mysqlx_Datatypes.Scalar{Type: Mysqlx_Datatypes.Scalar_V_BOOL.Enum(), VBool: proto.Bool(true)},
+						},
+					}},
+				},
+			})
+			return rejected(p.send(truncate(b, 3)))
+		},
+	},
+	{
+		name:        "truncated-authenticate-start",
+		description: "an AuthenticateStart cut off in the mechanism name",
+		run: func(p *probe, cfg *config) (bool, string) {
+			b := message(Mysqlx.ClientMessages_SESS_AUTHENTICATE_START, &Mysqlx_Session.AuthenticateStart{MechName: proto.String("MYSQL41")})
+			return rejected(p.send(truncate(b, 2)))
+		},
+	},
+	{
+		name:        "invalid-protobuf",
+		description: "an AuthenticateStart holding a varint which never ends",
+		run: func(p *probe, cfg *config) (bool, string) {
+			return rejected(p.send(frame(Mysqlx.ClientMessages_SESS_AUTHENTICATE_START, bytes.Repeat([]byte{0xff}, 16))))
+		},
+	},
+	{
+		name:        "auth-continue-first",
+		description: "an AuthenticateContinue without an AuthenticateStart",
+		run: func(p *probe, cfg *config) (bool, string) {
+			return rejected(p.send(message(Mysqlx.ClientMessages_SESS_AUTHENTICATE_CONTINUE,
+				&Mysqlx_Session.AuthenticateContinue{AuthData: []byte("\x00root\x00*0000000000000000000000000000000000000000")})))
+		},
+	},
+	{
+		name:        "sql-before-auth",
+		description: "a statement before logging in",
+		run: func(p *probe, cfg *config) (bool, string) {
+			return rejected(p.send(stmtExecute("SELECT 1")))
+		},
+	},
+	{
+		name:        "auth-continue-after-ok",
+		description: "an AuthenticateContinue once logged in",
+		auth:        true,
+		run: func(p *probe, cfg *config) (bool, string) {
+			return rejected(p.send(message(Mysqlx.ClientMessages_SESS_AUTHENTICATE_CONTINUE,
+				&Mysqlx_Session.AuthenticateContinue{AuthData: []byte("\x00" + cfg.user + "\x00")})))
+		},
+	},
+	{
+		name:        "capabilities-set-after-auth",
+		description: "turning on TLS once logged in",
+		auth:        true,
+		run: func(p *probe, cfg *config) (bool, string) {
+			return rejected(p.send(message(Mysqlx.ClientMessages_CON_CAPABILITIES_SET, &Mysqlx_Connection.CapabilitiesSet{
+				Capabilities: &Mysqlx_Connection.Capabilities{
+					Capabilities: []*Mysqlx_Connection.Capability{{
+						Name: proto.String("tls"),
+						Value: &Mysqlx_Datatypes.Any{
+							Type:   Mysqlx_Datatypes.Any_SCALAR.Enum(),
+							Scalar: &Mysqlx_Datatypes.Scalar{Type: Mysqlx_Datatypes.Scalar_V_BOOL.Enum(), VBool: proto.Bool(true)},
+						},
+					}},
+				},
+			})))
+		},
+	},
+	{
+		name:        "capabilities-get-flood",
+		description: "-flood CapabilitiesGet messages in a single write",
+		run: func(p *probe, cfg *config) (bool, string) {
+			return flood(p, cfg.flood, message(Mysqlx.ClientMessages_CON_CAPABILITIES_GET, &Mysqlx_Connection.CapabilitiesGet{}),
+				func(r response) bool { return r.is(Mysqlx.ServerMessages_CONN_CAPABILITIES) })
+		},
+	},
+	{
+		name:        "sql-flood",
+		description: "-flood SELECT 1 statements in a single write",
+		auth:        true,
+		run: func(p *probe, cfg *config) (bool, string) {
+			return flood(p, cfg.flood, stmtExecute("SELECT 1"), func(r response) bool {
+				// skip the result set
+				for r.is(Mysqlx.ServerMessages_RESULTSET_COLUMN_META_DATA) ||
+					r.is(Mysqlx.ServerMessages_RESULTSET_ROW) ||
+					r.is(Mysqlx.ServerMessages_RESULTSET_FETCH_DONE) {
+					r = p.next()
+				}
+				return r.is(Mysqlx.ServerMessages_SQL_STMT_EXECUTE_OK)
+			})
+		},
+	},
+}
+
+// rejected passes if the server sent an error or closed the connection
+func rejected(r response) (bool, string) {
+	return r.rejected(), r.String()
+}
+
+// truncate drops the last n bytes of the payload of the frame leaving
+// the length as it should be for what remains
+func truncate(frame []byte, n int) []byte {
+	b := frame[:len(frame)-n]
+	binary.LittleEndian.PutUint32(b, uint32(len(b)-4))
+	return b
+}
+
+func stmtExecute(stmt string) []byte {
+	return message(Mysqlx.ClientMessages_SQL_STMT_EXECUTE, &Mysqlx_Sql.StmtExecute{Stmt: []byte(stmt)})
+}
+
+// flood sends n copies of the frame in a single write and checks each
+// is answered. Writing happens while reading so that a server which
+// answers before reading everything does not block both sides.
+func flood(p *probe, n int, frame []byte, answered func(response) bool) (bool, string) {
+	written := make(chan error, 1)
+	go func() {
+		written <- p.write(bytes.Repeat(frame, n))
+	}()
+
+	for i := 0; i < n; i++ {
+		if r := p.next(); !answered(r) {
+			return false, fmt.Sprintf("message %d of %d: %v", i+1, n, r)
+		}
+	}
+	if err := <-written; err != nil {
+		return false, err.Error()
+	}
+	return true, fmt.Sprintf("%d answered", n)
+}
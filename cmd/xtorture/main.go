@@ -0,0 +1,138 @@
+// Go driver for MySQL X Protocol
+//
+// Copyright 2016 Simon J Mudd.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// xtorture sends malformed and unexpected messages to an X protocol
+// server and reports how it responds. Each case runs on its own
+// connection and passes if the server does the right thing, usually
+// sending an error or closing the connection, and still accepts new
+// connections afterwards.
+//
+// Usage:
+//
+//	xtorture [-target host:port] [-user user -password pass] [-run regexp]
+//
+// Cases which need to log in are skipped unless -user is given. -list
+// shows the cases. The exit status is 1 if any case fails.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx"
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Connection"
+)
+
+// config holds the settings given on the command line
+type config struct {
+	target    string
+	db        string
+	user      string
+	password  string
+	timeout   time.Duration
+	flood     int
+	maxPacket int
+}
+
+func main() {
+	cfg := &config{}
+	flag.StringVar(&cfg.target, "target", "127.0.0.1:33060", "address of the server to test")
+	flag.StringVar(&cfg.db, "db", "", "schema to log in to")
+	flag.StringVar(&cfg.user, "user", "", "user to log in as, cases which log in are skipped without it")
+	flag.StringVar(&cfg.password, "password", "", "password of the user")
+	flag.DurationVar(&cfg.timeout, "timeout", 5*time.Second, "how long to wait for the server to respond")
+	flag.IntVar(&cfg.flood, "flood", 1000, "number of messages sent by the flood cases")
+	flag.IntVar(&cfg.maxPacket, "max-packet", 64*1024*1024, "mysqlx_max_allowed_packet of the server")
+	run := flag.String("run", "", "only run the cases matching this regular expression")
+	list := flag.Bool("list", false, "list the cases and exit")
+	flag.Parse()
+
+	if *list {
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		for _, c := range cases {
+			login := ""
+			if c.auth {
+				login = " (logs in)"
+			}
+			fmt.Fprintf(w, "%s\t%s%s\n", c.name, c.description, login)
+		}
+		w.Flush()
+		return
+	}
+
+	match, err := regexp.Compile(*run)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "xtorture: -run: %v\n", err)
+		os.Exit(2)
+	}
+
+	failed := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "CASE\tRESULT\tSERVER RESPONSE")
+	for _, c := range cases {
+		if !match.MatchString(c.name) {
+			continue
+		}
+		result, detail := runCase(c, cfg)
+		if result == "FAIL" {
+			failed++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", c.name, result, detail)
+	}
+	w.Flush()
+
+	if failed > 0 {
+		fmt.Printf("\n%d failed\n", failed)
+		os.Exit(1)
+	}
+}
+
+// runCase runs the case on a new connection then checks the server is
+// still there
+func runCase(c testCase, cfg *config) (string, string) {
+	if c.auth && cfg.user == "" {
+		return "SKIP", "needs -user"
+	}
+
+	p, err := dial(cfg.target, cfg.timeout)
+	if err != nil {
+		return "FAIL", err.Error()
+	}
+	defer p.close()
+	if c.auth {
+		if err := p.authenticate(cfg.db, cfg.user, cfg.password); err != nil {
+			return "FAIL", err.Error()
+		}
+	}
+
+	ok, detail := c.run(p, cfg)
+	if err := alive(cfg); err != nil {
+		return "FAIL", fmt.Sprintf("%s, then the server stopped answering: %v", detail, err)
+	}
+	if !ok {
+		return "FAIL", detail
+	}
+	return "PASS", detail
+}
+
+// alive checks a new connection is answered
+func alive(cfg *config) error {
+	p, err := dial(cfg.target, cfg.timeout)
+	if err != nil {
+		return err
+	}
+	defer p.close()
+	if r := p.send(message(Mysqlx.ClientMessages_CON_CAPABILITIES_GET, &Mysqlx_Connection.CapabilitiesGet{})); !r.is(Mysqlx.ServerMessages_CONN_CAPABILITIES) {
+		return fmt.Errorf("CapabilitiesGet: %v", r)
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+// Go driver for MySQL X Protocol
+//
+// Copyright 2016 Simon J Mudd.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	mysql "github.com/sjmudd/go-mysqlx-driver"
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx"
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Session"
+)
+
+// probe is a connection to the server under test
+type probe struct {
+	conn    net.Conn
+	r       *bufio.Reader
+	timeout time.Duration
+}
+
+func dial(target string, timeout time.Duration) (*probe, error) {
+	conn, err := net.DialTimeout("tcp", target, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &probe{conn: conn, r: bufio.NewReader(conn), timeout: timeout}, nil
+}
+
+func (p *probe) close() {
+	p.conn.Close()
+}
+
+// frame returns a frame holding the payload
+func frame(msgType Mysqlx.ClientMessages_Type, payload []byte) []byte {
+	b := make([]byte, 5+len(payload))
+	binary.LittleEndian.PutUint32(b, uint32(len(payload)+1))
+	b[4] = byte(msgType)
+	copy(b[5:], payload)
+	return b
+}
+
+// message returns a frame holding the message
+func message(msgType Mysqlx.ClientMessages_Type, msg proto.Message) []byte {
+	payload, err := proto.Marshal(msg)
+	if err != nil {
+		panic(fmt.Sprintf("marshal %s: %v", msgType, err))
+	}
+	return frame(msgType, payload)
+}
+
+// write sends the bytes in a single write
+func (p *probe) write(b []byte) error {
+	p.conn.SetWriteDeadline(time.Now().Add(p.timeout))
+	_, err := p.conn.Write(b)
+	return err
+}
+
+// read returns the next message from the server
+func (p *probe) read() (Mysqlx.ServerMessages_Type, []byte, error) {
+	p.conn.SetReadDeadline(time.Now().Add(p.timeout))
+	var header [4]byte
+	if _, err := io.ReadFull(p.r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	length := binary.LittleEndian.Uint32(header[:])
+	if length < 1 || length > 64*1024*1024 {
+		return 0, nil, fmt.Errorf("server sent a frame of %d bytes", length)
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(p.r, data); err != nil {
+		return 0, nil, err
+	}
+	return Mysqlx.ServerMessages_Type(data[0]), data[1:], nil
+}
+
+// response is what the server did after a message
+type response struct {
+	msgType Mysqlx.ServerMessages_Type
+	payload []byte
+	err     *Mysqlx.Error // set for an ERROR message
+	closed  bool          // the server closed the connection
+	timeout bool          // nothing came back in time
+	other   error         // anything else that went wrong reading
+}
+
+func (r response) String() string {
+	switch {
+	case r.closed:
+		return "connection closed"
+	case r.timeout:
+		return "no answer"
+	case r.other != nil:
+		return r.other.Error()
+	case r.err != nil:
+		return fmt.Sprintf("ERROR %d (%s): %s", r.err.GetCode(), r.err.GetSqlState(), r.err.GetMsg())
+	}
+	return r.msgType.String()
+}
+
+// rejected is true if the server sent an error or hung up
+func (r response) rejected() bool {
+	return r.closed || r.err != nil
+}
+
+// is returns true if the server answered with a message of the type
+func (r response) is(msgType Mysqlx.ServerMessages_Type) bool {
+	return !r.closed && !r.timeout && r.other == nil && r.err == nil && r.msgType == msgType
+}
+
+// next returns the server's response, skipping notices
+func (p *probe) next() response {
+	for {
+		msgType, payload, err := p.read()
+		if err != nil {
+			return readFailure(err)
+		}
+		switch msgType {
+		case Mysqlx.ServerMessages_NOTICE:
+			continue
+		case Mysqlx.ServerMessages_ERROR:
+			e := new(Mysqlx.Error)
+			if err := proto.Unmarshal(payload, e); err != nil {
+				return response{other: fmt.Errorf("invalid Error: %v", err)}
+			}
+			return response{msgType: msgType, err: e}
+		}
+		return response{msgType: msgType, payload: payload}
+	}
+}
+
+// readFailure returns the response for an error reading from the server
+func readFailure(err error) response {
+	var ne net.Error
+	switch {
+	case err == io.EOF || err == io.ErrUnexpectedEOF || errors.Is(err, syscall.ECONNRESET):
+		return response{closed: true}
+	case errors.As(err, &ne) && ne.Timeout():
+		return response{timeout: true}
+	}
+	return response{other: err}
+}
+
+// send writes the bytes and returns the response. A server which hangs
+// up before reading everything is taken to have closed the connection.
+func (p *probe) send(b []byte) response {
+	if err := p.write(b); err != nil {
+		if errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNRESET) {
+			return response{closed: true}
+		}
+		return readFailure(err)
+	}
+	return p.next()
+}
+
+// authenticate logs in with MYSQL41
+func (p *probe) authenticate(db, user, password string) error {
+	auth := mysql.NewMySQL41(db, user, password)
+	r := p.send(message(Mysqlx.ClientMessages_SESS_AUTHENTICATE_START,
+		&Mysqlx_Session.AuthenticateStart{MechName: proto.String(auth.Name())}))
+	if !r.is(Mysqlx.ServerMessages_SESS_AUTHENTICATE_CONTINUE) {
+		return fmt.Errorf("AuthenticateStart: %v", r)
+	}
+	cont := new(Mysqlx_Session.AuthenticateContinue)
+	if err := proto.Unmarshal(r.payload, cont); err != nil {
+		return fmt.Errorf("AuthenticateContinue: %v", err)
+	}
+	data, err := auth.GetNextAuthData(cont.GetAuthData())
+	if err != nil {
+		return err
+	}
+	r = p.send(message(Mysqlx.ClientMessages_SESS_AUTHENTICATE_CONTINUE, &Mysqlx_Session.AuthenticateContinue{AuthData: data}))
+	if !r.is(Mysqlx.ServerMessages_SESS_AUTHENTICATE_OK) {
+		return fmt.Errorf("authentication failed: %v", r)
+	}
+	return nil
+}
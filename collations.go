@@ -233,18 +233,36 @@ var collations = map[string]byte{
 	"utf8mb4_croatian_ci":      245,
 	"utf8mb4_unicode_520_ci":   246,
 	"utf8mb4_vietnamese_ci":    247,
+	"gb18030_chinese_ci":       248,
+	"gb18030_bin":              249,
+	"gb18030_unicode_520_ci":   250,
+	"utf8mb4_0900_ai_ci":       255,
+}
+
+// collationName returns the name of the collation with the given id
+// or an empty string if it is not known.
+func collationName(id byte) string {
+	for name, collation := range collations {
+		if collation == id {
+			return name
+		}
+	}
+	return ""
 }
 
 // A blacklist of collations which is unsafe to interpolate parameters.
 // These multibyte encodings may contains 0x5c (`\`) in their trailing bytes.
 var unsafeCollations = map[byte]bool{
-	1:  true, // big5_chinese_ci
-	13: true, // sjis_japanese_ci
-	28: true, // gbk_chinese_ci
-	84: true, // big5_bin
-	86: true, // gb2312_bin
-	87: true, // gbk_bin
-	88: true, // sjis_bin
-	95: true, // cp932_japanese_ci
-	96: true, // cp932_bin
+	1:   true, // big5_chinese_ci
+	13:  true, // sjis_japanese_ci
+	28:  true, // gbk_chinese_ci
+	84:  true, // big5_bin
+	86:  true, // gb2312_bin
+	87:  true, // gbk_bin
+	88:  true, // sjis_bin
+	95:  true, // cp932_japanese_ci
+	96:  true, // cp932_bin
+	248: true, // gb18030_chinese_ci
+	249: true, // gb18030_bin
+	250: true, // gb18030_unicode_520_ci
 }
@@ -0,0 +1,223 @@
+// Go driver for MySQL X Protocol
+//
+// Copyright 2016 Simon J Mudd.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+// This file handles compression of the frames of a connection. The
+// algorithm is chosen by setting the compression capability before
+// authenticating. After that either side may send a COMPRESSION frame
+// holding one or more ordinary frames, headers included, compressed
+// with it. Only deflate_stream is built in, lz4_message and
+// zstd_stream can be added with RegisterCompression.
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"fmt"
+	"io"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx"
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Connection"
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Datatypes"
+)
+
+const (
+	// algorithm used if the compress DSN parameter is true
+	defaultCompression = "deflate_stream"
+
+	// frames with smaller payloads are not worth compressing
+	minCompressSize = 1024
+)
+
+// Compressor compresses the frames sent on a connection and
+// uncompresses those received. A new one is made for each connection
+// so a stream algorithm can keep its state between calls. The data
+// passed in is only valid until the call returns.
+type Compressor interface {
+	// Compress returns data, one or more frames, compressed
+	Compress(data []byte) ([]byte, error)
+
+	// Uncompress returns the size bytes data uncompresses to. The
+	// size is sent by the server so should not be trusted to allocate
+	// the result, and data which does not uncompress to exactly size
+	// bytes is an error.
+	Uncompress(data []byte, size int) ([]byte, error)
+}
+
+// NewCompressorFunc returns a Compressor for a new connection.
+// Custom compressors must be registered with RegisterCompression.
+type NewCompressorFunc func() Compressor
+
+var compressors = map[string]NewCompressorFunc{
+	"deflate_stream": newDeflateStream,
+}
+
+// RegisterCompression registers a compression algorithm by the name
+// the server gives it, for example lz4_message or zstd_stream. It can
+// then be used with the compress DSN parameter or Config.Compression.
+func RegisterCompression(algorithm string, newCompressor NewCompressorFunc) {
+	compressors[algorithm] = newCompressor
+}
+
+// startCompression asks the server to compress with the configured
+// algorithm by setting the compression capability
+func (mc *mysqlXConn) startCompression() error {
+	algorithm := mc.cfg.compression
+	newCompressor, found := compressors[algorithm]
+	if !found {
+		return fmt.Errorf("unknown compression algorithm: %s", algorithm)
+	}
+
+	offered := mc.capabilities.Values("compression.algorithm")
+	found = false
+	for i := range offered {
+		if offered[i].String() == algorithm {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("compression algorithm %s not offered by server. Found: %+v", algorithm, offered)
+	}
+
+	if mc.log.on(LogDebug) {
+		mc.log.printf(LogDebug, "Enabling %s compression via CapabilitySet", algorithm)
+	}
+	err := mc.setObjectCapability("compression", map[string]*Mysqlx_Datatypes.Any{
+		"algorithm": newAnyScalar(Mysqlx_Datatypes.Any_SCALAR, scalar(algorithm)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set compression capability: %v", err)
+	}
+	mc.compressor = newCompressor()
+	return nil
+}
+
+// writeCompressed sends a frame inside a COMPRESSION frame
+func (mc *mysqlXConn) writeCompressed(pb *netProtobuf) error {
+	frame := make([]byte, 5+len(pb.payload))
+	putFrameHeader(frame, pb.msgType, len(pb.payload))
+	copy(frame[5:], pb.payload)
+
+	compressed, err := mc.compressor.Compress(frame)
+	if err != nil {
+		return fmt.Errorf("Error compressing protobuf message: %v", err)
+	}
+	payload, err := proto.Marshal(&Mysqlx_Connection.Compression{
+		UncompressedSize: proto.Uint64(uint64(len(frame))),
+		ClientMessages:   Mysqlx.ClientMessages_Type(pb.msgType).Enum(),
+		Payload:          compressed,
+	})
+	if err != nil {
+		return fmt.Errorf("Error marshalling compressed protobuf message: %v", err)
+	}
+
+	if err := mc.writeFrame(int(Mysqlx.ClientMessages_COMPRESSION), payload); err != nil {
+		return err
+	}
+	mc.stats.frameOut(int(Mysqlx.ClientMessages_COMPRESSION), payload)
+	mc.stats.messageOut(pb.msgType)
+	return nil
+}
+
+// uncompress reads the payload of a COMPRESSION frame, leaving the
+// frames it holds to be returned by readMsg
+func (mc *mysqlXConn) uncompress(payload []byte) error {
+	c := new(Mysqlx_Connection.Compression)
+	if err := proto.Unmarshal(payload, c); err != nil {
+		return fmt.Errorf("unmarshaling error with compression: %v", err)
+	}
+	size := c.GetUncompressedSize()
+	if size > maxReadPacketSize {
+		return ErrPktTooLarge
+	}
+	data, err := mc.compressor.Uncompress(c.GetPayload(), int(size))
+	if err != nil {
+		return fmt.Errorf("Error uncompressing protobuf messages: %v", err)
+	}
+	if len(data) != int(size) {
+		return fmt.Errorf("Error uncompressing protobuf messages: got %d bytes, expected %d", len(data), size)
+	}
+	mc.uncompressed = data
+	return nil
+}
+
+// deflateStream is the deflate_stream algorithm: all the frames sent
+// in each direction form a single zlib stream, flushed at the end of
+// each COMPRESSION frame
+type deflateStream struct {
+	w      *zlib.Writer
+	out    bytes.Buffer
+	header bool          // the zlib header has been read
+	window []byte        // the last deflateWindow bytes uncompressed
+	r      io.ReadCloser // the flate reader, reset for each frame
+}
+
+// deflateWindow is how far back deflate may refer to earlier data
+const deflateWindow = 32 << 10
+
+func newDeflateStream() Compressor {
+	return new(deflateStream)
+}
+
+func (d *deflateStream) Compress(data []byte) ([]byte, error) {
+	d.out.Reset()
+	if d.w == nil {
+		d.w = zlib.NewWriter(&d.out)
+	}
+	if _, err := d.w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := d.w.Flush(); err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), d.out.Bytes()...), nil
+}
+
+// Uncompress reads the frame as raw deflate, with the end of what was
+// uncompressed before as its dictionary. As each frame ends with a
+// flush it starts on a block boundary, and the reader runs out of
+// input exactly where the frame ends, so asking for one byte more than
+// size tells if the frame held more. The result grows as the data is
+// uncompressed rather than being allocated up front, so a bad size
+// can't make us allocate more than is sent.
+func (d *deflateStream) Uncompress(data []byte, size int) ([]byte, error) {
+	if !d.header {
+		// CMF and FLG: deflate, a multiple of 31 and no preset dictionary
+		if len(data) < 2 || data[0]&0x0f != 8 || (int(data[0])<<8|int(data[1]))%31 != 0 || data[1]&0x20 != 0 {
+			return nil, zlib.ErrHeader
+		}
+		data = data[2:]
+		d.header = true
+	}
+	in := bytes.NewReader(data)
+	if d.r == nil {
+		d.r = flate.NewReader(in)
+	}
+	if err := d.r.(flate.Resetter).Reset(in, d.window); err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	_, err := io.CopyN(&out, d.r, int64(size)+1)
+	switch {
+	case out.Len() > size:
+		return nil, fmt.Errorf("uncompressed more than %d bytes", size)
+	case err != io.ErrUnexpectedEOF && err != io.EOF:
+		return nil, err
+	case out.Len() < size:
+		return nil, fmt.Errorf("uncompressed %d bytes, expected %d", out.Len(), size)
+	}
+	d.window = append(d.window, out.Bytes()...)
+	if len(d.window) > deflateWindow {
+		d.window = append([]byte(nil), d.window[len(d.window)-deflateWindow:]...)
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,164 @@
+// Go driver for MySQL X Protocol
+//
+// Copyright 2016 Simon J Mudd.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+import (
+	"bytes"
+	"context"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/sjmudd/go-mysqlx-driver/mysqlxtest"
+)
+
+// a large result and a large statement are sent compressed
+func TestCompression(t *testing.T) {
+	s := mysqlxtest.NewServer(fakeUser, fakePasswd)
+	s.Compression = []string{"deflate_stream", "lz4_message"}
+	defer s.Close()
+
+	const numRows = 200
+	value := strings.Repeat("compress me ", 100)
+	result := &mysqlxtest.Result{Columns: []mysqlxtest.Column{{Name: "id"}, {Name: "value"}}}
+	for i := 0; i < numRows; i++ {
+		result.Rows = append(result.Rows, []interface{}{int64(i), value})
+	}
+	s.Handle("SELECT id, value FROM t", result)
+	insert := "INSERT INTO t VALUES ('" + strings.Repeat("x", 5000) + "')"
+	s.Handle(insert, &mysqlxtest.Result{RowsAffected: 1})
+
+	for _, getCapabilities := range []bool{true, false} {
+		db := fakeDB(t, s, "MYSQL41", func(cfg *Config) {
+			cfg.Compression = "deflate_stream"
+			cfg.UseGetCapabilities = getCapabilities
+		})
+		conn, err := db.Conn(context.Background())
+		if err != nil {
+			t.Fatalf("TestCompression: getCapabilities=%v: Conn failed: %v", getCapabilities, err)
+		}
+
+		// twice to check the stream carries on from one frame to the next
+		for pass := 0; pass < 2; pass++ {
+			rows, err := conn.QueryContext(context.Background(), "SELECT id, value FROM t")
+			if err != nil {
+				t.Fatalf("TestCompression: getCapabilities=%v: Query failed: %v", getCapabilities, err)
+			}
+			n := 0
+			for rows.Next() {
+				var id int64
+				var got string
+				if err := rows.Scan(&id, &got); err != nil {
+					t.Fatalf("TestCompression: getCapabilities=%v: Scan failed: %v", getCapabilities, err)
+				}
+				if id != int64(n) || got != value {
+					t.Errorf("TestCompression: getCapabilities=%v: row %d: got %d, %q", getCapabilities, n, id, got)
+				}
+				n++
+			}
+			if err := rows.Close(); err != nil {
+				t.Errorf("TestCompression: getCapabilities=%v: Close failed: %v", getCapabilities, err)
+			}
+			if n != numRows {
+				t.Errorf("TestCompression: getCapabilities=%v: got %d rows, expected %d", getCapabilities, n, numRows)
+			}
+
+			res, err := conn.ExecContext(context.Background(), insert)
+			if err != nil {
+				t.Fatalf("TestCompression: getCapabilities=%v: Exec failed: %v", getCapabilities, err)
+			}
+			if affected, _ := res.RowsAffected(); affected != 1 {
+				t.Errorf("TestCompression: getCapabilities=%v: rows affected: %d, expected 1", getCapabilities, affected)
+			}
+		}
+
+		var stats Stats
+		conn.Raw(func(driverConn interface{}) error {
+			stats = driverConn.(XConn).Stats()
+			return nil
+		})
+		conn.Close()
+		db.Close()
+
+		if stats.In["COMPRESSION"].Frames == 0 || stats.Out["COMPRESSION"].Frames != 2 {
+			t.Errorf("TestCompression: getCapabilities=%v: COMPRESSION frames in: %d, out: %d, expected some and 2",
+				getCapabilities, stats.In["COMPRESSION"].Frames, stats.Out["COMPRESSION"].Frames)
+		}
+		if stats.In["RESULTSET_ROW"].Frames != 2*numRows {
+			t.Errorf("TestCompression: getCapabilities=%v: rows received: %d, expected %d", getCapabilities, stats.In["RESULTSET_ROW"].Frames, 2*numRows)
+		}
+		if uncompressed := uint64(2 * numRows * len(value)); stats.BytesIn() > uncompressed/10 {
+			t.Errorf("TestCompression: getCapabilities=%v: %d bytes received for %d bytes of rows", getCapabilities, stats.BytesIn(), uncompressed)
+		}
+	}
+	if errs := s.Errors(); len(errs) > 0 {
+		t.Errorf("TestCompression: server errors: %v", errs)
+	}
+}
+
+// the connection fails if the server does not offer the algorithm
+func TestCompressionNotOffered(t *testing.T) {
+	s := mysqlxtest.NewServer(fakeUser, fakePasswd)
+	defer s.Close()
+
+	db := fakeDB(t, s, "MYSQL41", func(cfg *Config) {
+		cfg.Compression = "deflate_stream"
+		cfg.UseGetCapabilities = true
+	})
+	defer db.Close()
+	if err := db.Ping(); err == nil || !strings.Contains(err.Error(), "not offered") {
+		t.Errorf("TestCompressionNotOffered: Ping gives: %v", err)
+	}
+}
+
+// what deflate_stream compresses in several calls uncompresses again
+// and data which does not uncompress to the size given is refused
+// without allocating that size
+func TestDeflateStream(t *testing.T) {
+	w, r := newDeflateStream(), newDeflateStream()
+	messages := [][]byte{
+		[]byte("first"),
+		bytes.Repeat([]byte("second "), 1000),
+		[]byte("third"),
+	}
+	compressed := make([][]byte, len(messages))
+	for i, m := range messages {
+		c, err := w.Compress(m)
+		if err != nil {
+			t.Fatalf("TestDeflateStream: Compress failed: %v", err)
+		}
+		compressed[i] = c
+	}
+	for i, m := range messages {
+		got, err := r.Uncompress(compressed[i], len(m))
+		if err != nil {
+			t.Fatalf("TestDeflateStream: message %d: Uncompress failed: %v", i, err)
+		}
+		if !bytes.Equal(got, m) {
+			t.Errorf("TestDeflateStream: message %d: got %q, expected %q", i, got, m)
+		}
+	}
+
+	// two frames given as one
+	joined := append(append([]byte(nil), compressed[0]...), compressed[1]...)
+	if _, err := newDeflateStream().Uncompress(joined, len(messages[0])); err == nil {
+		t.Error("TestDeflateStream: two frames given as one: expected an error")
+	}
+	for _, size := range []int{len(messages[0]) - 1, len(messages[0]) + 1, maxReadPacketSize} {
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		if _, err := newDeflateStream().Uncompress(compressed[0], size); err == nil {
+			t.Errorf("TestDeflateStream: %d bytes given as %d: expected an error", len(messages[0]), size)
+		}
+		runtime.ReadMemStats(&after)
+		if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+			t.Errorf("TestDeflateStream: size %d: allocated %d bytes", size, allocated)
+		}
+	}
+}
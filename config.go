@@ -12,25 +12,287 @@
 package mysql
 
 import (
+	"bytes"
+	"context"
 	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
 	"time"
 )
 
-type config struct {
-	user                    string
-	passwd                  string
-	net                     string
-	addr                    string
-	dbname                  string
-	params                  map[string]string
-	loc                     *time.Location
-	tls                     *tls.Config
-	timeout                 time.Duration
-	collation               uint8
-	allowAllFiles           bool
-	allowOldPasswords       bool
-	allowCleartextPasswords bool
-	columnsWithAlias        bool
-	interpolateParams       bool
-	useXProtocol            bool // use X protocol rather than native protocol
+// DialContextFunc is a function which can be used to establish the
+// network connection for a single Config. It has the same signature
+// as net.Dialer.DialContext.
+type DialContextFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// Config holds the driver configuration. It can be built from a DSN
+// with ParseDSN or filled in directly and used with NewConnector and
+// sql.OpenDB, in which case no DSN or global registry is needed.
+type Config struct {
+	User                    string            // Username
+	Passwd                  string            // Password (requires User)
+	Net                     string            // Network type
+	Addr                    string            // Network address (requires Net)
+	DBName                  string            // Database name
+	Params                  map[string]string // Connection parameters
+	Loc                     *time.Location    // Location for time.Time values
+	TLS                     *tls.Config       // TLS configuration, nil means no TLS
+	TLSConfig               string            // TLS configuration name as used in the DSN (true, skip-verify or a registered name)
+	Timeout                 time.Duration     // Connect timeout for each host, covers the dial and the handshake
+	Collation               uint8             // Connection collation
+	AllowAllFiles           bool              // Allow all files to be used with LOAD DATA LOCAL INFILE
+	AllowOldPasswords       bool              // Allows the old insecure password method
+	AllowCleartextPasswords bool              // Allows the cleartext client side plugin
+	ColumnsWithAlias        bool              // Prepend table alias to column names
+	ColumnsOriginalName     bool              // Name columns as in their table rather than by their alias, with the table rather than its alias if ColumnsWithAlias is set
+	InterpolateParams       bool              // Interpolate placeholders into query string
+	UseXProtocol            bool              // use X protocol rather than native protocol
+	UseGetCapabilities      bool              // for X protocol, do we send a GetCapabilities message to query server capabilities?
+	AuthMechanism           string            // for X protocol, the authentication mechanism to use
+	DialContext             DialContextFunc   // Custom dial function, overrides any function registered with RegisterDial
+	Hosts                   []Host            // Hosts to try in turn, if empty Net and Addr are used
+	RandomizeHosts          bool              // Try hosts of the same priority in a random order
+	RouteReadOnly           bool              // Send read only transactions to a secondary host
+	RouteQueries            bool              // Send Query calls made outside a transaction to a secondary host
+	WireTap                 string            // File to record the frames of every connection in, see the wiretap package
+	Logger                  LevelLogger       // Where the connections log to, nil means the Logger set with SetLogger
+	LogLevel                LogLevel          // Most verbose level logged, the default logs errors only
+	Hooks                   *Hooks            // Called around each statement and connection, nil for none
+	ConvertCharset          bool              // Convert text columns to UTF-8 and string args to the character set of Collation
+	Compression             string            // Compression algorithm to use, e.g. deflate_stream, "" for none
+	FetchSize               uint64            // Rows Query fetches at a time through a server side cursor, 0 to read all the rows as they are sent
+	CompactMetadata         bool              // Ask for column types only once the names of a statement's columns are known, see metadata.go
+}
+
+// NewConfig returns a Config with the same default values that
+// ParseDSN uses.
+func NewConfig() *Config {
+	return &Config{
+		Net:                "tcp",
+		Addr:               "127.0.0.1:33060",
+		Loc:                time.UTC,
+		Collation:          defaultCollation,
+		UseXProtocol:       true,
+		UseGetCapabilities: true,
+		AuthMechanism:      defaultAuthMechanism,
+	}
+}
+
+// normalize checks the Config and sets the default values of any
+// settings which have not been given.
+func (cfg *Config) normalize() error {
+	if cfg.Collation == 0 {
+		cfg.Collation = defaultCollation
+	}
+	if cfg.InterpolateParams && unsafeCollations[cfg.Collation] {
+		return errInvalidDSNUnsafeCollation
+	}
+	if cfg.ConvertCharset {
+		if _, err := collationEncoding(uint64(cfg.Collation)); err != nil {
+			return fmt.Errorf("Invalid DSN: convertCharset: %v", err)
+		}
+	}
+
+	// Set default network if empty
+	if cfg.Net == "" {
+		cfg.Net = "tcp"
+	}
+
+	// A list of hosts may be given in place of the address
+	if len(cfg.Hosts) == 0 && isHostList(cfg.Addr) {
+		defaultPort := ""
+		if cfg.UseXProtocol {
+			defaultPort = defaultXPort
+		}
+		hosts, err := parseHostList(cfg.Addr, defaultPort)
+		if err != nil {
+			return err
+		}
+		cfg.Hosts = hosts
+	}
+	if len(cfg.Hosts) > 0 {
+		cfg.Net, cfg.Addr = cfg.Hosts[0].Net, cfg.Hosts[0].Addr
+	}
+
+	// Set default address if empty
+	if cfg.Addr == "" {
+		switch cfg.Net {
+		case "tcp":
+			if cfg.UseXProtocol {
+				cfg.Addr = "127.0.0.1:33060" // Xprotocol default port 33060
+			} else {
+				cfg.Addr = "127.0.0.1:3306"
+			}
+		case "unix":
+			cfg.Addr = "/tmp/mysql.sock"
+		default:
+			return errors.New("Default addr for network '" + cfg.Net + "' unknown")
+		}
+	}
+
+	if cfg.Loc == nil {
+		cfg.Loc = time.UTC
+	}
+	if cfg.AuthMechanism == "" {
+		cfg.AuthMechanism = defaultAuthMechanism
+	}
+	if !isSupportedAuthMechanism(cfg.AuthMechanism) {
+		return fmt.Errorf("Invalid or unsupported authentication mechanism: %s", cfg.AuthMechanism)
+	}
+	if _, found := compressors[cfg.Compression]; cfg.Compression != "" && !found {
+		return fmt.Errorf("Unknown compression algorithm: %s", cfg.Compression)
+	}
+
+	return nil
+}
+
+// Clone returns a copy of the Config. The Params map and Hosts are
+// copied, the TLS configuration, location and Hooks are shared.
+func (cfg *Config) Clone() *Config {
+	cp := *cfg
+	if cfg.Hosts != nil {
+		cp.Hosts = make([]Host, len(cfg.Hosts))
+		copy(cp.Hosts, cfg.Hosts)
+	}
+	if cfg.Params != nil {
+		cp.Params = make(map[string]string, len(cfg.Params))
+		for k, v := range cfg.Params {
+			cp.Params[k] = v
+		}
+	}
+	return &cp
+}
+
+// FormatDSN formats the given Config into a DSN string which can be
+// passed to the driver. A TLS configuration set directly in TLS
+// without a matching TLSConfig name, a DialContext function, a Logger
+// and Hooks can not be represented and are not included.
+func (cfg *Config) FormatDSN() string {
+	var buf bytes.Buffer
+
+	// [username[:password]@]
+	if len(cfg.User) > 0 {
+		buf.WriteString(cfg.User)
+		if len(cfg.Passwd) > 0 {
+			buf.WriteByte(':')
+			buf.WriteString(cfg.Passwd)
+		}
+		buf.WriteByte('@')
+	}
+
+	// [protocol[(address)]]
+	if len(cfg.Hosts) > 1 {
+		buf.WriteString("tcp(")
+		buf.WriteString(formatHostList(cfg.Hosts))
+		buf.WriteByte(')')
+	} else if len(cfg.Net) > 0 {
+		buf.WriteString(cfg.Net)
+		if len(cfg.Addr) > 0 {
+			buf.WriteByte('(')
+			buf.WriteString(cfg.Addr)
+			buf.WriteByte(')')
+		}
+	}
+
+	// /dbname
+	buf.WriteByte('/')
+	buf.WriteString(cfg.DBName)
+
+	// [?param1=value1&...&paramN=valueN]
+	hasParam := false
+	writeParam := func(name, value string) {
+		if hasParam {
+			buf.WriteByte('&')
+		} else {
+			hasParam = true
+			buf.WriteByte('?')
+		}
+		buf.WriteString(name)
+		buf.WriteByte('=')
+		buf.WriteString(value)
+	}
+
+	if cfg.AllowAllFiles {
+		writeParam("allowAllFiles", "true")
+	}
+	if cfg.AllowCleartextPasswords {
+		writeParam("allowCleartextPasswords", "true")
+	}
+	if cfg.AuthMechanism != "" && cfg.AuthMechanism != defaultAuthMechanism {
+		writeParam("authMechanism", cfg.AuthMechanism)
+	}
+	if cfg.Collation != defaultCollation {
+		if name := collationName(cfg.Collation); name != "" {
+			writeParam("collation", name)
+		}
+	}
+	if cfg.ColumnsWithAlias {
+		writeParam("columnsWithAlias", "true")
+	}
+	if cfg.ColumnsOriginalName {
+		writeParam("columnsOriginalName", "true")
+	}
+	if cfg.CompactMetadata {
+		writeParam("compactMetadata", "true")
+	}
+	if cfg.Compression != "" {
+		writeParam("compress", cfg.Compression)
+	}
+	if cfg.ConvertCharset {
+		writeParam("convertCharset", "true")
+	}
+	if cfg.FetchSize > 0 {
+		writeParam("fetchSize", strconv.FormatUint(cfg.FetchSize, 10))
+	}
+	if !cfg.UseGetCapabilities {
+		writeParam("getCapabilities", "false")
+	}
+	if cfg.InterpolateParams {
+		writeParam("interpolateParams", "true")
+	}
+	if cfg.RandomizeHosts {
+		writeParam("randomizeHosts", "true")
+	}
+	if cfg.RouteQueries {
+		writeParam("routeQueries", "true")
+	}
+	if cfg.RouteReadOnly {
+		writeParam("routeReadOnly", "true")
+	}
+	if cfg.Loc != nil && cfg.Loc != time.UTC {
+		writeParam("loc", url.QueryEscape(cfg.Loc.String()))
+	}
+	if cfg.LogLevel != LogError {
+		writeParam("logLevel", cfg.LogLevel.String())
+	}
+	if cfg.Timeout > 0 {
+		writeParam("timeout", cfg.Timeout.String())
+	}
+	if len(cfg.TLSConfig) > 0 {
+		writeParam("tls", url.QueryEscape(cfg.TLSConfig))
+	}
+	if cfg.WireTap != "" {
+		writeParam("wireTap", url.QueryEscape(cfg.WireTap))
+	}
+	if cfg.UseXProtocol {
+		writeParam("xprotocol", "1")
+	}
+
+	// other params, sorted so the output is stable
+	if cfg.Params != nil {
+		keys := make([]string, 0, len(cfg.Params))
+		for k := range cfg.Params {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			writeParam(k, url.QueryEscape(cfg.Params[k]))
+		}
+	}
+
+	return buf.String()
 }
@@ -12,12 +12,15 @@
 package mysql
 
 import (
+	"context"
+	"crypto/tls"
+	"database/sql"
 	"database/sql/driver"
 	"errors"
 	"fmt"
-	"log"
 	"net"
 	"strings"
+	"time"
 
 	"github.com/golang/protobuf/proto"
 
@@ -26,7 +29,11 @@ import (
 	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Sql"
 
 	"github.com/sjmudd/go-mysqlx-driver/capability"
-	"github.com/sjmudd/go-mysqlx-driver/debug"
+	"github.com/sjmudd/go-mysqlx-driver/wiretap"
+)
+
+var (
+	errNamedArgs = errors.New("named args are not supported")
 )
 
 type mysqlXConn struct {
@@ -46,33 +53,64 @@ type mysqlXConn struct {
 	state          queryState
 	capabilities   capability.ServerCapabilities
 	systemVariable []byte
+	onGlobalNotice func()   // called when a notice with global scope is received
+	notices        []Notice // notices received since the last statement was sent
+	log            *logger  // nil logs errors only, to errLog
+	stats          connStats
+	collected      connStats     // stats as last passed to the StatsCollector
+	hooks          *Hooks        // nil if there are none
+	running        *queryHook    // the statement the hooks are being told about
+	compressor     Compressor    // nil unless compression has been negotiated
+	uncompressed   []byte        // frames of a COMPRESSION frame not yet read
+	lastStmtID     uint32        // of the last statement prepared for a cursor
+	metadata       metadataCache // column names of statements run asking for compact metadata
+
+	// followed for interpolateParams
+	session sessionVariables
 }
 
 func (mc *mysqlXConn) capabilityTestUnknownCapability() error {
 	name := "randomCapability"
-	debug.Msg("Testing setting a random unknown tls capability")
+	if mc.log.on(LogDebug) {
+		mc.log.printf(LogDebug, "Testing setting a random unknown tls capability")
+	}
 	err := mc.setScalarBoolCapability(name, true)
 	if err != nil {
-		debug.Msg("capabilityTestUnknownCapability fails: %v", err)
+		if mc.log.on(LogDebug) {
+			mc.log.printf(LogDebug, "capabilityTestUnknownCapability fails: %v", err)
+		}
 	}
 	return err
 }
 
 // second stage of the open once the driver has been selecteed
-func (mc *mysqlXConn) Open2() (driver.Conn, error) {
+func (mc *mysqlXConn) Open2(ctx context.Context) (driver.Conn, error) {
 	var err error
+	start := time.Now()
 
 	// Connect to Server
-	if dial, ok := dials[mc.cfg.net]; ok {
+	if mc.cfg.dialContext != nil {
+		mc.netConn, err = mc.cfg.dialContext(ctx, mc.cfg.net, mc.cfg.addr)
+	} else if dial, ok := dials[mc.cfg.net]; ok {
 		mc.netConn, err = dial(mc.cfg.addr)
 	} else {
 		nd := net.Dialer{Timeout: mc.cfg.timeout}
-		mc.netConn, err = nd.Dial(mc.cfg.net, mc.cfg.addr)
+		mc.netConn, err = nd.DialContext(ctx, mc.cfg.net, mc.cfg.addr)
 	}
 	if err != nil {
 		return nil, err
 	}
 
+	// Don't let the handshake run past the context deadline
+	if deadline, ok := ctx.Deadline(); ok {
+		mc.netConn.SetDeadline(deadline)
+		defer func() {
+			if mc.netConn != nil {
+				mc.netConn.SetDeadline(time.Time{})
+			}
+		}()
+	}
+
 	// Enable TCP Keepalives on TCP connections
 	if tc, ok := mc.netConn.(*net.TCPConn); ok {
 		if err := tc.SetKeepAlive(true); err != nil {
@@ -83,11 +121,21 @@ func (mc *mysqlXConn) Open2() (driver.Conn, error) {
 		}
 	}
 
+	// Record the frames sent and received if asked to
+	if mc.cfg.tap != nil {
+		mc.netConn = mc.cfg.tap.Conn(mc.netConn)
+	}
+
 	mc.buf = newBuffer(mc.netConn)
 
-	// could/should be optional for performance? e.g. dsn has get_capabilities=0
-	if err := mc.getCapabilities(); err != nil {
-		return nil, fmt.Errorf("mysqlXConn.Open2: getCapabilities() failed: %v", err)
+	// Skipping CapabilitiesGet saves a round trip but we must then
+	// trust the DSN to tell us what the server supports.
+	if mc.cfg.useGetCapabilities {
+		if err := mc.getCapabilities(); err != nil {
+			return nil, fmt.Errorf("mysqlXConn.Open2: getCapabilities() failed: %v", err)
+		}
+	} else {
+		mc.assumeCapabilities()
 	}
 
 	// can do some random checks here.
@@ -95,6 +143,24 @@ func (mc *mysqlXConn) Open2() (driver.Conn, error) {
 	//		return nil, fmt.Errorf("mysqlXConn.Open2: could not set unknown capability")
 	//	}
 
+	// Switch to TLS before sending any credentials
+	if mc.cfg.tls != nil {
+		if err := mc.startTLS(); err != nil {
+			mc.netConn.Close()
+			mc.netConn = nil
+			return nil, fmt.Errorf("mysqlXConn.Open2: %v", err)
+		}
+	}
+
+	// Compress everything after the handshake, credentials included
+	if mc.cfg.compression != "" {
+		if err := mc.startCompression(); err != nil {
+			mc.netConn.Close()
+			mc.netConn = nil
+			return nil, fmt.Errorf("mysqlXConn.Open2: %v", err)
+		}
+	}
+
 	if !mc.capabilities.Exists("authentication.mechanisms") {
 		return nil, fmt.Errorf("mysqlXConn.Open2: did not find capability: authentication.mechanisms")
 	}
@@ -106,25 +172,27 @@ func (mc *mysqlXConn) Open2() (driver.Conn, error) {
 	//   "node_type"                 (scalar string)
 	//   "plugin.version"            (scalar string)
 	//   "client.pwd_expire_ok"      (scalar bool)
+	// and from 8.0.19:
+	//   "compression.algorithm"     (array string)
 
-	// Check and use the first one we can. SHOULD prioritise.
+	// Check the server offers the mechanism we have been configured to use.
 	values := mc.capabilities.Values("authentication.mechanisms")
 
-	//	debug.Msg("authentication.mechanisms found: %+v", values)
-
 	found := false
 	for i := range values {
-		if values[i].String() == "MYSQL41" {
+		if values[i].String() == mc.cfg.authMechanism {
 			found = true
-			if err := mc.AuthenticateMySQL41(); err != nil {
-				return nil, fmt.Errorf("Authentication failed: %v", err)
-			}
 			break
 		}
 	}
 	if !found {
-		return nil, fmt.Errorf("mysqlXConn.Open2: could not find authentication.mechanism I can deal with. Found: %+v", values)
+		return nil, fmt.Errorf("mysqlXConn.Open2: authentication.mechanism %s not offered by server. Found: %+v", mc.cfg.authMechanism, values)
+	}
+	authStart := time.Now()
+	if err := mc.authenticate(); err != nil {
+		return nil, fmt.Errorf("Authentication failed: %v", err)
 	}
+	mc.stats.authTime = time.Since(authStart)
 
 	//	// Get max allowed packet size
 	//	maxap, err := mc.getSystemVar("mysqlx_max_allowed_packet") // NOT THE SAME AS max_allowed_packet !!
@@ -143,10 +211,105 @@ func (mc *mysqlXConn) Open2() (driver.Conn, error) {
 		mc.Close()
 		return nil, err
 	}
+	if mc.cfg.interpolateParams {
+		if err := mc.readSessionVariables(); err != nil {
+			mc.Close()
+			return nil, fmt.Errorf("mysqlXConn.Open2: %v", err)
+		}
+	}
+
+	mc.stats.connects = 1
+	mc.stats.connectTime = time.Since(start)
+	mc.collectStats()
 
 	return mc, nil
 }
 
+// assumeCapabilities fills in the capabilities we would have expected
+// the server to return based on the DSN settings. Used when the
+// CapabilitiesGet round trip has been disabled.
+func (mc *mysqlXConn) assumeCapabilities() {
+	if mc.log.on(LogDebug) {
+		mc.log.printf(LogDebug, "mysqlXConn.assumeCapabilities: not asking server, assuming authentication.mechanisms: %s, tls: %v, compression.algorithm: %q", mc.cfg.authMechanism, mc.cfg.tls != nil, mc.cfg.compression)
+	}
+
+	mc.capabilities.AddScalarString("authentication.mechanisms", mc.cfg.authMechanism)
+	if mc.cfg.tls != nil {
+		mc.capabilities.AddScalarBool("tls", true)
+	}
+	if mc.cfg.compression != "" {
+		mc.capabilities.AddScalarString("compression.algorithm", mc.cfg.compression)
+	}
+}
+
+// startTLS asks the server to switch to TLS by setting the tls
+// capability and then performs the TLS handshake on the connection.
+func (mc *mysqlXConn) startTLS() error {
+	if !mc.capabilities.Exists("tls") {
+		return ErrNoTLS
+	}
+
+	if mc.log.on(LogDebug) {
+		mc.log.printf(LogDebug, "Enabling tls via CapabilitySet")
+	}
+	if err := mc.setScalarBoolCapability("tls", true); err != nil {
+		return fmt.Errorf("failed to set tls capability: %v", err)
+	}
+
+	tlsConfig := mc.cfg.tls
+	if len(tlsConfig.ServerName) == 0 && !tlsConfig.InsecureSkipVerify {
+		if host, _, err := net.SplitHostPort(mc.cfg.addr); err == nil {
+			tlsConfig = tlsConfig.Clone()
+			tlsConfig.ServerName = host
+		}
+	}
+
+	// A wire tap stays on top of TLS so the frames are recorded in clear
+	tc, tapped := mc.netConn.(*wiretap.Conn)
+	rawConn := mc.netConn
+	if tapped {
+		rawConn = tc.Conn
+	}
+
+	tlsConn := tls.Client(rawConn, tlsConfig)
+	if err := tlsConn.Handshake(); err != nil {
+		return fmt.Errorf("TLS handshake failed: %v", err)
+	}
+	if tapped {
+		tc.Conn = tlsConn
+	} else {
+		mc.netConn = tlsConn
+	}
+	mc.buf = newBuffer(mc.netConn)
+	if mc.log.on(LogDebug) {
+		mc.log.printf(LogDebug, "tls enabled")
+	}
+
+	return nil
+}
+
+// authenticate using the configured authentication mechanism
+func (mc *mysqlXConn) authenticate() error {
+	switch mc.cfg.authMechanism {
+	case "MYSQL41":
+		return mc.AuthenticateMySQL41()
+	case "PLAIN":
+		return mc.AuthenticatePlain()
+	case "SHA256_MEMORY":
+		return mc.AuthenticateSHA256Memory()
+	}
+	return fmt.Errorf("mysqlXConn.authenticate: unsupported authentication mechanism: %s", mc.cfg.authMechanism)
+}
+
+// isSupportedAuthMechanism returns true if the driver can authenticate with the named mechanism
+func isSupportedAuthMechanism(mechanism string) bool {
+	switch mechanism {
+	case "MYSQL41", "PLAIN", "SHA256_MEMORY":
+		return true
+	}
+	return false
+}
+
 // Gets the value of the given MySQL System Variable
 // FIXME FIXME FIXME
 // - Note this is broken as we need to send a normal SQL statement to get the values.
@@ -162,7 +325,9 @@ func (mc *mysqlXConn) getSystemVar(name string) ([]byte, error) {
 	if name == "mysqlx_max_allowed_packet" {
 		// hard-coded: should not be FIXME FIXME
 		response := "1048576"
-		debug.Msg("FIXME: Using hard-coded value for getSystemVar(%s): %s", name, response)
+		if mc.log.on(LogDebug) {
+			mc.log.printf(LogDebug, "FIXME: Using hard-coded value for getSystemVar(%s): %s", name, response)
+		}
 		return []byte(response), nil
 	}
 
@@ -205,52 +370,6 @@ func (mc *mysqlXConn) handleParams() (err error) {
 				return errors.New("Invalid Bool value: " + val)
 			}
 
-		// Compression
-		case "compress":
-			err = errors.New("Compression not implemented yet")
-			return
-
-		// TLS
-		case "tls":
-			// FIXME FIXME FIXME
-			//
-			// not sure about order and handling of TLS
-			// - do we try to go into TLS mode first?
-			// - do we go into TLS mode at the point that we process the parameter options?
-			// - do we go into TLS mode at the end of processing parameters, having remembered that we want to use TLS mode?
-			// - it may make sense to go into TLS mode early but that changes logic etc.
-			//
-			// FIXME FIXME FIXME
-
-			// check if the server has advertised tls capabilities
-			if !mc.capabilities.Exists("tls") {
-				return errors.New("Server does not support TLS")
-			}
-			debug.Msg("Checking tls capability")
-			tls := mc.capabilities.Values("tls")
-			debug.Msg("- got back %d value(s) for tls", len(tls))
-			if len(tls) == 1 {
-				return fmt.Errorf("server tls capability returns unexpected result: len(tls) = %d, expecting 1", len(tls))
-			}
-			tlsType := tls[0].Type()
-			debug.Msg("- tls has one value of type: %s", tlsType)
-			if tlsType != "bool" {
-				return fmt.Errorf("server tls capability type unexpected: %s, expecting bool", tlsType)
-			}
-			tlsValue := tls[0].Bool()
-			debug.Msg("- tls value: %v", tlsValue)
-
-			// Setup or check the magic TLS config here
-			// - should have been done by the app before
-
-			// Tell the server we want to go in TLS mode.
-			debug.Msg("Enabling tls via CapabilitySet")
-			if err := mc.setScalarBoolCapability("tls", tlsValue); err != nil {
-				return fmt.Errorf("Failed to set Capability TLS <fill in here>: %+v", err)
-			}
-			debug.Msg("Enabling tls though the code won't handle it yet...")
-			// wait for OK back and if we get it then we go into TLS mode
-
 		// System Vars
 		default:
 			err = mc.exec("SET " + param + "=" + val + "")
@@ -266,31 +385,70 @@ func (mc *mysqlXConn) handleParams() (err error) {
 // Internal function to execute commands when we don't expect a resultset
 // e.g. for sending commands.
 func (mc *mysqlXConn) exec(query string) error {
-	debug.Msg("mysqlXConn.exec(%q) called", query)
+	if mc.log.on(LogDebug) {
+		mc.log.printf(LogDebug, "mysqlXConn.exec(%q) called", query)
+	}
 
 	// Should be able to use normal "query logic" here
-	rows, err := mc.Query(query, nil)
+	rows, err := mc.stmtExecute(query, nil, false)
 	if err != nil {
 		return fmt.Errorf("mysqlXConn.exec failed: %+v", err)
 	}
-	debug.Msg("mysqlXConn.exec waiting for reponse")
+	if mc.log.on(LogDebug) {
+		mc.log.printf(LogDebug, "mysqlXConn.exec waiting for reponse")
+	}
 
 	// close the rows and handle any response packets received
 	return rows.Close()
 }
 
-// methods needed to make things work
+// Begin starts a transaction with the default options
 func (mc *mysqlXConn) Begin() (driver.Tx, error) {
-	return nil, fmt.Errorf("DEBUG: mysqlXConn.Begin() not implemented yet")
+	return mc.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+// BeginTx starts a transaction with the given isolation level and
+// access mode
+func (mc *mysqlXConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if mc.netConn == nil {
+		mc.log.print(ErrInvalidConn)
+		return nil, driver.ErrBadConn
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var level string
+	if opts.Isolation != driver.IsolationLevel(sql.LevelDefault) {
+		var err error
+		if level, err = isolationLevel(sql.IsolationLevel(opts.Isolation)); err != nil {
+			return nil, err
+		}
+	}
+
+	query := "START TRANSACTION"
+	if opts.ReadOnly {
+		query += " READ ONLY"
+	}
+	qh := mc.beforeQuery(ctx, "Begin", Mysqlx.ClientMessages_SQL_STMT_EXECUTE, query, nil)
+	var err error
+	if level != "" {
+		err = mc.exec("SET TRANSACTION ISOLATION LEVEL " + level)
+	}
+	if err == nil {
+		err = mc.exec(query)
+	}
+	qh.done(mc, err)
+	if err != nil {
+		return nil, err
+	}
+
+	return &mysqlXTx{mc: mc, ctx: ctx}, nil
 }
 
 // close the connection
 func (mc *mysqlXConn) Close() error {
-	//	debug.Msg("mysqlXConn.Close: entry")
-	if mc == nil {
-		debug.Msg("mysqlXConn: mc == nil")
-	}
-	if mc.netConn == nil {
+	if mc == nil || mc.netConn == nil {
 		return nil
 	}
 
@@ -319,47 +477,173 @@ func (mc *mysqlXConn) Close() error {
 				if err := proto.Unmarshal(pb.payload, ok); err != nil {
 					return fmt.Errorf("mysqlXConn.Close: Failed to read Ok: %v", err)
 				}
-				debug.Msg("Got response %s: msg: %s", printableMsgTypeIn(Mysqlx.ServerMessages_OK), ok.GetMsg())
+				if mc.log.on(LogDebug) {
+					mc.log.printf(LogDebug, "Got response %s: msg: %s", printableMsgTypeIn(Mysqlx.ServerMessages_OK), ok.GetMsg())
+				}
 				done = true
 			}
 		case Mysqlx.ServerMessages_ERROR:
 			return fmt.Errorf("mysqlXConn.Close received: %+v", err)
 		case Mysqlx.ServerMessages_NOTICE:
-			mc.processNotice("mysqlXConn.Close()") // process the notice message
+			mc.pb = pb
+			if err := mc.processNotice("mysqlXConn.Close()"); err != nil {
+				mc.cleanup()
+				return fmt.Errorf("mysqlXConn.Close failed: %v", err)
+			}
 		default:
-			debug.Msg("ignoring unexpected message: %s", printableMsgTypeIn(Mysqlx.ServerMessages_Type(pb.msgType)))
+			if mc.log.on(LogWarn) {
+				mc.log.printf(LogWarn, "ignoring unexpected message: %s", printableMsgTypeIn(Mysqlx.ServerMessages_Type(pb.msgType)))
+			}
 		}
 	}
 
-	mc.netConn.Close()
-	mc.netConn = nil
-
-	//	debug.Msg("mysqlXConn.Close: exit")
+	mc.cleanup()
 
 	return nil
 }
+
+// cleanup closes the network connection without telling the server.
+// It is used once the connection can no longer be trusted, as Close
+// would try to read from it again.
+func (mc *mysqlXConn) cleanup() {
+	if mc.netConn == nil {
+		return
+	}
+	mc.netConn.Close()
+	mc.netConn = nil
+	mc.collectStats()
+}
+
 func (mc *mysqlXConn) Prepare(query string) (driver.Stmt, error) {
 	return nil, fmt.Errorf("DEBUG: mysqlXConn.Prepare() not implemented yet")
 }
+
+// Exec runs a statement which does not return rows. The affected
+// rows and insert id are taken from the SessionStateChanged notices
+// sent by the server.
 func (mc *mysqlXConn) Exec(query string, args []driver.Value) (driver.Result, error) {
-	return nil, fmt.Errorf("DEBUG: mysqlXConn.Exec() not implemented yet")
+	return mc.execContext(context.Background(), query, args)
+}
+
+// ExecContext implements driver.ExecerContext
+func (mc *mysqlXConn) ExecContext(ctx context.Context, query string, named []driver.NamedValue) (driver.Result, error) {
+	args, err := values(named)
+	if err != nil {
+		return nil, err
+	}
+	return mc.execContext(ctx, query, args)
+}
+
+func (mc *mysqlXConn) execContext(ctx context.Context, query string, args []driver.Value) (driver.Result, error) {
+	if mc.netConn == nil {
+		mc.log.print(ErrInvalidConn)
+		return nil, driver.ErrBadConn
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	mc.affectedRows, mc.insertID = 0, 0
+	qh := mc.beforeQuery(ctx, "Exec", Mysqlx.ClientMessages_SQL_STMT_EXECUTE, query, args)
+	query, args, err := mc.interpolate(query, args)
+	var rows *mysqlXRows
+	if err == nil {
+		rows, err = mc.stmtExecute(query, args, false)
+	}
+	if err == nil {
+		err = rows.Close()
+	}
+	if qh != nil {
+		qh.info.RowsAffected = int64(mc.affectedRows)
+	}
+	qh.done(mc, err)
+	if err != nil {
+		return nil, err
+	}
+
+	return &mysqlResult{
+		affectedRows: int64(mc.affectedRows),
+		insertId:     int64(mc.insertID),
+	}, nil
 }
 
 // Query is the public interface to making a query via database/sql
 func (mc *mysqlXConn) Query(query string, args []driver.Value) (driver.Rows, error) {
+	return mc.queryContext(context.Background(), query, args)
+}
+
+// QueryContext implements driver.QueryerContext
+func (mc *mysqlXConn) QueryContext(ctx context.Context, query string, named []driver.NamedValue) (driver.Rows, error) {
+	args, err := values(named)
+	if err != nil {
+		return nil, err
+	}
+	return mc.queryContext(ctx, query, args)
+}
+
+func (mc *mysqlXConn) queryContext(ctx context.Context, query string, args []driver.Value) (driver.Rows, error) {
 	if mc.netConn == nil {
-		errLog.Print(ErrInvalidConn)
+		mc.log.print(ErrInvalidConn)
 		return nil, driver.ErrBadConn
 	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// compact metadata is only asked for once the column names are known
+	wantCompact := mc.wantCompactMetadata(ctx)
+	compact := wantCompact && mc.cachedMetadata(query) != nil
 
-	if len(args) > 0 {
-		debug.Msg("WARNING not processing args for mysqlXConn.Query()")
+	message := Mysqlx.ClientMessages_SQL_STMT_EXECUTE
+	if mc.cfg.fetchSize > 0 {
+		message = Mysqlx.ClientMessages_CURSOR_OPEN
+	}
+	qh := mc.beforeQuery(ctx, "Query", message, query, args)
+
+	// the metadata is kept under the statement before interpolation
+	stmt, stmtArgs, err := mc.interpolate(query, args)
+	var rows *mysqlXRows
+	if err == nil {
+		if mc.cfg.fetchSize > 0 {
+			rows, err = mc.openCursor(stmt, stmtArgs, compact)
+		} else {
+			rows, err = mc.stmtExecute(stmt, stmtArgs, compact)
+		}
+	}
+	if err != nil {
+		qh.done(mc, err)
+		return nil, err
+	}
+	if wantCompact {
+		rows.query = query
+		rows.compact = compact
 	}
+	// the hooks are told the statement has finished when the rows are closed
+	rows.hook = qh
+	return rows, nil
+}
 
-	debug.Msg("mysqlXConn.Query(%s,...)", query)
+// stmtExecute sends the query and returns the rows to read the result
+// from, asking for compact metadata if compact is true. The hooks are
+// not called so it is also used for the statements the driver runs
+// itself.
+func (mc *mysqlXConn) stmtExecute(query string, args []driver.Value, compact bool) (*mysqlXRows, error) {
+	if mc.log.on(LogDebug) {
+		mc.log.printf(LogDebug, "mysqlXConn.Query(%s,...)", query)
+	}
+
+	encodedArgs, err := mc.encodeArgs(args)
+	if err != nil {
+		return nil, fmt.Errorf("mysqlXConn.Query(%q,...): %v", query, err)
+	}
+	mc.notices = nil
 
 	stmtExecute := &Mysqlx_Sql.StmtExecute{
 		Stmt: []byte(query),
+		Args: encodedArgs,
+	}
+	if compact {
+		stmtExecute.CompactMetadata = proto.Bool(true)
 	}
 
 	// write a StmtExecute packet with the given query to the network
@@ -377,10 +661,26 @@ func (mc *mysqlXConn) Query(query string, args []driver.Value) (driver.Rows, err
 	}, nil
 }
 
+// values returns the args of a QueryContext or ExecContext call,
+// which can only be given by position
+func values(named []driver.NamedValue) ([]driver.Value, error) {
+	if len(named) == 0 {
+		return nil, nil
+	}
+	args := make([]driver.Value, len(named))
+	for i, nv := range named {
+		if nv.Name != "" {
+			return nil, errNamedArgs
+		}
+		args[i] = nv.Value
+	}
+	return args, nil
+}
+
 func printableColumnMetaData(pb *netProtobuf) string {
 	p := new(Mysqlx_Resultset.ColumnMetaData)
 	if err := proto.Unmarshal(pb.payload, p); err != nil {
-		log.Fatalf("error unmarshaling ColumnMetaData p: %v", err)
+		return fmt.Sprintf("invalid ColumnMetaData: %v", err)
 	}
 
 	return fmt.Sprintf("Type: %v, Name: %q, OriginalName: %q, Table: %q, Schema: %q, Catalog: %q, Collation: %v, FractionalDigits: %v, Length: %v, Flags: %v, ContentType: %v",
@@ -0,0 +1,162 @@
+// Go driver for MySQL X Protocol
+//
+// Copyright 2016 Simon J Mudd.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sjmudd/go-mysqlx-driver/capability"
+	"github.com/sjmudd/go-mysqlx-driver/wiretap"
+)
+
+// connector implements driver.Connector and holds the configuration
+// used for every connection in a sql.DB pool.
+type connector struct {
+	cfg      *Config
+	topology *topology         // roles of the hosts, shared by all connections
+	tap      *wiretap.Recorder // records the frames of all connections if set
+	log      *logger           // shared by all connections
+}
+
+// NewConnector returns a driver.Connector for the given Config which
+// can be passed to sql.OpenDB. The Config is copied so later changes
+// made by the caller do not affect the connector.
+//
+//	cfg := mysql.NewConfig()
+//	cfg.User = "user"
+//	cfg.Passwd = "pass"
+//	cfg.DBName = "test"
+//	connector, err := mysql.NewConnector(cfg)
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	db := sql.OpenDB(connector)
+func NewConnector(cfg *Config) (driver.Connector, error) {
+	if cfg == nil {
+		return nil, errors.New("NewConnector: cfg is nil")
+	}
+	cfg = cfg.Clone()
+	cfg.UseXProtocol = true // force X protocol as this driver was called explicitly
+	if err := cfg.normalize(); err != nil {
+		return nil, fmt.Errorf("NewConnector: %v", err)
+	}
+
+	log := newLogger(cfg)
+	c := &connector{cfg: cfg, topology: newTopology(log), log: log}
+	if cfg.WireTap != "" {
+		var err error
+		if c.tap, err = openWireTap(cfg.WireTap); err != nil {
+			return nil, fmt.Errorf("NewConnector: wire tap: %v", err)
+		}
+	}
+	return c, nil
+}
+
+// Connect implements driver.Connector. If several hosts are
+// configured each is tried in turn until one accepts the connection
+// and completes the handshake. If read/write routing is enabled the
+// returned connection may use more than one host, see routing.go.
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	if c.routing() {
+		return c.connectRouted(ctx)
+	}
+
+	mc, err := c.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return mc, nil
+}
+
+// connect connects to any of the configured hosts, ignoring their roles
+func (c *connector) connect(ctx context.Context) (*mysqlXConn, error) {
+	hosts := c.cfg.Hosts
+	if len(hosts) == 0 {
+		hosts = []Host{{Net: c.cfg.Net, Addr: c.cfg.Addr}}
+	}
+	return c.connectAny(ctx, hosts, func(*mysqlXConn) bool { return true })
+}
+
+// connectAny connects to the first of the hosts which accepts the
+// connection and for which accept returns true. Connections which are
+// not accepted are closed.
+func (c *connector) connectAny(ctx context.Context, hosts []Host, accept func(*mysqlXConn) bool) (*mysqlXConn, error) {
+	var errs []string
+	var lastErr error
+	for _, host := range orderHosts(hosts, c.cfg.RandomizeHosts) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		mc, err := c.connectHost(ctx, host)
+		if err == nil {
+			if accept(mc) {
+				return mc, nil
+			}
+			mc.Close()
+			err = errWrongRole
+		}
+		if c.log.on(LogDebug) {
+			c.log.printf(LogDebug, "connector.Connect: skipping %s(%s): %v", host.Net, host.Addr, err)
+		}
+		lastErr = err
+		errs = append(errs, fmt.Sprintf("%s(%s): %v", host.Net, host.Addr, err))
+	}
+
+	if len(hosts) == 1 {
+		return nil, lastErr
+	}
+	return nil, fmt.Errorf("unable to connect to any host: %s", strings.Join(errs, "; "))
+}
+
+// connectHost makes a connection to a single host. The configured
+// timeout covers both the dial and the handshake.
+func (c *connector) connectHost(ctx context.Context, host Host) (*mysqlXConn, error) {
+	if c.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.cfg.Timeout)
+		defer cancel()
+	}
+
+	cfg := NewXconfigFromConfig(c.cfg)
+	cfg.net, cfg.addr = host.Net, host.Addr
+	cfg.tap = c.tap
+
+	// New mysqlConn
+	mc := &mysqlXConn{
+		capabilities:     capability.NewServerCapabilities(),
+		cfg:              cfg,
+		maxPacketAllowed: maxPacketSize,
+		maxWriteSize:     maxPacketSize - 1,
+		onGlobalNotice:   c.topology.invalidate,
+		log:              c.log,
+		hooks:            c.cfg.Hooks,
+	}
+	start := time.Now()
+	_, err := mc.Open2(ctx)
+	c.onConnect(ctx, host, start, err)
+	if err != nil {
+		if mc.netConn != nil {
+			// don't leak the socket if the handshake failed
+			mc.netConn.Close()
+			mc.netConn = nil
+		}
+		return nil, err
+	}
+	return mc, nil
+}
+
+// Driver implements driver.Connector
+func (c *connector) Driver() driver.Driver {
+	return &XDriver{}
+}
@@ -15,6 +15,20 @@ const (
 	maxPacketSize = 1<<32 - 1 // adjusted for X protocol
 	minPacketSize = 1         // adjusted for X protocol, see http://bugs.mysql.com/82862
 	timeFormat    = "2006-01-02 15:04:05.999999"
+
+	// largest message accepted from the server, the most
+	// mysqlx_max_allowed_packet can be set to
+	maxReadPacketSize = 1 << 30
+
+	defaultAuthMechanism = "MYSQL41" // X protocol authentication mechanism used if none is given
+)
+
+// content types of BYTES columns and of octets sent as args, see
+// Mysqlx.Resultset.ContentType_BYTES
+const (
+	contentTypeGeometry = 1 // WKB encoding
+	contentTypeJSON     = 2 // text encoding
+	contentTypeXML      = 3 // text encoding
 )
 
 // MySQL constants documentation:
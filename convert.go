@@ -12,6 +12,7 @@ package mysql
 
 import (
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
 	"math"
 
@@ -20,7 +21,6 @@ import (
 	"github.com/golang/protobuf/proto"
 
 	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Resultset"
-	"github.com/sjmudd/go-mysqlx-driver/debug"
 )
 
 // decodeZigzag64 reads a zigzag-encoded 64-bit integer
@@ -39,7 +39,6 @@ func mysql_sint_to_Int(data []byte) (signed int64, e error) {
 	}
 
 	signed = decodeZigzag64(myint)
-	debug.Msg("SINT: '% x' -> %d", data, signed)
 
 	return signed, e
 }
@@ -50,23 +49,26 @@ func mysql_uint_to_Uint(data []byte) (unsigned uint64, e error) {
 	if num == 0 {
 		e = fmt.Errorf("Unable to decode '% x' as varint. unsigned: %+v", data, unsigned)
 	}
-	debug.Msg("UINT: '% x' -> %d", data, unsigned)
 
 	return unsigned, e
 }
 
 // MySQL float to float32
 func mysql_float_to_float32(data []byte) (float32, error) {
+	if len(data) != 4 {
+		return 0, fmt.Errorf("Unable to decode '% x' as float, expected 4 bytes", data)
+	}
 	f := math.Float32frombits(binary.LittleEndian.Uint32(data[:]))
-	debug.Msg("FLOAT: '% x' -> %.3f", data, f)
 
 	return f, nil
 }
 
 // MySQL double to float64
 func mysql_double_to_float64(data []byte) (float64, error) {
+	if len(data) != 8 {
+		return 0, fmt.Errorf("Unable to decode '% x' as double, expected 8 bytes", data)
+	}
 	f := math.Float64frombits(binary.LittleEndian.Uint64(data[:]))
-	debug.Msg("DOUBLE: '% x' -> %.3f", data, f)
 
 	return f, nil
 }
@@ -76,15 +78,144 @@ func mysql_bytes_to_bytes(data []byte) ([]byte, error) {
 	// 012345
 	// ABCDEF\x00 (len 7)
 	dest := data[0 : len(data)-1] // not copying to avoid overhead (is this ok?), just using a shorter slice
-	debug.Msg("BYTES: '% x'", dest)
 
 	return dest, nil
 }
 
+// convertBytes returns a BYTES column according to its content type:
+// JSON as json.RawMessage, GEOMETRY as Geometry and anything else as
+// []byte. The JSON is copied as json.RawMessage is not copied by
+// database/sql when scanned.
+func convertBytes(column *Mysqlx_Resultset.ColumnMetaData, data []byte) (driver.Value, error) {
+	b, err := mysql_bytes_to_bytes(data)
+	if err != nil {
+		return nil, err
+	}
+	switch column.GetContentType() {
+	case contentTypeJSON:
+		return json.RawMessage(append([]byte(nil), b...)), nil
+	case contentTypeGeometry:
+		return parseGeometry(b)
+	}
+	return b, nil
+}
+
+// decodeDecimal returns a DECIMAL as text, e.g. -12.3401, so that it
+// can be scanned into a Decimal without passing through a float64. The
+// value is a byte holding the scale followed by packed BCD digits and a
+// sign nibble, 0xc for + and 0xd for -, padded with a 0 nibble to a
+// whole byte.
+func decodeDecimal(data []byte) ([]byte, error) {
+	if len(data) < 2 {
+		return nil, fmt.Errorf("Unable to decode '% x' as decimal, too short", data)
+	}
+	scale := int(data[0])
+	digits := make([]byte, 0, 2*len(data))
+	negative := false
+	signed := false
+	for i, b := range data[1:] {
+		for _, nibble := range []byte{b >> 4, b & 0x0f} {
+			switch {
+			case signed:
+				if nibble != 0 || i != len(data)-2 {
+					return nil, fmt.Errorf("Unable to decode '% x' as decimal, data after the sign", data)
+				}
+			case nibble <= 9:
+				digits = append(digits, '0'+nibble)
+			case nibble == 0x0c || nibble == 0x0d:
+				negative, signed = nibble == 0x0d, true
+			default:
+				return nil, fmt.Errorf("Unable to decode '% x' as decimal, invalid digit %x", data, nibble)
+			}
+		}
+	}
+	if !signed || len(digits) == 0 {
+		return nil, fmt.Errorf("Unable to decode '% x' as decimal, no digits or sign", data)
+	}
+
+	// at least one digit before the point and no other leading zeros
+	for len(digits) <= scale {
+		digits = append([]byte{'0'}, digits...)
+	}
+	for len(digits) > scale+1 && digits[0] == '0' {
+		digits = digits[1:]
+	}
+	text := make([]byte, 0, len(digits)+2)
+	if negative {
+		text = append(text, '-')
+	}
+	text = append(text, digits[:len(digits)-scale]...)
+	if scale > 0 {
+		text = append(text, '.')
+		text = append(text, digits[len(digits)-scale:]...)
+	}
+	return text, nil
+}
+
+// decodeSet returns a SET as text, its members separated by commas.
+// The value is a sequence of length prefixed members, except for 0x01
+// alone which is the empty set.
+func decodeSet(data []byte) ([]byte, error) {
+	if len(data) == 1 && data[0] == 0x01 {
+		return []byte{}, nil
+	}
+	var text []byte
+	for len(data) > 0 {
+		n, size := proto.DecodeVarint(data)
+		if size == 0 || n > uint64(len(data)-size) {
+			return nil, fmt.Errorf("Unable to decode '% x' as set", data)
+		}
+		if text != nil {
+			text = append(text, ',')
+		} else {
+			text = []byte{}
+		}
+		text = append(text, data[size:size+int(n)]...)
+		data = data[size+int(n):]
+	}
+	return text, nil
+}
+
+// decodeTime returns a TIME as text, e.g. -838:59:59.000000, with as
+// many fractional digits as the column has. The value is a sign byte,
+// 0x00 for + and 0x01 for -, followed by varints for the hours, minutes,
+// seconds and microseconds, those to the right left out when 0.
+func decodeTime(column *Mysqlx_Resultset.ColumnMetaData, data []byte) ([]byte, error) {
+	if data[0] > 1 {
+		return nil, fmt.Errorf("Unable to decode '% x' as time, invalid sign", data)
+	}
+	var parts [4]uint64
+	rest := data[1:]
+	for i := 0; len(rest) > 0; i++ {
+		v, size := proto.DecodeVarint(rest)
+		if size == 0 || i == len(parts) {
+			return nil, fmt.Errorf("Unable to decode '% x' as time", data)
+		}
+		parts[i] = v
+		rest = rest[size:]
+	}
+	if parts[1] > 59 || parts[2] > 59 || parts[3] > 999999 {
+		return nil, fmt.Errorf("Unable to decode '% x' as time, out of range", data)
+	}
+
+	sign := ""
+	if data[0] == 1 {
+		sign = "-"
+	}
+	text := fmt.Sprintf("%s%02d:%02d:%02d", sign, parts[0], parts[1], parts[2])
+	digits := int(column.GetFractionalDigits())
+	if digits > 6 || digits == 0 && parts[3] != 0 {
+		digits = 6 // compact metadata does not say
+	}
+	if digits > 0 {
+		text += fmt.Sprintf(".%06d", parts[3])[:1+digits]
+	}
+	return []byte(text), nil
+}
+
 // for handling stuff we haven't done yet. Should become obsolete as I finish the code...
 func no_conversion(typeName string, data []byte) ([]byte, error) {
 	dest := data
-	debug.Msg("no conversion yet for %s: '% x'", typeName, data, dest)
 
 	//	if len(data) == 0 {
 	//		return nil, nil // this is a NULL result
@@ -95,15 +226,12 @@ func no_conversion(typeName string, data []byte) ([]byte, error) {
 
 // Handle the conversion from the MysQL type to the driver type
 func convertColumnData(column *Mysqlx_Resultset.ColumnMetaData, data []byte) (dest driver.Value, e error) {
-	debug.Msg("convertType: converting %s with bytes '% x'", column.GetType().String(), data)
-
 	// We don't expect data to be nil. Probably a bug?
 	if data == nil {
 		return nil, fmt.Errorf("convertColumnData: data == nil. Unexpected. Returning dest = nil")
 	}
 	// An empty slice implies NULL
 	if len(data) == 0 {
-		debug.Msg("convertColumnData: len(data) == 0, so NULL: returning dest = nil")
 		return nil, nil
 	}
 
@@ -116,16 +244,19 @@ func convertColumnData(column *Mysqlx_Resultset.ColumnMetaData, data []byte) (de
 	case Mysqlx_Resultset.ColumnMetaData_DOUBLE:
 		return mysql_double_to_float64(data)
 	case Mysqlx_Resultset.ColumnMetaData_BYTES:
-		return mysql_bytes_to_bytes(data)
+		return convertBytes(column, data)
 	case Mysqlx_Resultset.ColumnMetaData_FLOAT:
 		return mysql_float_to_float32(data)
-	//        ColumnMetaData_BYTES    ColumnMetaData_FieldType = 7
-	//        ColumnMetaData_TIME     ColumnMetaData_FieldType = 10
+	case Mysqlx_Resultset.ColumnMetaData_BIT:
+		return mysql_uint_to_Uint(data)
+	case Mysqlx_Resultset.ColumnMetaData_DECIMAL:
+		return decodeDecimal(data)
+	case Mysqlx_Resultset.ColumnMetaData_SET:
+		return decodeSet(data)
+	case Mysqlx_Resultset.ColumnMetaData_TIME:
+		return decodeTime(column, data)
 	//        ColumnMetaData_DATETIME ColumnMetaData_FieldType = 12
-	//        ColumnMetaData_SET      ColumnMetaData_FieldType = 15
 	//        ColumnMetaData_ENUM     ColumnMetaData_FieldType = 16
-	//        ColumnMetaData_BIT      ColumnMetaData_FieldType = 17
-	//        ColumnMetaData_DECIMAL  ColumnMetaData_FieldType = 18
 	default:
 		return no_conversion("BYTES", data)
 	}
@@ -0,0 +1,160 @@
+// Go driver for MySQL X Protocol
+//
+// Copyright 2016 Simon J Mudd.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+// This file reads the rows of a query through a server side cursor,
+// used when fetchSize is set. The statement is prepared and a cursor
+// opened on it. The server sends fetchSize rows, then
+// RESULTSET_FETCH_SUSPENDED and StmtExecuteOk, after which
+// mysqlXRows.Next asks for the next fetchSize rows with a Fetch. Rows
+// closed early close the cursor so only the rows already sent have to
+// be read, not the whole result. The server closes a cursor once it has
+// sent the last row. Either way the prepared statement is deallocated
+// when the rows are closed.
+
+import (
+	"database/sql/driver"
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx"
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Cursor"
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Prepare"
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Sql"
+)
+
+// cursor is a server side cursor the rows of a query are read through
+type cursor struct {
+	id     uint32 // of the cursor and of the prepared statement it reads
+	closed bool   // the server has closed the cursor
+}
+
+// openCursor prepares the query, opens a cursor on it and returns the
+// rows to read the result from, asking for compact metadata if compact
+// is true
+func (mc *mysqlXConn) openCursor(query string, args []driver.Value, compact bool) (*mysqlXRows, error) {
+	if mc.log.on(LogDebug) {
+		mc.log.printf(LogDebug, "mysqlXConn.openCursor(%s,...) fetching %d rows at a time", query, mc.cfg.fetchSize)
+	}
+
+	encodedArgs, err := mc.encodeArgs(args)
+	if err != nil {
+		return nil, fmt.Errorf("mysqlXConn.Query(%q,...): %v", query, err)
+	}
+	mc.notices = nil
+	mc.lastStmtID++
+	id := mc.lastStmtID
+
+	err = mc.writeMessage(Mysqlx.ClientMessages_PREPARE_PREPARE, &Mysqlx_Prepare.Prepare{
+		StmtId: proto.Uint32(id),
+		Stmt: &Mysqlx_Prepare.Prepare_OneOfMessage{
+			Type:        Mysqlx_Prepare.Prepare_OneOfMessage_STMT.Enum(),
+			StmtExecute: &Mysqlx_Sql.StmtExecute{Stmt: []byte(query)},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("mysqlXConn.openCursor(%q,...) failed: %v", query, err)
+	}
+	if err := mc.readOk("mysqlXConn.openCursor"); err != nil {
+		return nil, err
+	}
+
+	execute := &Mysqlx_Prepare.Execute{
+		StmtId: proto.Uint32(id),
+		Args:   encodedArgs,
+	}
+	if compact {
+		execute.CompactMetadata = proto.Bool(true)
+	}
+	err = mc.writeMessage(Mysqlx.ClientMessages_CURSOR_OPEN, &Mysqlx_Cursor.Open{
+		CursorId: proto.Uint32(id),
+		Stmt: &Mysqlx_Cursor.Open_OneOfMessage{
+			Type:           Mysqlx_Cursor.Open_OneOfMessage_PREPARE_EXECUTE.Enum(),
+			PrepareExecute: execute,
+		},
+		FetchRows: proto.Uint64(mc.cfg.fetchSize),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("mysqlXConn.openCursor(%q,...) failed: %v", query, err)
+	}
+
+	return &mysqlXRows{
+		mc:     mc,
+		state:  queryStateWaitingColumnMetaData,
+		cursor: &cursor{id: id},
+	}, nil
+}
+
+// fetch asks for the next rows of the cursor
+func (mc *mysqlXConn) fetch(c *cursor) error {
+	return mc.writeMessage(Mysqlx.ClientMessages_CURSOR_FETCH, &Mysqlx_Cursor.Fetch{
+		CursorId:  proto.Uint32(c.id),
+		FetchRows: proto.Uint64(mc.cfg.fetchSize),
+	})
+}
+
+// closeCursor closes the cursor if the server has not already done so
+// and deallocates its prepared statement. No rows may be in flight.
+func (mc *mysqlXConn) closeCursor(c *cursor) error {
+	if !c.closed {
+		if err := mc.writeMessage(Mysqlx.ClientMessages_CURSOR_CLOSE, &Mysqlx_Cursor.Close{CursorId: proto.Uint32(c.id)}); err != nil {
+			return err
+		}
+		if err := mc.readOk("mysqlXConn.closeCursor"); err != nil {
+			return err
+		}
+		c.closed = true
+	}
+	if err := mc.writeMessage(Mysqlx.ClientMessages_PREPARE_DEALLOCATE, &Mysqlx_Prepare.Deallocate{StmtId: proto.Uint32(c.id)}); err != nil {
+		return err
+	}
+	return mc.readOk("mysqlXConn.closeCursor")
+}
+
+// writeMessage marshals a message and sends it
+func (mc *mysqlXConn) writeMessage(msgType Mysqlx.ClientMessages_Type, msg proto.Message) error {
+	payload, err := proto.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("Failed to marshall message: %+v: %v", msg, err)
+	}
+	return mc.writeProtobufPacket(&netProtobuf{msgType: int(msgType), payload: payload})
+}
+
+// readOk waits for an Ok, returning the error sent instead if there is one
+func (mc *mysqlXConn) readOk(where string) error {
+	return mc.readReply(where, Mysqlx.ServerMessages_OK)
+}
+
+// readReply waits for a message of the given type, which carries
+// nothing we need, returning the error sent instead if there is one
+func (mc *mysqlXConn) readReply(where string, want Mysqlx.ServerMessages_Type) error {
+	for {
+		pb, err := mc.readMsg()
+		if err != nil {
+			return err
+		}
+		mc.pb = pb
+
+		switch Mysqlx.ServerMessages_Type(pb.msgType) {
+		case want:
+			mc.pb = nil
+			return nil
+		case Mysqlx.ServerMessages_ERROR:
+			return mc.processErrorMsg()
+		case Mysqlx.ServerMessages_NOTICE:
+			if err := mc.processNotice(where); err != nil {
+				return err
+			}
+		default:
+			mc.pb = nil
+			return fmt.Errorf("%s: received unexpected message type: %s", where, printableMsgTypeIn(Mysqlx.ServerMessages_Type(pb.msgType)))
+		}
+	}
+}
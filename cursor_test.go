@@ -0,0 +1,186 @@
+// Go driver for MySQL X Protocol
+//
+// Copyright 2016 Simon J Mudd.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/sjmudd/go-mysqlx-driver/mysqlxtest"
+)
+
+// cursorServer returns a fake server with a result of numRows rows
+func cursorServer(numRows int) *mysqlxtest.Server {
+	s := mysqlxtest.NewServer(fakeUser, fakePasswd)
+	result := &mysqlxtest.Result{Columns: []mysqlxtest.Column{{Name: "id"}}}
+	for i := 0; i < numRows; i++ {
+		result.Rows = append(result.Rows, []interface{}{int64(i)})
+	}
+	s.Handle("SELECT id FROM t", result)
+	s.Handle("SELECT id FROM t WHERE 0", &mysqlxtest.Result{Columns: []mysqlxtest.Column{{Name: "id"}}})
+	s.Handle("SELECT id FROM t WHERE id = ?", &mysqlxtest.Result{Columns: []mysqlxtest.Column{{Name: "id"}}, Rows: [][]interface{}{{int64(7)}}})
+	s.Handle("DELETE FROM t", &mysqlxtest.Result{RowsAffected: 3})
+	return s
+}
+
+// connStatsOf returns the stats of the connection
+func connStatsOf(t *testing.T, conn *sql.Conn) Stats {
+	var stats Stats
+	if err := conn.Raw(func(driverConn interface{}) error {
+		stats = driverConn.(XConn).Stats()
+		return nil
+	}); err != nil {
+		t.Fatalf("Raw failed: %v", err)
+	}
+	return stats
+}
+
+// all the rows are read whatever the fetch size
+func TestCursorReadAll(t *testing.T) {
+	const numRows = 95
+	s := cursorServer(numRows)
+	defer s.Close()
+
+	for _, fetchSize := range []uint64{0, 1, 10, numRows, 1000} {
+		db := fakeDB(t, s, "MYSQL41", func(cfg *Config) { cfg.FetchSize = fetchSize })
+		conn, err := db.Conn(context.Background())
+		if err != nil {
+			t.Fatalf("TestCursorReadAll: fetchSize=%d: Conn failed: %v", fetchSize, err)
+		}
+
+		rows, err := conn.QueryContext(context.Background(), "SELECT id FROM t")
+		if err != nil {
+			t.Fatalf("TestCursorReadAll: fetchSize=%d: Query failed: %v", fetchSize, err)
+		}
+		n := 0
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				t.Fatalf("TestCursorReadAll: fetchSize=%d: Scan failed: %v", fetchSize, err)
+			}
+			if id != int64(n) {
+				t.Errorf("TestCursorReadAll: fetchSize=%d: row %d has id %d", fetchSize, n, id)
+			}
+			n++
+		}
+		if err := rows.Err(); err != nil {
+			t.Errorf("TestCursorReadAll: fetchSize=%d: rows.Err: %v", fetchSize, err)
+		}
+		if err := rows.Close(); err != nil {
+			t.Errorf("TestCursorReadAll: fetchSize=%d: Close failed: %v", fetchSize, err)
+		}
+		if n != numRows {
+			t.Errorf("TestCursorReadAll: fetchSize=%d: got %d rows, expected %d", fetchSize, n, numRows)
+		}
+
+		stats := connStatsOf(t, conn)
+		fetches := uint64(0)
+		if fetchSize > 0 {
+			fetches = (numRows - 1) / fetchSize
+		}
+		if got := stats.Out["CURSOR_FETCH"].Frames; got != fetches {
+			t.Errorf("TestCursorReadAll: fetchSize=%d: %d fetches, expected %d", fetchSize, got, fetches)
+		}
+
+		// the connection can be used again
+		var id int64
+		if err := conn.QueryRowContext(context.Background(), "SELECT id FROM t WHERE id = ?", 7).Scan(&id); err != nil || id != 7 {
+			t.Errorf("TestCursorReadAll: fetchSize=%d: QueryRow gives %d, %v", fetchSize, id, err)
+		}
+		if err := conn.QueryRowContext(context.Background(), "SELECT id FROM t WHERE 0").Scan(&id); err != sql.ErrNoRows {
+			t.Errorf("TestCursorReadAll: fetchSize=%d: QueryRow of an empty result gives %v", fetchSize, err)
+		}
+		conn.Close()
+		db.Close()
+	}
+	if n := s.OpenCursors(); n != 0 {
+		t.Errorf("TestCursorReadAll: %d cursors left open", n)
+	}
+	if n := s.PreparedStatements(); n != 0 {
+		t.Errorf("TestCursorReadAll: %d statements left prepared", n)
+	}
+	if errs := s.Errors(); len(errs) > 0 {
+		t.Errorf("TestCursorReadAll: server errors: %v", errs)
+	}
+}
+
+// rows closed early close the cursor rather than reading every row
+func TestCursorCloseEarly(t *testing.T) {
+	const (
+		numRows   = 10000
+		fetchSize = 50
+	)
+	s := cursorServer(numRows)
+	defer s.Close()
+
+	db := fakeDB(t, s, "MYSQL41", func(cfg *Config) { cfg.FetchSize = fetchSize })
+	defer db.Close()
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("TestCursorCloseEarly: Conn failed: %v", err)
+	}
+	defer conn.Close()
+
+	rows, err := conn.QueryContext(context.Background(), "SELECT id FROM t")
+	if err != nil {
+		t.Fatalf("TestCursorCloseEarly: Query failed: %v", err)
+	}
+	for i := 0; i < 3 && rows.Next(); i++ {
+	}
+	if err := rows.Close(); err != nil {
+		t.Errorf("TestCursorCloseEarly: Close failed: %v", err)
+	}
+
+	stats := connStatsOf(t, conn)
+	if got := stats.In["RESULTSET_ROW"].Frames; got != fetchSize {
+		t.Errorf("TestCursorCloseEarly: %d rows received, expected %d", got, fetchSize)
+	}
+	if n := s.OpenCursors(); n != 0 {
+		t.Errorf("TestCursorCloseEarly: %d cursors left open", n)
+	}
+	if n := s.PreparedStatements(); n != 0 {
+		t.Errorf("TestCursorCloseEarly: %d statements left prepared", n)
+	}
+
+	// statements which are not queries and empty results still work
+	res, err := conn.ExecContext(context.Background(), "DELETE FROM t")
+	if err != nil {
+		t.Fatalf("TestCursorCloseEarly: Exec failed: %v", err)
+	}
+	if affected, _ := res.RowsAffected(); affected != 3 {
+		t.Errorf("TestCursorCloseEarly: rows affected: %d, expected 3", affected)
+	}
+	rows, err = conn.QueryContext(context.Background(), "SELECT id FROM t WHERE 0")
+	if err != nil {
+		t.Fatalf("TestCursorCloseEarly: Query failed: %v", err)
+	}
+	if rows.Next() {
+		t.Errorf("TestCursorCloseEarly: empty result has a row")
+	}
+	if err := rows.Close(); err != nil {
+		t.Errorf("TestCursorCloseEarly: Close of empty result failed: %v", err)
+	}
+
+	// an error from the server leaves nothing behind either
+	rows, err = conn.QueryContext(context.Background(), "SELECT nothing")
+	if err != nil {
+		t.Fatalf("TestCursorCloseEarly: Query failed: %v", err)
+	}
+	if rows.Next() || rows.Err() == nil {
+		t.Errorf("TestCursorCloseEarly: unknown statement did not fail")
+	}
+	rows.Close()
+	if n := s.PreparedStatements(); n != 0 {
+		t.Errorf("TestCursorCloseEarly: %d statements left prepared after an error", n)
+	}
+	if errs := s.Errors(); len(errs) > 0 {
+		t.Errorf("TestCursorCloseEarly: server errors: %v", errs)
+	}
+}
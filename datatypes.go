@@ -14,7 +14,6 @@ package mysql
 
 import (
 	"fmt"
-	"log"
 	"strings"
 
 	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Datatypes"
@@ -82,8 +81,33 @@ func printableDatatype(d *Mysqlx_Datatypes.Any) string {
 			s = fmt.Sprintf("Array: %s", printableArray(d.GetArray()))
 		}
 	default:
-		log.Fatalf("Unexpected datatype %+v", t)
+		s = fmt.Sprintf("Unexpected datatype %+v", t)
 	}
 
 	return s
 }
+
+// scalarValue returns the value of a scalar as a Go value: nil, int64,
+// uint64, float64, bool, string or []byte
+func scalarValue(s *Mysqlx_Datatypes.Scalar) interface{} {
+	if s == nil {
+		return nil
+	}
+	switch s.GetType() {
+	case Mysqlx_Datatypes.Scalar_V_SINT:
+		return s.GetVSignedInt()
+	case Mysqlx_Datatypes.Scalar_V_UINT:
+		return s.GetVUnsignedInt()
+	case Mysqlx_Datatypes.Scalar_V_OCTETS:
+		return s.GetVOctets().GetValue()
+	case Mysqlx_Datatypes.Scalar_V_DOUBLE:
+		return s.GetVDouble()
+	case Mysqlx_Datatypes.Scalar_V_FLOAT:
+		return float64(s.GetVFloat())
+	case Mysqlx_Datatypes.Scalar_V_BOOL:
+		return s.GetVBool()
+	case Mysqlx_Datatypes.Scalar_V_STRING:
+		return string(s.GetVString().GetValue())
+	}
+	return nil
+}
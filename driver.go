@@ -11,11 +11,10 @@
 package mysql
 
 import (
+	"context"
 	"database/sql"
 	"database/sql/driver"
 	"net"
-
-	"github.com/sjmudd/go-mysqlx-driver/capability"
 )
 
 // This struct is exported to make the driver directly accessible.
@@ -38,23 +37,24 @@ func RegisterDial(net string, dial DialFunc) {
 	dials[net] = dial
 }
 
+// Open a new connection using the given DSN.
 func (d XDriver) Open(dsn string) (driver.Conn, error) {
-	var err error
-
-	cfg, err := parseDSN(dsn)
+	c, err := d.OpenConnector(dsn)
 	if err != nil {
 		return nil, err
 	}
-	cfg.useXProtocol = true // force X protocol as this driver was called explicitly
-
-	// New mysqlConn
-	mc := &mysqlXConn{
-		capabilities:     capability.NewServerCapabilities(),
-		cfg:              NewXconfigFromConfig(cfg),
-		maxPacketAllowed: maxPacketSize,
-		maxWriteSize:     maxPacketSize - 1,
+	return c.Connect(context.Background())
+}
+
+// OpenConnector implements driver.DriverContext. The DSN is parsed
+// once and the resulting connector is used for every connection
+// made by the pool.
+func (d XDriver) OpenConnector(dsn string) (driver.Connector, error) {
+	cfg, err := ParseDSN(dsn)
+	if err != nil {
+		return nil, err
 	}
-	return mc.Open2()
+	return NewConnector(cfg)
 }
 
 func init() {
@@ -11,6 +11,7 @@
 package mysql
 
 import (
+	"reflect"
 	"testing"
 )
 
@@ -34,12 +35,124 @@ func TestDSN(t *testing.T) {
 	}
 
 	for i := range d {
-		cfg, err := parseDSN(d[i].dsn)
+		cfg, err := ParseDSN(d[i].dsn)
 		if err != nil {
-			t.Errorf("TestDSN: dsn: %s, parseDSN gives error: %v", d[i].dsn, err)
+			t.Errorf("TestDSN: dsn: %s, ParseDSN gives error: %v", d[i].dsn, err)
 		}
-		if cfg.useXProtocol != d[i].result {
-			t.Errorf("TestDSN: dsn: %s, cfg.useXprotocol: %v, expected: %v", d[i].dsn, cfg.useXProtocol, d[i].result)
+		if cfg.UseXProtocol != d[i].result {
+			t.Errorf("TestDSN: dsn: %s, cfg.useXprotocol: %v, expected: %v", d[i].dsn, cfg.UseXProtocol, d[i].result)
 		}
 	}
 }
+
+// test getCapabilities and authMechanism are picked up from the DSN
+func TestDSNCapabilities(t *testing.T) {
+	cfg, err := ParseDSN("user:pass@tcp(127.0.0.1:33060)/test?xprotocol=1")
+	if err != nil {
+		t.Fatalf("TestDSNCapabilities: ParseDSN gives error: %v", err)
+	}
+	if !cfg.UseGetCapabilities {
+		t.Errorf("TestDSNCapabilities: cfg.UseGetCapabilities: %v, expected: true", cfg.UseGetCapabilities)
+	}
+	if cfg.AuthMechanism != defaultAuthMechanism {
+		t.Errorf("TestDSNCapabilities: cfg.AuthMechanism: %q, expected: %q", cfg.AuthMechanism, defaultAuthMechanism)
+	}
+
+	cfg, err = ParseDSN("user:pass@tcp(127.0.0.1:33060)/test?xprotocol=1&getCapabilities=0&authMechanism=mysql41")
+	if err != nil {
+		t.Fatalf("TestDSNCapabilities: ParseDSN gives error: %v", err)
+	}
+	if cfg.UseGetCapabilities {
+		t.Errorf("TestDSNCapabilities: cfg.UseGetCapabilities: %v, expected: false", cfg.UseGetCapabilities)
+	}
+	if cfg.AuthMechanism != "MYSQL41" {
+		t.Errorf("TestDSNCapabilities: cfg.AuthMechanism: %q, expected: %q", cfg.AuthMechanism, "MYSQL41")
+	}
+
+	if _, err = ParseDSN("user:pass@tcp(127.0.0.1:33060)/test?xprotocol=1&authMechanism=UNKNOWN"); err == nil {
+		t.Errorf("TestDSNCapabilities: ParseDSN accepted an unknown authMechanism")
+	}
+}
+
+// test the compress parameter takes a bool or a known algorithm
+func TestDSNCompress(t *testing.T) {
+	tests := []struct {
+		value       string
+		compression string
+		ok          bool
+	}{
+		{"true", "deflate_stream", true},
+		{"1", "deflate_stream", true},
+		{"false", "", true},
+		{"deflate_stream", "deflate_stream", true},
+		{"zstd_stream", "", false}, // not registered
+		{"gzip", "", false},
+	}
+	for _, test := range tests {
+		cfg, err := ParseDSN("user:pass@tcp(127.0.0.1:33060)/test?xprotocol=1&compress=" + test.value)
+		if (err == nil) != test.ok {
+			t.Errorf("TestDSNCompress: compress=%s: error: %v, expected ok: %v", test.value, err, test.ok)
+			continue
+		}
+		if err == nil && cfg.Compression != test.compression {
+			t.Errorf("TestDSNCompress: compress=%s: cfg.Compression: %q, expected: %q", test.value, cfg.Compression, test.compression)
+		}
+	}
+}
+
+// test that FormatDSN gives back a DSN which parses to the same Config
+func TestFormatDSN(t *testing.T) {
+	dsns := []string{
+		"user:pass@tcp(127.0.0.1:33060)/test?xprotocol=1",
+		"user@unix(/tmp/mysqlx.sock)/?xprotocol=1",
+		"user:pass@tcp(localhost:33060)/test?columnsOriginalName=true&columnsWithAlias=true&getCapabilities=false&timeout=5s&xprotocol=1&charset=utf8mb4",
+		"user:pass@tcp(localhost:33060)/test?collation=utf8mb4_general_ci&loc=Europe%2FMadrid&tls=skip-verify&xprotocol=1",
+		"user:pass@tcp(h1:33060,h2:33060)/test?routeQueries=true&routeReadOnly=true&xprotocol=1",
+		"user:pass@tcp(localhost:33060)/test?wireTap=%2Ftmp%2Fmysqlx+tap.txt&xprotocol=1",
+		"user:pass@tcp(localhost:33060)/test?logLevel=trace&xprotocol=1",
+		"user:pass@tcp(localhost:33060)/test?collation=cp1251_general_ci&convertCharset=true&xprotocol=1",
+		"user:pass@tcp(localhost:33060)/test?compress=true&xprotocol=1",
+		"user:pass@tcp(localhost:33060)/test?compactMetadata=true&fetchSize=1000&xprotocol=1",
+	}
+
+	for i := range dsns {
+		cfg1, err := ParseDSN(dsns[i])
+		if err != nil {
+			t.Errorf("TestFormatDSN: dsn: %s, ParseDSN gives error: %v", dsns[i], err)
+			continue
+		}
+		formatted := cfg1.FormatDSN()
+		cfg2, err := ParseDSN(formatted)
+		if err != nil {
+			t.Errorf("TestFormatDSN: formatted dsn: %s, ParseDSN gives error: %v", formatted, err)
+			continue
+		}
+		// *tls.Config values are freshly allocated by ParseDSN
+		cfg1.TLS, cfg2.TLS = nil, nil
+		if !reflect.DeepEqual(cfg1, cfg2) {
+			t.Errorf("TestFormatDSN: dsn: %s, formatted as: %s\n%+v\n%+v", dsns[i], formatted, cfg1, cfg2)
+		}
+	}
+}
+
+// test NewConnector fills in defaults and rejects bad configs
+func TestNewConnector(t *testing.T) {
+	c, err := NewConnector(&Config{User: "user"})
+	if err != nil {
+		t.Fatalf("TestNewConnector: NewConnector gives error: %v", err)
+	}
+	cfg := c.(*connector).cfg
+	if cfg.Net != "tcp" || cfg.Addr != "127.0.0.1:33060" || cfg.AuthMechanism != defaultAuthMechanism || !cfg.UseXProtocol {
+		t.Errorf("TestNewConnector: defaults not filled in: %+v", cfg)
+	}
+	if _, ok := c.Driver().(*XDriver); !ok {
+		t.Errorf("TestNewConnector: Driver() returned %T, expected *XDriver", c.Driver())
+	}
+
+	if _, err := NewConnector(nil); err == nil {
+		t.Errorf("TestNewConnector: NewConnector(nil) did not return an error")
+	}
+	if _, err := NewConnector(&Config{AuthMechanism: "UNKNOWN"}); err == nil {
+		t.Errorf("TestNewConnector: NewConnector accepted an unknown AuthMechanism")
+	}
+}
@@ -49,6 +49,19 @@ func SetLogger(logger Logger) error {
 	return nil
 }
 
+// MySQLError is an error reported by the server in an X protocol
+// Error message.
+type MySQLError struct {
+	Severity string // ERROR or FATAL
+	Code     uint32
+	SQLState string
+	Message  string
+}
+
+func (me *MySQLError) Error() string {
+	return fmt.Sprintf("%s: %04d [%s] %s", me.Severity, me.Code, me.SQLState, me.Message)
+}
+
 // MySQLWarnings is an error type which represents a group of one or more MySQL
 // warnings
 type MySQLWarnings []MySQLWarning
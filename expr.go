@@ -0,0 +1,618 @@
+// Go driver for MySQL X Protocol
+//
+// Copyright 2016 Simon J Mudd.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+// This file parses the filter expressions used by the CRUD messages,
+// such as "age > 18 AND name LIKE 'J%'", into a Mysqlx.Expr.Expr. The
+// grammar is a subset of the one used by the X DevAPI:
+//
+//	expr     = or
+//	or       = and { ("OR" | "||") and }
+//	and      = not { ("AND" | "&&") not }
+//	not      = ("NOT" | "!") not | compare
+//	compare  = add [ op add | ["NOT"] "LIKE" add | ["NOT"] "IN" "(" list ")" | "IS" ["NOT"] "NULL" ]
+//	op       = "=" | "==" | "!=" | "<>" | "<" | "<=" | ">" | ">="
+//	add      = mul { ("+" | "-") mul }
+//	mul      = unary { ("*" | "/" | "%") unary }
+//	unary    = "-" unary | primary
+//	primary  = number | string | "TRUE" | "FALSE" | "NULL" | "(" expr ")" | call | path | param
+//	call     = name "(" [ list ] ")"
+//	path     = [ "$" ] { "." name | "[" integer "]" }   (for documents)
+//	list     = expr { "," expr }
+//	param    = ":" name
+//
+// Names may be quoted with backticks. For documents a path names a
+// member of the document, for tables a name is a column, optionally
+// qualified by the table name. A param is a named placeholder, replaced
+// by the server with the arg of that name sent with the message. Each
+// name is given a position in the order it first appears.
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Datatypes"
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Expr"
+)
+
+// token kinds
+const (
+	tokenEOF = iota
+	tokenIdent
+	tokenQuotedIdent
+	tokenNumber
+	tokenString
+	tokenOp
+)
+
+type token struct {
+	kind int
+	text string
+	pos  int
+}
+
+// exprParser holds the state of parsing one expression
+type exprParser struct {
+	input    string
+	tokens   []token
+	next     int
+	document bool // identifiers are document paths rather than columns
+
+	placeholders []string // names of the placeholders by position
+}
+
+// parseExpr parses a filter expression. If document is true
+// identifiers are taken to be paths within the document.
+func parseExpr(input string, document bool) (*Mysqlx_Expr.Expr, error) {
+	e, _, err := parseExprPlaceholders(input, document, nil)
+	return e, err
+}
+
+// parseExprPlaceholders parses a filter expression as parseExpr and
+// also returns the names of its placeholders by position. Those given
+// keep their positions so the expressions of one message, such as the
+// criteria and values of an Update, can share placeholders.
+func parseExprPlaceholders(input string, document bool, placeholders []string) (*Mysqlx_Expr.Expr, []string, error) {
+	tokens, err := tokenize(input)
+	if err != nil {
+		return nil, nil, err
+	}
+	p := &exprParser{input: input, tokens: tokens, document: document, placeholders: placeholders}
+	e, err := p.or()
+	if err != nil {
+		return nil, nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, nil, p.errorf(t, "unexpected %q", t.text)
+	}
+	return e, p.placeholders, nil
+}
+
+// tokenize splits the input into tokens
+func tokenize(input string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(input) {
+		c := input[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case isIdentByte(c):
+			start := i
+			for i < len(input) && (isIdentByte(input[i]) || input[i] >= '0' && input[i] <= '9') {
+				i++
+			}
+			tokens = append(tokens, token{tokenIdent, input[start:i], start})
+		case c >= '0' && c <= '9':
+			start := i
+			for i < len(input) && (input[i] >= '0' && input[i] <= '9' || input[i] == '.' || input[i] == 'e' || input[i] == 'E' ||
+				(input[i] == '-' || input[i] == '+') && (input[i-1] == 'e' || input[i-1] == 'E')) {
+				i++
+			}
+			tokens = append(tokens, token{tokenNumber, input[start:i], start})
+		case c == '\'' || c == '"' || c == '`':
+			start := i
+			var text []byte
+			i++
+			for {
+				if i >= len(input) {
+					return nil, fmt.Errorf("unterminated quoted string at position %d", start)
+				}
+				if input[i] == '\\' && c != '`' && i+1 < len(input) {
+					text = append(text, unescape(input[i+1]))
+					i += 2
+					continue
+				}
+				if input[i] == c {
+					if i+1 < len(input) && input[i+1] == c { // doubled quote
+						text = append(text, c)
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				text = append(text, input[i])
+				i++
+			}
+			kind := tokenString
+			if c == '`' {
+				kind = tokenQuotedIdent
+			}
+			tokens = append(tokens, token{kind, string(text), start})
+		default:
+			start := i
+			op := string(c)
+			if i+1 < len(input) {
+				switch two := input[i : i+2]; two {
+				case "==", "!=", "<>", "<=", ">=", "&&", "||":
+					op = two
+				}
+			}
+			if !strings.Contains("=!<>&|()[].,+-*/%$:", op[:1]) || op == "&" || op == "|" {
+				return nil, fmt.Errorf("unexpected character %q at position %d", c, i)
+			}
+			i += len(op)
+			tokens = append(tokens, token{tokenOp, op, start})
+		}
+	}
+	return append(tokens, token{tokenEOF, "", len(input)}), nil
+}
+
+// isIdentByte returns true if c may start a name. Bytes of multibyte
+// UTF-8 characters are accepted.
+func isIdentByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+// unescape returns the character for a backslash escape
+func unescape(c byte) byte {
+	switch c {
+	case 'n':
+		return '\n'
+	case 'r':
+		return '\r'
+	case 't':
+		return '\t'
+	case '0':
+		return 0
+	}
+	return c
+}
+
+func (p *exprParser) peek() token {
+	return p.tokens[p.next]
+}
+
+func (p *exprParser) advance() token {
+	t := p.tokens[p.next]
+	if t.kind != tokenEOF {
+		p.next++
+	}
+	return t
+}
+
+// isKeyword returns true if the token is the keyword, in any case
+func (t token) isKeyword(keyword string) bool {
+	return t.kind == tokenIdent && strings.EqualFold(t.text, keyword)
+}
+
+// isOp returns true if the token is one of the operators
+func (t token) isOp(ops ...string) bool {
+	if t.kind != tokenOp {
+		return false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			return true
+		}
+	}
+	return false
+}
+
+// accept consumes the next token if it is the keyword
+func (p *exprParser) accept(keyword string) bool {
+	if p.peek().isKeyword(keyword) {
+		p.advance()
+		return true
+	}
+	return false
+}
+
+// expect consumes the next token which must be the operator
+func (p *exprParser) expect(op string) error {
+	if t := p.advance(); !t.isOp(op) {
+		return p.errorf(t, "expected %q", op)
+	}
+	return nil
+}
+
+func (p *exprParser) errorf(t token, format string, args ...interface{}) error {
+	return fmt.Errorf("%s at position %d in %q", fmt.Sprintf(format, args...), t.pos, p.input)
+}
+
+func (p *exprParser) or() (*Mysqlx_Expr.Expr, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().isKeyword("OR") || p.peek().isOp("||") {
+		p.advance()
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = operator("||", left, right)
+	}
+	return left, nil
+}
+
+func (p *exprParser) and() (*Mysqlx_Expr.Expr, error) {
+	left, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().isKeyword("AND") || p.peek().isOp("&&") {
+		p.advance()
+		right, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		left = operator("&&", left, right)
+	}
+	return left, nil
+}
+
+func (p *exprParser) not() (*Mysqlx_Expr.Expr, error) {
+	if p.peek().isKeyword("NOT") || p.peek().isOp("!") {
+		p.advance()
+		e, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return operator("not", e), nil
+	}
+	return p.compare()
+}
+
+func (p *exprParser) compare() (*Mysqlx_Expr.Expr, error) {
+	left, err := p.add()
+	if err != nil {
+		return nil, err
+	}
+
+	t := p.peek()
+	switch {
+	case t.isOp("=", "==", "!=", "<>", "<", "<=", ">", ">="):
+		p.advance()
+		right, err := p.add()
+		if err != nil {
+			return nil, err
+		}
+		name := t.text
+		switch name {
+		case "=":
+			name = "=="
+		case "<>":
+			name = "!="
+		}
+		return operator(name, left, right), nil
+
+	case t.isKeyword("IS"):
+		p.advance()
+		name := "is"
+		if p.accept("NOT") {
+			name = "is_not"
+		}
+		if !p.accept("NULL") {
+			return nil, p.errorf(p.peek(), "expected NULL")
+		}
+		return operator(name, left, literal(nil)), nil
+
+	case t.isKeyword("NOT") || t.isKeyword("LIKE") || t.isKeyword("IN"):
+		p.advance()
+		negate := t.isKeyword("NOT")
+		if negate {
+			t = p.advance()
+		}
+		switch {
+		case t.isKeyword("LIKE"):
+			right, err := p.add()
+			if err != nil {
+				return nil, err
+			}
+			if negate {
+				return operator("not_like", left, right), nil
+			}
+			return operator("like", left, right), nil
+		case t.isKeyword("IN"):
+			if err := p.expect("("); err != nil {
+				return nil, err
+			}
+			list, err := p.list(")")
+			if err != nil {
+				return nil, err
+			}
+			if negate {
+				return operator("not_in", append([]*Mysqlx_Expr.Expr{left}, list...)...), nil
+			}
+			return operator("in", append([]*Mysqlx_Expr.Expr{left}, list...)...), nil
+		}
+		return nil, p.errorf(t, "expected LIKE or IN")
+	}
+	return left, nil
+}
+
+func (p *exprParser) add() (*Mysqlx_Expr.Expr, error) {
+	left, err := p.mul()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().isOp("+", "-") {
+		op := p.advance().text
+		right, err := p.mul()
+		if err != nil {
+			return nil, err
+		}
+		left = operator(op, left, right)
+	}
+	return left, nil
+}
+
+func (p *exprParser) mul() (*Mysqlx_Expr.Expr, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().isOp("*", "/", "%") {
+		op := p.advance().text
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = operator(op, left, right)
+	}
+	return left, nil
+}
+
+func (p *exprParser) unary() (*Mysqlx_Expr.Expr, error) {
+	if p.peek().isOp("-") {
+		p.advance()
+		e, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return operator("sign_minus", e), nil
+	}
+	return p.primary()
+}
+
+func (p *exprParser) primary() (*Mysqlx_Expr.Expr, error) {
+	t := p.peek()
+	switch {
+	case t.kind == tokenNumber:
+		p.advance()
+		if i, err := strconv.ParseInt(t.text, 10, 64); err == nil {
+			return literal(i), nil
+		}
+		if u, err := strconv.ParseUint(t.text, 10, 64); err == nil {
+			return literal(u), nil
+		}
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, p.errorf(t, "invalid number %q", t.text)
+		}
+		return literal(f), nil
+
+	case t.kind == tokenString:
+		p.advance()
+		return literal(t.text), nil
+
+	case t.isKeyword("TRUE"), t.isKeyword("FALSE"):
+		p.advance()
+		return literal(t.isKeyword("TRUE")), nil
+
+	case t.isKeyword("NULL"):
+		p.advance()
+		return literal(nil), nil
+
+	case t.isOp("("):
+		p.advance()
+		e, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return e, nil
+
+	case t.isOp(":"):
+		return p.placeholder()
+
+	case t.kind == tokenIdent || t.kind == tokenQuotedIdent || t.isOp("$"):
+		if t.kind == tokenIdent && p.tokens[p.next+1].isOp("(") {
+			return p.call()
+		}
+		if p.document {
+			return p.documentPath()
+		}
+		return p.column()
+	}
+	return nil, p.errorf(t, "unexpected %q", t.text)
+}
+
+// placeholder parses a named placeholder
+func (p *exprParser) placeholder() (*Mysqlx_Expr.Expr, error) {
+	p.advance() // :
+	t := p.advance()
+	if t.kind != tokenIdent && t.kind != tokenQuotedIdent {
+		return nil, p.errorf(t, "expected a placeholder name, got %q", t.text)
+	}
+	position := len(p.placeholders)
+	for i, name := range p.placeholders {
+		if name == t.text {
+			position = i
+			break
+		}
+	}
+	if position == len(p.placeholders) {
+		p.placeholders = append(p.placeholders, t.text)
+	}
+	return &Mysqlx_Expr.Expr{Type: Mysqlx_Expr.Expr_PLACEHOLDER.Enum(), Position: proto.Uint32(uint32(position))}, nil
+}
+
+// call parses a function call
+func (p *exprParser) call() (*Mysqlx_Expr.Expr, error) {
+	name := p.advance().text
+	p.advance() // (
+	var params []*Mysqlx_Expr.Expr
+	if p.peek().isOp(")") {
+		p.advance()
+	} else {
+		var err error
+		if params, err = p.list(")"); err != nil {
+			return nil, err
+		}
+	}
+	return &Mysqlx_Expr.Expr{
+		Type: Mysqlx_Expr.Expr_FUNC_CALL.Enum(),
+		FunctionCall: &Mysqlx_Expr.FunctionCall{
+			Name:  &Mysqlx_Expr.Identifier{Name: proto.String(name)},
+			Param: params,
+		},
+	}, nil
+}
+
+// list parses expressions separated by commas up to the closing operator
+func (p *exprParser) list(closing string) ([]*Mysqlx_Expr.Expr, error) {
+	var list []*Mysqlx_Expr.Expr
+	for {
+		e, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, e)
+		t := p.advance()
+		if t.isOp(closing) {
+			return list, nil
+		}
+		if !t.isOp(",") {
+			return nil, p.errorf(t, "expected \",\" or %q", closing)
+		}
+	}
+}
+
+// documentPath parses a path within a document such as $.a.b[1]
+func (p *exprParser) documentPath() (*Mysqlx_Expr.Expr, error) {
+	var path []*Mysqlx_Expr.DocumentPathItem
+	member := func(name string) {
+		path = append(path, &Mysqlx_Expr.DocumentPathItem{
+			Type:  Mysqlx_Expr.DocumentPathItem_MEMBER.Enum(),
+			Value: proto.String(name),
+		})
+	}
+
+	if t := p.advance(); t.kind == tokenIdent || t.kind == tokenQuotedIdent {
+		member(t.text)
+	}
+
+	for {
+		t := p.peek()
+		switch {
+		case t.isOp("."):
+			p.advance()
+			name := p.advance()
+			if name.kind != tokenIdent && name.kind != tokenQuotedIdent {
+				return nil, p.errorf(name, "expected a member name")
+			}
+			member(name.text)
+		case t.isOp("["):
+			p.advance()
+			index := p.advance()
+			n, err := strconv.ParseUint(index.text, 10, 32)
+			if index.kind != tokenNumber || err != nil {
+				return nil, p.errorf(index, "expected an array index")
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			path = append(path, &Mysqlx_Expr.DocumentPathItem{
+				Type:  Mysqlx_Expr.DocumentPathItem_ARRAY_INDEX.Enum(),
+				Index: proto.Uint32(uint32(n)),
+			})
+		default:
+			if len(path) == 0 {
+				return nil, p.errorf(t, "expected a document path")
+			}
+			return &Mysqlx_Expr.Expr{
+				Type:       Mysqlx_Expr.Expr_IDENT.Enum(),
+				Identifier: &Mysqlx_Expr.ColumnIdentifier{DocumentPath: path},
+			}, nil
+		}
+	}
+}
+
+// column parses a column name optionally qualified by the table name
+func (p *exprParser) column() (*Mysqlx_Expr.Expr, error) {
+	t := p.advance()
+	if t.kind != tokenIdent && t.kind != tokenQuotedIdent {
+		return nil, p.errorf(t, "expected a column name")
+	}
+	id := &Mysqlx_Expr.ColumnIdentifier{Name: proto.String(t.text)}
+	if p.peek().isOp(".") {
+		p.advance()
+		name := p.advance()
+		if name.kind != tokenIdent && name.kind != tokenQuotedIdent {
+			return nil, p.errorf(name, "expected a column name")
+		}
+		id.TableName, id.Name = id.Name, proto.String(name.text)
+	}
+	return &Mysqlx_Expr.Expr{Type: Mysqlx_Expr.Expr_IDENT.Enum(), Identifier: id}, nil
+}
+
+// operator returns an operator expression
+func operator(name string, params ...*Mysqlx_Expr.Expr) *Mysqlx_Expr.Expr {
+	return &Mysqlx_Expr.Expr{
+		Type:     Mysqlx_Expr.Expr_OPERATOR.Enum(),
+		Operator: &Mysqlx_Expr.Operator{Name: proto.String(name), Param: params},
+	}
+}
+
+// literal returns a literal expression holding nil, int64, uint64,
+// float64, bool or string
+func literal(v interface{}) *Mysqlx_Expr.Expr {
+	return &Mysqlx_Expr.Expr{
+		Type:    Mysqlx_Expr.Expr_LITERAL.Enum(),
+		Literal: scalar(v),
+	}
+}
+
+// scalar returns a Mysqlx.Datatypes.Scalar holding nil, int64, uint64,
+// float64, bool, string or []byte
+func scalar(v interface{}) *Mysqlx_Datatypes.Scalar {
+	switch v := v.(type) {
+	case int64:
+		return &Mysqlx_Datatypes.Scalar{Type: Mysqlx_Datatypes.Scalar_V_SINT.Enum(), VSignedInt: proto.Int64(v)}
+	case uint64:
+		return &Mysqlx_Datatypes.Scalar{Type: Mysqlx_Datatypes.Scalar_V_UINT.Enum(), VUnsignedInt: proto.Uint64(v)}
+	case float64:
+		return &Mysqlx_Datatypes.Scalar{Type: Mysqlx_Datatypes.Scalar_V_DOUBLE.Enum(), VDouble: proto.Float64(v)}
+	case bool:
+		return &Mysqlx_Datatypes.Scalar{Type: Mysqlx_Datatypes.Scalar_V_BOOL.Enum(), VBool: proto.Bool(v)}
+	case string:
+		return &Mysqlx_Datatypes.Scalar{Type: Mysqlx_Datatypes.Scalar_V_STRING.Enum(), VString: &Mysqlx_Datatypes.Scalar_String{Value: []byte(v)}}
+	case []byte:
+		return &Mysqlx_Datatypes.Scalar{Type: Mysqlx_Datatypes.Scalar_V_OCTETS.Enum(), VOctets: &Mysqlx_Datatypes.Scalar_Octets{Value: v}}
+	}
+	return &Mysqlx_Datatypes.Scalar{Type: Mysqlx_Datatypes.Scalar_V_NULL.Enum()}
+}
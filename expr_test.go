@@ -0,0 +1,134 @@
+// Go driver for MySQL X Protocol
+//
+// Copyright 2016 Simon J Mudd.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Expr"
+)
+
+// member returns a document path of members
+func member(names ...string) *Mysqlx_Expr.Expr {
+	var path []*Mysqlx_Expr.DocumentPathItem
+	for _, name := range names {
+		path = append(path, &Mysqlx_Expr.DocumentPathItem{
+			Type:  Mysqlx_Expr.DocumentPathItem_MEMBER.Enum(),
+			Value: proto.String(name),
+		})
+	}
+	return &Mysqlx_Expr.Expr{
+		Type:       Mysqlx_Expr.Expr_IDENT.Enum(),
+		Identifier: &Mysqlx_Expr.ColumnIdentifier{DocumentPath: path},
+	}
+}
+
+// column returns a column optionally qualified by the table name
+func column(table, name string) *Mysqlx_Expr.Expr {
+	id := &Mysqlx_Expr.ColumnIdentifier{Name: proto.String(name)}
+	if table != "" {
+		id.TableName = proto.String(table)
+	}
+	return &Mysqlx_Expr.Expr{Type: Mysqlx_Expr.Expr_IDENT.Enum(), Identifier: id}
+}
+
+func TestParseExpr(t *testing.T) {
+	index := member("a")
+	index.Identifier.DocumentPath = append(index.Identifier.DocumentPath, &Mysqlx_Expr.DocumentPathItem{
+		Type:  Mysqlx_Expr.DocumentPathItem_ARRAY_INDEX.Enum(),
+		Index: proto.Uint32(2),
+	})
+
+	tests := []struct {
+		input    string
+		document bool
+		want     *Mysqlx_Expr.Expr
+	}{
+		{"age > 18", true, operator(">", member("age"), literal(int64(18)))},
+		{"$.name.first = 'Jo'", true, operator("==", member("name", "first"), literal("Jo"))},
+		{"a[2] IS NOT NULL", true, operator("is_not", index, literal(nil))},
+		{"age > 18 AND name LIKE 'J%'", true, operator("&&",
+			operator(">", member("age"), literal(int64(18))),
+			operator("like", member("name"), literal("J%")))},
+		{"NOT a OR b", true, operator("||", operator("not", member("a")), member("b"))},
+		{"a + 2 * -b", true, operator("+", member("a"), operator("*", literal(int64(2)), operator("sign_minus", member("b"))))},
+		{"(a + 2) * 1.5", true, operator("*", operator("+", member("a"), literal(int64(2))), literal(1.5))},
+		{"x IN (1, 'two', TRUE)", true, operator("in", member("x"), literal(int64(1)), literal("two"), literal(true))},
+		{"x NOT IN (1)", true, operator("not_in", member("x"), literal(int64(1)))},
+		{`s != "it""s"`, true, operator("!=", member("s"), literal(`it"s`))},
+		{"t.id <> 18446744073709551615", false, operator("!=", column("t", "id"), literal(uint64(18446744073709551615)))},
+		{"`select` <= 3", false, operator("<=", column("", "select"), literal(int64(3)))},
+	}
+	for _, test := range tests {
+		got, err := parseExpr(test.input, test.document)
+		if err != nil {
+			t.Errorf("TestParseExpr: %q: failed: %v", test.input, err)
+			continue
+		}
+		if !proto.Equal(got, test.want) {
+			t.Errorf("TestParseExpr: %q:\ngot:      %s\nexpected: %s", test.input,
+				proto.CompactTextString(got), proto.CompactTextString(test.want))
+		}
+	}
+}
+
+// placeholders are numbered by the order their names first appear
+func TestParseExprPlaceholders(t *testing.T) {
+	placeholder := func(position uint32) *Mysqlx_Expr.Expr {
+		return &Mysqlx_Expr.Expr{Type: Mysqlx_Expr.Expr_PLACEHOLDER.Enum(), Position: proto.Uint32(position)}
+	}
+	want := operator("||",
+		operator("&&", operator(">", member("age"), placeholder(0)), operator("<", member("age"), placeholder(1))),
+		operator("==", placeholder(0), literal(int64(0))))
+
+	got, names, err := parseExprPlaceholders("age > :min AND age < :max OR :min = 0", true, nil)
+	if err != nil {
+		t.Fatalf("TestParseExprPlaceholders: failed: %v", err)
+	}
+	if !proto.Equal(got, want) {
+		t.Errorf("TestParseExprPlaceholders:\ngot:      %s\nexpected: %s", proto.CompactTextString(got), proto.CompactTextString(want))
+	}
+	if len(names) != 2 || names[0] != "min" || names[1] != "max" {
+		t.Errorf("TestParseExprPlaceholders: got names %q, expected [min max]", names)
+	}
+
+	// a second expression of the same message carries on the positions
+	want = operator("+", placeholder(1), placeholder(2))
+	got, names, err = parseExprPlaceholders(":max + :step", true, names)
+	if err != nil {
+		t.Fatalf("TestParseExprPlaceholders: failed: %v", err)
+	}
+	if !proto.Equal(got, want) {
+		t.Errorf("TestParseExprPlaceholders:\ngot:      %s\nexpected: %s", proto.CompactTextString(got), proto.CompactTextString(want))
+	}
+	if len(names) != 3 || names[2] != "step" {
+		t.Errorf("TestParseExprPlaceholders: got names %q, expected [min max step]", names)
+	}
+}
+
+func TestParseExprErrors(t *testing.T) {
+	for _, input := range []string{
+		"",
+		"age >",
+		"(a = 1",
+		"a = 'unterminated",
+		"a[x]",
+		"a IN 1",
+		"a b",
+		"a = #",
+		"a = :",
+		"a = :1",
+	} {
+		if e, err := parseExpr(input, true); err == nil {
+			t.Errorf("TestParseExprErrors: %q: expected an error, got %s", input, proto.CompactTextString(e))
+		}
+	}
+}
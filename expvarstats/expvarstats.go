@@ -0,0 +1,68 @@
+// Go driver for MySQL X Protocol
+//
+// Copyright 2016 Simon J Mudd.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package expvarstats publishes the counters of all the driver's
+// connections with expvar, so they appear in /debug/vars:
+//
+//	expvarstats.Publish("mysqlx")
+//
+// It is also an example of a mysql.StatsCollector. A collector for
+// another monitoring system keeps a total in the same way and reads
+// it when asked for the values.
+package expvarstats
+
+import (
+	"expvar"
+	"sync"
+
+	mysql "github.com/sjmudd/go-mysqlx-driver"
+)
+
+// Collector adds up the counters of the connections it is given
+type Collector struct {
+	mu    sync.Mutex
+	total mysql.Stats
+}
+
+// Collect implements mysql.StatsCollector
+func (c *Collector) Collect(delta *mysql.Stats) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.total.Add(delta)
+}
+
+// Stats returns a copy of the totals
+func (c *Collector) Stats() mysql.Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var s mysql.Stats
+	s.Add(&c.total)
+	return s
+}
+
+// vars is what is published, the totals with the bytes added up
+type vars struct {
+	mysql.Stats
+	BytesIn  uint64
+	BytesOut uint64
+}
+
+func (c *Collector) vars() interface{} {
+	s := c.Stats()
+	return vars{Stats: s, BytesIn: s.BytesIn(), BytesOut: s.BytesOut()}
+}
+
+// Publish sets a new Collector as the driver's StatsCollector and
+// publishes its totals as the expvar of the given name. Like
+// expvar.Publish it panics if the name is already in use.
+func Publish(name string) *Collector {
+	c := &Collector{}
+	expvar.Publish(name, expvar.Func(c.vars))
+	mysql.SetStatsCollector(c)
+	return c
+}
@@ -0,0 +1,606 @@
+// Go driver for MySQL X Protocol
+//
+// Copyright 2016 Simon J Mudd.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+// These tests run the driver against the fake server in mysqlxtest so
+// need no database.
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Crud"
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Expr"
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Resultset"
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Sql"
+	"github.com/sjmudd/go-mysqlx-driver/mysqlxtest"
+)
+
+const (
+	fakeUser   = "xprotocol_user"
+	fakePasswd = "xprotocol_pass"
+)
+
+// fakeConfig returns a Config for the fake server, changed by opts
+func fakeConfig(s *mysqlxtest.Server, opts ...func(*Config)) *Config {
+	cfg := NewConfig()
+	cfg.User = fakeUser
+	cfg.Passwd = fakePasswd
+	cfg.DialContext = s.DialContext
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// fakeDB returns a sql.DB connected to the fake server over a net.Pipe,
+// with the Config changed by opts
+func fakeDB(t *testing.T, s *mysqlxtest.Server, mechanism string, opts ...func(*Config)) *sql.DB {
+	cfg := fakeConfig(s, opts...)
+	cfg.AuthMechanism = mechanism
+
+	connector, err := NewConnector(cfg)
+	if err != nil {
+		t.Fatalf("NewConnector failed: %v", err)
+	}
+	return sql.OpenDB(connector)
+}
+
+// both supported mechanisms accept the right password and reject a
+// wrong one, PLAIN only being used over a socket
+func TestFakeServerAuthenticate(t *testing.T) {
+	s := mysqlxtest.NewServer(fakeUser, fakePasswd)
+	defer s.Close()
+
+	tcp := func(cfg *Config) {}
+	socket := func(cfg *Config) { cfg.Net, cfg.Addr = "unix", "/tmp/mysqlx.sock" }
+	tests := []struct {
+		mechanism string
+		opt       func(*Config)
+	}{
+		{"MYSQL41", tcp},
+		{"MYSQL41", socket},
+		{"PLAIN", socket},
+	}
+	for _, test := range tests {
+		db := fakeDB(t, s, test.mechanism, test.opt)
+		if err := db.Ping(); err != nil {
+			t.Errorf("TestFakeServerAuthenticate: %s: Ping failed: %v", test.mechanism, err)
+		}
+		db.Close()
+
+		s.Password = "other"
+		db = fakeDB(t, s, test.mechanism, test.opt)
+		if err := db.Ping(); err == nil {
+			t.Errorf("TestFakeServerAuthenticate: %s: Ping with the wrong password succeeded", test.mechanism)
+		}
+		db.Close()
+		s.Password = fakePasswd
+	}
+
+	// without TLS the server does not offer PLAIN over TCP, the driver
+	// does not send the password if it doesn't ask and the server
+	// refuses it if allowCleartextPasswords makes it send it
+	noCapabilities := func(cfg *Config) { cfg.UseGetCapabilities = false }
+	allowCleartext := func(cfg *Config) { cfg.AllowCleartextPasswords = true }
+	refusals := []struct {
+		opts     []func(*Config)
+		expected string
+	}{
+		{nil, "PLAIN not offered"},
+		{[]func(*Config){noCapabilities}, ErrCleartextPassword.Error()},
+		{[]func(*Config){noCapabilities, allowCleartext}, "Invalid authentication method PLAIN"},
+	}
+	for _, test := range refusals {
+		db := fakeDB(t, s, "PLAIN", test.opts...)
+		if err := db.Ping(); err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("TestFakeServerAuthenticate: PLAIN over TCP: got error %v, expected %q", err, test.expected)
+		}
+		db.Close()
+	}
+}
+
+// rows of the different types are returned
+func TestFakeServerQuery(t *testing.T) {
+	s := mysqlxtest.NewServer(fakeUser, fakePasswd)
+	defer s.Close()
+	s.Handle("SELECT id, name, price FROM t", &mysqlxtest.Result{
+		Columns: []mysqlxtest.Column{{Name: "id"}, {Name: "name"}, {Name: "price"}},
+		Rows: [][]interface{}{
+			{int64(-1), "one", 1.5},
+			{int64(2), nil, 2.25},
+		},
+		Notices: []mysqlxtest.Notice{mysqlxtest.WarningNotice(1265, "Data truncated")},
+	})
+
+	db := fakeDB(t, s, "MYSQL41")
+	defer db.Close()
+
+	rows, err := db.Query("SELECT id, name, price FROM t")
+	if err != nil {
+		t.Fatalf("TestFakeServerQuery: Query failed: %v", err)
+	}
+	defer rows.Close()
+
+	type row struct {
+		id    int64
+		name  sql.NullString
+		price float64
+	}
+	var got []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.id, &r.name, &r.price); err != nil {
+			t.Fatalf("TestFakeServerQuery: Scan failed: %v", err)
+		}
+		got = append(got, r)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("TestFakeServerQuery: rows.Err: %v", err)
+	}
+
+	want := []row{
+		{-1, sql.NullString{String: "one", Valid: true}, 1.5},
+		{2, sql.NullString{}, 2.25},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("TestFakeServerQuery: got %d rows, expected %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("TestFakeServerQuery: row %d: got %+v, expected %+v", i, got[i], want[i])
+		}
+	}
+}
+
+// columns are named by their alias or original name, with or without
+// the table
+func TestFakeServerColumnNames(t *testing.T) {
+	s := mysqlxtest.NewServer(fakeUser, fakePasswd)
+	defer s.Close()
+	s.Handle("SELECT p.id AS pid, p.name, 1 FROM people p", &mysqlxtest.Result{
+		Columns: []mysqlxtest.Column{
+			{Name: "pid", OriginalName: "id", Table: "p", OriginalTable: "people"},
+			{Name: "name", OriginalName: "name", Table: "p", OriginalTable: "people"},
+			{Name: "1"},
+		},
+		Rows: [][]interface{}{{int64(1), "alice", int64(1)}},
+	})
+
+	tests := []struct {
+		withAlias, originalName bool
+		expected                []string
+	}{
+		{false, false, []string{"pid", "name", "1"}},
+		{true, false, []string{"p.pid", "p.name", "1"}},
+		{false, true, []string{"id", "name", "1"}},
+		{true, true, []string{"people.id", "people.name", "1"}},
+	}
+	for _, test := range tests {
+		db := fakeDB(t, s, "MYSQL41", func(cfg *Config) {
+			cfg.ColumnsWithAlias = test.withAlias
+			cfg.ColumnsOriginalName = test.originalName
+		})
+
+		rows, err := db.Query("SELECT p.id AS pid, p.name, 1 FROM people p")
+		if err != nil {
+			t.Fatalf("TestFakeServerColumnNames: Query failed: %v", err)
+		}
+		columns, err := rows.Columns()
+		if err != nil {
+			t.Errorf("TestFakeServerColumnNames: Columns failed: %v", err)
+		}
+		if !reflect.DeepEqual(columns, test.expected) {
+			t.Errorf("TestFakeServerColumnNames: columnsWithAlias=%v columnsOriginalName=%v: got %q, expected %q",
+				test.withAlias, test.originalName, columns, test.expected)
+		}
+		rows.Close()
+		db.Close()
+	}
+}
+
+// a server error is returned as a *MySQLError and the connection can
+// still be used
+func TestFakeServerError(t *testing.T) {
+	s := mysqlxtest.NewServer(fakeUser, fakePasswd)
+	defer s.Close()
+	s.Handle("SELECT * FROM missing", &mysqlxtest.Result{
+		Err: &mysqlxtest.Error{Code: 1146, SQLState: "42S02", Msg: "Table 'test.missing' doesn't exist"},
+	})
+	s.Handle("SELECT 1", &mysqlxtest.Result{
+		Columns: []mysqlxtest.Column{{Name: "1", Type: Mysqlx_Resultset.ColumnMetaData_SINT}},
+		Rows:    [][]interface{}{{1}},
+	})
+
+	db := fakeDB(t, s, "MYSQL41")
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	for _, exec := range []bool{false, true} {
+		var err error
+		if exec {
+			_, err = db.Exec("SELECT * FROM missing")
+		} else {
+			var n int
+			err = db.QueryRow("SELECT * FROM missing").Scan(&n)
+		}
+		me, ok := err.(*MySQLError)
+		if !ok {
+			t.Fatalf("TestFakeServerError: exec: %v, got error %T: %v, expected *MySQLError", exec, err, err)
+		}
+		if me.Code != 1146 || me.SQLState != "42S02" {
+			t.Errorf("TestFakeServerError: exec: %v, got: %v", exec, me)
+		}
+	}
+
+	var n int
+	if err := db.QueryRow("SELECT 1").Scan(&n); err != nil || n != 1 {
+		t.Errorf("TestFakeServerError: SELECT 1 after an error: n: %d, err: %v", n, err)
+	}
+}
+
+// the affected rows and insert id come from the session state notices
+func TestFakeServerExec(t *testing.T) {
+	s := mysqlxtest.NewServer(fakeUser, fakePasswd)
+	defer s.Close()
+	s.Handle("INSERT INTO t VALUES (NULL), (NULL)", &mysqlxtest.Result{RowsAffected: 2, LastInsertID: 41})
+
+	db := fakeDB(t, s, "MYSQL41")
+	defer db.Close()
+
+	res, err := db.Exec("INSERT INTO t VALUES (NULL), (NULL)")
+	if err != nil {
+		t.Fatalf("TestFakeServerExec: Exec failed: %v", err)
+	}
+	if n, _ := res.RowsAffected(); n != 2 {
+		t.Errorf("TestFakeServerExec: RowsAffected: %d, expected: 2", n)
+	}
+	if id, _ := res.LastInsertId(); id != 41 {
+		t.Errorf("TestFakeServerExec: LastInsertId: %d, expected: 41", id)
+	}
+}
+
+// notices sent before the Ok answering a Close are processed and a
+// global one invalidates the roles of the hosts
+func TestFakeServerCloseNotices(t *testing.T) {
+	s := mysqlxtest.NewServer(fakeUser, fakePasswd)
+	defer s.Close()
+	s.CloseNotices = []mysqlxtest.Notice{
+		mysqlxtest.WarningNotice(1000, "closing"),
+		mysqlxtest.GroupReplicationNotice(),
+	}
+
+	dc, err := NewConnector(fakeConfig(s))
+	if err != nil {
+		t.Fatalf("TestFakeServerCloseNotices: NewConnector failed: %v", err)
+	}
+	c := dc.(*connector)
+	conn, err := c.Connect(context.Background())
+	if err != nil {
+		t.Fatalf("TestFakeServerCloseNotices: Connect failed: %v", err)
+	}
+	gen := c.topology.gen()
+	if err := conn.Close(); err != nil {
+		t.Errorf("TestFakeServerCloseNotices: Close failed: %v", err)
+	}
+	if c.topology.gen() == gen {
+		t.Error("TestFakeServerCloseNotices: the global notice did not invalidate the roles")
+	}
+}
+
+// transactions send the right statements
+func TestFakeServerTx(t *testing.T) {
+	s := mysqlxtest.NewServer(fakeUser, fakePasswd)
+	defer s.Close()
+	s.HandleFunc(func(*Mysqlx_Sql.StmtExecute) *mysqlxtest.Result { return &mysqlxtest.Result{} })
+
+	db := fakeDB(t, s, "MYSQL41")
+	defer db.Close()
+
+	tx, err := db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelReadCommitted, ReadOnly: true})
+	if err != nil {
+		t.Fatalf("TestFakeServerTx: BeginTx failed: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("TestFakeServerTx: Commit failed: %v", err)
+	}
+
+	want := []string{"SET TRANSACTION ISOLATION LEVEL READ COMMITTED", "START TRANSACTION READ ONLY", "COMMIT"}
+	got := s.Statements()
+	if len(got) != len(want) {
+		t.Fatalf("TestFakeServerTx: statements: %q, expected: %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("TestFakeServerTx: statement %d: %q, expected: %q", i, got[i], want[i])
+		}
+	}
+}
+
+// read only transactions and queries go to the secondary, writes to the primary
+func TestFakeServerRouting(t *testing.T) {
+	servers := map[string]*mysqlxtest.Server{}
+	for addr, readOnly := range map[string]int64{"primary:33060": 0, "secondary:33060": 1} {
+		s := mysqlxtest.NewServer(fakeUser, fakePasswd)
+		defer s.Close()
+		s.Handle("SELECT @@super_read_only, @@read_only", &mysqlxtest.Result{
+			Columns: []mysqlxtest.Column{{Name: "@@super_read_only"}, {Name: "@@read_only"}},
+			Rows:    [][]interface{}{{readOnly, readOnly}},
+		})
+		s.HandleFunc(func(stmt *Mysqlx_Sql.StmtExecute) *mysqlxtest.Result {
+			if strings.Contains(string(stmt.GetStmt()), "replication_group_members") {
+				return &mysqlxtest.Result{Err: &mysqlxtest.Error{Code: 1146, SQLState: "42S02", Msg: "Table doesn't exist"}}
+			}
+			return &mysqlxtest.Result{Columns: []mysqlxtest.Column{{Name: "1"}}, Rows: [][]interface{}{{int64(1)}}}
+		})
+		servers[addr] = s
+	}
+
+	db := fakeDB(t, servers["primary:33060"], "MYSQL41", func(cfg *Config) {
+		cfg.Addr = "secondary:33060,primary:33060"
+		cfg.RouteReadOnly = true
+		cfg.RouteQueries = true
+		cfg.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return servers[addr].DialContext(ctx, network, addr)
+		}
+	})
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	var n int
+	if err := db.QueryRow("SELECT 'query'").Scan(&n); err != nil {
+		t.Fatalf("TestFakeServerRouting: query failed: %v", err)
+	}
+	if _, err := db.Exec("UPDATE 'exec'"); err != nil {
+		t.Fatalf("TestFakeServerRouting: exec failed: %v", err)
+	}
+	tx, err := db.BeginTx(context.Background(), &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		t.Fatalf("TestFakeServerRouting: BeginTx failed: %v", err)
+	}
+	if err := tx.QueryRow("SELECT 'read only tx'").Scan(&n); err != nil {
+		t.Fatalf("TestFakeServerRouting: query in tx failed: %v", err)
+	}
+	tx.Rollback()
+
+	want := map[string][]string{
+		"primary:33060":   {"UPDATE 'exec'"},
+		"secondary:33060": {"SELECT 'query'", "START TRANSACTION READ ONLY", "SELECT 'read only tx'", "ROLLBACK"},
+	}
+	for addr, s := range servers {
+		var got []string
+		for _, stmt := range s.Statements() {
+			if !strings.Contains(stmt, "read_only") && !strings.Contains(stmt, "replication_group_members") {
+				got = append(got, stmt)
+			}
+		}
+		if !reflect.DeepEqual(got, want[addr]) {
+			t.Errorf("TestFakeServerRouting: %s: statements: %q, expected: %q", addr, got, want[addr])
+		}
+	}
+}
+
+// a session recorded with the wire tap can be replayed to the driver
+func TestFakeServerWireTap(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wiretap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "session.txt")
+
+	s := mysqlxtest.NewServer(fakeUser, fakePasswd)
+	defer s.Close()
+	s.Handle("SELECT name FROM t", &mysqlxtest.Result{
+		Columns: []mysqlxtest.Column{{Name: "name"}},
+		Rows:    [][]interface{}{{"recorded"}},
+	})
+
+	query := func(dial DialContextFunc) (string, error) {
+		connector, err := NewConnector(fakeConfig(s, func(cfg *Config) {
+			cfg.DialContext = dial
+			cfg.WireTap = path
+		}))
+		if err != nil {
+			return "", err
+		}
+		db := sql.OpenDB(connector)
+		defer db.Close()
+		var name string
+		err = db.QueryRow("SELECT name FROM t").Scan(&name)
+		return name, err
+	}
+
+	if name, err := query(s.DialContext); err != nil || name != "recorded" {
+		t.Fatalf("TestFakeServerWireTap: recording: name: %q, err: %v", name, err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	replay, err := mysqlxtest.NewReplayServer(f)
+	if err != nil {
+		t.Fatalf("TestFakeServerWireTap: NewReplayServer failed: %v", err)
+	}
+	defer replay.Close()
+
+	if name, err := query(replay.DialContext); err != nil || name != "recorded" {
+		t.Fatalf("TestFakeServerWireTap: replay: name: %q, err: %v", name, err)
+	}
+	replay.Close()
+	if errs := replay.Errors(); len(errs) > 0 {
+		t.Errorf("TestFakeServerWireTap: replay errors: %v", errs)
+	}
+}
+
+// Find sends the parsed criteria and the notices can be read with Raw
+func TestFakeServerFind(t *testing.T) {
+	s := mysqlxtest.NewServer(fakeUser, fakePasswd)
+	defer s.Close()
+	var got *Mysqlx_Crud.Find
+	s.HandleFind(func(find *Mysqlx_Crud.Find) *mysqlxtest.Result {
+		got = find
+		return &mysqlxtest.Result{
+			Columns: []mysqlxtest.Column{{Name: "doc"}},
+			Rows:    [][]interface{}{{`{"_id": "1", "age": 30}`}},
+			Notices: []mysqlxtest.Notice{mysqlxtest.WarningNotice(1265, "Data truncated")},
+		}
+	})
+
+	db := fakeDB(t, s, "MYSQL41")
+	defer db.Close()
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("TestFakeServerFind: Conn failed: %v", err)
+	}
+	defer conn.Close()
+
+	err = conn.Raw(func(driverConn interface{}) error {
+		xc, ok := driverConn.(XConn)
+		if !ok {
+			t.Fatalf("TestFakeServerFind: %T does not implement XConn", driverConn)
+		}
+		rows, err := xc.Find(context.Background(), "s", "c", "age > 18", 10)
+		if err != nil {
+			return err
+		}
+		dest := make([]driver.Value, 1)
+		if err := rows.Next(dest); err != nil {
+			return err
+		}
+		if doc, _ := dest[0].([]byte); string(doc) != `{"_id": "1", "age": 30}` {
+			t.Errorf("TestFakeServerFind: got document %q", dest[0])
+		}
+		if err := rows.Close(); err != nil {
+			return err
+		}
+
+		want := []Notice{{Type: NoticeWarning, Level: "WARNING", Code: 1265, Msg: "Data truncated"}}
+		if notices := xc.Notices(); !reflect.DeepEqual(notices, want) {
+			t.Errorf("TestFakeServerFind: got notices %#v, expected %#v", notices, want)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("TestFakeServerFind: Find failed: %v", err)
+	}
+
+	if got == nil {
+		t.Fatal("TestFakeServerFind: the server received no Find")
+	}
+	if got.GetCollection().GetSchema() != "s" || got.GetCollection().GetName() != "c" || got.GetLimit().GetRowCount() != 10 {
+		t.Errorf("TestFakeServerFind: got Find %s", proto.CompactTextString(got))
+	}
+	if criteria := operator(">", member("age"), literal(int64(18))); !proto.Equal(got.Criteria, criteria) {
+		t.Errorf("TestFakeServerFind: got criteria %s", proto.CompactTextString(got.Criteria))
+	}
+}
+
+// Insert, Update and Delete send their CRUD messages and return the
+// rows affected
+func TestFakeServerCrud(t *testing.T) {
+	s := mysqlxtest.NewServer(fakeUser, fakePasswd)
+	defer s.Close()
+	var got []proto.Message
+	s.HandleCrud(func(msg proto.Message) *mysqlxtest.Result {
+		got = append(got, msg)
+		return &mysqlxtest.Result{RowsAffected: 2}
+	})
+
+	db := fakeDB(t, s, "MYSQL41")
+	defer db.Close()
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("TestFakeServerCrud: Conn failed: %v", err)
+	}
+	defer conn.Close()
+
+	ctx := context.Background()
+	err = conn.Raw(func(driverConn interface{}) error {
+		xc := driverConn.(XConn)
+		results := make([]driver.Result, 3)
+		var err error
+		if results[0], err = xc.Insert(ctx, "s", "c", `{"age": 30}`, map[string]int{"age": 40}); err != nil {
+			return err
+		}
+		if results[1], err = xc.Update(ctx, "s", "c", "age > :age", map[string]string{"age": "age + :step", "name": "'Jo'"},
+			sql.Named("age", 18), sql.Named("step", 1)); err != nil {
+			return err
+		}
+		if results[2], err = xc.Delete(ctx, "s", "c", "age > :age", 5, sql.Named("age", 18)); err != nil {
+			return err
+		}
+		for i, result := range results {
+			if n, _ := result.RowsAffected(); n != 2 {
+				t.Errorf("TestFakeServerCrud: result %d: got %d rows affected, expected 2", i, n)
+			}
+		}
+
+		for i, bad := range []func() error{
+			func() error { _, err := xc.Insert(ctx, "s", "c"); return err },
+			func() error { _, err := xc.Insert(ctx, "s", "c", "{"); return err },
+			func() error { _, err := xc.Update(ctx, "s", "c", "", map[string]string{"a": "1"}); return err },
+			func() error { _, err := xc.Update(ctx, "s", "c", "true", map[string]string{"a + 1": "1"}); return err },
+			func() error { _, err := xc.Delete(ctx, "s", "c", "", 0); return err },
+			func() error { _, err := xc.Delete(ctx, "s", "c", "true", 0, sql.Named("unused", 1)); return err },
+		} {
+			if err := bad(); err == nil {
+				t.Errorf("TestFakeServerCrud: bad call %d succeeded", i)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("TestFakeServerCrud: failed: %v", err)
+	}
+
+	if len(got) != 3 {
+		t.Fatalf("TestFakeServerCrud: the server received %d messages, expected 3", len(got))
+	}
+	placeholder := func(position uint32) *Mysqlx_Expr.Expr {
+		return &Mysqlx_Expr.Expr{Type: Mysqlx_Expr.Expr_PLACEHOLDER.Enum(), Position: proto.Uint32(position)}
+	}
+
+	insert, _ := got[0].(*Mysqlx_Crud.Insert)
+	var docs []string
+	for _, row := range insert.GetRow() {
+		docs = append(docs, string(row.GetField()[0].GetLiteral().GetVOctets().GetValue()))
+	}
+	if !reflect.DeepEqual(docs, []string{`{"age": 30}`, `{"age":40}`}) {
+		t.Errorf("TestFakeServerCrud: got Insert %s", proto.CompactTextString(got[0]))
+	}
+
+	update, _ := got[1].(*Mysqlx_Crud.Update)
+	operations := update.GetOperation()
+	if !proto.Equal(update.GetCriteria(), operator(">", member("age"), placeholder(0))) || len(operations) != 2 ||
+		!proto.Equal(operations[0].GetValue(), operator("+", member("age"), placeholder(1))) ||
+		!proto.Equal(operations[1].GetValue(), literal("Jo")) ||
+		operations[1].GetSource().GetDocumentPath()[0].GetValue() != "name" ||
+		len(update.GetArgs()) != 2 {
+		t.Errorf("TestFakeServerCrud: got Update %s", proto.CompactTextString(got[1]))
+	}
+
+	del, _ := got[2].(*Mysqlx_Crud.Delete)
+	if !proto.Equal(del.GetCriteria(), operator(">", member("age"), placeholder(0))) ||
+		del.GetLimit().GetRowCount() != 5 || len(del.GetArgs()) != 1 {
+		t.Errorf("TestFakeServerCrud: got Delete %s", proto.CompactTextString(got[2]))
+	}
+}
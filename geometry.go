@@ -0,0 +1,461 @@
+// Go driver for MySQL X Protocol
+//
+// Copyright 2016 Simon J Mudd.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+// GEOMETRY columns are sent as BYTES with the GEOMETRY content type in
+// the internal format of MySQL: the SRID as 4 bytes little endian
+// followed by the shape as WKB (well known binary). They are returned
+// as a Geometry, which can be given back as an arg to insert or
+// compare the same value as MySQL accepts the same format for a
+// GEOMETRY.
+
+import (
+	"database/sql/driver"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// WKB geometry types
+const (
+	wkbPoint              = 1
+	wkbLineString         = 2
+	wkbPolygon            = 3
+	wkbMultiPoint         = 4
+	wkbMultiLineString    = 5
+	wkbMultiPolygon       = 6
+	wkbGeometryCollection = 7
+)
+
+// Shape is one of Point, LineString, Polygon, MultiPoint,
+// MultiLineString, MultiPolygon or GeometryCollection
+type Shape interface {
+	wkbType() uint32
+	appendWKB(b []byte) []byte // the shape after the byte order and type
+	appendWKT(b []byte) []byte // the shape after the type name
+}
+
+// Point is a position, in longitude and latitude order for a
+// geographic SRID as MySQL stores them
+type Point struct {
+	X, Y float64
+}
+
+// LineString is a line through its points
+type LineString []Point
+
+// Polygon is an outer ring and any inner rings, each a closed
+// LineString
+type Polygon []LineString
+
+// MultiPoint is a collection of points
+type MultiPoint []Point
+
+// MultiLineString is a collection of lines
+type MultiLineString []LineString
+
+// MultiPolygon is a collection of polygons
+type MultiPolygon []Polygon
+
+// GeometryCollection is a collection of shapes of any type
+type GeometryCollection []Shape
+
+// Geometry is the value of a GEOMETRY column. A Geometry with no
+// Shape is NULL.
+type Geometry struct {
+	SRID  uint32
+	Shape Shape
+}
+
+// Scan implements the Scanner interface. The value must be a Geometry
+// or []byte in the internal format of MySQL.
+func (g *Geometry) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*g = Geometry{}
+		return nil
+	case Geometry:
+		*g = v
+		return nil
+	case []byte:
+		geometry, err := parseGeometry(v)
+		if err != nil {
+			return err
+		}
+		*g = geometry
+		return nil
+	}
+	return fmt.Errorf("Can't convert %T to Geometry", value)
+}
+
+// Value implements the driver Valuer interface, returning the
+// Geometry in the internal format of MySQL
+func (g Geometry) Value() (driver.Value, error) {
+	if g.Shape == nil {
+		return nil, nil
+	}
+	b := make([]byte, 4, 64)
+	binary.LittleEndian.PutUint32(b, g.SRID)
+	return appendWKB(b, g.Shape), nil
+}
+
+// String returns the Geometry as WKT (well known text), e.g.
+// POINT(1 2), with the SRID in front if it is not 0
+func (g Geometry) String() string {
+	if g.Shape == nil {
+		return "NULL"
+	}
+	var b []byte
+	if g.SRID != 0 {
+		b = append(b, "SRID="...)
+		b = strconv.AppendUint(b, uint64(g.SRID), 10)
+		b = append(b, ';')
+	}
+	return string(appendWKT(b, g.Shape))
+}
+
+// parseGeometry reads a Geometry in the internal format of MySQL
+func parseGeometry(data []byte) (Geometry, error) {
+	if len(data) < 4 {
+		return Geometry{}, fmt.Errorf("parseGeometry: %d bytes is too short for a geometry", len(data))
+	}
+	r := &wkbReader{data: data[4:]}
+	shape, err := r.shape(0)
+	if err != nil {
+		return Geometry{}, fmt.Errorf("parseGeometry: %v", err)
+	}
+	if len(r.data) != 0 {
+		return Geometry{}, fmt.Errorf("parseGeometry: %d bytes after the geometry", len(r.data))
+	}
+	return Geometry{SRID: binary.LittleEndian.Uint32(data), Shape: shape}, nil
+}
+
+// maxWKBDepth is the deepest GeometryCollections are nested
+const maxWKBDepth = 32
+
+// wkbReader reads WKB, with the byte order of the shape being read
+type wkbReader struct {
+	data  []byte
+	order binary.ByteOrder
+	depth int // of GeometryCollections
+}
+
+func (r *wkbReader) uint32() (uint32, error) {
+	if len(r.data) < 4 {
+		return 0, fmt.Errorf("WKB ends early")
+	}
+	v := r.order.Uint32(r.data)
+	r.data = r.data[4:]
+	return v, nil
+}
+
+// count reads the number of items which follow, each at least size
+// bytes long, and checks there is room for them
+func (r *wkbReader) count(size int) (int, error) {
+	n, err := r.uint32()
+	if err != nil {
+		return 0, err
+	}
+	if uint64(n)*uint64(size) > uint64(len(r.data)) {
+		return 0, fmt.Errorf("WKB count %d does not fit in %d bytes", n, len(r.data))
+	}
+	return int(n), nil
+}
+
+func (r *wkbReader) point() (Point, error) {
+	if len(r.data) < 16 {
+		return Point{}, fmt.Errorf("WKB ends early")
+	}
+	p := Point{
+		X: math.Float64frombits(r.order.Uint64(r.data)),
+		Y: math.Float64frombits(r.order.Uint64(r.data[8:])),
+	}
+	r.data = r.data[16:]
+	return p, nil
+}
+
+func (r *wkbReader) points() ([]Point, error) {
+	n, err := r.count(16)
+	if err != nil {
+		return nil, err
+	}
+	points := make([]Point, n)
+	for i := range points {
+		if points[i], err = r.point(); err != nil {
+			return nil, err
+		}
+	}
+	return points, nil
+}
+
+func (r *wkbReader) polygon() (Polygon, error) {
+	n, err := r.count(4)
+	if err != nil {
+		return nil, err
+	}
+	rings := make(Polygon, n)
+	for i := range rings {
+		if rings[i], err = r.points(); err != nil {
+			return nil, err
+		}
+	}
+	return rings, nil
+}
+
+// shape reads a shape with its byte order and type, checking it is of
+// the type wanted if that is not 0
+func (r *wkbReader) shape(want uint32) (Shape, error) {
+	if len(r.data) < 1 {
+		return nil, fmt.Errorf("WKB ends early")
+	}
+	switch r.data[0] {
+	case 0:
+		r.order = binary.BigEndian
+	case 1:
+		r.order = binary.LittleEndian
+	default:
+		return nil, fmt.Errorf("invalid WKB byte order %d", r.data[0])
+	}
+	r.data = r.data[1:]
+	typ, err := r.uint32()
+	if err != nil {
+		return nil, err
+	}
+	if want != 0 && typ != want {
+		return nil, fmt.Errorf("WKB type %d where %d was expected", typ, want)
+	}
+
+	switch typ {
+	case wkbPoint:
+		return r.point()
+	case wkbLineString:
+		points, err := r.points()
+		return LineString(points), err
+	case wkbPolygon:
+		return r.polygon()
+	case wkbMultiPoint:
+		n, err := r.count(21)
+		if err != nil {
+			return nil, err
+		}
+		mp := make(MultiPoint, n)
+		for i := range mp {
+			s, err := r.shape(wkbPoint)
+			if err != nil {
+				return nil, err
+			}
+			mp[i] = s.(Point)
+		}
+		return mp, nil
+	case wkbMultiLineString:
+		n, err := r.count(9)
+		if err != nil {
+			return nil, err
+		}
+		ml := make(MultiLineString, n)
+		for i := range ml {
+			s, err := r.shape(wkbLineString)
+			if err != nil {
+				return nil, err
+			}
+			ml[i] = s.(LineString)
+		}
+		return ml, nil
+	case wkbMultiPolygon:
+		n, err := r.count(9)
+		if err != nil {
+			return nil, err
+		}
+		mp := make(MultiPolygon, n)
+		for i := range mp {
+			s, err := r.shape(wkbPolygon)
+			if err != nil {
+				return nil, err
+			}
+			mp[i] = s.(Polygon)
+		}
+		return mp, nil
+	case wkbGeometryCollection:
+		return r.collection()
+	}
+	return nil, fmt.Errorf("unsupported WKB type %d", typ)
+}
+
+// collection reads the shapes of a GeometryCollection
+func (r *wkbReader) collection() (GeometryCollection, error) {
+	if r.depth >= maxWKBDepth {
+		return nil, fmt.Errorf("WKB geometry collections nested more than %d deep", maxWKBDepth)
+	}
+	n, err := r.count(5)
+	if err != nil {
+		return nil, err
+	}
+	r.depth++
+	defer func() { r.depth-- }()
+	c := make(GeometryCollection, n)
+	for i := range c {
+		if c[i], err = r.shape(0); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// appendWKB appends a shape as little endian WKB
+func appendWKB(b []byte, s Shape) []byte {
+	b = append(b, 1)
+	b = appendUint32(b, s.wkbType())
+	return s.appendWKB(b)
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+func appendPoints(b []byte, points []Point) []byte {
+	b = appendUint32(b, uint32(len(points)))
+	for _, p := range points {
+		b = p.appendWKB(b)
+	}
+	return b
+}
+
+func (p Point) wkbType() uint32 { return wkbPoint }
+
+func (p Point) appendWKB(b []byte) []byte {
+	x, y := math.Float64bits(p.X), math.Float64bits(p.Y)
+	b = appendUint32(b, uint32(x))
+	b = appendUint32(b, uint32(x>>32))
+	b = appendUint32(b, uint32(y))
+	return appendUint32(b, uint32(y>>32))
+}
+
+func (l LineString) wkbType() uint32 { return wkbLineString }
+
+func (l LineString) appendWKB(b []byte) []byte { return appendPoints(b, l) }
+
+func (p Polygon) wkbType() uint32 { return wkbPolygon }
+
+func (p Polygon) appendWKB(b []byte) []byte {
+	b = appendUint32(b, uint32(len(p)))
+	for _, ring := range p {
+		b = appendPoints(b, ring)
+	}
+	return b
+}
+
+func (m MultiPoint) wkbType() uint32 { return wkbMultiPoint }
+
+func (m MultiPoint) appendWKB(b []byte) []byte {
+	b = appendUint32(b, uint32(len(m)))
+	for _, p := range m {
+		b = appendWKB(b, p)
+	}
+	return b
+}
+
+func (m MultiLineString) wkbType() uint32 { return wkbMultiLineString }
+
+func (m MultiLineString) appendWKB(b []byte) []byte {
+	b = appendUint32(b, uint32(len(m)))
+	for _, l := range m {
+		b = appendWKB(b, l)
+	}
+	return b
+}
+
+func (m MultiPolygon) wkbType() uint32 { return wkbMultiPolygon }
+
+func (m MultiPolygon) appendWKB(b []byte) []byte {
+	b = appendUint32(b, uint32(len(m)))
+	for _, p := range m {
+		b = appendWKB(b, p)
+	}
+	return b
+}
+
+func (c GeometryCollection) wkbType() uint32 { return wkbGeometryCollection }
+
+func (c GeometryCollection) appendWKB(b []byte) []byte {
+	b = appendUint32(b, uint32(len(c)))
+	for _, s := range c {
+		b = appendWKB(b, s)
+	}
+	return b
+}
+
+// wktNames are the WKT names of the WKB types
+var wktNames = map[uint32]string{
+	wkbPoint:              "POINT",
+	wkbLineString:         "LINESTRING",
+	wkbPolygon:            "POLYGON",
+	wkbMultiPoint:         "MULTIPOINT",
+	wkbMultiLineString:    "MULTILINESTRING",
+	wkbMultiPolygon:       "MULTIPOLYGON",
+	wkbGeometryCollection: "GEOMETRYCOLLECTION",
+}
+
+// appendWKT appends a shape as WKT
+func appendWKT(b []byte, s Shape) []byte {
+	b = append(b, wktNames[s.wkbType()]...)
+	return s.appendWKT(b)
+}
+
+// appendList appends n items written by item, in brackets and
+// separated by commas
+func appendList(b []byte, n int, item func(b []byte, i int) []byte) []byte {
+	b = append(b, '(')
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = item(b, i)
+	}
+	return append(b, ')')
+}
+
+func (p Point) appendCoordinates(b []byte) []byte {
+	b = strconv.AppendFloat(b, p.X, 'g', -1, 64)
+	b = append(b, ' ')
+	return strconv.AppendFloat(b, p.Y, 'g', -1, 64)
+}
+
+func appendPointList(b []byte, points []Point) []byte {
+	return appendList(b, len(points), func(b []byte, i int) []byte { return points[i].appendCoordinates(b) })
+}
+
+func (p Point) appendWKT(b []byte) []byte {
+	return appendPointList(b, []Point{p})
+}
+
+func (l LineString) appendWKT(b []byte) []byte { return appendPointList(b, l) }
+
+func (p Polygon) appendWKT(b []byte) []byte {
+	return appendList(b, len(p), func(b []byte, i int) []byte { return appendPointList(b, p[i]) })
+}
+
+func (m MultiPoint) appendWKT(b []byte) []byte {
+	return appendList(b, len(m), func(b []byte, i int) []byte { return m[i].appendWKT(b) })
+}
+
+func (m MultiLineString) appendWKT(b []byte) []byte {
+	return appendList(b, len(m), func(b []byte, i int) []byte { return m[i].appendWKT(b) })
+}
+
+func (m MultiPolygon) appendWKT(b []byte) []byte {
+	return appendList(b, len(m), func(b []byte, i int) []byte { return m[i].appendWKT(b) })
+}
+
+func (c GeometryCollection) appendWKT(b []byte) []byte {
+	if len(c) == 0 {
+		return append(b, " EMPTY"...)
+	}
+	return appendList(b, len(c), func(b []byte, i int) []byte { return appendWKT(b, c[i]) })
+}
@@ -0,0 +1,147 @@
+// Go driver for MySQL X Protocol
+//
+// Copyright 2016 Simon J Mudd.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Sql"
+	"github.com/sjmudd/go-mysqlx-driver/mysqlxtest"
+)
+
+// every shape is written and read back and shown as WKT
+func TestGeometry(t *testing.T) {
+	square := LineString{{0, 0}, {4, 0}, {4, 4}, {0, 4}, {0, 0}}
+	hole := LineString{{1, 1}, {2, 1}, {2, 2}, {1, 1}}
+	tests := []struct {
+		geometry Geometry
+		wkt      string
+	}{
+		{Geometry{Shape: Point{1, -2.5}}, "POINT(1 -2.5)"},
+		{Geometry{SRID: 4326, Shape: Point{51.5, -0.1}}, "SRID=4326;POINT(51.5 -0.1)"},
+		{Geometry{Shape: LineString{{0, 0}, {1, 1}}}, "LINESTRING(0 0,1 1)"},
+		{Geometry{Shape: Polygon{square, hole}}, "POLYGON((0 0,4 0,4 4,0 4,0 0),(1 1,2 1,2 2,1 1))"},
+		{Geometry{Shape: MultiPoint{{1, 2}, {3, 4}}}, "MULTIPOINT((1 2),(3 4))"},
+		{Geometry{Shape: MultiLineString{{{0, 0}, {1, 1}}, {{2, 2}, {3, 3}}}}, "MULTILINESTRING((0 0,1 1),(2 2,3 3))"},
+		{Geometry{Shape: MultiPolygon{{square}, {hole}}}, "MULTIPOLYGON(((0 0,4 0,4 4,0 4,0 0)),((1 1,2 1,2 2,1 1)))"},
+		{Geometry{Shape: GeometryCollection{Point{1, 2}, GeometryCollection{LineString{{0, 0}, {1, 1}}}}},
+			"GEOMETRYCOLLECTION(POINT(1 2),GEOMETRYCOLLECTION(LINESTRING(0 0,1 1)))"},
+		{Geometry{Shape: GeometryCollection{}}, "GEOMETRYCOLLECTION EMPTY"},
+	}
+	for _, test := range tests {
+		if got := test.geometry.String(); got != test.wkt {
+			t.Errorf("TestGeometry: String() = %q, expected %q", got, test.wkt)
+		}
+		v, err := test.geometry.Value()
+		if err != nil {
+			t.Errorf("TestGeometry: %s: Value failed: %v", test.wkt, err)
+			continue
+		}
+		var got Geometry
+		if err := got.Scan(v); err != nil {
+			t.Errorf("TestGeometry: %s: Scan failed: %v", test.wkt, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.geometry) {
+			t.Errorf("TestGeometry: %s: read back as %s", test.wkt, got)
+		}
+	}
+
+	// big endian WKB is read as well
+	data, _ := hex.DecodeString("00000000" + "00" + "00000001" + "3ff0000000000000" + "4000000000000000")
+	if g, err := parseGeometry(data); err != nil || !reflect.DeepEqual(g, Geometry{Shape: Point{1, 2}}) {
+		t.Errorf("TestGeometry: big endian point: got %v, %v", g, err)
+	}
+
+	var null Geometry
+	if v, err := null.Value(); v != nil || err != nil {
+		t.Errorf("TestGeometry: NULL Value() = %v, %v", v, err)
+	}
+	if err := null.Scan(nil); err != nil || null.Shape != nil {
+		t.Errorf("TestGeometry: Scan(nil) = %v, left %v", err, null)
+	}
+}
+
+func TestParseGeometryErrors(t *testing.T) {
+	for _, data := range []string{
+		"",
+		"000000",
+		"00000000",
+		"00000000" + "02" + "01000000",
+		"00000000" + "01" + "09000000",
+		"00000000" + "01" + "01000000" + "0000",
+		"00000000" + "01" + "02000000" + "ffffffff",
+		"00000000" + "01" + "04000000" + "01000000" + "01" + "02000000" + "00000000",
+		"00000000" + "01" + "01000000" + "000000000000f03f" + "0000000000000040" + "00",
+	} {
+		b, _ := hex.DecodeString(data)
+		if g, err := parseGeometry(b); err == nil {
+			t.Errorf("TestParseGeometryErrors: %s: expected an error, got %v", data, g)
+		}
+	}
+
+	// collections nested too deep are refused rather than followed
+	b := []byte{0, 0, 0, 0}
+	for i := 0; i <= maxWKBDepth; i++ {
+		b = append(b, 1, wkbGeometryCollection, 0, 0, 0, 1, 0, 0, 0)
+	}
+	b = append(b, 1, wkbGeometryCollection, 0, 0, 0, 0, 0, 0, 0)
+	if _, err := parseGeometry(b); err == nil {
+		t.Error("TestParseGeometryErrors: deeply nested collections: expected an error")
+	}
+}
+
+// JSON and GEOMETRY columns are returned as json.RawMessage and
+// Geometry and a Geometry can be given back as an arg
+func TestFakeServerContentTypes(t *testing.T) {
+	const query = "SELECT doc, location FROM places"
+	location := Geometry{SRID: 4326, Shape: Point{40.4, -3.7}}
+	wkb, _ := location.Value()
+
+	s := mysqlxtest.NewServer(fakeUser, fakePasswd)
+	defer s.Close()
+	s.Handle(query, &mysqlxtest.Result{
+		Columns: []mysqlxtest.Column{
+			{Name: "doc", ContentType: contentTypeJSON},
+			{Name: "location", ContentType: contentTypeGeometry},
+		},
+		Rows: [][]interface{}{{`{"name": "Madrid"}`, wkb}},
+	})
+	var got *Mysqlx_Sql.StmtExecute
+	s.HandleFunc(func(stmt *Mysqlx_Sql.StmtExecute) *mysqlxtest.Result {
+		got = stmt
+		return &mysqlxtest.Result{RowsAffected: 1}
+	})
+
+	db := fakeDB(t, s, "MYSQL41")
+	defer db.Close()
+	var (
+		doc   json.RawMessage
+		where Geometry
+	)
+	if err := db.QueryRow(query).Scan(&doc, &where); err != nil {
+		t.Fatalf("TestFakeServerContentTypes: Query failed: %v", err)
+	}
+	if string(doc) != `{"name": "Madrid"}` {
+		t.Errorf("TestFakeServerContentTypes: got doc %s", doc)
+	}
+	if !reflect.DeepEqual(where, location) {
+		t.Errorf("TestFakeServerContentTypes: got location %v, expected %v", where, location)
+	}
+
+	if _, err := db.Exec("INSERT INTO places (location) VALUES (?)", where); err != nil {
+		t.Fatalf("TestFakeServerContentTypes: Exec failed: %v", err)
+	}
+	if len(got.GetArgs()) != 1 || !reflect.DeepEqual(got.Args[0].GetScalar().GetVOctets().GetValue(), wkb) {
+		t.Errorf("TestFakeServerContentTypes: sent args %v, expected % x", got.GetArgs(), wkb)
+	}
+}
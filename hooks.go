@@ -0,0 +1,156 @@
+// Go driver for MySQL X Protocol
+//
+// Copyright 2016 Simon J Mudd.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+import (
+	"context"
+	"database/sql/driver"
+	"time"
+
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx"
+)
+
+// Hooks are called by the connections of a connector around each
+// statement, transaction boundary and CRUD operation, see
+// Config.Hooks. Any of them may be nil. They are called from the
+// goroutine using the connection and must not use it themselves.
+type Hooks struct {
+	// BeforeQuery is called before the statement is sent. The
+	// context it returns, for example holding a new span, is the
+	// one AfterQuery and OnNotice are given.
+	BeforeQuery func(ctx context.Context, q *QueryInfo) context.Context
+
+	// AfterQuery is called once the statement has finished: for a
+	// query when its rows are closed, otherwise when the result has
+	// been received.
+	AfterQuery func(ctx context.Context, q *QueryInfo)
+
+	// OnNotice is called for each notice the server sends, with the
+	// context of the statement running if there is one.
+	OnNotice func(ctx context.Context, n Notice)
+
+	// OnConnect is called once each connection to a server has
+	// succeeded or failed.
+	OnConnect func(ctx context.Context, c *ConnectInfo)
+
+	// RedactArgs, if set, is given the args of each statement and
+	// returns what the hooks see in their place, nil to hide them all.
+	RedactArgs func(args []driver.Value) []driver.Value
+}
+
+// QueryInfo describes a statement passed to Hooks
+type QueryInfo struct {
+	Op           string         // Query, Exec, Begin, Commit, Rollback, Find, Insert, Update, Delete or Admin
+	Message      string         // type of the message sent, e.g. SQL_STMT_EXECUTE or CRUD_FIND
+	Statement    string         // the SQL, for CRUD the collection and criteria, for Admin the command
+	Args         []driver.Value // bound args, after Hooks.RedactArgs
+	Addr         string         // address of the server
+	Start        time.Time
+	Duration     time.Duration // set for AfterQuery
+	Rows         int64         // rows read, for Query and Find
+	RowsAffected int64         // for Exec, Insert, Update and Delete
+	Warnings     []Notice      // warning notices received
+	Err          error         // the error returned to the caller or while reading the rows
+}
+
+// ConnectInfo describes a connection passed to Hooks.OnConnect
+type ConnectInfo struct {
+	Net      string
+	Addr     string
+	Duration time.Duration // time taken to dial and complete the handshake
+	Err      error
+}
+
+// queryHook is a statement the hooks are being told about. A nil
+// queryHook does nothing so callers need not check for hooks.
+type queryHook struct {
+	hooks *Hooks
+	ctx   context.Context
+	info  QueryInfo
+	rows  uint64 // the connection's count of rows when the statement started
+}
+
+// beforeQuery calls Hooks.BeforeQuery and returns the queryHook to
+// finish with done, nil if there are no hooks
+func (mc *mysqlXConn) beforeQuery(ctx context.Context, op string, message Mysqlx.ClientMessages_Type, statement string, args []driver.Value) *queryHook {
+	h := mc.hooks
+	if h == nil || (h.BeforeQuery == nil && h.AfterQuery == nil && h.OnNotice == nil) {
+		return nil
+	}
+	if h.RedactArgs != nil && len(args) > 0 {
+		args = h.RedactArgs(args)
+	}
+	qh := &queryHook{
+		hooks: h,
+		ctx:   ctx,
+		info: QueryInfo{
+			Op:        op,
+			Message:   message.String(),
+			Statement: statement,
+			Args:      args,
+			Addr:      mc.cfg.addr,
+			Start:     time.Now(),
+		},
+		rows: mc.stats.rows,
+	}
+	if h.BeforeQuery != nil {
+		if hctx := h.BeforeQuery(ctx, &qh.info); hctx != nil {
+			qh.ctx = hctx
+		}
+	}
+	mc.running = qh
+	return qh
+}
+
+// done fills in the outcome of the statement and calls
+// Hooks.AfterQuery
+func (qh *queryHook) done(mc *mysqlXConn, err error) {
+	if qh == nil {
+		return
+	}
+	if mc.running == qh {
+		mc.running = nil
+	}
+	qh.info.Duration = time.Since(qh.info.Start)
+	qh.info.Rows = int64(mc.stats.rows - qh.rows)
+	qh.info.Err = err
+	for _, n := range mc.notices {
+		if n.Type == NoticeWarning {
+			qh.info.Warnings = append(qh.info.Warnings, n)
+		}
+	}
+	if qh.hooks.AfterQuery != nil {
+		qh.hooks.AfterQuery(qh.ctx, &qh.info)
+	}
+}
+
+// onNotice calls Hooks.OnNotice
+func (mc *mysqlXConn) onNotice(n Notice) {
+	if mc.hooks == nil || mc.hooks.OnNotice == nil {
+		return
+	}
+	ctx := context.Background()
+	if mc.running != nil {
+		ctx = mc.running.ctx
+	}
+	mc.hooks.OnNotice(ctx, n)
+}
+
+// onConnect calls Hooks.OnConnect
+func (c *connector) onConnect(ctx context.Context, host Host, start time.Time, err error) {
+	if c.cfg.Hooks == nil || c.cfg.Hooks.OnConnect == nil {
+		return
+	}
+	c.cfg.Hooks.OnConnect(ctx, &ConnectInfo{
+		Net:      host.Net,
+		Addr:     host.Addr,
+		Duration: time.Since(start),
+		Err:      err,
+	})
+}
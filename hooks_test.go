@@ -0,0 +1,161 @@
+// Go driver for MySQL X Protocol
+//
+// Copyright 2016 Simon J Mudd.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+	"testing"
+
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Crud"
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Resultset"
+	"github.com/sjmudd/go-mysqlx-driver/mysqlxtest"
+)
+
+type hookKey struct{}
+
+// recordingHooks returns Hooks which record what they are told in the
+// given slices. BeforeQuery adds a value to the context which the
+// other hooks check they get back.
+func recordingHooks(t *testing.T, queries *[]QueryInfo, notices *[]Notice, connects *[]ConnectInfo) *Hooks {
+	return &Hooks{
+		BeforeQuery: func(ctx context.Context, q *QueryInfo) context.Context {
+			return context.WithValue(ctx, hookKey{}, q.Statement)
+		},
+		AfterQuery: func(ctx context.Context, q *QueryInfo) {
+			if ctx.Value(hookKey{}) != q.Statement {
+				t.Errorf("AfterQuery: %q given the context of %v", q.Statement, ctx.Value(hookKey{}))
+			}
+			*queries = append(*queries, *q)
+		},
+		OnNotice: func(ctx context.Context, n Notice) {
+			if ctx.Value(hookKey{}) == nil {
+				t.Errorf("OnNotice: %v not given the context of a statement", n)
+			}
+			*notices = append(*notices, n)
+		},
+		OnConnect: func(ctx context.Context, c *ConnectInfo) {
+			*connects = append(*connects, *c)
+		},
+		RedactArgs: func(args []driver.Value) []driver.Value {
+			return []driver.Value{"?"}
+		},
+	}
+}
+
+// the hooks are called around queries, execs, transactions and finds
+func TestHooks(t *testing.T) {
+	s := mysqlxtest.NewServer(fakeUser, fakePasswd)
+	defer s.Close()
+	s.Handle("SELECT 1", &mysqlxtest.Result{
+		Columns: []mysqlxtest.Column{{Name: "1", Type: Mysqlx_Resultset.ColumnMetaData_SINT}},
+		Rows:    [][]interface{}{{1}, {2}},
+		Notices: []mysqlxtest.Notice{mysqlxtest.WarningNotice(1265, "Data truncated")},
+	})
+	s.Handle("DELETE FROM t", &mysqlxtest.Result{RowsAffected: 3})
+	s.Handle("START TRANSACTION READ ONLY", &mysqlxtest.Result{})
+	s.Handle("COMMIT", &mysqlxtest.Result{})
+	s.Handle("SELECT * FROM missing", &mysqlxtest.Result{
+		Err: &mysqlxtest.Error{Code: 1146, SQLState: "42S02", Msg: "Table 'test.missing' doesn't exist"},
+	})
+	s.HandleFind(func(find *Mysqlx_Crud.Find) *mysqlxtest.Result {
+		return &mysqlxtest.Result{
+			Columns: []mysqlxtest.Column{{Name: "doc"}},
+			Rows:    [][]interface{}{{`{"_id": "1"}`}},
+		}
+	})
+
+	var queries []QueryInfo
+	var notices []Notice
+	var connects []ConnectInfo
+	db := fakeDB(t, s, "MYSQL41", func(cfg *Config) {
+		cfg.Hooks = recordingHooks(t, &queries, &notices, &connects)
+	})
+	defer db.Close()
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("TestHooks: Conn failed: %v", err)
+	}
+	defer conn.Close()
+	ctx := context.Background()
+
+	rows, err := conn.QueryContext(ctx, "SELECT 1", 42)
+	if err != nil {
+		t.Fatalf("TestHooks: Query failed: %v", err)
+	}
+	for rows.Next() {
+	}
+	rows.Close()
+	if _, err := conn.ExecContext(ctx, "DELETE FROM t"); err != nil {
+		t.Fatalf("TestHooks: Exec failed: %v", err)
+	}
+	if _, err := conn.ExecContext(ctx, "SELECT * FROM missing"); err == nil {
+		t.Fatal("TestHooks: Exec gives no error")
+	}
+	tx, err := conn.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		t.Fatalf("TestHooks: BeginTx failed: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("TestHooks: Commit failed: %v", err)
+	}
+	err = conn.Raw(func(driverConn interface{}) error {
+		rows, err := driverConn.(XConn).Find(ctx, "s", "c", "age > 18", 10)
+		if err != nil {
+			return err
+		}
+		for rows.Next(make([]driver.Value, 1)) == nil {
+		}
+		return rows.Close()
+	})
+	if err != nil {
+		t.Fatalf("TestHooks: Find failed: %v", err)
+	}
+
+	if len(connects) != 1 || connects[0].Err != nil || connects[0].Addr != fakeConfig(s).Addr || connects[0].Duration <= 0 {
+		t.Errorf("TestHooks: connects: %+v", connects)
+	}
+	var warnings []Notice
+	for _, n := range notices {
+		if n.Type == NoticeWarning {
+			warnings = append(warnings, n)
+		}
+	}
+	if len(warnings) != 1 || warnings[0].Code != 1265 {
+		t.Errorf("TestHooks: notices: %+v", notices)
+	}
+
+	type summary struct {
+		Op, Message, Statement string
+		Args                   []driver.Value
+		Rows, RowsAffected     int64
+		Warnings               int
+		Failed                 bool
+	}
+	var got []summary
+	for _, q := range queries {
+		if q.Duration <= 0 || q.Start.IsZero() {
+			t.Errorf("TestHooks: %s %q: start: %v, duration: %v", q.Op, q.Statement, q.Start, q.Duration)
+		}
+		got = append(got, summary{q.Op, q.Message, q.Statement, q.Args, q.Rows, q.RowsAffected, len(q.Warnings), q.Err != nil})
+	}
+	expected := []summary{
+		{"Query", "SQL_STMT_EXECUTE", "SELECT 1", []driver.Value{"?"}, 2, 0, 1, false},
+		{"Exec", "SQL_STMT_EXECUTE", "DELETE FROM t", nil, 0, 3, 0, false},
+		{"Exec", "SQL_STMT_EXECUTE", "SELECT * FROM missing", nil, 0, 0, 0, true},
+		{"Begin", "SQL_STMT_EXECUTE", "START TRANSACTION READ ONLY", nil, 0, 0, 0, false},
+		{"Commit", "SQL_STMT_EXECUTE", "COMMIT", nil, 0, 0, 0, false},
+		{"Find", "CRUD_FIND", `s.c.find("age > 18").limit(10)`, nil, 1, 0, 0, false},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("TestHooks: got:\n%+v\nexpected:\n%+v", got, expected)
+	}
+}
@@ -0,0 +1,180 @@
+// Go driver for MySQL X Protocol
+//
+// Copyright 2016 Simon J Mudd.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+// This file handles lists of hosts used for failover. A list can be
+// given wherever a single address is accepted:
+//
+//	user:pass@tcp(h1:33060,h2:33060)/db
+//	user:pass@tcp((address=h1:33060,priority=100),(address=h2:33060,priority=90))/db
+//	mysqlx://user:pass@[h1:33060,h2:33060]/db
+//	mysqlx://user:pass@[(address=h1:33060,priority=100),(address=h2,priority=90)]/db
+//
+// Hosts are tried in order of descending priority, or in the order
+// given if no priorities are set. With randomizeHosts=true hosts of
+// the same priority are tried in a random order.
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const maxHostPriority = 100
+
+var (
+	errInvalidHostList       = errors.New("Invalid host list: empty address")
+	errInvalidHostPriority   = errors.New("Invalid host list: priority must be between 0 and 100")
+	errInvalidHostPriorities = errors.New("Invalid host list: either all or none of the hosts must have a priority")
+)
+
+// Host is one of the servers the driver may connect to
+type Host struct {
+	Net      string // Network type, tcp or unix
+	Addr     string // Network address
+	Priority int    // 0 to 100, higher priority hosts are tried first
+}
+
+// isHostList returns true if the address holds more than one host or
+// uses the (address=...) syntax
+func isHostList(addr string) bool {
+	return strings.ContainsAny(addr, ",(")
+}
+
+// splitHostList splits a comma separated list, ignoring commas
+// within parentheses
+func splitHostList(list string) ([]string, error) {
+	var parts []string
+	depth, start := 0, 0
+	for i := 0; i < len(list); i++ {
+		switch list[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth < 0 {
+				return nil, fmt.Errorf("Invalid host list: unbalanced parentheses: %s", list)
+			}
+		case ',':
+			if depth == 0 {
+				parts = append(parts, strings.TrimSpace(list[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("Invalid host list: unbalanced parentheses: %s", list)
+	}
+	return append(parts, strings.TrimSpace(list[start:])), nil
+}
+
+// parseHostList parses a list of hosts in either the comma separated
+// or the (address=...,priority=...) form. defaultPort is added to any
+// tcp address without a port.
+func parseHostList(list string, defaultPort string) ([]Host, error) {
+	parts, err := splitHostList(list)
+	if err != nil {
+		return nil, err
+	}
+
+	hosts := make([]Host, 0, len(parts))
+	withPriority := 0
+	for _, part := range parts {
+		host := Host{Priority: -1}
+		if strings.HasPrefix(part, "(") && strings.HasSuffix(part, ")") {
+			for _, kv := range strings.Split(part[1:len(part)-1], ",") {
+				kv := strings.SplitN(kv, "=", 2)
+				if len(kv) != 2 {
+					return nil, fmt.Errorf("Invalid host list: expected key=value, got: %s", part)
+				}
+				key, value := strings.ToLower(strings.TrimSpace(kv[0])), strings.TrimSpace(kv[1])
+				switch key {
+				case "address":
+					host.Addr = value
+				case "priority":
+					if host.Priority, err = strconv.Atoi(value); err != nil || host.Priority < 0 || host.Priority > maxHostPriority {
+						return nil, errInvalidHostPriority
+					}
+				default:
+					return nil, fmt.Errorf("Invalid host list: unknown key %q", key)
+				}
+			}
+		} else {
+			host.Addr = part
+		}
+		if host.Addr == "" {
+			return nil, errInvalidHostList
+		}
+		if host.Priority >= 0 {
+			withPriority++
+		}
+
+		if strings.HasPrefix(host.Addr, "/") || strings.HasPrefix(host.Addr, ".") {
+			host.Net = "unix"
+		} else {
+			host.Net = "tcp"
+			if _, _, err := net.SplitHostPort(host.Addr); err != nil && defaultPort != "" {
+				host.Addr = net.JoinHostPort(strings.Trim(host.Addr, "[]"), defaultPort)
+			}
+		}
+		hosts = append(hosts, host)
+	}
+
+	if withPriority != 0 && withPriority != len(hosts) {
+		return nil, errInvalidHostPriorities
+	}
+	if withPriority == 0 {
+		for i := range hosts {
+			hosts[i].Priority = 0
+		}
+	}
+
+	return hosts, nil
+}
+
+// formatHostList returns the hosts in a form parseHostList understands
+func formatHostList(hosts []Host) string {
+	withPriority := false
+	for i := range hosts {
+		if hosts[i].Priority != 0 {
+			withPriority = true
+		}
+	}
+
+	parts := make([]string, len(hosts))
+	for i := range hosts {
+		if withPriority {
+			parts[i] = fmt.Sprintf("(address=%s,priority=%d)", hosts[i].Addr, hosts[i].Priority)
+		} else {
+			parts[i] = hosts[i].Addr
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// orderHosts returns a copy of the hosts in the order they should be
+// tried: by descending priority and, if randomize is set, shuffled
+// within each priority.
+func orderHosts(hosts []Host, randomize bool) []Host {
+	ordered := make([]Host, len(hosts))
+	copy(ordered, hosts)
+	if randomize {
+		rand.Shuffle(len(ordered), func(i, j int) {
+			ordered[i], ordered[j] = ordered[j], ordered[i]
+		})
+	}
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Priority > ordered[j].Priority
+	})
+	return ordered
+}
@@ -0,0 +1,159 @@
+// Go driver for MySQL X Protocol
+//
+// Copyright 2016 Simon J Mudd.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// test the different ways of giving a list of hosts
+func TestHostList(t *testing.T) {
+	tests := []struct {
+		dsn   string
+		hosts []Host
+	}{
+		{
+			"user:pass@tcp(h1:33060,h2)/test?xprotocol=1",
+			[]Host{{"tcp", "h1:33060", 0}, {"tcp", "h2:33060", 0}},
+		},
+		{
+			"user:pass@tcp((address=h1:33061,priority=90),(address=h2,priority=100))/test?xprotocol=1",
+			[]Host{{"tcp", "h1:33061", 90}, {"tcp", "h2:33060", 100}},
+		},
+		{
+			"mysqlx://user:pass@[h1,[::1]:33061,(/tmp/mysqlx.sock)]/test",
+			nil, // parentheses without address= are not valid
+		},
+		{
+			"mysqlx://user:pass@[h1,[::1]:33061]/test",
+			[]Host{{"tcp", "h1:33060", 0}, {"tcp", "[::1]:33061", 0}},
+		},
+		{
+			"mysqlx://user:pass@[(address=h1,priority=100),(address=/tmp/mysqlx.sock,priority=50)]/test",
+			[]Host{{"tcp", "h1:33060", 100}, {"unix", "/tmp/mysqlx.sock", 50}},
+		},
+	}
+
+	for _, test := range tests {
+		cfg, err := ParseDSN(test.dsn)
+		if test.hosts == nil {
+			if err == nil {
+				t.Errorf("TestHostList: dsn: %s, expected an error", test.dsn)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("TestHostList: dsn: %s, ParseDSN gives error: %v", test.dsn, err)
+			continue
+		}
+		if !reflect.DeepEqual(cfg.Hosts, test.hosts) {
+			t.Errorf("TestHostList: dsn: %s, got hosts: %+v, expected: %+v", test.dsn, cfg.Hosts, test.hosts)
+		}
+		if cfg.Addr != test.hosts[0].Addr {
+			t.Errorf("TestHostList: dsn: %s, Addr: %q, expected first host: %q", test.dsn, cfg.Addr, test.hosts[0].Addr)
+		}
+
+		// and check it survives FormatDSN
+		cfg2, err := ParseDSN(cfg.FormatDSN())
+		if err != nil {
+			t.Errorf("TestHostList: formatted dsn: %s, ParseDSN gives error: %v", cfg.FormatDSN(), err)
+		} else if !reflect.DeepEqual(cfg2.Hosts, test.hosts) {
+			t.Errorf("TestHostList: formatted dsn: %s, got hosts: %+v, expected: %+v", cfg.FormatDSN(), cfg2.Hosts, test.hosts)
+		}
+	}
+
+	bad := []string{
+		"user:pass@tcp((address=h1,priority=100),h2)/test?xprotocol=1",
+		"user:pass@tcp((address=h1,priority=101),(address=h2,priority=1))/test?xprotocol=1",
+		"user:pass@tcp((address=h1,weight=1),h2)/test?xprotocol=1",
+		"user:pass@tcp(h1,,h2)/test?xprotocol=1",
+	}
+	for _, dsn := range bad {
+		if _, err := ParseDSN(dsn); err == nil {
+			t.Errorf("TestHostList: dsn: %s, ParseDSN did not return an error", dsn)
+		}
+	}
+}
+
+// test hosts are tried by priority
+func TestOrderHosts(t *testing.T) {
+	hosts := []Host{{"tcp", "a:1", 10}, {"tcp", "b:1", 90}, {"tcp", "c:1", 90}, {"tcp", "d:1", 50}}
+
+	ordered := orderHosts(hosts, false)
+	expected := []Host{{"tcp", "b:1", 90}, {"tcp", "c:1", 90}, {"tcp", "d:1", 50}, {"tcp", "a:1", 10}}
+	if !reflect.DeepEqual(ordered, expected) {
+		t.Errorf("TestOrderHosts: got: %+v, expected: %+v", ordered, expected)
+	}
+
+	for i := 0; i < 20; i++ {
+		ordered = orderHosts(hosts, true)
+		if ordered[2].Addr != "d:1" || ordered[3].Addr != "a:1" {
+			t.Fatalf("TestOrderHosts: randomized order does not respect priority: %+v", ordered)
+		}
+	}
+}
+
+// test that hosts which refuse the connection or drop it during the
+// handshake are skipped
+func TestConnectFailover(t *testing.T) {
+	// a port nobody is listening on
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	refused := l.Addr().String()
+	l.Close()
+
+	// a server which hangs up straight away
+	l, err = net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	hangup := l.Addr().String()
+
+	cfg := NewConfig()
+	cfg.User = "user"
+	cfg.Timeout = time.Second
+	cfg.Hosts = []Host{{"tcp", refused, 100}, {"tcp", hangup, 50}}
+	c, err := NewConnector(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oldLogger := errLog
+	SetLogger(nullLogger{})
+	defer func() { errLog = oldLogger }()
+
+	_, err = c.Connect(context.Background())
+	if err == nil {
+		t.Fatalf("TestConnectFailover: Connect succeeded unexpectedly")
+	}
+	if !strings.Contains(err.Error(), refused) || !strings.Contains(err.Error(), hangup) {
+		t.Errorf("TestConnectFailover: error does not mention both hosts: %v", err)
+	}
+}
+
+type nullLogger struct{}
+
+func (nullLogger) Print(v ...interface{}) {}
@@ -0,0 +1,191 @@
+// Go driver for MySQL X Protocol
+//
+// Copyright 2016 Simon J Mudd.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+// With interpolateParams the args of Query and Exec are written into
+// the statement text on the client, for servers or statements which do
+// not accept args in StmtExecute. Strings are escaped with backslashes,
+// or by doubling quotes if the session has NO_BACKSLASH_ESCAPES in its
+// sql_mode. The sql_mode and character_set_client are read when the
+// connection is made and followed through the SessionVariableChanged
+// notices the server sends. Escaping is not safe in character sets such
+// as sjis or gbk where \ can be the second byte of a character, so
+// statements with args are refused while one of those is in use.
+//
+// A statement whose args can not be written as SQL, because the
+// number of ? does not match the number of args, an arg is of a type
+// with no literal form or it is a json.RawMessage which would lose its
+// content type, is sent with its args as usual.
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// unsafeCharsets are the multibyte character sets in which 0x5c (\)
+// can be the trailing byte of a character, see unsafeCollations
+var unsafeCharsets = map[string]bool{
+	"big5":    true,
+	"cp932":   true,
+	"gb2312":  true,
+	"gb18030": true,
+	"gbk":     true,
+	"sjis":    true,
+}
+
+// sessionVariables are the session variables interpolation depends on
+type sessionVariables struct {
+	noBackslashEscapes bool   // sql_mode has NO_BACKSLASH_ESCAPES
+	charsetClient      string // character_set_client
+}
+
+// errSkipInterpolation means the args are to be sent to the server
+var errSkipInterpolation = errors.New("args can not be interpolated")
+
+// readSessionVariables reads the session variables interpolation
+// depends on
+func (mc *mysqlXConn) readSessionVariables() error {
+	row, err := mc.queryRow("SELECT @@sql_mode, @@character_set_client")
+	if err != nil {
+		return fmt.Errorf("failed to read sql_mode and character_set_client: %v", err)
+	}
+	if len(row) != 2 {
+		return fmt.Errorf("failed to read sql_mode and character_set_client: got %d values", len(row))
+	}
+	mc.sessionVariableChanged("sql_mode", row[0])
+	mc.sessionVariableChanged("character_set_client", row[1])
+	return nil
+}
+
+// sessionVariableChanged notes the new value of a session variable
+// reported by the server
+func (mc *mysqlXConn) sessionVariableChanged(param string, value interface{}) {
+	switch strings.ToLower(param) {
+	case "sql_mode":
+		mc.session.noBackslashEscapes = strings.Contains(strings.ToUpper(valueString(value)), "NO_BACKSLASH_ESCAPES")
+	case "character_set_client":
+		mc.session.charsetClient = strings.ToLower(valueString(value))
+	default:
+		return
+	}
+	if mc.log.on(LogDebug) {
+		mc.log.printf(LogDebug, "mysqlXConn.sessionVariableChanged: %s = %v: noBackslashEscapes: %v, charsetClient: %s",
+			param, value, mc.session.noBackslashEscapes, mc.session.charsetClient)
+	}
+}
+
+// interpolate returns the statement and args to send, with the args
+// written into the statement if interpolateParams is set and that can
+// be done
+func (mc *mysqlXConn) interpolate(query string, args []driver.Value) (string, []driver.Value, error) {
+	if !mc.cfg.interpolateParams || len(args) == 0 {
+		return query, args, nil
+	}
+	interpolated, err := mc.interpolateParams(query, args)
+	switch err {
+	case nil:
+		return interpolated, nil, nil
+	case errSkipInterpolation:
+		if mc.log.on(LogDebug) {
+			mc.log.printf(LogDebug, "mysqlXConn.interpolate(%q,...): sending the args to the server", query)
+		}
+		return query, args, nil
+	}
+	return "", nil, err
+}
+
+// interpolateParams replaces each ? in the query with the next arg
+// written as an SQL literal
+func (mc *mysqlXConn) interpolateParams(query string, args []driver.Value) (string, error) {
+	if unsafeCharsets[mc.session.charsetClient] {
+		return "", fmt.Errorf("interpolateParams can not be used with character set %s", mc.session.charsetClient)
+	}
+	if strings.Count(query, "?") != len(args) {
+		return "", errSkipInterpolation
+	}
+
+	buf := make([]byte, 0, len(query)+16*len(args))
+	argPos := 0
+	for i := 0; i < len(query); i++ {
+		q := strings.IndexByte(query[i:], '?')
+		if q == -1 {
+			buf = append(buf, query[i:]...)
+			break
+		}
+		buf = append(buf, query[i:i+q]...)
+		i += q
+
+		arg := args[argPos]
+		argPos++
+
+		switch v := arg.(type) {
+		case nil:
+			buf = append(buf, "NULL"...)
+		case int64:
+			buf = strconv.AppendInt(buf, v, 10)
+		case uint64:
+			buf = strconv.AppendUint(buf, v, 10)
+		case float64:
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				return "", errSkipInterpolation
+			}
+			buf = strconv.AppendFloat(buf, v, 'g', -1, 64)
+		case bool:
+			if v {
+				buf = append(buf, '1')
+			} else {
+				buf = append(buf, '0')
+			}
+		case time.Time:
+			if v.IsZero() {
+				buf = append(buf, "'0000-00-00'"...)
+			} else {
+				buf = append(buf, '\'')
+				buf = v.In(mc.cfg.loc).AppendFormat(buf, timeFormat)
+				buf = append(buf, '\'')
+			}
+		case time.Duration:
+			buf = append(buf, '\'')
+			buf = append(buf, formatDuration(v)...)
+			buf = append(buf, '\'')
+		case []byte:
+			if v == nil {
+				buf = append(buf, "NULL"...)
+				break
+			}
+			buf = append(buf, "_binary'"...)
+			if mc.session.noBackslashEscapes {
+				buf = escapeBytesQuotes(buf, v)
+			} else {
+				buf = escapeBytesBackslash(buf, v)
+			}
+			buf = append(buf, '\'')
+		case string:
+			// the text of the statement is not converted
+			if mc.cfg.convertCharset {
+				return "", errSkipInterpolation
+			}
+			buf = append(buf, '\'')
+			if mc.session.noBackslashEscapes {
+				buf = escapeStringQuotes(buf, v)
+			} else {
+				buf = escapeStringBackslash(buf, v)
+			}
+			buf = append(buf, '\'')
+		default:
+			return "", errSkipInterpolation
+		}
+	}
+	return string(buf), nil
+}
@@ -0,0 +1,122 @@
+// Go driver for MySQL X Protocol
+//
+// Copyright 2016 Simon J Mudd.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+import (
+	"context"
+	"database/sql/driver"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Sql"
+	"github.com/sjmudd/go-mysqlx-driver/mysqlxtest"
+)
+
+// args are written as SQL literals
+func TestInterpolateParams(t *testing.T) {
+	madrid, err := time.LoadLocation("Europe/Madrid")
+	if err != nil {
+		t.Skipf("TestInterpolateParams: no time zone data: %v", err)
+	}
+	when := time.Date(2016, 8, 1, 10, 30, 15, 123000000, time.UTC)
+
+	tests := []struct {
+		noBackslashEscapes bool
+		query              string
+		args               []driver.Value
+		expected           string
+	}{
+		{false, "SELECT ?, ?, ?", []driver.Value{int64(-1), uint64(18446744073709551615), 1.5}, "SELECT -1, 18446744073709551615, 1.5"},
+		{false, "SELECT ?, ?, ?", []driver.Value{nil, true, false}, "SELECT NULL, 1, 0"},
+		{false, "SELECT ?, ?", []driver.Value{when, time.Time{}}, "SELECT '2016-08-01 12:30:15.123', '0000-00-00'"},
+		{false, "SELECT ?, ?", []driver.Value{[]byte("a'b\\c\x00"), []byte(nil)}, "SELECT _binary'a\\'b\\\\c\\0', NULL"},
+		{false, "SELECT * FROM t WHERE name = ? AND id > 3", []driver.Value{"O'Brien\n"}, "SELECT * FROM t WHERE name = 'O\\'Brien\\n' AND id > 3"},
+		{true, "SELECT ?", []driver.Value{"O'Brien\\"}, "SELECT 'O''Brien\\'"},
+		{true, "SELECT ?", []driver.Value{[]byte("a'b\\")}, "SELECT _binary'a''b\\'"},
+	}
+	for _, test := range tests {
+		mc := &mysqlXConn{cfg: &xconfig{loc: madrid}}
+		mc.session.noBackslashEscapes = test.noBackslashEscapes
+		got, err := mc.interpolateParams(test.query, test.args)
+		if err != nil {
+			t.Errorf("TestInterpolateParams: %q %v: error: %v", test.query, test.args, err)
+			continue
+		}
+		if got != test.expected {
+			t.Errorf("TestInterpolateParams: %q %v: got %q, expected %q", test.query, test.args, got, test.expected)
+		}
+	}
+
+	// args which can not be written are sent to the server
+	mc := &mysqlXConn{cfg: &xconfig{loc: time.UTC}}
+	for _, args := range [][]driver.Value{{int64(1), int64(2)}, {struct{}{}}} {
+		if _, err := mc.interpolateParams("SELECT ?", args); err != errSkipInterpolation {
+			t.Errorf("TestInterpolateParams: %v: got error %v, expected errSkipInterpolation", args, err)
+		}
+	}
+
+	// escaping is refused in character sets where it is unsafe
+	mc.sessionVariableChanged("character_set_client", "sjis")
+	if _, err := mc.interpolateParams("SELECT ?", []driver.Value{"x"}); err == nil || err == errSkipInterpolation {
+		t.Errorf("TestInterpolateParams: sjis: got error %v, expected a refusal", err)
+	}
+}
+
+// the statement is sent with the args written into it, following the
+// sql_mode reported by the server
+func TestFakeServerInterpolateParams(t *testing.T) {
+	s := mysqlxtest.NewServer(fakeUser, fakePasswd)
+	defer s.Close()
+	s.Handle("SELECT @@sql_mode, @@character_set_client", &mysqlxtest.Result{
+		Columns: []mysqlxtest.Column{{Name: "@@sql_mode"}, {Name: "@@character_set_client"}},
+		Rows:    [][]interface{}{{"STRICT_TRANS_TABLES", "utf8mb4"}},
+	})
+	s.Handle("SET sql_mode = 'NO_BACKSLASH_ESCAPES'", &mysqlxtest.Result{
+		Notices: []mysqlxtest.Notice{mysqlxtest.SessionVariableNotice("sql_mode", "NO_BACKSLASH_ESCAPES")},
+	})
+	var got []*Mysqlx_Sql.StmtExecute
+	s.HandleFunc(func(stmt *Mysqlx_Sql.StmtExecute) *mysqlxtest.Result {
+		got = append(got, stmt)
+		return &mysqlxtest.Result{RowsAffected: 1}
+	})
+
+	db := fakeDB(t, s, "MYSQL41", func(cfg *Config) { cfg.InterpolateParams = true })
+	defer db.Close()
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("TestFakeServerInterpolateParams: Conn failed: %v", err)
+	}
+	defer conn.Close()
+
+	for _, query := range []string{"UPDATE t SET name = ? WHERE id = ?", "SET sql_mode = 'NO_BACKSLASH_ESCAPES'", "UPDATE t SET name = ? WHERE id = ?"} {
+		var args []interface{}
+		if query != "SET sql_mode = 'NO_BACKSLASH_ESCAPES'" {
+			args = []interface{}{"it's", 7}
+		}
+		if _, err := conn.ExecContext(context.Background(), query, args...); err != nil {
+			t.Fatalf("TestFakeServerInterpolateParams: Exec(%q) failed: %v", query, err)
+		}
+	}
+
+	expected := []string{
+		`UPDATE t SET name = 'it\'s' WHERE id = 7`,
+		`UPDATE t SET name = 'it''s' WHERE id = 7`,
+	}
+	var statements []string
+	for _, stmt := range got {
+		if len(stmt.GetArgs()) != 0 {
+			t.Errorf("TestFakeServerInterpolateParams: %q sent with %d args", stmt.GetStmt(), len(stmt.GetArgs()))
+		}
+		statements = append(statements, string(stmt.GetStmt()))
+	}
+	if !reflect.DeepEqual(statements, expected) {
+		t.Errorf("TestFakeServerInterpolateParams: statements sent: %q, expected %q", statements, expected)
+	}
+}
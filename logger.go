@@ -0,0 +1,120 @@
+// Go driver for MySQL X Protocol
+//
+// Copyright 2016 Simon J Mudd.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+import (
+	"fmt"
+	"strings"
+)
+
+// LogLevel is the level of a message logged by the driver, and the
+// most verbose level a connector logs. The zero value logs errors only.
+type LogLevel int
+
+// Log levels, each including those before it
+const (
+	LogError LogLevel = iota // failures, also what the Logger set with SetLogger receives
+	LogWarn                  // protocol oddities the driver works around
+	LogDebug                 // state changes such as connecting, authenticating and routing
+	LogTrace                 // every frame sent and received and the reading of rows
+)
+
+var logLevelNames = []string{"error", "warn", "debug", "trace"}
+
+func (l LogLevel) String() string {
+	if l >= 0 && int(l) < len(logLevelNames) {
+		return logLevelNames[l]
+	}
+	return fmt.Sprintf("LogLevel(%d)", int(l))
+}
+
+// ParseLogLevel returns the level called error, warn, debug or trace
+func ParseLogLevel(name string) (LogLevel, error) {
+	for i, n := range logLevelNames {
+		if strings.EqualFold(name, n) {
+			return LogLevel(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown log level %q", name)
+}
+
+// LevelLogger receives the messages logged by the connections of a
+// connector, see Config.Logger. If the Logger passed to SetLogger is
+// also a LevelLogger it is used for connectors without a Logger of
+// their own, so errors and the other messages reach the same place.
+type LevelLogger interface {
+	Log(level LogLevel, msg string)
+}
+
+// LevelLoggerFunc lets an ordinary function be used as a LevelLogger
+type LevelLoggerFunc func(level LogLevel, msg string)
+
+// Log implements LevelLogger
+func (f LevelLoggerFunc) Log(level LogLevel, msg string) {
+	f(level, msg)
+}
+
+// errLogger sends messages to the Logger set with SetLogger, using
+// Log if it is a LevelLogger
+type errLogger struct{}
+
+func (errLogger) Log(level LogLevel, msg string) {
+	if l, ok := errLog.(LevelLogger); ok {
+		l.Log(level, msg)
+		return
+	}
+	if level != LogError {
+		msg = level.String() + ": " + msg
+	}
+	errLog.Print(msg)
+}
+
+// logger is what the driver logs through. Callers check on before
+// building the message so that nothing is formatted or allocated for
+// a level which is not logged:
+//
+//	if mc.log.on(LogDebug) {
+//		mc.log.printf(LogDebug, "...", args...)
+//	}
+//
+// A nil logger logs errors to errLog.
+type logger struct {
+	out   LevelLogger
+	level LogLevel
+}
+
+// newLogger returns the logger for the connections of a connector
+func newLogger(cfg *Config) *logger {
+	l := &logger{out: cfg.Logger, level: cfg.LogLevel}
+	if l.out == nil {
+		l.out = errLogger{}
+	}
+	return l
+}
+
+// on returns true if messages of the level are logged
+func (l *logger) on(level LogLevel) bool {
+	if l == nil {
+		return level == LogError
+	}
+	return level <= l.level
+}
+
+func (l *logger) printf(level LogLevel, format string, args ...interface{}) {
+	var out LevelLogger = errLogger{}
+	if l != nil {
+		out = l.out
+	}
+	out.Log(level, fmt.Sprintf(format, args...))
+}
+
+// print logs an error
+func (l *logger) print(err error) {
+	l.printf(LogError, "%v", err)
+}
@@ -0,0 +1,135 @@
+// Go driver for MySQL X Protocol
+//
+// Copyright 2016 Simon J Mudd.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sjmudd/go-mysqlx-driver/mysqlxtest"
+)
+
+// logRecorder keeps the messages it is sent. It is both a Logger and
+// a LevelLogger.
+type logRecorder struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (r *logRecorder) Log(level LogLevel, msg string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messages = append(r.messages, level.String()+": "+msg)
+}
+
+func (r *logRecorder) Print(v ...interface{}) {
+	r.Log(LogError, "Print: "+fmt.Sprint(v...))
+}
+
+// count returns how many messages start with the prefix
+func (r *logRecorder) count(prefix string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, m := range r.messages {
+		if strings.HasPrefix(m, prefix) {
+			n++
+		}
+	}
+	return n
+}
+
+func TestParseLogLevel(t *testing.T) {
+	for _, level := range []LogLevel{LogError, LogWarn, LogDebug, LogTrace} {
+		got, err := ParseLogLevel(strings.ToUpper(level.String()))
+		if err != nil || got != level {
+			t.Errorf("TestParseLogLevel: ParseLogLevel(%q) = %v, %v, want %v", level.String(), got, err, level)
+		}
+	}
+	if _, err := ParseLogLevel("verbose"); err == nil {
+		t.Errorf("TestParseLogLevel: ParseLogLevel(%q) gives no error", "verbose")
+	}
+}
+
+// a message at a level which is not logged costs nothing
+func TestLoggerDisabledAllocs(t *testing.T) {
+	r := &logRecorder{}
+	data := []byte{1, 2, 3}
+	for _, l := range []*logger{nil, {out: r, level: LogWarn}} {
+		allocs := testing.AllocsPerRun(100, func() {
+			if l.on(LogTrace) {
+				l.printf(LogTrace, "frame: type %d, payload: % x", 17, data)
+			}
+		})
+		if allocs != 0 {
+			t.Errorf("TestLoggerDisabledAllocs: %v allocations for a disabled level, want 0", allocs)
+		}
+	}
+	if len(r.messages) != 0 {
+		t.Errorf("TestLoggerDisabledAllocs: got messages %q", r.messages)
+	}
+}
+
+// the connections of a connector log to Config.Logger up to Config.LogLevel
+func TestLoggerConnector(t *testing.T) {
+	s := mysqlxtest.NewServer(fakeUser, fakePasswd)
+	defer s.Close()
+	s.Handle("SELECT 1", &mysqlxtest.Result{
+		Columns: []mysqlxtest.Column{{Name: "1"}},
+		Rows:    [][]interface{}{{int64(1)}},
+	})
+
+	for _, level := range []LogLevel{LogDebug, LogTrace} {
+		r := &logRecorder{}
+		db := fakeDB(t, s, "MYSQL41", func(cfg *Config) {
+			cfg.Logger = r
+			cfg.LogLevel = level
+		})
+		var one int64
+		if err := db.QueryRow("SELECT 1").Scan(&one); err != nil {
+			t.Fatalf("TestLoggerConnector: %v: QueryRow failed: %v", level, err)
+		}
+		db.Close()
+
+		if r.count("debug: ") == 0 {
+			t.Errorf("TestLoggerConnector: %v: no debug messages", level)
+		}
+		frames := r.count("trace: C -> S") + r.count("trace: S -> C")
+		switch {
+		case level == LogTrace && frames == 0:
+			t.Errorf("TestLoggerConnector: %v: no frames traced", level)
+		case level < LogTrace && r.count("trace: ") > 0:
+			t.Errorf("TestLoggerConnector: %v: got trace messages", level)
+		}
+	}
+}
+
+// a Logger given to SetLogger which is also a LevelLogger is sent
+// errors, and the messages of connectors without a Logger, with their level
+func TestSetLoggerLevelLogger(t *testing.T) {
+	oldLogger := errLog
+	r := &logRecorder{}
+	SetLogger(r)
+	defer func() { errLog = oldLogger }()
+
+	var l *logger
+	l.print(errors.New("broken"))
+	l = newLogger(&Config{LogLevel: LogDebug})
+	if l.on(LogDebug) {
+		l.printf(LogDebug, "connecting to %s", "h1")
+	}
+
+	want := []string{"error: broken", "debug: connecting to h1"}
+	if strings.Join(r.messages, "\n") != strings.Join(want, "\n") {
+		t.Errorf("TestSetLoggerLevelLogger: got %q, want %q", r.messages, want)
+	}
+}
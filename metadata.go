@@ -0,0 +1,86 @@
+// Go driver for MySQL X Protocol
+//
+// Copyright 2016 Simon J Mudd.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+// With compact metadata the server sends only the type information of
+// each column: the type, length, fractional digits, flags, collation
+// and content type. The column name, original name, table, original
+// table, schema and catalog are left out, which for a point lookup can
+// be more bytes than the row itself.
+//
+// So that Columns() still has names to return the first time a
+// statement is run on a connection it is sent asking for full
+// metadata and the names are kept, keyed by the statement text. Later
+// runs of the same statement ask for compact metadata and are given the
+// kept names. If the number of columns sent no longer matches, for
+// example because a table has been altered, the names are forgotten
+// and are missing from that result only. A table altered to rename a
+// column without changing the number of columns keeps the old name
+// until the connection is closed.
+
+import (
+	"context"
+
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Resultset"
+)
+
+// maxMetadataCacheSize is the most statements a connection keeps the
+// column names of. Once full the cache is emptied and starts again.
+const maxMetadataCacheSize = 1000
+
+// metadataCache holds the full metadata of statements by their text
+type metadataCache map[string][]*Mysqlx_Resultset.ColumnMetaData
+
+// compactMetadataKey is the context key of WithCompactMetadata
+type compactMetadataKey struct{}
+
+// WithCompactMetadata returns a context which makes QueryContext ask
+// for compact metadata, or not, whatever Config.CompactMetadata says
+func WithCompactMetadata(ctx context.Context, compact bool) context.Context {
+	return context.WithValue(ctx, compactMetadataKey{}, compact)
+}
+
+// wantCompactMetadata returns true if a query run with the context
+// should ask for compact metadata
+func (mc *mysqlXConn) wantCompactMetadata(ctx context.Context) bool {
+	if compact, ok := ctx.Value(compactMetadataKey{}).(bool); ok {
+		return compact
+	}
+	return mc.cfg.compactMetadata
+}
+
+// cachedMetadata returns the full metadata kept for the statement, nil
+// if there is none
+func (mc *mysqlXConn) cachedMetadata(query string) []*Mysqlx_Resultset.ColumnMetaData {
+	return mc.metadata[query]
+}
+
+// cacheMetadata keeps the full metadata of the statement
+func (mc *mysqlXConn) cacheMetadata(query string, columns []*Mysqlx_Resultset.ColumnMetaData) {
+	if mc.metadata == nil || len(mc.metadata) >= maxMetadataCacheSize {
+		mc.metadata = make(metadataCache)
+	}
+	mc.metadata[query] = columns
+}
+
+// forgetMetadata drops the metadata kept for the statement
+func (mc *mysqlXConn) forgetMetadata(query string) {
+	delete(mc.metadata, query)
+}
+
+// addNames copies the names of a column from the full metadata kept
+// for it into the compact metadata sent
+func addNames(column, full *Mysqlx_Resultset.ColumnMetaData) {
+	column.Name = full.Name
+	column.OriginalName = full.OriginalName
+	column.Table = full.Table
+	column.OriginalTable = full.OriginalTable
+	column.Schema = full.Schema
+	column.Catalog = full.Catalog
+}
@@ -0,0 +1,87 @@
+// Go driver for MySQL X Protocol
+//
+// Copyright 2016 Simon J Mudd.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/sjmudd/go-mysqlx-driver/mysqlxtest"
+)
+
+// compact metadata is asked for once the column names are known and
+// the names kept are returned by Columns
+func TestCompactMetadata(t *testing.T) {
+	const query = "SELECT id, name FROM people WHERE id = ?"
+	s := mysqlxtest.NewServer(fakeUser, fakePasswd)
+	defer s.Close()
+	s.Handle(query, &mysqlxtest.Result{
+		Columns: []mysqlxtest.Column{
+			{Name: "id", OriginalName: "id", Table: "people", OriginalTable: "people", Schema: "test"},
+			{Name: "name", OriginalName: "name", Table: "people", OriginalTable: "people", Schema: "test"},
+		},
+		Rows: [][]interface{}{{int64(1), "alice"}},
+	})
+
+	db := fakeDB(t, s, "MYSQL41", func(cfg *Config) { cfg.CompactMetadata = true })
+	defer db.Close()
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("TestCompactMetadata: Conn failed: %v", err)
+	}
+	defer conn.Close()
+
+	// query returns the metadata bytes received and the column names
+	query1 := func(ctx context.Context) (uint64, []string) {
+		before := connStatsOf(t, conn).In["RESULTSET_COLUMN_META_DATA"].Bytes
+		rows, err := conn.QueryContext(ctx, query, 1)
+		if err != nil {
+			t.Fatalf("TestCompactMetadata: Query failed: %v", err)
+		}
+		columns, err := rows.Columns()
+		if err != nil {
+			t.Fatalf("TestCompactMetadata: Columns failed: %v", err)
+		}
+		for rows.Next() {
+		}
+		if err := rows.Close(); err != nil {
+			t.Fatalf("TestCompactMetadata: Close failed: %v", err)
+		}
+		return connStatsOf(t, conn).In["RESULTSET_COLUMN_META_DATA"].Bytes - before, columns
+	}
+	expected := []string{"id", "name"}
+
+	full, columns := query1(context.Background())
+	if !reflect.DeepEqual(columns, expected) {
+		t.Errorf("TestCompactMetadata: first query: columns %q, expected %q", columns, expected)
+	}
+	compact, columns := query1(context.Background())
+	if !reflect.DeepEqual(columns, expected) {
+		t.Errorf("TestCompactMetadata: second query: columns %q, expected %q", columns, expected)
+	}
+	if compact >= full {
+		t.Errorf("TestCompactMetadata: %d metadata bytes with compact metadata, %d without", compact, full)
+	}
+	if n, _ := query1(WithCompactMetadata(context.Background(), false)); n != full {
+		t.Errorf("TestCompactMetadata: %d metadata bytes with compact metadata turned off, expected %d", n, full)
+	}
+
+	// a different number of columns drops the names kept
+	s.Handle(query, &mysqlxtest.Result{
+		Columns: []mysqlxtest.Column{{Name: "id"}},
+		Rows:    [][]interface{}{{int64(1)}},
+	})
+	if _, columns = query1(context.Background()); !reflect.DeepEqual(columns, []string{""}) {
+		t.Errorf("TestCompactMetadata: altered result: columns %q, expected one without a name", columns)
+	}
+	if _, columns = query1(context.Background()); !reflect.DeepEqual(columns, []string{"id"}) {
+		t.Errorf("TestCompactMetadata: altered result run again: columns %q, expected [id]", columns)
+	}
+}
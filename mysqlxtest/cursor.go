@@ -0,0 +1,166 @@
+// Go driver for MySQL X Protocol
+//
+// Copyright 2016 Simon J Mudd.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysqlxtest
+
+import (
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx"
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Cursor"
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Prepare"
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Resultset"
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Sql"
+)
+
+// cursor is an open cursor on the result of a prepared statement
+type cursor struct {
+	result *Result
+	next   int // index of the next row to send
+	what   string
+}
+
+// handleCursor answers the messages which prepare statements and
+// open, fetch from and close cursors. Only SQL statements can be
+// prepared.
+func (s *session) handleCursor(msgType Mysqlx.ClientMessages_Type, payload []byte) error {
+	var (
+		msg proto.Message
+		f   func() error
+	)
+	switch msgType {
+	case Mysqlx.ClientMessages_PREPARE_PREPARE:
+		prepare := new(Mysqlx_Prepare.Prepare)
+		msg, f = prepare, func() error { return s.prepare(prepare) }
+	case Mysqlx.ClientMessages_PREPARE_DEALLOCATE:
+		deallocate := new(Mysqlx_Prepare.Deallocate)
+		msg, f = deallocate, func() error { return s.deallocate(deallocate.GetStmtId()) }
+	case Mysqlx.ClientMessages_CURSOR_OPEN:
+		open := new(Mysqlx_Cursor.Open)
+		msg, f = open, func() error { return s.openCursor(open) }
+	case Mysqlx.ClientMessages_CURSOR_FETCH:
+		fetch := new(Mysqlx_Cursor.Fetch)
+		msg, f = fetch, func() error { return s.fetch(fetch.GetCursorId(), fetch.GetFetchRows()) }
+	case Mysqlx.ClientMessages_CURSOR_CLOSE:
+		cl := new(Mysqlx_Cursor.Close)
+		msg, f = cl, func() error { return s.closeCursor(cl.GetCursorId()) }
+	}
+	if err := proto.Unmarshal(payload, msg); err != nil {
+		return s.writeError(&Error{Code: errBadMessage, SQLState: "HY000", Msg: "Invalid message"})
+	}
+	return f()
+}
+
+func (s *session) prepare(prepare *Mysqlx_Prepare.Prepare) error {
+	stmt := prepare.GetStmt()
+	if stmt.GetType() != Mysqlx_Prepare.Prepare_OneOfMessage_STMT || stmt.GetStmtExecute() == nil {
+		return s.writeError(&Error{Code: errBadMessage, SQLState: "HY000", Msg: "Invalid message"})
+	}
+	if s.prepared == nil {
+		s.prepared = make(map[uint32]*Mysqlx_Sql.StmtExecute)
+	}
+	if _, found := s.prepared[prepare.GetStmtId()]; !found {
+		s.server.countPrepared(1)
+	}
+	s.prepared[prepare.GetStmtId()] = stmt.GetStmtExecute()
+	return s.writeOk("")
+}
+
+func (s *session) deallocate(id uint32) error {
+	if _, found := s.prepared[id]; !found {
+		return s.writeError(&Error{Code: errBadStatementID, SQLState: "HY000", Msg: fmt.Sprintf("Statement with ID=%d was not prepared", id)})
+	}
+	delete(s.prepared, id)
+	s.server.countPrepared(-1)
+	return s.writeOk("")
+}
+
+// openCursor executes a prepared statement sending the first rows of
+// its result
+func (s *session) openCursor(open *Mysqlx_Cursor.Open) error {
+	execute := open.GetStmt().GetPrepareExecute()
+	prepared, found := s.prepared[execute.GetStmtId()]
+	if !found {
+		return s.writeError(&Error{
+			Code:     errBadStatementID,
+			SQLState: "HY000",
+			Msg:      fmt.Sprintf("Statement with ID=%d was not prepared", execute.GetStmtId()),
+		})
+	}
+	stmt := &Mysqlx_Sql.StmtExecute{
+		Namespace: prepared.Namespace,
+		Stmt:      prepared.GetStmt(),
+		Args:      execute.GetArgs(),
+	}
+
+	result := s.server.result(stmt)
+	if result == nil {
+		return s.writeError(&Error{
+			Code:     errParse,
+			SQLState: "42000",
+			Msg:      fmt.Sprintf("mysqlxtest: no result for statement: %s", stmt.GetStmt()),
+		})
+	}
+	what := fmt.Sprintf("statement %q", stmt.GetStmt())
+	if result.Err != nil || len(result.Columns) == 0 {
+		return s.writeMessages(result, what, execute.GetCompactMetadata())
+	}
+
+	if s.cursors == nil {
+		s.cursors = make(map[uint32]*cursor)
+	}
+	if _, found := s.cursors[open.GetCursorId()]; !found {
+		s.server.countCursors(1)
+	}
+	s.cursors[open.GetCursorId()] = &cursor{result: result, what: what}
+	if err := s.writeMetaData(result, execute.GetCompactMetadata()); err != nil {
+		return err
+	}
+	return s.fetch(open.GetCursorId(), open.GetFetchRows())
+}
+
+// fetch sends up to n rows of a cursor, all of them if n is 0. The
+// cursor is closed once the last row has been sent.
+func (s *session) fetch(id uint32, n uint64) error {
+	c, found := s.cursors[id]
+	if !found {
+		return s.writeError(&Error{Code: errBadCursorID, SQLState: "HY000", Msg: fmt.Sprintf("Cursor with ID=%d was not opened", id)})
+	}
+	rows := c.result.Rows[c.next:]
+	if n > 0 && n < uint64(len(rows)) {
+		rows = rows[:n]
+	}
+	if err := s.writeRows(rows, c.what); err != nil {
+		return err
+	}
+	c.next += len(rows)
+	if c.next < len(c.result.Rows) {
+		if err := s.writeMsg(Mysqlx.ServerMessages_RESULTSET_FETCH_SUSPENDED, &Mysqlx_Resultset.FetchSuspended{}); err != nil {
+			return err
+		}
+		return s.writeMsg(Mysqlx.ServerMessages_SQL_STMT_EXECUTE_OK, &Mysqlx_Sql.StmtExecuteOk{})
+	}
+
+	delete(s.cursors, id)
+	s.server.countCursors(-1)
+	if err := s.writeMsg(Mysqlx.ServerMessages_RESULTSET_FETCH_DONE, &Mysqlx_Resultset.FetchDone{}); err != nil {
+		return err
+	}
+	return s.writeDone(c.result)
+}
+
+func (s *session) closeCursor(id uint32) error {
+	if _, found := s.cursors[id]; !found {
+		return s.writeError(&Error{Code: errBadCursorID, SQLState: "HY000", Msg: fmt.Sprintf("Cursor with ID=%d was not opened", id)})
+	}
+	delete(s.cursors, id)
+	s.server.countCursors(-1)
+	return s.writeOk("")
+}
@@ -0,0 +1,78 @@
+// Go driver for MySQL X Protocol
+//
+// Copyright 2016 Simon J Mudd.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysqlxtest
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/sjmudd/go-mysqlx-driver/wiretap"
+)
+
+// NewReplayServer returns a server which plays back the transcript.
+// Each client connection is given the next connection recorded in
+// the transcript: frames the client sent are read and checked to have
+// the recorded message type, frames the server sent are written as
+// they were recorded. Any mismatch ends the connection and is returned
+// by Errors.
+func NewReplayServer(transcript io.Reader) (*Server, error) {
+	records, err := wiretap.ReadAll(transcript)
+	if err != nil {
+		return nil, err
+	}
+
+	s := NewServer("", "")
+	s.replay = [][]wiretap.Record{}
+	index := make(map[uint64]int)
+	for _, r := range records {
+		i, found := index[r.Conn]
+		if !found {
+			i = len(s.replay)
+			index[r.Conn] = i
+			s.replay = append(s.replay, nil)
+		}
+		s.replay[i] = append(s.replay[i], r)
+	}
+	return s, nil
+}
+
+// replayConn plays back the next recorded connection
+func (s *Server) replayConn(conn net.Conn) error {
+	s.mu.Lock()
+	if len(s.replay) == 0 {
+		s.mu.Unlock()
+		return errors.New("mysqlxtest: replay: no more recorded connections")
+	}
+	records := s.replay[0]
+	s.replay = s.replay[1:]
+	s.mu.Unlock()
+
+	r := bufio.NewReader(conn)
+	for i, record := range records {
+		if record.Direction == wiretap.ServerToClient {
+			if _, err := conn.Write(record.Frame()); err != nil {
+				return fmt.Errorf("mysqlxtest: replay: frame %d: %v", i+1, err)
+			}
+			continue
+		}
+
+		msgType, _, err := readFrame(r, s.MaxMessageSize)
+		if err != nil {
+			return fmt.Errorf("mysqlxtest: replay: frame %d: expected %s: %v", i+1, record.TypeName(), err)
+		}
+		if msgType != record.Type {
+			got := wiretap.Record{Direction: wiretap.ClientToServer, Type: msgType}
+			return fmt.Errorf("mysqlxtest: replay: frame %d: client sent %s, transcript has %s", i+1, got.TypeName(), record.TypeName())
+		}
+	}
+	return nil
+}
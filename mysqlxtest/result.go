@@ -0,0 +1,270 @@
+// Go driver for MySQL X Protocol
+//
+// Copyright 2016 Simon J Mudd.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysqlxtest
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Datatypes"
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Notice"
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Resultset"
+)
+
+// Notice types sent in a Mysqlx.Notice.Frame
+const (
+	NoticeWarning                      = 1
+	NoticeSessionVariableChanged       = 2
+	NoticeSessionStateChanged          = 3
+	NoticeGroupReplicationStateChanged = 4
+	NoticeServerHello                  = 5
+)
+
+// Result is the scripted response to a statement. If Err is set only
+// the notices and the error are sent. If there are no Columns no
+// result set is sent, as for an INSERT or UPDATE.
+type Result struct {
+	Columns      []Column
+	Rows         [][]interface{} // values, see Raw for the types understood
+	Notices      []Notice        // sent after the rows
+	RowsAffected uint64
+	LastInsertID uint64
+	Err          *Error
+}
+
+// Column describes a column of the result set. If Type is not set it
+// is taken from the first non-nil value in the column, BYTES if there
+// is none.
+type Column struct {
+	Name             string
+	Type             Mysqlx_Resultset.ColumnMetaData_FieldType
+	OriginalName     string
+	Table            string
+	OriginalTable    string
+	Schema           string
+	Collation        uint64
+	FractionalDigits uint32
+	Length           uint32
+	Flags            uint32
+	ContentType      uint32
+}
+
+// Raw is a value which is sent as is in a row, for types such as
+// DATETIME or DECIMAL which have their own encoding. Other values are
+// encoded according to their Go type:
+//
+//	nil                     NULL
+//	int, int8 ... int64     SINT
+//	uint, uint8 ... uint64  UINT
+//	float32                 FLOAT
+//	float64                 DOUBLE
+//	string, []byte          BYTES
+type Raw []byte
+
+// Error is an error returned to the client
+type Error struct {
+	Code     uint32
+	SQLState string
+	Msg      string
+	Fatal    bool
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%04d [%s] %s", e.Code, e.SQLState, e.Msg)
+}
+
+// Notice is a notice sent to the client
+type Notice struct {
+	Type    uint32
+	Global  bool   // scope is GLOBAL rather than LOCAL
+	Payload []byte // the encoded notice
+}
+
+// WarningNotice returns a notice holding a warning
+func WarningNotice(code uint32, msg string) Notice {
+	payload, _ := proto.Marshal(&Mysqlx_Notice.Warning{
+		Code: proto.Uint32(code),
+		Msg:  proto.String(msg),
+	})
+	return Notice{Type: NoticeWarning, Payload: payload}
+}
+
+// SessionVariableNotice returns a notice saying a session variable has
+// changed to a string value
+func SessionVariableNotice(param, value string) Notice {
+	payload, _ := proto.Marshal(&Mysqlx_Notice.SessionVariableChanged{
+		Param: proto.String(param),
+		Value: stringScalar(value),
+	})
+	return Notice{Type: NoticeSessionVariableChanged, Payload: payload}
+}
+
+// SessionStateNotice returns a notice saying the session state has
+// changed to an unsigned value
+func SessionStateNotice(param Mysqlx_Notice.SessionStateChanged_Parameter, value uint64) Notice {
+	payload, _ := proto.Marshal(&Mysqlx_Notice.SessionStateChanged{
+		Param: param.Enum(),
+		Value: uintScalar(value),
+	})
+	return Notice{Type: NoticeSessionStateChanged, Payload: payload}
+}
+
+// GroupReplicationNotice returns a global notice saying the group
+// replication state has changed
+func GroupReplicationNotice() Notice {
+	return Notice{Type: NoticeGroupReplicationStateChanged, Global: true}
+}
+
+func stringScalar(value string) *Mysqlx_Datatypes.Scalar {
+	return &Mysqlx_Datatypes.Scalar{
+		Type:    Mysqlx_Datatypes.Scalar_V_STRING.Enum(),
+		VString: &Mysqlx_Datatypes.Scalar_String{Value: []byte(value)},
+	}
+}
+
+func uintScalar(value uint64) *Mysqlx_Datatypes.Scalar {
+	return &Mysqlx_Datatypes.Scalar{
+		Type:         Mysqlx_Datatypes.Scalar_V_UINT.Enum(),
+		VUnsignedInt: proto.Uint64(value),
+	}
+}
+
+// frame returns the notice as a Mysqlx.Notice.Frame
+func (n Notice) frame() *Mysqlx_Notice.Frame {
+	scope := Mysqlx_Notice.Frame_LOCAL
+	if n.Global {
+		scope = Mysqlx_Notice.Frame_GLOBAL
+	}
+	return &Mysqlx_Notice.Frame{
+		Type:    proto.Uint32(n.Type),
+		Scope:   scope.Enum(),
+		Payload: n.Payload,
+	}
+}
+
+// metaData returns the column metadata, working out the type from the
+// rows if needed
+func (r *Result) metaData() []*Mysqlx_Resultset.ColumnMetaData {
+	columns := make([]*Mysqlx_Resultset.ColumnMetaData, len(r.Columns))
+	for i, c := range r.Columns {
+		fieldType := c.Type
+		if fieldType == 0 {
+			fieldType = r.columnType(i)
+		}
+		columns[i] = &Mysqlx_Resultset.ColumnMetaData{
+			Type:          fieldType.Enum(),
+			Name:          []byte(c.Name),
+			OriginalName:  []byte(c.OriginalName),
+			Table:         []byte(c.Table),
+			OriginalTable: []byte(c.OriginalTable),
+			Schema:        []byte(c.Schema),
+			Catalog:       []byte("def"),
+		}
+		if c.Collation != 0 {
+			columns[i].Collation = proto.Uint64(c.Collation)
+		}
+		if c.FractionalDigits != 0 {
+			columns[i].FractionalDigits = proto.Uint32(c.FractionalDigits)
+		}
+		if c.Length != 0 {
+			columns[i].Length = proto.Uint32(c.Length)
+		}
+		if c.Flags != 0 {
+			columns[i].Flags = proto.Uint32(c.Flags)
+		}
+		if c.ContentType != 0 {
+			columns[i].ContentType = proto.Uint32(c.ContentType)
+		}
+	}
+	return columns
+}
+
+// columnType returns the type of the first non-nil value in the column
+func (r *Result) columnType(column int) Mysqlx_Resultset.ColumnMetaData_FieldType {
+	for _, row := range r.Rows {
+		if column >= len(row) {
+			continue
+		}
+		switch row[column].(type) {
+		case int, int8, int16, int32, int64:
+			return Mysqlx_Resultset.ColumnMetaData_SINT
+		case uint, uint8, uint16, uint32, uint64:
+			return Mysqlx_Resultset.ColumnMetaData_UINT
+		case float32:
+			return Mysqlx_Resultset.ColumnMetaData_FLOAT
+		case float64:
+			return Mysqlx_Resultset.ColumnMetaData_DOUBLE
+		}
+	}
+	return Mysqlx_Resultset.ColumnMetaData_BYTES
+}
+
+// encodeRow encodes the values of a row in the X protocol format
+func encodeRow(values []interface{}) (*Mysqlx_Resultset.Row, error) {
+	row := &Mysqlx_Resultset.Row{Field: make([][]byte, len(values))}
+	for i, v := range values {
+		field, err := encodeValue(v)
+		if err != nil {
+			return nil, fmt.Errorf("column %d: %v", i, err)
+		}
+		row.Field[i] = field
+	}
+	return row, nil
+}
+
+// encodeValue encodes a single value, see Raw
+func encodeValue(v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return []byte{}, nil
+	case Raw:
+		return []byte(v), nil
+	case int:
+		return encodeSint(int64(v)), nil
+	case int8:
+		return encodeSint(int64(v)), nil
+	case int16:
+		return encodeSint(int64(v)), nil
+	case int32:
+		return encodeSint(int64(v)), nil
+	case int64:
+		return encodeSint(v), nil
+	case uint:
+		return proto.EncodeVarint(uint64(v)), nil
+	case uint8:
+		return proto.EncodeVarint(uint64(v)), nil
+	case uint16:
+		return proto.EncodeVarint(uint64(v)), nil
+	case uint32:
+		return proto.EncodeVarint(uint64(v)), nil
+	case uint64:
+		return proto.EncodeVarint(v), nil
+	case float32:
+		b := make([]byte, 4)
+		binary.LittleEndian.PutUint32(b, math.Float32bits(v))
+		return b, nil
+	case float64:
+		b := make([]byte, 8)
+		binary.LittleEndian.PutUint64(b, math.Float64bits(v))
+		return b, nil
+	case string:
+		return append([]byte(v), 0), nil
+	case []byte:
+		return append(append([]byte{}, v...), 0), nil
+	}
+	return nil, fmt.Errorf("unsupported type %T", v)
+}
+
+// encodeSint zigzag encodes a signed integer
+func encodeSint(v int64) []byte {
+	return proto.EncodeVarint(uint64((v << 1) ^ (v >> 63)))
+}
@@ -0,0 +1,342 @@
+// Go driver for MySQL X Protocol
+//
+// Copyright 2016 Simon J Mudd.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package mysqlxtest provides a fake MySQL X protocol server which runs
+// in the same process as the tests using it, so no real database is
+// needed. It understands enough of the protocol for a client to
+// connect, authenticate with MYSQL41 or PLAIN, run statements and
+// close the session. The results of statements are scripted:
+//
+//	s := mysqlxtest.NewServer("user", "pass")
+//	s.Handle("SELECT 1", &mysqlxtest.Result{
+//		Columns: []mysqlxtest.Column{{Name: "1"}},
+//		Rows:    [][]interface{}{{int64(1)}},
+//	})
+//	addr, err := s.Start()
+//	...
+//	defer s.Close()
+//
+// Clients can connect to the returned address over TCP or use
+// s.DialContext to talk to the server over a net.Pipe.
+//
+// NewReplayServer returns a server which plays back a transcript
+// recorded with the wiretap package instead.
+package mysqlxtest
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Crud"
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Sql"
+	"github.com/sjmudd/go-mysqlx-driver/wiretap"
+)
+
+// MaxMessageSize is the default largest message the server accepts
+const MaxMessageSize = 64 * 1024 * 1024
+
+// HandlerFunc returns the result of a statement which has no result
+// set up with Handle. Returning nil sends an error to the client.
+type HandlerFunc func(stmt *Mysqlx_Sql.StmtExecute) *Result
+
+// FindFunc returns the result of a Mysqlx.Crud.Find. Returning nil
+// sends an error saying the collection does not exist.
+type FindFunc func(find *Mysqlx_Crud.Find) *Result
+
+// CrudFunc returns the result of a Mysqlx.Crud.Insert, Update or
+// Delete, given as msg. Returning nil sends an error saying the
+// collection does not exist.
+type CrudFunc func(msg proto.Message) *Result
+
+// Server is a fake X protocol server
+type Server struct {
+	User           string            // the only user allowed to log in
+	Password       string            // the password of User
+	Mechanisms     []string          // authentication mechanisms offered, default MYSQL41 and PLAIN (only over a unix socket)
+	Capabilities   map[string]string // extra capabilities sent to the client as scalar strings
+	Compression    []string          // algorithms offered in the compression capability, only deflate_stream works
+	MaxMessageSize int               // largest message accepted from a client
+	CloseNotices   []Notice          // sent before the Ok answering a Close
+
+	mu          sync.Mutex
+	results     map[string]*Result
+	handler     HandlerFunc
+	findHandler FindFunc
+	crudHandler CrudFunc
+	statements  []string
+	prepared    int // statements prepared and not deallocated
+	cursors     int // cursors open
+	errs        []error
+	replay      [][]wiretap.Record // recorded connections still to be replayed
+	listener    net.Listener
+	conns       map[net.Conn]struct{}
+	closed      bool
+	wg          sync.WaitGroup
+}
+
+// NewServer returns a server which accepts the given user and password
+func NewServer(user, password string) *Server {
+	return &Server{
+		User:           user,
+		Password:       password,
+		Mechanisms:     []string{"MYSQL41", "PLAIN"},
+		MaxMessageSize: MaxMessageSize,
+		results:        make(map[string]*Result),
+		conns:          make(map[net.Conn]struct{}),
+	}
+}
+
+// Handle sets the result returned when the given statement is executed.
+// Statements in a namespace other than sql, such as the admin commands
+// of the X plugin, are named with the namespace and a dot in front, e.g.
+// mysqlx.list_objects. The mysqlx.ping command succeeds unless handled.
+func (s *Server) Handle(stmt string, result *Result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results[stmt] = result
+}
+
+// HandleFunc sets the function called for statements with no result
+// set up with Handle
+func (s *Server) HandleFunc(f HandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handler = f
+}
+
+// HandleFind sets the function called for CRUD find messages. Like a
+// real server the result should have a single column, doc, holding
+// each document as JSON.
+func (s *Server) HandleFind(f FindFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.findHandler = f
+}
+
+// HandleCrud sets the function called for CRUD insert, update and
+// delete messages. The result should have no columns, only the rows
+// affected.
+func (s *Server) HandleCrud(f CrudFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.crudHandler = f
+}
+
+// Statements returns the statements executed so far by all clients
+func (s *Server) Statements() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.statements...)
+}
+
+// PreparedStatements returns the number of statements prepared by all
+// clients which have not been deallocated
+func (s *Server) PreparedStatements() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.prepared
+}
+
+// OpenCursors returns the number of cursors opened by all clients
+// which have not been closed
+func (s *Server) OpenCursors() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cursors
+}
+
+func (s *Server) countPrepared(n int) {
+	s.mu.Lock()
+	s.prepared += n
+	s.mu.Unlock()
+}
+
+func (s *Server) countCursors(n int) {
+	s.mu.Lock()
+	s.cursors += n
+	s.mu.Unlock()
+}
+
+// result returns the scripted result of the statement
+func (s *Server) result(stmt *Mysqlx_Sql.StmtExecute) *Result {
+	name := statementName(stmt)
+	s.mu.Lock()
+	s.statements = append(s.statements, name)
+	result, found := s.results[name]
+	handler := s.handler
+	s.mu.Unlock()
+
+	if !found && handler != nil {
+		result = handler(stmt)
+	}
+	if result == nil && name == "mysqlx.ping" {
+		result = &Result{}
+	}
+	return result
+}
+
+// statementName returns the statement as given to Handle
+func statementName(stmt *Mysqlx_Sql.StmtExecute) string {
+	if namespace := stmt.GetNamespace(); namespace != "sql" {
+		return namespace + "." + string(stmt.GetStmt())
+	}
+	return string(stmt.GetStmt())
+}
+
+// Start listens on a random port on 127.0.0.1 and serves connections
+// in the background. It returns the address to connect to.
+func (s *Server) Start() (string, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	s.listener = l
+	s.mu.Unlock()
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.Serve(l)
+	}()
+	return l.Addr().String(), nil
+}
+
+// Addr returns the address the server is listening on, if any
+func (s *Server) Addr() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener == nil {
+		return ""
+	}
+	return s.listener.Addr().String()
+}
+
+// Serve accepts connections on the listener until it is closed
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		l.Close()
+		return errors.New("mysqlxtest: server closed")
+	}
+	s.listener = l
+	s.mu.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.ServeConn(conn)
+		}()
+	}
+}
+
+// ServeConn talks the X protocol on a single connection until the
+// client closes the session or the connection
+func (s *Server) ServeConn(conn net.Conn) error {
+	return s.serveConn(conn, conn.LocalAddr().Network() == "unix")
+}
+
+// serveConn is ServeConn for a connection which may be a unix socket
+func (s *Server) serveConn(conn net.Conn, socket bool) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		conn.Close()
+		return errors.New("mysqlxtest: server closed")
+	}
+	s.conns[conn] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	var err error
+	if s.replay != nil {
+		err = s.replayConn(conn)
+	} else {
+		err = newSession(s, conn, socket).run()
+	}
+	if err != nil {
+		s.mu.Lock()
+		s.errs = append(s.errs, err)
+		s.mu.Unlock()
+	}
+	return err
+}
+
+// Errors returns the errors which ended client connections, such as a
+// message which could not be read or a replay which did not match
+func (s *Server) Errors() []error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]error(nil), s.errs...)
+}
+
+// Pipe returns the client end of a net.Pipe with the server end being
+// served in the background
+func (s *Server) Pipe() net.Conn {
+	return s.pipe(false)
+}
+
+// pipe is Pipe for a connection which may stand in for a unix socket
+func (s *Server) pipe(socket bool) net.Conn {
+	client, server := net.Pipe()
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.serveConn(server, socket)
+	}()
+	return client
+}
+
+// DialContext has the signature of net.Dialer.DialContext and
+// connects to the server over a net.Pipe whatever the address given.
+// The pipe is treated as a unix socket if the network is unix.
+func (s *Server) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.pipe(network == "unix"), nil
+}
+
+// Close stops the server, closes all client connections and waits for
+// them to finish
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return err
+}
@@ -0,0 +1,661 @@
+// Go driver for MySQL X Protocol
+//
+// Copyright 2016 Simon J Mudd.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysqlxtest
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx"
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Connection"
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Crud"
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Datatypes"
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Notice"
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Resultset"
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Session"
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Sql"
+)
+
+// Error codes used by the server, the same as those of a real server
+const (
+	errAccessDenied      = 1045
+	errUnknownCommand    = 1047
+	errParse             = 1064
+	errNoSuchTable       = 1146
+	errNotSupportedAuth  = 1251
+	errBadMessage        = 5000
+	errCapabilitiesSet   = 5001
+	errUnexpectedMessage = 5010
+	errBadStatementID    = 5110
+	errBadCursorID       = 5111
+)
+
+// session is a single client connection
+type session struct {
+	server        *Server
+	conn          net.Conn
+	r             *bufio.Reader
+	socket        bool // a unix socket, the only secure connection without TLS
+	authenticated bool
+	mechanism     string // mechanism of the authentication in progress
+	nonce         []byte // MYSQL41 challenge
+	schema        string
+	done          bool
+	compress      *compression // nil until the client sets the compression capability
+	prepared      map[uint32]*Mysqlx_Sql.StmtExecute
+	cursors       map[uint32]*cursor
+}
+
+func newSession(server *Server, conn net.Conn, socket bool) *session {
+	return &session{
+		server: server,
+		conn:   conn,
+		socket: socket,
+		r:      bufio.NewReader(conn),
+	}
+}
+
+// run reads and answers messages until the session ends
+func (s *session) run() error {
+	for !s.done {
+		msgType, payload, err := s.readMsg()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if err := s.handle(Mysqlx.ClientMessages_Type(msgType), payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readMsg reads a message from the client, uncompressing it if it
+// was sent in a COMPRESSION frame
+func (s *session) readMsg() (int, []byte, error) {
+	if s.compress != nil && s.compress.in.Len() > 0 {
+		return readFrame(&s.compress.in, s.server.MaxMessageSize)
+	}
+	msgType, payload, err := readFrame(s.r, s.server.MaxMessageSize)
+	if err != nil || s.compress == nil || msgType != int(Mysqlx.ClientMessages_COMPRESSION) {
+		return msgType, payload, err
+	}
+	if err := s.compress.uncompress(payload); err != nil {
+		return 0, nil, err
+	}
+	return s.readMsg()
+}
+
+// readFrame reads a single frame returning the message type and payload
+func readFrame(r io.Reader, maxSize int) (int, []byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	length := binary.LittleEndian.Uint32(header[:])
+	if length < 1 || length > uint32(maxSize) {
+		return 0, nil, fmt.Errorf("mysqlxtest: invalid message length %d", length)
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return 0, nil, err
+	}
+	return int(data[0]), data[1:], nil
+}
+
+// writeMsg sends a message to the client, or adds it to the messages
+// to be sent compressed if a result is being sent to a client which
+// asked for compression
+func (s *session) writeMsg(msgType Mysqlx.ServerMessages_Type, msg proto.Message) error {
+	payload, err := proto.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("mysqlxtest: marshal %s: %v", msgType, err)
+	}
+	data := make([]byte, 5+len(payload))
+	binary.LittleEndian.PutUint32(data, uint32(len(payload)+1))
+	data[4] = byte(msgType)
+	copy(data[5:], payload)
+	if s.compress != nil && s.compress.batching {
+		s.compress.batch.Write(data)
+		return nil
+	}
+	_, err = s.conn.Write(data)
+	return err
+}
+
+// compression holds the deflate_stream state of a session. Results are
+// sent in a single COMPRESSION frame.
+type compression struct {
+	in       bytes.Buffer // uncompressed frames not yet read
+	zin      bytes.Buffer // compressed data not yet read by zr
+	zr       io.ReadCloser
+	batching bool
+	batch    bytes.Buffer // frames to send compressed
+	zout     bytes.Buffer
+	zw       *zlib.Writer
+}
+
+func (c *compression) uncompress(payload []byte) error {
+	msg := new(Mysqlx_Connection.Compression)
+	if err := proto.Unmarshal(payload, msg); err != nil {
+		return fmt.Errorf("mysqlxtest: unmarshal compression: %v", err)
+	}
+	c.zin.Write(msg.GetPayload())
+	if c.zr == nil {
+		zr, err := zlib.NewReader(&c.zin)
+		if err != nil {
+			return fmt.Errorf("mysqlxtest: compression: %v", err)
+		}
+		c.zr = zr
+	}
+	if _, err := io.CopyN(&c.in, c.zr, int64(msg.GetUncompressedSize())); err != nil {
+		return fmt.Errorf("mysqlxtest: uncompress: %v", err)
+	}
+	return nil
+}
+
+// startBatch starts collecting the frames of a result
+func (s *session) startBatch() {
+	if s.compress != nil {
+		s.compress.batching = true
+	}
+}
+
+// flushBatch sends the frames collected in a COMPRESSION frame
+func (s *session) flushBatch() error {
+	c := s.compress
+	if c == nil || !c.batching {
+		return nil
+	}
+	c.batching = false
+	if c.batch.Len() == 0 {
+		return nil
+	}
+	c.zout.Reset()
+	if c.zw == nil {
+		c.zw = zlib.NewWriter(&c.zout)
+	}
+	size := c.batch.Len()
+	if _, err := c.batch.WriteTo(c.zw); err != nil {
+		return err
+	}
+	if err := c.zw.Flush(); err != nil {
+		return err
+	}
+	return s.writeMsg(Mysqlx.ServerMessages_COMPRESSION, &Mysqlx_Connection.Compression{
+		UncompressedSize: proto.Uint64(uint64(size)),
+		Payload:          c.zout.Bytes(),
+	})
+}
+
+func (s *session) writeOk(msg string) error {
+	return s.writeMsg(Mysqlx.ServerMessages_OK, &Mysqlx.Ok{Msg: proto.String(msg)})
+}
+
+func (s *session) writeError(e *Error) error {
+	severity := Mysqlx.Error_ERROR
+	if e.Fatal {
+		severity = Mysqlx.Error_FATAL
+	}
+	return s.writeMsg(Mysqlx.ServerMessages_ERROR, &Mysqlx.Error{
+		Severity: severity.Enum(),
+		Code:     proto.Uint32(e.Code),
+		SqlState: proto.String(e.SQLState),
+		Msg:      proto.String(e.Msg),
+	})
+}
+
+func (s *session) writeNotice(n Notice) error {
+	return s.writeMsg(Mysqlx.ServerMessages_NOTICE, n.frame())
+}
+
+// handle answers a single client message
+func (s *session) handle(msgType Mysqlx.ClientMessages_Type, payload []byte) error {
+	switch msgType {
+	case Mysqlx.ClientMessages_CON_CAPABILITIES_GET:
+		return s.capabilitiesGet()
+	case Mysqlx.ClientMessages_CON_CAPABILITIES_SET:
+		return s.capabilitiesSet(payload)
+	case Mysqlx.ClientMessages_SESS_AUTHENTICATE_START:
+		return s.authenticateStart(payload)
+	case Mysqlx.ClientMessages_SESS_AUTHENTICATE_CONTINUE:
+		return s.authenticateContinue(payload)
+	case Mysqlx.ClientMessages_SESS_RESET:
+		s.authenticated = false
+		return s.writeOk("")
+	case Mysqlx.ClientMessages_SESS_CLOSE, Mysqlx.ClientMessages_CON_CLOSE:
+		s.done = true
+		for _, n := range s.server.CloseNotices {
+			if err := s.writeNotice(n); err != nil {
+				return err
+			}
+		}
+		return s.writeOk("bye!")
+	case Mysqlx.ClientMessages_EXPECT_OPEN, Mysqlx.ClientMessages_EXPECT_CLOSE:
+		return s.writeOk("")
+	case Mysqlx.ClientMessages_SQL_STMT_EXECUTE:
+		if !s.authenticated {
+			return s.writeError(&Error{Code: errUnknownCommand, SQLState: "HY000", Msg: "Unexpected message received"})
+		}
+		return s.stmtExecute(payload)
+	case Mysqlx.ClientMessages_CRUD_FIND:
+		if !s.authenticated {
+			return s.writeError(&Error{Code: errUnknownCommand, SQLState: "HY000", Msg: "Unexpected message received"})
+		}
+		return s.find(payload)
+	case Mysqlx.ClientMessages_CRUD_INSERT, Mysqlx.ClientMessages_CRUD_UPDATE, Mysqlx.ClientMessages_CRUD_DELETE:
+		if !s.authenticated {
+			return s.writeError(&Error{Code: errUnknownCommand, SQLState: "HY000", Msg: "Unexpected message received"})
+		}
+		return s.crud(msgType, payload)
+	case Mysqlx.ClientMessages_PREPARE_PREPARE, Mysqlx.ClientMessages_PREPARE_DEALLOCATE,
+		Mysqlx.ClientMessages_CURSOR_OPEN, Mysqlx.ClientMessages_CURSOR_FETCH, Mysqlx.ClientMessages_CURSOR_CLOSE:
+		if !s.authenticated {
+			return s.writeError(&Error{Code: errUnknownCommand, SQLState: "HY000", Msg: "Unexpected message received"})
+		}
+		s.startBatch()
+		if err := s.handleCursor(msgType, payload); err != nil {
+			return err
+		}
+		return s.flushBatch()
+	}
+	return s.writeError(&Error{Code: errUnknownCommand, SQLState: "HY000", Msg: fmt.Sprintf("Unexpected message received: %d", msgType)})
+}
+
+func (s *session) capabilitiesGet() error {
+	var mechanisms []*Mysqlx_Datatypes.Any
+	for _, m := range s.server.Mechanisms {
+		if s.offers(m) {
+			mechanisms = append(mechanisms, stringAny(m))
+		}
+	}
+	capabilities := []*Mysqlx_Connection.Capability{
+		{
+			Name: proto.String("authentication.mechanisms"),
+			Value: &Mysqlx_Datatypes.Any{
+				Type:  Mysqlx_Datatypes.Any_ARRAY.Enum(),
+				Array: &Mysqlx_Datatypes.Array{Value: mechanisms},
+			},
+		},
+		{Name: proto.String("doc.formats"), Value: stringAny("text")},
+		{Name: proto.String("node_type"), Value: stringAny("mysql")},
+	}
+	if len(s.server.Compression) > 0 {
+		algorithms := make([]*Mysqlx_Datatypes.Any, len(s.server.Compression))
+		for i, a := range s.server.Compression {
+			algorithms[i] = stringAny(a)
+		}
+		capabilities = append(capabilities, &Mysqlx_Connection.Capability{
+			Name: proto.String("compression"),
+			Value: &Mysqlx_Datatypes.Any{
+				Type: Mysqlx_Datatypes.Any_OBJECT.Enum(),
+				Obj: &Mysqlx_Datatypes.Object{Fld: []*Mysqlx_Datatypes.Object_ObjectField{{
+					Key: proto.String("algorithm"),
+					Value: &Mysqlx_Datatypes.Any{
+						Type:  Mysqlx_Datatypes.Any_ARRAY.Enum(),
+						Array: &Mysqlx_Datatypes.Array{Value: algorithms},
+					},
+				}}},
+			},
+		})
+	}
+	for name, value := range s.server.Capabilities {
+		capabilities = append(capabilities, &Mysqlx_Connection.Capability{
+			Name:  proto.String(name),
+			Value: stringAny(value),
+		})
+	}
+	return s.writeMsg(Mysqlx.ServerMessages_CONN_CAPABILITIES, &Mysqlx_Connection.Capabilities{Capabilities: capabilities})
+}
+
+func stringAny(value string) *Mysqlx_Datatypes.Any {
+	return &Mysqlx_Datatypes.Any{
+		Type:   Mysqlx_Datatypes.Any_SCALAR.Enum(),
+		Scalar: stringScalar(value),
+	}
+}
+
+// capabilitiesSet accepts anything except tls which is not supported
+// and compression with an algorithm which is not offered
+func (s *session) capabilitiesSet(payload []byte) error {
+	set := new(Mysqlx_Connection.CapabilitiesSet)
+	if err := proto.Unmarshal(payload, set); err != nil {
+		return s.writeError(&Error{Code: errBadMessage, SQLState: "HY000", Msg: "Invalid message"})
+	}
+	compress := false
+	for _, c := range set.GetCapabilities().GetCapabilities() {
+		switch c.GetName() {
+		case "tls":
+			return s.writeError(&Error{Code: errCapabilitiesSet, SQLState: "HY000", Msg: "Capability prepare failed for 'tls'"})
+		case "compression":
+			if !s.offersCompression(c.GetValue()) {
+				return s.writeError(&Error{Code: errCapabilitiesSet, SQLState: "HY000", Msg: "Capability prepare failed for 'compression'"})
+			}
+			compress = true
+		}
+	}
+	if err := s.writeOk(""); err != nil {
+		return err
+	}
+	if compress {
+		s.compress = new(compression)
+	}
+	return nil
+}
+
+// offersCompression returns true if the compression capability asks
+// for deflate_stream and the server offers it
+func (s *session) offersCompression(value *Mysqlx_Datatypes.Any) bool {
+	for _, f := range value.GetObj().GetFld() {
+		if f.GetKey() != "algorithm" {
+			continue
+		}
+		algorithm := string(f.GetValue().GetScalar().GetVString().GetValue())
+		for _, a := range s.server.Compression {
+			if a == algorithm && a == "deflate_stream" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// offers returns true if the mechanism can be used on the connection.
+// Like a real server PLAIN, which sends the password in clear text,
+// needs a secure connection and as TLS is not supported that means a
+// unix socket.
+func (s *session) offers(mechanism string) bool {
+	return mechanism != "PLAIN" || s.socket
+}
+
+func (s *session) authenticateStart(payload []byte) error {
+	start := new(Mysqlx_Session.AuthenticateStart)
+	if err := proto.Unmarshal(payload, start); err != nil {
+		return s.writeError(&Error{Code: errBadMessage, SQLState: "HY000", Msg: "Invalid message"})
+	}
+
+	s.mechanism = ""
+	for _, m := range s.server.Mechanisms {
+		if m == start.GetMechName() && s.offers(m) {
+			s.mechanism = m
+		}
+	}
+
+	switch s.mechanism {
+	case "MYSQL41":
+		s.nonce = make([]byte, 20)
+		if _, err := rand.Read(s.nonce); err != nil {
+			return err
+		}
+		return s.writeMsg(Mysqlx.ServerMessages_SESS_AUTHENTICATE_CONTINUE, &Mysqlx_Session.AuthenticateContinue{AuthData: s.nonce})
+	case "PLAIN":
+		schema, user, password := splitAuthData(start.GetAuthData())
+		return s.finishAuthentication(schema, user, password == s.server.Password, password != "")
+	}
+	return s.writeError(&Error{Code: errNotSupportedAuth, SQLState: "HY000", Msg: "Invalid authentication method " + start.GetMechName()})
+}
+
+func (s *session) authenticateContinue(payload []byte) error {
+	if s.mechanism != "MYSQL41" || s.nonce == nil {
+		return s.writeError(&Error{Code: errUnexpectedMessage, SQLState: "HY000", Msg: "Unexpected message received"})
+	}
+	cont := new(Mysqlx_Session.AuthenticateContinue)
+	if err := proto.Unmarshal(payload, cont); err != nil {
+		return s.writeError(&Error{Code: errBadMessage, SQLState: "HY000", Msg: "Invalid message"})
+	}
+
+	schema, user, scramble := splitAuthData(cont.GetAuthData())
+	var ok bool
+	if scramble == "" {
+		ok = s.server.Password == ""
+	} else {
+		ok = scramble == "*"+mysql41Scramble(s.server.Password, s.nonce)
+	}
+	s.nonce = nil
+	return s.finishAuthentication(schema, user, ok, scramble != "")
+}
+
+// finishAuthentication sends an AuthenticateOk or an access denied error
+func (s *session) finishAuthentication(schema, user string, passwordOk, usingPassword bool) error {
+	s.mechanism = ""
+	if user != s.server.User || !passwordOk {
+		using := "NO"
+		if usingPassword {
+			using = "YES"
+		}
+		return s.writeError(&Error{
+			Code:     errAccessDenied,
+			SQLState: "HY000",
+			Msg:      fmt.Sprintf("Access denied for user '%s'@'localhost' (using password: %s)", user, using),
+		})
+	}
+	s.authenticated = true
+	s.schema = schema
+	return s.writeMsg(Mysqlx.ServerMessages_SESS_AUTHENTICATE_OK, &Mysqlx_Session.AuthenticateOk{})
+}
+
+// splitAuthData splits schema\0user\0password
+func splitAuthData(data []byte) (schema, user, password string) {
+	parts := bytes.SplitN(data, []byte{0}, 3)
+	for len(parts) < 3 {
+		parts = append(parts, nil)
+	}
+	return string(parts[0]), string(parts[1]), string(parts[2])
+}
+
+// mysql41Scramble returns the hex encoded response the client should
+// send for the password and nonce
+func mysql41Scramble(password string, nonce []byte) string {
+	hash1 := sha1.Sum([]byte(password))
+	hash2 := sha1.Sum(hash1[:])
+	h := sha1.New()
+	h.Write(nonce)
+	h.Write(hash2[:])
+	hash3 := h.Sum(nil)
+	for i := range hash3 {
+		hash3[i] ^= hash1[i]
+	}
+	return hex.EncodeToString(hash3)
+}
+
+// stmtExecute sends the scripted result of the statement
+func (s *session) stmtExecute(payload []byte) error {
+	stmt := new(Mysqlx_Sql.StmtExecute)
+	if err := proto.Unmarshal(payload, stmt); err != nil {
+		return s.writeError(&Error{Code: errBadMessage, SQLState: "HY000", Msg: "Invalid message"})
+	}
+
+	result := s.server.result(stmt)
+	if result == nil {
+		return s.writeError(&Error{
+			Code:     errParse,
+			SQLState: "42000",
+			Msg:      fmt.Sprintf("mysqlxtest: no result for statement: %s", stmt.GetStmt()),
+		})
+	}
+	return s.writeResult(result, fmt.Sprintf("statement %q", stmt.GetStmt()), stmt.GetCompactMetadata())
+}
+
+// find handles a Mysqlx.Crud.Find
+func (s *session) find(payload []byte) error {
+	find := new(Mysqlx_Crud.Find)
+	if err := proto.Unmarshal(payload, find); err != nil {
+		return s.writeError(&Error{Code: errBadMessage, SQLState: "HY000", Msg: "Invalid message"})
+	}
+
+	s.server.mu.Lock()
+	handler := s.server.findHandler
+	s.server.mu.Unlock()
+
+	var result *Result
+	if handler != nil {
+		result = handler(find)
+	}
+	if result == nil {
+		return s.writeError(&Error{
+			Code:     errNoSuchTable,
+			SQLState: "42S02",
+			Msg:      fmt.Sprintf("Table '%s.%s' doesn't exist", find.GetCollection().GetSchema(), find.GetCollection().GetName()),
+		})
+	}
+	return s.writeResult(result, "find "+find.GetCollection().GetName(), false)
+}
+
+// crud handles a Mysqlx.Crud.Insert, Update or Delete
+func (s *session) crud(msgType Mysqlx.ClientMessages_Type, payload []byte) error {
+	var (
+		msg        proto.Message
+		collection *Mysqlx_Crud.Collection
+	)
+	switch msgType {
+	case Mysqlx.ClientMessages_CRUD_INSERT:
+		insert := new(Mysqlx_Crud.Insert)
+		msg = insert
+		if err := proto.Unmarshal(payload, insert); err != nil {
+			return s.writeError(&Error{Code: errBadMessage, SQLState: "HY000", Msg: "Invalid message"})
+		}
+		collection = insert.GetCollection()
+	case Mysqlx.ClientMessages_CRUD_UPDATE:
+		update := new(Mysqlx_Crud.Update)
+		msg = update
+		if err := proto.Unmarshal(payload, update); err != nil {
+			return s.writeError(&Error{Code: errBadMessage, SQLState: "HY000", Msg: "Invalid message"})
+		}
+		collection = update.GetCollection()
+	default:
+		del := new(Mysqlx_Crud.Delete)
+		msg = del
+		if err := proto.Unmarshal(payload, del); err != nil {
+			return s.writeError(&Error{Code: errBadMessage, SQLState: "HY000", Msg: "Invalid message"})
+		}
+		collection = del.GetCollection()
+	}
+
+	s.server.mu.Lock()
+	handler := s.server.crudHandler
+	s.server.mu.Unlock()
+
+	var result *Result
+	if handler != nil {
+		result = handler(msg)
+	}
+	if result == nil {
+		return s.writeError(&Error{
+			Code:     errNoSuchTable,
+			SQLState: "42S02",
+			Msg:      fmt.Sprintf("Table '%s.%s' doesn't exist", collection.GetSchema(), collection.GetName()),
+		})
+	}
+	return s.writeResult(result, "crud "+collection.GetName(), false)
+}
+
+// writeResult sends the result of a statement or CRUD message,
+// leaving out the column names if compact is true
+func (s *session) writeResult(result *Result, what string, compact bool) error {
+	s.startBatch()
+	if err := s.writeMessages(result, what, compact); err != nil {
+		return err
+	}
+	return s.flushBatch()
+}
+
+// writeMessages sends the messages of a result
+func (s *session) writeMessages(result *Result, what string, compact bool) error {
+	if result.Err != nil {
+		return s.writeFailure(result)
+	}
+	if len(result.Columns) > 0 {
+		if err := s.writeMetaData(result, compact); err != nil {
+			return err
+		}
+		if err := s.writeRows(result.Rows, what); err != nil {
+			return err
+		}
+		if err := s.writeMsg(Mysqlx.ServerMessages_RESULTSET_FETCH_DONE, &Mysqlx_Resultset.FetchDone{}); err != nil {
+			return err
+		}
+	}
+	return s.writeDone(result)
+}
+
+// writeFailure sends the notices and error of a result which failed
+func (s *session) writeFailure(result *Result) error {
+	for _, n := range result.Notices {
+		if err := s.writeNotice(n); err != nil {
+			return err
+		}
+	}
+	if err := s.writeError(result.Err); err != nil {
+		return err
+	}
+	if result.Err.Fatal {
+		s.done = true
+	}
+	return nil
+}
+
+// writeMetaData sends the column metadata of a result. Compact
+// metadata has only the type information, like a real server sends.
+func (s *session) writeMetaData(result *Result, compact bool) error {
+	for _, column := range result.metaData() {
+		if compact {
+			column.Name, column.OriginalName = nil, nil
+			column.Table, column.OriginalTable = nil, nil
+			column.Schema, column.Catalog = nil, nil
+		}
+		if err := s.writeMsg(Mysqlx.ServerMessages_RESULTSET_COLUMN_META_DATA, column); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeRows sends rows of a result
+func (s *session) writeRows(rows [][]interface{}, what string) error {
+	for _, values := range rows {
+		row, err := encodeRow(values)
+		if err != nil {
+			return fmt.Errorf("mysqlxtest: %s: %v", what, err)
+		}
+		if err := s.writeMsg(Mysqlx.ServerMessages_RESULTSET_ROW, row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeDone sends the notices of a result and StmtExecuteOk
+func (s *session) writeDone(result *Result) error {
+	notices := append([]Notice(nil), result.Notices...)
+	if len(result.Columns) == 0 {
+		notices = append(notices, SessionStateNotice(Mysqlx_Notice.SessionStateChanged_ROWS_AFFECTED, result.RowsAffected))
+	}
+	if result.LastInsertID != 0 {
+		notices = append(notices, SessionStateNotice(Mysqlx_Notice.SessionStateChanged_GENERATED_INSERT_ID, result.LastInsertID))
+	}
+	for _, n := range notices {
+		if err := s.writeNotice(n); err != nil {
+			return err
+		}
+	}
+
+	return s.writeMsg(Mysqlx.ServerMessages_SQL_STMT_EXECUTE_OK, &Mysqlx_Sql.StmtExecuteOk{})
+}
@@ -0,0 +1,49 @@
+// Go driver for MySQL X Protocol
+//
+// Copyright 2016 Simon J Mudd.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Notice is a notice sent by the server, such as a warning raised by a
+// statement or a change of a session variable. The notices received
+// while running the last statement are returned by XConn.Notices.
+type Notice struct {
+	Type   uint32 // NoticeWarning, NoticeSessionVariableChanged, ...
+	Global bool   // the scope is global rather than this session
+
+	// Set for NoticeWarning
+	Level string // NOTE, WARNING or ERROR
+	Code  uint32
+	Msg   string
+
+	// Set for NoticeSessionVariableChanged and NoticeSessionStateChanged
+	Param string      // variable name or state such as ROWS_AFFECTED
+	Value interface{} // nil, int64, uint64, float64, bool, string or []byte
+}
+
+// String returns the notice in the style of the mysql client
+func (n Notice) String() string {
+	switch n.Type {
+	case NoticeWarning:
+		level := strings.ToLower(n.Level)
+		if level != "" {
+			level = strings.ToUpper(level[:1]) + level[1:]
+		}
+		return fmt.Sprintf("%s (Code %d): %s", level, n.Code, n.Msg)
+	case NoticeSessionVariableChanged, NoticeSessionStateChanged:
+		if b, ok := n.Value.([]byte); ok {
+			return fmt.Sprintf("%s: %s = %q", noticeTypeToName(n.Type), n.Param, b)
+		}
+		return fmt.Sprintf("%s: %s = %v", noticeTypeToName(n.Type), n.Param, n.Value)
+	}
+	return noticeTypeToName(n.Type)
+}
@@ -0,0 +1,55 @@
+// Go driver for MySQL X Protocol
+//
+// Copyright 2016 Simon J Mudd.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+//
+// MySQL X protocol authentication using PLAIN method
+
+package mysql
+
+import (
+	"fmt"
+)
+
+// Plain manages the PLAIN authentication protocol. The password is
+// sent in clear text so this should only be used over TLS.
+type Plain struct {
+	dbname   string
+	name     string
+	username string
+	password string
+}
+
+// NewPlain returns a pointer to an initialised Plain struct
+func NewPlain(dbname, username, password string) *Plain {
+	if username == "" {
+		return nil
+	}
+
+	p := Plain{
+		name:     "PLAIN",
+		username: username,
+		password: password,
+		dbname:   dbname,
+	}
+
+	return &p
+}
+
+// Name returns the name of the authentication method
+func (p *Plain) Name() string {
+	return p.name
+}
+
+// GetInitialAuthData returns db + name + password, which is all the server needs
+func (p *Plain) GetInitialAuthData() []byte {
+	return []byte(p.dbname + "\x00" + p.username + "\x00" + p.password)
+}
+
+// GetNextAuthData is not expected to be called as PLAIN has no challenge
+func (p *Plain) GetNextAuthData(serverData []byte) ([]byte, error) {
+	return nil, fmt.Errorf("PLAIN authentication does not expect a challenge from the server, got %d bytes", len(serverData))
+}
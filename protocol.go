@@ -13,7 +13,7 @@ package mysql
 import (
 	"database/sql/driver"
 	"fmt"
-	"log"
+	"sort"
 
 	"github.com/golang/protobuf/proto"
 
@@ -23,8 +23,6 @@ import (
 	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Notice"
 	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Session"
 	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Sql"
-
-	"github.com/sjmudd/go-mysqlx-driver/debug"
 )
 
 var (
@@ -33,9 +31,20 @@ var (
 		1: "Warning",
 		2: "SessionVariableChanged",
 		3: "SessionStateChanged",
+		4: "GroupReplicationStateChanged",
+		5: "ServerHello",
 	}
 )
 
+// Notice types, not defined in Mysqlx_Notice
+const (
+	NoticeWarning                      = 1
+	NoticeSessionVariableChanged       = 2
+	NoticeSessionStateChanged          = 3
+	NoticeGroupReplicationStateChanged = 4
+	NoticeServerHello                  = 5
+)
+
 // netProtobuf holds the protobuf message type and the network bytes from a protobuf message
 // - see docs at ....
 type netProtobuf struct {
@@ -43,30 +52,42 @@ type netProtobuf struct {
 	payload []byte
 }
 
-// Read a raw netProtobuf packet from the network and return a pointer to the structure
+// Read a raw netProtobuf packet from the network and return a pointer to the structure.
+// If the read fails the connection can not be used any more so it is
+// closed without telling the server, see cleanup.
+// Frames sent inside a COMPRESSION frame are returned one at a time.
 func (mc *mysqlXConn) readMsg() (*netProtobuf, error) {
+	if len(mc.uncompressed) > 0 {
+		return mc.readUncompressedMsg()
+	}
+
 	// Read packet header
 	data, err := mc.buf.readNext(4)
 	if err != nil {
-		errLog.Print(err)
-		mc.Close()
+		mc.log.print(err)
+		mc.cleanup()
 		return nil, driver.ErrBadConn
 	}
 
 	// Packet Length [32 bit]
-	pktLen := int(uint32(data[0]) | uint32(data[1])<<8 | uint32(data[2])<<16 | uint32(data[3])<<24)
+	pktLen := uint32(data[0]) | uint32(data[1])<<8 | uint32(data[2])<<16 | uint32(data[3])<<24
 
 	if pktLen < minPacketSize {
-		errLog.Print(ErrMalformPkt)
-		mc.Close()
+		mc.log.print(ErrMalformPkt)
+		mc.cleanup()
+		return nil, driver.ErrBadConn
+	}
+	if pktLen > maxReadPacketSize {
+		mc.log.print(ErrPktTooLarge)
+		mc.cleanup()
 		return nil, driver.ErrBadConn
 	}
 
 	// Read body which is 1-byte msg type and 0+ bytes payload
-	data, err = mc.buf.readNext(pktLen)
+	data, err = mc.buf.readNext(int(pktLen))
 	if err != nil {
-		errLog.Print(err)
-		mc.Close()
+		mc.log.print(err)
+		mc.cleanup()
 		return nil, driver.ErrBadConn
 	}
 
@@ -76,25 +97,73 @@ func (mc *mysqlXConn) readMsg() (*netProtobuf, error) {
 	if len(data) > 1 {
 		pb.payload = data[1:]
 	}
+	mc.stats.frameIn(pb.msgType, pb.payload)
+
+	if mc.log.on(LogTrace) {
+		mc.log.printf(LogTrace, "S -> C: len: %d, type: %d [%s], payload: %+v",
+			5+len(pb.payload),
+			pb.msgType,
+			Mysqlx.ServerMessages_Type(pb.msgType).String(),
+			pb.payload)
+	}
 
-	debug.MsgProtobuf("S -> C: len: %d, type: %d [%s], payload: %+v",
-		5+len(pb.payload),
-		pb.msgType,
-		Mysqlx.ServerMessages_Type(pb.msgType).String(),
-		pb.payload)
+	if mc.compressor != nil && pb.msgType == int(Mysqlx.ServerMessages_COMPRESSION) {
+		if err := mc.uncompress(pb.payload); err != nil {
+			mc.log.print(err)
+			mc.cleanup()
+			return nil, driver.ErrBadConn
+		}
+		return mc.readMsg()
+	}
 	return pb, nil
 }
 
-// Write packet buffer 'data'
+// readUncompressedMsg returns the next frame of those left by a
+// COMPRESSION frame
+func (mc *mysqlXConn) readUncompressedMsg() (*netProtobuf, error) {
+	data := mc.uncompressed
+	var pktLen uint32
+	if len(data) >= 4 {
+		pktLen = uint32(data[0]) | uint32(data[1])<<8 | uint32(data[2])<<16 | uint32(data[3])<<24
+	}
+	if pktLen < minPacketSize || uint64(pktLen) > uint64(len(data)-4) {
+		mc.log.print(ErrMalformPkt)
+		mc.cleanup()
+		return nil, driver.ErrBadConn
+	}
+	mc.uncompressed = data[4+pktLen:]
+
+	pb := &netProtobuf{
+		msgType: int(data[4]),
+	}
+	if pktLen > 1 {
+		pb.payload = data[5 : 4+pktLen]
+	}
+	mc.stats.messageIn(pb.msgType, pb.payload)
+
+	if mc.log.on(LogTrace) {
+		mc.log.printf(LogTrace, "S -> C: (compressed) len: %d, type: %d [%s], payload: %+v",
+			5+len(pb.payload),
+			pb.msgType,
+			Mysqlx.ServerMessages_Type(pb.msgType).String(),
+			pb.payload)
+	}
+	return pb, nil
+}
+
+// Write packet buffer 'data', compressed if compression has been
+// negotiated and it is large enough to be worth it
 func (mc *mysqlXConn) writeProtobufPacket(pb *netProtobuf) error {
 	if pb == nil || pb.msgType > 255 {
 		mc.Close()
 		return ErrMalformPkt
 	}
-	debug.MsgProtobuf("C -> S: len: %d, type: %d [%s], payload: %+v",
-		5+len(pb.payload),
-		pb.msgType, Mysqlx.ClientMessages_Type(pb.msgType).String(),
-		pb.payload)
+	if mc.log.on(LogTrace) {
+		mc.log.printf(LogTrace, "C -> S: len: %d, type: %d [%s], payload: %+v",
+			5+len(pb.payload),
+			pb.msgType, Mysqlx.ClientMessages_Type(pb.msgType).String(),
+			pb.payload)
+	}
 
 	pktLen := len(pb.payload) + 1
 
@@ -102,31 +171,40 @@ func (mc *mysqlXConn) writeProtobufPacket(pb *netProtobuf) error {
 		return ErrPktTooLarge
 	}
 
-	// setup initial header
-	data := make([]byte, 5)
+	if mc.compressor != nil && len(pb.payload) >= minCompressSize {
+		return mc.writeCompressed(pb)
+	}
+	if err := mc.writeFrame(pb.msgType, pb.payload); err != nil {
+		return err
+	}
+	mc.stats.frameOut(pb.msgType, pb.payload)
+	return nil
+}
 
-	var size int
-	data[0] = byte(pktLen)
-	data[1] = byte(pktLen >> 8)
-	data[2] = byte(pktLen >> 16)
-	data[3] = byte(pktLen >> 24)
-	data[4] = byte(pb.msgType)
-	size = pktLen
+// writeFrame sends a single frame, header and payload in a single write
+func (mc *mysqlXConn) writeFrame(msgType int, payload []byte) error {
+	data := make([]byte, 5+len(payload))
+	putFrameHeader(data, msgType, len(payload))
+	copy(data[5:], payload)
 
-	// Write header
 	n, err := mc.netConn.Write(data)
-	if err != nil || n != 5 {
-		return fmt.Errorf("Error writing protobuf header to socket, wrote %d of 5 bytes: %v", n, err)
-	}
-
-	// Write payload
-	n, err = mc.netConn.Write(pb.payload)
-	if err != nil && n != size {
-		return fmt.Errorf("Error writing protobuf body to socket, wrote %d of %d bytes: %v", n, size, err)
+	if err != nil || n != len(data) {
+		return fmt.Errorf("Error writing protobuf message to socket, wrote %d of %d bytes: %v", n, len(data), err)
 	}
 	return nil
 }
 
+// putFrameHeader writes the length and type of a frame to its first
+// 5 bytes
+func putFrameHeader(data []byte, msgType int, payloadLen int) {
+	pktLen := payloadLen + 1
+	data[0] = byte(pktLen)
+	data[1] = byte(pktLen >> 8)
+	data[2] = byte(pktLen >> 16)
+	data[3] = byte(pktLen >> 24)
+	data[4] = byte(msgType)
+}
+
 /******************************************************************************
 *                           Initialisation Process                            *
 ******************************************************************************/
@@ -199,7 +277,6 @@ func arrayString(value *Mysqlx_Datatypes.Any) []string {
 // Request the getCapabilities
 // see: http://.....
 func (mc *mysqlXConn) getCapabilities() error {
-	// debug.Msg("Enter getCapabilities()")
 
 	if err := mc.writeConnCapabilitiesGet(); err != nil {
 		return fmt.Errorf("getCapabilities: %v", err)
@@ -210,7 +287,6 @@ func (mc *mysqlXConn) getCapabilities() error {
 	done := false
 	// wait for the answer CONN_CAPABILITIES, but handle ERROR or NOTICE
 	for !done {
-		// debug.Msg("getCapabilities() read response to CapabilitiesGet...")
 		if pb, err = mc.readMsg(); err != nil {
 			return err
 		}
@@ -218,26 +294,29 @@ func (mc *mysqlXConn) getCapabilities() error {
 			return fmt.Errorf("getCapabilities() pb = nil (not expected to happen ever)")
 		}
 
-		// debug.Msg("getCapabilities() have data: type: %s, data: %+v", Mysqlx.ServerMessages_Type(pb.msgType).String(), pb.payload)
-
 		switch Mysqlx.ServerMessages_Type(pb.msgType) {
 		case Mysqlx.ServerMessages_ERROR:
-			debug.Msg("getCapabilities() got back ERROR msg", errorMsg(pb.payload).Error())
+			if mc.log.on(LogDebug) {
+				mc.log.printf(LogDebug, "getCapabilities() got back ERROR msg: %s", errorMsg(pb.payload).Error())
+			}
 			return fmt.Errorf("getCapabilities returned: %+v", errorMsg(pb.payload))
 		case Mysqlx.ServerMessages_CONN_CAPABILITIES:
-			// debug.Msg("getCapabilities() CONN_CAPABILITIES msg")
 			done = true
 		case Mysqlx.ServerMessages_NOTICE: // we don't expect a notice here so just print it.
-			debug.Msg("got unexpected NOTICE (see below), ignoring")
-			mc.pb = pb                          // hack though maybe should always use mc
-			mc.processNotice("getCapabilities") // process the notice message
+			if mc.log.on(LogWarn) {
+				mc.log.printf(LogWarn, "got unexpected NOTICE (see below), ignoring")
+			}
+			mc.pb = pb // hack though maybe should always use mc
+			if err := mc.processNotice("getCapabilities"); err != nil {
+				return err
+			}
 		default:
-			debug.Msg("got unexpected message type (show the type here), ignoring")
+			if mc.log.on(LogWarn) {
+				mc.log.printf(LogWarn, "got unexpected message type (show the type here), ignoring")
+			}
 		}
 	}
 
-	// debug.Msg("getCapabilities() exit loop")
-
 	if pb == nil {
 		return fmt.Errorf("BUG: Empty pb")
 	}
@@ -245,48 +324,60 @@ func (mc *mysqlXConn) getCapabilities() error {
 		return fmt.Errorf("BUG: Empty pb.payload")
 	}
 
-	// debug.Msg("getCapabilities() payload has data, %d bytes", len(pb.payload))
-
 	// get the capabilities info
 	capabilities := &Mysqlx_Connection.Capabilities{}
 	if err := proto.Unmarshal(pb.payload, capabilities); err != nil {
 		return fmt.Errorf("unmarshaling error with capabilities: %v", err)
 	}
 
-	debug.Msg("found %d capabilities", len(capabilities.GetCapabilities()))
+	if mc.log.on(LogDebug) {
+		mc.log.printf(LogDebug, "found %d capabilities", len(capabilities.GetCapabilities()))
+	}
 	for i := range capabilities.GetCapabilities() {
-		name := capabilities.GetCapabilities()[i].GetName()
-		value := capabilities.GetCapabilities()[i].GetValue()
-		if isScalar(value) {
-			if isScalarString(value) {
-				scalar := scalarString(value)
-				debug.Msg("- scalar string: name: %q, value: %q", name, scalar)
-				mc.capabilities.AddScalarString(name, scalar)
-			} else if isScalarBool(value) {
-				scalar := scalarBool(value)
-				debug.Msg("- scalar bool: name: %q, value: %v", name, scalar)
-				mc.capabilities.AddScalarBool(name, scalar)
-			} else {
-				scalarName := value.Scalar.Type.String()
-				debug.Msg("Found scalar: name: %q, value: %+v (type %s) which I can not handle yet", name, value, scalarName)
+		mc.addCapability(capabilities.GetCapabilities()[i].GetName(), capabilities.GetCapabilities()[i].GetValue())
+	}
+
+	return nil
+}
+
+// addCapability records a capability sent by the server. The fields
+// of an object are recorded as separate capabilities named
+// "name.field", so compression's algorithm is "compression.algorithm".
+func (mc *mysqlXConn) addCapability(name string, value *Mysqlx_Datatypes.Any) {
+	if isScalar(value) {
+		if isScalarString(value) {
+			scalar := scalarString(value)
+			if mc.log.on(LogDebug) {
+				mc.log.printf(LogDebug, "- scalar string: name: %q, value: %q", name, scalar)
 			}
-		} else if isArrayString(value) {
-			values := arrayString(value)
-			debug.Msg("- array of strings: name: %q, values: %+v", name, values)
-			mc.capabilities.AddArrayString(name, values)
+			mc.capabilities.AddScalarString(name, scalar)
+		} else if isScalarBool(value) {
+			scalar := scalarBool(value)
+			if mc.log.on(LogDebug) {
+				mc.log.printf(LogDebug, "- scalar bool: name: %q, value: %v", name, scalar)
+			}
+			mc.capabilities.AddScalarBool(name, scalar)
 		} else {
-			valueType := ""
-			if isScalar(value) {
-				valueType = "scalar"
-			} else {
-				valueType = "non-scalar"
+			scalarName := value.GetScalar().GetType().String()
+			if mc.log.on(LogDebug) {
+				mc.log.printf(LogDebug, "Found scalar: name: %q, value: %+v (type %s) which I can not handle yet", name, value, scalarName)
 			}
-			scalarName := value.Scalar.Type.String()
-			debug.Msg("getCapabilities: capability %q is of a %s type (%s) I can not handle yet (so ignoring)", name, valueType, scalarName)
+		}
+	} else if isArrayString(value) {
+		values := arrayString(value)
+		if mc.log.on(LogDebug) {
+			mc.log.printf(LogDebug, "- array of strings: name: %q, values: %+v", name, values)
+		}
+		mc.capabilities.AddArrayString(name, values)
+	} else if value.GetType() == Mysqlx_Datatypes.Any_OBJECT {
+		for _, field := range value.GetObj().GetFld() {
+			mc.addCapability(name+"."+field.GetKey(), field.GetValue())
+		}
+	} else {
+		if mc.log.on(LogWarn) {
+			mc.log.printf(LogWarn, "getCapabilities: capability %q is of a type (%s) I can not handle yet (so ignoring)", name, value.GetType())
 		}
 	}
-
-	return nil
 }
 
 // return a new boolean scalar of the given type
@@ -317,7 +408,9 @@ func newAnyScalar(anyType Mysqlx_Datatypes.Any_Type, scalar *Mysqlx_Datatypes.Sc
 
 // set a boolean scalar capability (tls probably)
 func (mc *mysqlXConn) setScalarBoolCapability(name string, value bool) error {
-	debug.Msg("setScalarBoolCapability(%q,%v)", name, value)
+	if mc.log.on(LogDebug) {
+		mc.log.printf(LogDebug, "setScalarBoolCapability(%q,%v)", name, value)
+	}
 
 	// Wow this is long-winded and harder than I'd expect (even
 	// for a trivial setup like this)
@@ -326,12 +419,43 @@ func (mc *mysqlXConn) setScalarBoolCapability(name string, value bool) error {
 	//   ourselves afterwards and can leave it to Go's garbage
 	//   collector.
 
-	any := newAnyScalar(Mysqlx_Datatypes.Any_SCALAR, newBoolScalar(value))
+	return mc.setCapability(name, newAnyScalar(Mysqlx_Datatypes.Any_SCALAR, newBoolScalar(value)))
+}
+
+// set a capability with an object value, made of the given fields
+// (compression)
+func (mc *mysqlXConn) setObjectCapability(name string, fields map[string]*Mysqlx_Datatypes.Any) error {
+	if mc.log.on(LogDebug) {
+		mc.log.printf(LogDebug, "setObjectCapability(%q,%v)", name, fields)
+	}
+
+	// sorted so the message is the same each time
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	obj := &Mysqlx_Datatypes.Object{}
+	for _, key := range keys {
+		obj.Fld = append(obj.Fld, &Mysqlx_Datatypes.Object_ObjectField{
+			Key:   proto.String(key),
+			Value: fields[key],
+		})
+	}
+
+	return mc.setCapability(name, &Mysqlx_Datatypes.Any{
+		Type: Mysqlx_Datatypes.Any_OBJECT.Enum(),
+		Obj:  obj,
+	})
+}
 
+// setCapability sends CapabilitiesSet for a single capability and
+// waits for the server to accept it
+func (mc *mysqlXConn) setCapability(name string, value *Mysqlx_Datatypes.Any) error {
 	// setup capability structure from what we've just created
 	capability := &Mysqlx_Connection.Capability{
 		Name:  proto.String(name),
-		Value: any,
+		Value: value,
 	}
 
 	var capabilitiesArray []*Mysqlx_Connection.Capability
@@ -346,55 +470,65 @@ func (mc *mysqlXConn) setScalarBoolCapability(name string, value bool) error {
 		Capabilities: capabilities,
 	}
 
-	debug.Msg("CapabilitiesSet msg: <%+v>", capabilitiesSet.String())
+	if mc.log.on(LogDebug) {
+		mc.log.printf(LogDebug, "CapabilitiesSet msg: <%+v>", capabilitiesSet.String())
+	}
 
 	var err error
 	pb := new(netProtobuf)
 	pb.msgType = int(Mysqlx.ClientMessages_CON_CAPABILITIES_SET)
 	if pb.payload, err = proto.Marshal(capabilitiesSet); err != nil {
-		return fmt.Errorf("SetScalarBoolCapability(%q,%v) failed to create marshalled message: %v", err)
+		return fmt.Errorf("setCapability(%q) failed to create marshalled message: %v", name, err)
 	}
 
-	debug.Msg("CapabilitySet message: %s", capabilitiesSet.String())
+	if mc.log.on(LogDebug) {
+		mc.log.printf(LogDebug, "CapabilitySet message: %s", capabilitiesSet.String())
+	}
 
 	// Send the message
 	if err = mc.writeProtobufPacket(pb); err != nil {
-		return fmt.Errorf("SetScalarBoolCapability(%q,%v) failed: %v", name, value, err)
+		return fmt.Errorf("setCapability(%q) failed: %v", name, err)
 	}
 
 	// wait for the answer (I expect it to be OK / ERROR)
 	done := false
 	// wait for the answer OK, ERROR or NOTICE
 	for !done {
-		debug.Msg("setScalarBoolCapability() read response from capabilitiesSet message...")
+		if mc.log.on(LogDebug) {
+			mc.log.printf(LogDebug, "setCapability() read response from capabilitiesSet message...")
+		}
 		if pb, err = mc.readMsg(); err != nil {
 			return err
 		}
 		if pb == nil {
-			return fmt.Errorf("setScalarBoolCapability() pb = nil (not expected to happen ever)")
+			return fmt.Errorf("setCapability() pb = nil (not expected to happen ever)")
 		}
 
-		// debug.Msg("setScalarBoolCapability() have data: type: %s, data: %+v", Mysqlx.ServerMessages_Type(pb.msgType).String(), string(pb.payload))
-
 		switch Mysqlx.ServerMessages_Type(pb.msgType) {
 		case Mysqlx.ServerMessages_OK:
-			debug.Msg("setScalarBoolCapability() OK msg")
+			if mc.log.on(LogDebug) {
+				mc.log.printf(LogDebug, "setCapability() OK msg")
+			}
 			return nil
 		case Mysqlx.ServerMessages_ERROR:
-			debug.Msg("setScalarBoolCapability() %s", pb.errorMsg().Error())
-			return fmt.Errorf("setScalarBoolCapability failed: %v", mc.processErrorMsg())
+			mc.pb = pb
+			return fmt.Errorf("setCapability(%q) failed: %v", name, mc.processErrorMsg())
 		case Mysqlx.ServerMessages_NOTICE:
 			// we don't expect a notice here so just print it.
-			debug.Msg("got unexpected NOTICE (below), ignoring")
+			if mc.log.on(LogWarn) {
+				mc.log.printf(LogWarn, "got unexpected NOTICE (below), ignoring")
+			}
 			mc.pb = pb // should use just mc.pb ??
-			mc.processNotice("setScalarBoolCapability")
+			if err := mc.processNotice("setCapability"); err != nil {
+				return err
+			}
 		default:
-			debug.Msg("got unexpected message type %d, ignoring", Mysqlx.ServerMessages_Type(pb.msgType))
+			if mc.log.on(LogWarn) {
+				mc.log.printf(LogWarn, "got unexpected message type %d, ignoring", Mysqlx.ServerMessages_Type(pb.msgType))
+			}
 		}
 	}
 
-	// debug.Msg("getCapabilities() exit loop")
-
 	if pb == nil {
 		return fmt.Errorf("BUG: Empty pb")
 	}
@@ -402,7 +536,6 @@ func (mc *mysqlXConn) setScalarBoolCapability(name string, value bool) error {
 		return fmt.Errorf("BUG: Empty pb.payload")
 	}
 
-	// debug.Msg("getCapabilities() payload has data, %d bytes", len(pb.payload))
 	return nil
 }
 
@@ -411,7 +544,12 @@ func errorText(e *Mysqlx.Error) error {
 	if e == nil {
 		return fmt.Errorf("errorText: ERROR e == nil")
 	}
-	return fmt.Errorf("%v: %04d [%s] %s", e.Severity, *(e.Code), *(e.SqlState), *(e.Msg))
+	return &MySQLError{
+		Severity: e.GetSeverity().String(),
+		Code:     e.GetCode(),
+		SQLState: e.GetSqlState(),
+		Message:  e.GetMsg(),
+	}
 }
 
 // return an error message type as an error
@@ -435,65 +573,82 @@ func (pb *netProtobuf) errorMsg() error {
 func errorMsg(data []byte) error {
 	e := new(Mysqlx.Error)
 	if err := proto.Unmarshal(data, e); err != nil {
-		log.Fatal("unmarshaling error with e: ", err)
+		return fmt.Errorf("unmarshaling error with e: %v", err)
 	}
 	return errorText(e)
 }
 
-func printAuthenticateOk(data []byte) {
+func (mc *mysqlXConn) printAuthenticateOk(data []byte) {
+	if !mc.log.on(LogDebug) {
+		return
+	}
 	ok := &Mysqlx_Session.AuthenticateOk{}
 	if err := proto.Unmarshal(data, ok); err != nil {
-		log.Fatal("unmarshaling error with AuthenticateOk: ", err)
+		mc.log.printf(LogDebug, "unmarshaling error with AuthenticateOk: %v", err)
+		return
 	}
 
 	okAuthData := []byte(ok.GetAuthData())
-	debug.Msg("Login successful: Got back authData: %q (%d bytes)", okAuthData, len(okAuthData))
+	mc.log.printf(LogDebug, "Login successful: Got back authData: %q (%d bytes)", okAuthData, len(okAuthData))
 }
 
 func (mc *mysqlXConn) processNotice(where string) error {
-	debug.Msg("mysqlXConn.processNotice(%q)", where)
 	if mc == nil {
-		log.Fatalf("mysqlXConn.processNotice(%q): mc == nil", where)
+		return fmt.Errorf("mysqlXConn.processNotice(%q): mc == nil", where)
+	}
+	if mc.log.on(LogDebug) {
+		mc.log.printf(LogDebug, "mysqlXConn.processNotice(%q)", where)
 	}
 	if mc.pb == nil {
-		log.Fatalf("mysqlXConn.processNotice(%q): mc.pb == nil", where)
+		return fmt.Errorf("mysqlXConn.processNotice(%q): mc.pb == nil", where)
 	}
 
 	var payload string
 
 	f := new(Mysqlx_Notice.Frame)
 	if err := proto.Unmarshal(mc.pb.payload, f); err != nil {
-		log.Fatalf("error unmarshaling Notice f: %v", err)
+		return fmt.Errorf("mysqlXConn.processNotice(%q): error unmarshaling Notice f: %v", where, err)
 	}
 
+	mc.stats.notice(f.GetType())
+	notice := Notice{Type: f.GetType(), Global: f.GetScope() == Mysqlx_Notice.Frame_GLOBAL}
 	switch f.GetType() {
-	case 1: // warning
+	case NoticeWarning:
 		{
 			w := new(Mysqlx_Notice.Warning)
 			if err := proto.Unmarshal(f.Payload, w); err != nil {
-				log.Fatalf("error unmarshaling Warning w: %v", err)
+				return fmt.Errorf("mysqlXConn.processNotice(%q): error unmarshaling Warning w: %v", where, err)
 			}
+			notice.Level, notice.Code, notice.Msg = w.GetLevel().String(), w.GetCode(), w.GetMsg()
 			payload = fmt.Sprintf("Level: %+v, code: %d, msg: %s",
 				w.GetLevel().String(),
 				w.GetCode(),
 				w.GetMsg())
 		}
-	case 2: // session variable change
+	case NoticeSessionVariableChanged:
 		{
-
 			s := new(Mysqlx_Notice.SessionVariableChanged)
 			if err := proto.Unmarshal(f.Payload, s); err != nil {
-				log.Fatalf("error unmarshaling SessionVariableChanged s: %v", err)
+				return fmt.Errorf("mysqlXConn.processNotice(%q): error unmarshaling SessionVariableChanged s: %v", where, err)
 			}
+			notice.Param, notice.Value = s.GetParam(), scalarValue(s.GetValue())
+			mc.sessionVariableChanged(s.GetParam(), notice.Value)
 			payload = fmt.Sprintf("SessionVariableChanged: Param: %s, Value: %+v",
 				s.GetParam(),
 				s.GetValue()) // show value properly
 		}
-	case 3: // SessionStateChanged
+	case NoticeSessionStateChanged:
 		{
 			s := new(Mysqlx_Notice.SessionStateChanged)
 			if err := proto.Unmarshal(f.Payload, s); err != nil {
-				log.Fatalf("error unmarshaling SessionStateChanged s: %v", err)
+				return fmt.Errorf("mysqlXConn.processNotice(%q): error unmarshaling SessionStateChanged s: %v", where, err)
+			}
+			notice.Param, notice.Value = s.GetParam().String(), scalarValue(s.GetValue())
+			switch s.GetParam() {
+			case Mysqlx_Notice.SessionStateChanged_ROWS_AFFECTED:
+				mc.affectedRows = s.GetValue().GetVUnsignedInt()
+			case Mysqlx_Notice.SessionStateChanged_GENERATED_INSERT_ID:
+				mc.insertID = s.GetValue().GetVUnsignedInt()
 			}
 			payload = fmt.Sprintf("SessionStateChanged: Param: %s, Value: %+v",
 				s.GetParam(),
@@ -501,17 +656,29 @@ func (mc *mysqlXConn) processNotice(where string) error {
 		}
 	default:
 		{
-			debug.Msg("WARNING: Unable to handle Notice type: %d, ignoring", f.GetType())
+			if mc.log.on(LogWarn) {
+				mc.log.printf(LogWarn, "WARNING: Unable to handle Notice type: %d, ignoring", f.GetType())
+			}
 			payload = fmt.Sprintf("% x", f.Payload)
 		}
 	}
+	mc.notices = append(mc.notices, notice)
+	mc.onNotice(notice)
 
-	debug.Msg("Received NOTICE: Type: %+v [%s], Scope: %d [%s], %s",
-		f.GetType(),
-		noticeTypeToName(f.GetType()), // not available by protobuf ?
-		f.GetScope(),
-		f.GetScope().String(),
-		payload)
+	if mc.log.on(LogDebug) {
+		mc.log.printf(LogDebug, "Received NOTICE: Type: %+v [%s], Scope: %d [%s], %s",
+			f.GetType(),
+			noticeTypeToName(f.GetType()), // not available by protobuf ?
+			f.GetScope(),
+			f.GetScope().String(),
+			payload)
+	}
+
+	// A global notice such as a group replication state change may
+	// mean the role of this server has changed.
+	if f.GetScope() == Mysqlx_Notice.Frame_GLOBAL && f.GetType() != NoticeServerHello && mc.onGlobalNotice != nil {
+		mc.onGlobalNotice()
+	}
 
 	mc.pb = nil // reset message (as now processed)
 
@@ -529,7 +696,9 @@ func (mc *mysqlXConn) writeConnCapabilitiesGet() error {
 func (mc *mysqlXConn) writeSessAuthenticateStart(m *Mysqlx_Session.AuthenticateStart) error {
 	var err error
 
-	debug.Msg("writeSessAuthenticateStart: %+v", m.String())
+	if mc.log.on(LogDebug) {
+		mc.log.printf(LogDebug, "writeSessAuthenticateStart: %+v", m.String())
+	}
 
 	pb := new(netProtobuf)
 	pb.msgType = int(Mysqlx.ClientMessages_SESS_AUTHENTICATE_START)
@@ -544,7 +713,9 @@ func (mc *mysqlXConn) writeSessAuthenticateStart(m *Mysqlx_Session.AuthenticateS
 func (mc *mysqlXConn) writeSessAuthenticateContinue(m *Mysqlx_Session.AuthenticateContinue) error {
 	var err error
 
-	debug.Msg("writeSessAuthenticateContinue: %+v", m.String())
+	if mc.log.on(LogDebug) {
+		mc.log.printf(LogDebug, "writeSessAuthenticateContinue: %+v", m.String())
+	}
 
 	pb := new(netProtobuf)
 	pb.msgType = int(Mysqlx.ClientMessages_SESS_AUTHENTICATE_CONTINUE)
@@ -555,39 +726,72 @@ func (mc *mysqlXConn) writeSessAuthenticateContinue(m *Mysqlx_Session.Authentica
 	return mc.writeProtobufPacket(pb)
 }
 
-func readSessAuthenticateContinue(pb *netProtobuf) *Mysqlx_Session.AuthenticateContinue {
+func (mc *mysqlXConn) readSessAuthenticateContinue(pb *netProtobuf) (*Mysqlx_Session.AuthenticateContinue, error) {
 	authenticateContinue := &Mysqlx_Session.AuthenticateContinue{}
 	if err := proto.Unmarshal(pb.payload, authenticateContinue); err != nil {
-		log.Fatal("unmarshaling error with authenticateContinue: ", err)
+		return nil, fmt.Errorf("unmarshaling error with authenticateContinue: %v", err)
+	}
+	if mc.log.on(LogTrace) {
+		mc.log.printf(LogTrace, "readSessAuthenticateContinue: %+v", authenticateContinue.String())
 	}
 
-	debug.Msg("readSessAuthenticateContinue: %+v", authenticateContinue.String())
+	return authenticateContinue, nil
+}
 
-	return authenticateContinue
+// authenticator is implemented by each of the authentication methods
+// the driver supports.
+type authenticator interface {
+	Name() string
+	GetInitialAuthData() []byte
+	GetNextAuthData(serverData []byte) ([]byte, error)
 }
 
 // AuthenticateMySQL41 uses MYSQL41 authentication method
 func (mc *mysqlXConn) AuthenticateMySQL41() error {
+	return mc.authenticateWith(NewMySQL41(mc.cfg.dbname, mc.cfg.user, mc.cfg.passwd))
+}
+
+// AuthenticatePlain uses PLAIN authentication method. The password is
+// sent as it is so this is refused unless the connection uses TLS or a
+// unix socket or allowCleartextPasswords is set.
+func (mc *mysqlXConn) AuthenticatePlain() error {
+	if mc.cfg.tls == nil && mc.cfg.net != "unix" && !mc.cfg.allowCleartextPasswords {
+		return ErrCleartextPassword
+	}
+	return mc.authenticateWith(NewPlain(mc.cfg.dbname, mc.cfg.user, mc.cfg.passwd))
+}
+
+// AuthenticateSHA256Memory uses SHA256_MEMORY authentication method
+func (mc *mysqlXConn) AuthenticateSHA256Memory() error {
+	return mc.authenticateWith(NewSHA256Memory(mc.cfg.dbname, mc.cfg.user, mc.cfg.passwd))
+}
+
+// authenticateWith runs the authentication message exchange using the given method
+func (mc *mysqlXConn) authenticateWith(authInfo authenticator) error {
 	var err error
-	debug.Msg("AuthenticateMySQL41(db: %q, user: %q, passwd: <not shown>)", mc.cfg.dbname, mc.cfg.user)
+	if mc.log.on(LogDebug) {
+		mc.log.printf(LogDebug, "authenticateWith(db: %q, user: %q, passwd: <not shown>)", mc.cfg.dbname, mc.cfg.user)
+	}
+	if mc.cfg.user == "" {
+		return fmt.Errorf("authenticateWith: no user given")
+	}
+	name := authInfo.Name()
 
 	// ------------------------------------------------------------------------
 	// C -> S   SESS_AUTHENTICATE_START
 	// ------------------------------------------------------------------------
-	authInfo := NewMySQL41(mc.cfg.dbname, mc.cfg.user, mc.cfg.passwd) // copy me into AuthData: ... (and adjust)
 
 	// create the protobuf message (AuthenticateStart)
 	msg := &Mysqlx_Session.AuthenticateStart{
-		MechName: proto.String("MYSQL41"),
-		AuthData: []byte(authInfo.GetInitialAuthData()),
+		MechName: proto.String(name),
+		AuthData: authInfo.GetInitialAuthData(),
 	}
-	mc.writeSessAuthenticateStart(msg)
-	if err != nil {
-		return fmt.Errorf("AuthenticateMySQL41: %v", err)
+	if err = mc.writeSessAuthenticateStart(msg); err != nil {
+		return fmt.Errorf("Authenticate%s: %v", name, err)
 	}
 
 	// ------------------------------------------------------------------------
-	// S -> C   SESS_AUTHENTICATE_CONTINUE
+	// S -> C   SESS_AUTHENTICATE_CONTINUE, or OK / ERROR if there is no challenge
 	// ------------------------------------------------------------------------
 
 	// wait for the answer
@@ -596,44 +800,48 @@ func (mc *mysqlXConn) AuthenticateMySQL41() error {
 		return err
 	}
 
-	if Mysqlx.ServerMessages_Type(pb.msgType) != Mysqlx.ServerMessages_SESS_AUTHENTICATE_CONTINUE {
-		return fmt.Errorf("Got unexpected message type back: %s, expecting: %s",
-			printableMsgTypeIn(Mysqlx.ServerMessages_Type(pb.msgType)),
-			printableMsgTypeIn(Mysqlx.ServerMessages_SESS_AUTHENTICATE_CONTINUE))
-	}
+	switch Mysqlx.ServerMessages_Type(pb.msgType) {
+	case Mysqlx.ServerMessages_SESS_AUTHENTICATE_CONTINUE:
+		authenticateContinue, err := mc.readSessAuthenticateContinue(pb)
+		if err != nil {
+			return fmt.Errorf("Authenticate%s: %v", name, err)
+		}
 
-	authenticateContinue := readSessAuthenticateContinue(pb)
+		authData := []byte(authenticateContinue.GetAuthData())
 
-	authData := []byte(authenticateContinue.GetAuthData())
-	//	debug.Msg("- unmarshalled authData, len: %d:%s", len(authData), hex.Dump(authData))
-	if len(authData) != 20 {
-		return fmt.Errorf("Received %d bytes from server, expecting: 20", len(authData))
-	}
+		// ------------------------------------------------------------------------
+		// C -> S   SESS_AUTHENTICATE_CONTINUE with scrambled password
+		// ------------------------------------------------------------------------
+		{
+			authenticateContinue := &Mysqlx_Session.AuthenticateContinue{}
+			response, err := authInfo.GetNextAuthData(authData)
+			if err != nil {
+				return fmt.Errorf("authInfo.GetNextAuthData() gave an error: %v", err)
+			}
+			authenticateContinue.AuthData = []byte(response)
 
-	// ------------------------------------------------------------------------
-	// C -> S   SESS_AUTHENTICATE_CONTINUE with scrambled password
-	// ------------------------------------------------------------------------
-	{
-		authenticateContinue := &Mysqlx_Session.AuthenticateContinue{}
-		response, err := authInfo.GetNextAuthData(authData)
-		if err != nil {
-			return fmt.Errorf("authInfo.GetNextAuthData() gave an error: %v", err)
+			if err := mc.writeSessAuthenticateContinue(authenticateContinue); err != nil {
+				return fmt.Errorf("Authenticate%s: failed writing AuthenticateContinue: %v", name, err)
+			}
 		}
-		authenticateContinue.AuthData = []byte(response)
 
-		if err := mc.writeSessAuthenticateContinue(authenticateContinue); err != nil {
-			return fmt.Errorf("AuthenticateMySQL41: failed writing AuthenticateContinue: %v", err)
+		// ------------------------------------------------------------------------
+		// S -> C   SESS_AUTHENTICATE_OK / ERROR / NOTICE
+		// ------------------------------------------------------------------------
+		if err := mc.waitingForAuthenticateOk(); err != nil {
+			return fmt.Errorf("Failed to read message response from our SESS_AUTHENTICATE_CONTINUE: %v", err)
 		}
+	case Mysqlx.ServerMessages_SESS_AUTHENTICATE_OK:
+		mc.pb = pb
+	case Mysqlx.ServerMessages_ERROR:
+		return errorMsg(pb.payload)
+	default:
+		return fmt.Errorf("Got unexpected message type back: %s, expecting: %s",
+			printableMsgTypeIn(Mysqlx.ServerMessages_Type(pb.msgType)),
+			printableMsgTypeIn(Mysqlx.ServerMessages_SESS_AUTHENTICATE_CONTINUE))
 	}
 
-	// ------------------------------------------------------------------------
-	// S -> C   SESS_AUTHENTICATE_OK / ERROR / NOTICE
-	// ------------------------------------------------------------------------
-	if err := mc.waitingForAuthenticateOk(); err != nil {
-		return fmt.Errorf("Failed to read message response from our SESS_AUTHENTICATE_CONTINUE: %v", err)
-	}
-
-	printAuthenticateOk(mc.pb.payload)
+	mc.printAuthenticateOk(mc.pb.payload)
 	mc.pb = nil // treat the incoming message as processsed
 
 	return nil // supposedly we have done the right thing
@@ -643,51 +851,65 @@ func (mc *mysqlXConn) AuthenticateMySQL41() error {
 // We may get an error (of the form: 1045 [HY000] Invalid user or password which needs
 // to be passed to the caller so that the connection is closed.
 func (mc *mysqlXConn) waitingForAuthenticateOk() error {
-	debug.Msg("WaitingForAuthenticateOk: starting")
+	if mc.log.on(LogDebug) {
+		mc.log.printf(LogDebug, "WaitingForAuthenticateOk: starting")
+	}
 	var err error
 	done := false
 	for !done {
-		debug.Msg("WaitingForAuthenticateOk: wait for message...")
+		if mc.log.on(LogDebug) {
+			mc.log.printf(LogDebug, "WaitingForAuthenticateOk: wait for message...")
+		}
 		mc.pb, err = mc.readMsg()
 		if err != nil {
 			return fmt.Errorf("Failed to read message response from our SESS_AUTHENTICATE_CONTINUE: %v", err)
 		}
-		debug.Msg("WaitingForAuthenticateOk: msg read from server")
+		if mc.log.on(LogDebug) {
+			mc.log.printf(LogDebug, "WaitingForAuthenticateOk: msg read from server")
+		}
 
 		switch Mysqlx.ServerMessages_Type(mc.pb.msgType) {
 		case Mysqlx.ServerMessages_SESS_AUTHENTICATE_OK:
 			{
 				done = true /* fall through */
-				debug.Msg("WaitingForAuthenticateOk: found expected SESS_AUTHENTICATE_OK")
+				if mc.log.on(LogDebug) {
+					mc.log.printf(LogDebug, "WaitingForAuthenticateOk: found expected SESS_AUTHENTICATE_OK")
+				}
 			}
 		case Mysqlx.ServerMessages_ERROR:
 			{
-				debug.Msg("WaitingForAuthenticateOk: found ERROR, returning to caller")
+				if mc.log.on(LogDebug) {
+					mc.log.printf(LogDebug, "WaitingForAuthenticateOk: found ERROR, returning to caller")
+				}
 				return errorMsg(mc.pb.payload)
 			}
 		case Mysqlx.ServerMessages_NOTICE:
 			{
 				// Not currently documented (explicitly) but we always get this type of message prior to SESS_AUTHENTICATE_OK
-				// debug.Msg("WaitingForAuthenticateOk: found NOTICE, not really expecting")
 				if err := mc.processNotice("waitingForAuthenticateOk"); err != nil {
-					// just carry on but record the problem. handle properly later
-					debug.Msg("waitingForAuthenticateOk: processNotice() failed: %v", err)
+					return err
 				}
 			}
 		default:
 			{
-				debug.Msg("Received unexpected message type: %s",
-					printableMsgTypeIn(Mysqlx.ServerMessages_Type(mc.pb.msgType)))
-				debug.Msg("Expected message type: %s",
-					printableMsgTypeIn(Mysqlx.ServerMessages_OK))
+				if mc.log.on(LogWarn) {
+					mc.log.printf(LogWarn, "Received unexpected message type: %s",
+						printableMsgTypeIn(Mysqlx.ServerMessages_Type(mc.pb.msgType)))
+				}
+				if mc.log.on(LogDebug) {
+					mc.log.printf(LogDebug, "Expected message type: %s",
+						printableMsgTypeIn(Mysqlx.ServerMessages_OK))
+				}
 
-				log.Fatalf("mysqlXConn.waitingfor_SESS_AUTHENTICATE_OK: Received unexpected message type: %s, expecting: %s",
+				return fmt.Errorf("mysqlXConn.waitingfor_SESS_AUTHENTICATE_OK: Received unexpected message type: %s, expecting: %s",
 					printableMsgTypeIn(Mysqlx.ServerMessages_Type(mc.pb.msgType)),
-					printableMsgTypeIn(Mysqlx.ServerMessages_OK))
+					printableMsgTypeIn(Mysqlx.ServerMessages_SESS_AUTHENTICATE_OK))
 			}
 		}
 	}
-	debug.Msg("WaitingForAuthenticateOk: out of loop, return no error")
+	if mc.log.on(LogDebug) {
+		mc.log.printf(LogDebug, "WaitingForAuthenticateOk: out of loop, return no error")
+	}
 	return nil
 }
 
@@ -711,7 +933,9 @@ func noticeTypeToName(t uint32) string {
 // Gets the value of the given MySQL System Variable
 // The returned byte slice is only valid until the next read
 func getSystemVarXProtocol(name string, mc *mysqlXConn) ([]byte, error) {
-	debug.Msg("getSystemVarXProtocol() not implemented")
+	if mc.log.on(LogDebug) {
+		mc.log.printf(LogDebug, "getSystemVarXProtocol() not implemented")
+	}
 
 	return nil, nil
 }
@@ -725,7 +949,7 @@ func (mc *mysqlXConn) writeStmtExecute(stmtExecute *Mysqlx_Sql.StmtExecute) erro
 	pb.payload, err = proto.Marshal(stmtExecute)
 
 	if err != nil {
-		log.Fatalf("Failed to marshall message: %+v: %v", stmtExecute, err)
+		return fmt.Errorf("Failed to marshall message: %+v: %v", stmtExecute, err)
 	}
 
 	err = mc.writeProtobufPacket(pb)
@@ -770,10 +994,13 @@ func (mc *mysqlXConn) processErrorMsg() error {
 	if err := proto.Unmarshal(mc.pb.payload, e); err != nil {
 		return fmt.Errorf("unmarshaling error with e: %v", err)
 	}
-	debug.Msg("processErrorMsg: %v: ", errorText(e))
+	err := errorText(e)
+	if mc.log.on(LogDebug) {
+		mc.log.printf(LogDebug, "processErrorMsg: %v: ", err)
+	}
 	mc.pb = nil
 
-	return nil
+	return err
 }
 
 // is this data printable?
@@ -0,0 +1,441 @@
+// Go driver for MySQL X Protocol
+//
+// Copyright 2016 Simon J Mudd.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+// This file handles read/write routing. When a list of hosts is given
+// together with routeReadOnly=true and/or routeQueries=true each
+// database/sql connection may use two servers:
+//
+//	- the primary, which gets all writes and all read/write transactions
+//	- a secondary, which gets read only transactions (routeReadOnly)
+//	  and Query calls made outside a transaction (routeQueries)
+//
+// The role of a server is worked out after connecting from, in order:
+//
+//	- the node_type capability: anything other than "mysql" (e.g. a
+//	  proxy) is treated as a primary as it does its own routing
+//	- the MEMBER_ROLE of the server in a group replication group
+//	- @@super_read_only and @@read_only
+//
+// The roles are shared by all connections of a connector. A notice
+// with global scope (e.g. a group replication state change) marks them
+// as stale and each connection checks its servers again before running
+// the next statement. If no secondary is available the primary is used.
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+var (
+	errWrongRole = errors.New("server does not have the required role")
+)
+
+type hostRole int
+
+const (
+	roleUnknown hostRole = iota
+	rolePrimary
+	roleSecondary
+)
+
+func (r hostRole) String() string {
+	switch r {
+	case rolePrimary:
+		return "primary"
+	case roleSecondary:
+		return "secondary"
+	}
+	return "unknown"
+}
+
+// topology holds the known role of each host
+type topology struct {
+	mu         sync.Mutex
+	roles      map[string]hostRole // keyed by address
+	generation uint64              // incremented each time the roles are invalidated
+	log        *logger
+}
+
+func newTopology(log *logger) *topology {
+	return &topology{roles: make(map[string]hostRole), log: log}
+}
+
+// role returns the last known role of the host
+func (t *topology) role(addr string) hostRole {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.roles[addr]
+}
+
+// setRole records the role of the host
+func (t *topology) setRole(addr string, role hostRole) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.roles[addr] = role
+}
+
+// invalidate forgets all roles so they are checked again
+func (t *topology) invalidate() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.log.on(LogDebug) {
+		t.log.printf(LogDebug, "topology.invalidate: generation %d", t.generation+1)
+	}
+	t.roles = make(map[string]hostRole)
+	t.generation++
+}
+
+// gen returns the current generation
+func (t *topology) gen() uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.generation
+}
+
+// routing returns true if connections should route between hosts
+func (c *connector) routing() bool {
+	return (c.cfg.RouteReadOnly || c.cfg.RouteQueries) && len(c.cfg.Hosts) > 1
+}
+
+// connectRole connects to a host with the given role. Hosts known to
+// have a different role are not tried and the role of a host is only
+// checked if it is not already known.
+func (c *connector) connectRole(ctx context.Context, want hostRole) (*mysqlXConn, error) {
+	var hosts []Host
+	for _, host := range c.cfg.Hosts {
+		if role := c.topology.role(host.Addr); role == roleUnknown || role == want {
+			hosts = append(hosts, host)
+		}
+	}
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("no %s host available", want)
+	}
+
+	return c.connectAny(ctx, hosts, func(mc *mysqlXConn) bool {
+		role := c.topology.role(mc.cfg.addr)
+		if role == roleUnknown {
+			role = mc.classify()
+			c.topology.setRole(mc.cfg.addr, role)
+		}
+		if c.log.on(LogDebug) {
+			c.log.printf(LogDebug, "connector.connectRole: %s(%s) is %s, want %s", mc.cfg.net, mc.cfg.addr, role, want)
+		}
+		return role == want
+	})
+}
+
+// connectRouted returns a routing connection. The primary is connected
+// to first but if none can be found a secondary is enough to start
+// with so reads keep working.
+func (c *connector) connectRouted(ctx context.Context) (driver.Conn, error) {
+	rc := &routedConn{c: c, generation: c.topology.gen()}
+
+	var err error
+	if rc.primary, err = c.connectRole(ctx, rolePrimary); err != nil {
+		if c.log.on(LogDebug) {
+			c.log.printf(LogDebug, "connector.connectRouted: no primary: %v", err)
+		}
+		if rc.secondary, err = c.connectRole(ctx, roleSecondary); err != nil {
+			return nil, err
+		}
+	}
+	return rc, nil
+}
+
+// classify works out the role of the server. Errors are not fatal,
+// the server is just not usable for routing.
+func (mc *mysqlXConn) classify() hostRole {
+	if values := mc.capabilities.Values("node_type"); len(values) > 0 {
+		if nodeType := values[0].String(); nodeType != "" && nodeType != "mysql" {
+			return rolePrimary
+		}
+	}
+
+	// group replication, which needs 8.0 for MEMBER_ROLE
+	row, err := mc.queryRow("SELECT MEMBER_ROLE, MEMBER_STATE FROM performance_schema.replication_group_members WHERE MEMBER_ID = @@server_uuid")
+	if err != nil {
+		if mc.log.on(LogDebug) {
+			mc.log.printf(LogDebug, "mysqlXConn.classify: group replication check failed: %v", err)
+		}
+	} else if len(row) == 2 {
+		role, state := valueString(row[0]), valueString(row[1])
+		if state != "ONLINE" {
+			return roleUnknown
+		}
+		switch role {
+		case "PRIMARY":
+			return rolePrimary
+		case "SECONDARY":
+			return roleSecondary
+		}
+	}
+
+	row, err = mc.queryRow("SELECT @@super_read_only, @@read_only")
+	if err != nil || len(row) != 2 {
+		if mc.log.on(LogDebug) {
+			mc.log.printf(LogDebug, "mysqlXConn.classify: read_only check failed: %v", err)
+		}
+		return roleUnknown
+	}
+	if valueTrue(row[0]) || valueTrue(row[1]) {
+		return roleSecondary
+	}
+	return rolePrimary
+}
+
+// queryRow runs a query and returns a copy of the first row, or nil if
+// there are no rows
+func (mc *mysqlXConn) queryRow(query string) ([]driver.Value, error) {
+	rows, err := mc.stmtExecute(query, nil, false)
+	if err != nil {
+		return nil, err
+	}
+
+	dest := make([]driver.Value, len(rows.Columns()))
+	err = rows.Next(dest)
+	if err == nil {
+		// the data refers to the read buffer so copy it before reading more
+		for i := range dest {
+			if b, ok := dest[i].([]byte); ok {
+				dest[i] = string(b)
+			}
+		}
+	}
+	closeErr := rows.Close()
+	switch {
+	case err == io.EOF:
+		return nil, closeErr
+	case err != nil:
+		return nil, err
+	case closeErr != nil:
+		return nil, closeErr
+	}
+	return dest, nil
+}
+
+// valueString returns a string or []byte value as a string
+func valueString(v driver.Value) string {
+	switch v := v.(type) {
+	case string:
+		return strings.TrimRight(v, "\x00")
+	case []byte:
+		return strings.TrimRight(string(v), "\x00")
+	}
+	return ""
+}
+
+// valueTrue returns true for a non-zero number or ON
+func valueTrue(v driver.Value) bool {
+	switch v := v.(type) {
+	case int64:
+		return v != 0
+	case uint64:
+		return v != 0
+	case string, []byte:
+		s := valueString(v)
+		return s == "1" || strings.EqualFold(s, "ON")
+	}
+	return false
+}
+
+// routedConn is a driver.Conn which sends writes to the primary and
+// reads to a secondary. The secondary is only connected when needed.
+type routedConn struct {
+	c           *connector
+	primary     *mysqlXConn
+	secondary   *mysqlXConn
+	txConn      *mysqlXConn // the connection the open transaction is on
+	generation  uint64      // topology generation the roles were last checked at
+	noSecondary bool        // no secondary could be found at this generation
+	last        *mysqlXConn // the connection used for the last statement
+}
+
+// refresh checks the roles of the servers again if the topology has
+// changed, dropping any connection which no longer has its role
+func (rc *routedConn) refresh() {
+	gen := rc.c.topology.gen()
+	if gen == rc.generation {
+		return
+	}
+	rc.generation = gen
+	rc.noSecondary = false
+
+	rc.primary = rc.checkRole(rc.primary, rolePrimary)
+	rc.secondary = rc.checkRole(rc.secondary, roleSecondary)
+}
+
+// checkRole returns the connection if the server still has the wanted
+// role, otherwise it closes it and returns nil
+func (rc *routedConn) checkRole(mc *mysqlXConn, want hostRole) *mysqlXConn {
+	if mc == nil {
+		return nil
+	}
+	role := mc.classify()
+	rc.c.topology.setRole(mc.cfg.addr, role)
+	if role != want {
+		if rc.c.log.on(LogDebug) {
+			rc.c.log.printf(LogDebug, "routedConn.checkRole: %s is now %s, dropping connection", mc.cfg.addr, role)
+		}
+		mc.Close()
+		return nil
+	}
+	return mc
+}
+
+// conn returns the connection to use for the next statement
+func (rc *routedConn) conn(ctx context.Context, readOnly bool) (*mysqlXConn, error) {
+	mc, err := rc.pick(ctx, readOnly)
+	if err == nil {
+		rc.last = mc
+	}
+	return mc, err
+}
+
+// pick returns the connection to use, see conn
+func (rc *routedConn) pick(ctx context.Context, readOnly bool) (*mysqlXConn, error) {
+	if rc.txConn != nil {
+		return rc.txConn, nil
+	}
+	rc.refresh()
+
+	if readOnly {
+		if rc.secondary == nil && !rc.noSecondary {
+			mc, err := rc.c.connectRole(ctx, roleSecondary)
+			if err != nil {
+				if rc.c.log.on(LogDebug) {
+					rc.c.log.printf(LogDebug, "routedConn.conn: no secondary, using primary: %v", err)
+				}
+				rc.noSecondary = true
+			}
+			rc.secondary = mc
+		}
+		if rc.secondary != nil {
+			return rc.secondary, nil
+		}
+	}
+
+	if rc.primary == nil {
+		mc, err := rc.c.connectRole(ctx, rolePrimary)
+		if err != nil {
+			return nil, err
+		}
+		rc.primary = mc
+	}
+	return rc.primary, nil
+}
+
+// Prepare is passed to the primary
+func (rc *routedConn) Prepare(query string) (driver.Stmt, error) {
+	mc, err := rc.conn(context.Background(), false)
+	if err != nil {
+		return nil, err
+	}
+	return mc.Prepare(query)
+}
+
+// Close closes the connections to both servers
+func (rc *routedConn) Close() error {
+	var err error
+	for _, mc := range []*mysqlXConn{rc.primary, rc.secondary} {
+		if mc == nil {
+			continue
+		}
+		if closeErr := mc.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	rc.primary, rc.secondary, rc.txConn, rc.last = nil, nil, nil, nil
+	return err
+}
+
+// Begin starts a read/write transaction on the primary
+func (rc *routedConn) Begin() (driver.Tx, error) {
+	return rc.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+// BeginTx starts a transaction. Read only transactions go to a
+// secondary if routeReadOnly is set.
+func (rc *routedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	mc, err := rc.conn(ctx, opts.ReadOnly && rc.c.cfg.RouteReadOnly)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := mc.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	rc.txConn = mc
+	return &routedTx{rc: rc, tx: tx}, nil
+}
+
+// Query goes to a secondary if routeQueries is set and no transaction
+// is open
+func (rc *routedConn) Query(query string, args []driver.Value) (driver.Rows, error) {
+	mc, err := rc.conn(context.Background(), rc.c.cfg.RouteQueries)
+	if err != nil {
+		return nil, err
+	}
+	return mc.Query(query, args)
+}
+
+// QueryContext is routed like Query
+func (rc *routedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	mc, err := rc.conn(ctx, rc.c.cfg.RouteQueries)
+	if err != nil {
+		return nil, err
+	}
+	return mc.QueryContext(ctx, query, args)
+}
+
+// Exec always goes to the primary unless a transaction is open
+func (rc *routedConn) Exec(query string, args []driver.Value) (driver.Result, error) {
+	mc, err := rc.conn(context.Background(), false)
+	if err != nil {
+		return nil, err
+	}
+	return mc.Exec(query, args)
+}
+
+// ExecContext is routed like Exec
+func (rc *routedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	mc, err := rc.conn(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	return mc.ExecContext(ctx, query, args)
+}
+
+// CheckNamedValue converts args as a single connection does, without
+// picking one
+func (rc *routedConn) CheckNamedValue(nv *driver.NamedValue) error {
+	return checkNamedValue(nv)
+}
+
+// routedTx keeps the routedConn on the same server until the
+// transaction ends
+type routedTx struct {
+	rc *routedConn
+	tx driver.Tx
+}
+
+func (tx *routedTx) Commit() error {
+	tx.rc.txConn = nil
+	return tx.tx.Commit()
+}
+
+func (tx *routedTx) Rollback() error {
+	tx.rc.txConn = nil
+	return tx.tx.Rollback()
+}
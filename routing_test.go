@@ -0,0 +1,88 @@
+// Go driver for MySQL X Protocol
+//
+// Copyright 2016 Simon J Mudd.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+import (
+	"database/sql/driver"
+	"testing"
+)
+
+// routing is only used with more than one host
+func TestRoutingEnabled(t *testing.T) {
+	tests := []struct {
+		dsn     string
+		routing bool
+	}{
+		{"user:pass@tcp(h1:33060,h2:33060)/test?xprotocol=1", false},
+		{"user:pass@tcp(h1:33060,h2:33060)/test?xprotocol=1&routeReadOnly=true", true},
+		{"user:pass@tcp(h1:33060,h2:33060)/test?xprotocol=1&routeQueries=1", true},
+		{"user:pass@tcp(h1:33060)/test?xprotocol=1&routeReadOnly=true", false},
+		{"mysqlx://user:pass@[h1,h2]/test?routeReadOnly=true", true},
+	}
+
+	for _, test := range tests {
+		cfg, err := ParseDSN(test.dsn)
+		if err != nil {
+			t.Errorf("TestRoutingEnabled: dsn: %s, ParseDSN gives error: %v", test.dsn, err)
+			continue
+		}
+		c, err := NewConnector(cfg)
+		if err != nil {
+			t.Errorf("TestRoutingEnabled: dsn: %s, NewConnector gives error: %v", test.dsn, err)
+			continue
+		}
+		if routing := c.(*connector).routing(); routing != test.routing {
+			t.Errorf("TestRoutingEnabled: dsn: %s, routing: %v, expected: %v", test.dsn, routing, test.routing)
+		}
+	}
+}
+
+// a global notice forgets the known roles
+func TestTopologyInvalidate(t *testing.T) {
+	topo := newTopology(nil)
+	topo.setRole("h1:33060", rolePrimary)
+	topo.setRole("h2:33060", roleSecondary)
+	gen := topo.gen()
+
+	if role := topo.role("h2:33060"); role != roleSecondary {
+		t.Errorf("TestTopologyInvalidate: role: %s, expected: %s", role, roleSecondary)
+	}
+
+	topo.invalidate()
+	if topo.gen() == gen {
+		t.Errorf("TestTopologyInvalidate: generation did not change")
+	}
+	for _, addr := range []string{"h1:33060", "h2:33060"} {
+		if role := topo.role(addr); role != roleUnknown {
+			t.Errorf("TestTopologyInvalidate: %s role: %s, expected: %s", addr, role, roleUnknown)
+		}
+	}
+}
+
+// the values returned for read_only and super_read_only
+func TestValueTrue(t *testing.T) {
+	tests := []struct {
+		value driver.Value
+		want  bool
+	}{
+		{int64(1), true},
+		{int64(0), false},
+		{uint64(1), true},
+		{[]byte("ON"), true},
+		{[]byte("OFF"), false},
+		{"1", true},
+		{nil, false},
+	}
+
+	for _, test := range tests {
+		if got := valueTrue(test.value); got != test.want {
+			t.Errorf("TestValueTrue: value: %#v, got: %v, expected: %v", test.value, got, test.want)
+		}
+	}
+}
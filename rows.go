@@ -14,21 +14,31 @@ import (
 	"database/sql/driver"
 	"fmt"
 	"io"
-	"log"
 
 	"github.com/golang/protobuf/proto"
 
 	"github.com/sjmudd/go-mysqlx-driver/Mysqlx"
 	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Resultset"
-
-	"github.com/sjmudd/go-mysqlx-driver/debug"
 )
 
 type mysqlXRows struct {
 	columns [](*Mysqlx_Resultset.ColumnMetaData) // holds column metadata (if present) for a row
 	mc      *mysqlXConn
 	state   queryState
-	err     error // provides the error received from a query (if present)
+	err     error      // provides the error received from a query (if present)
+	hook    *queryHook // told when the rows are closed, nil if there are no hooks
+	cursor  *cursor    // the rows are read through, nil if they are all sent at once
+	query   string     // the column names are kept for or taken from, "" if neither
+	compact bool       // the server sends compact metadata without the column names
+	names   []string   // returned by Columns, nil until the metadata has been read
+}
+
+// log returns the logger of the connection, nil once the rows are closed
+func (rows *mysqlXRows) log() *logger {
+	if rows == nil || rows.mc == nil {
+		return nil
+	}
+	return rows.mc.log
 }
 
 // readMsgIfNecessary reads in a message only if we don't have one already
@@ -42,11 +52,15 @@ func (rows *mysqlXRows) readMsgIfNecessary() error {
 	}
 	// if we already have a protobuf message then no need to read a new one
 	if rows.mc.pb != nil {
-		debug.Msg("mysqlXRows.readMsgIfNecessary: DO NOT read new message (pb != nil)")
+		if rows.log().on(LogTrace) {
+			rows.log().printf(LogTrace, "mysqlXRows.readMsgIfNecessary: DO NOT read new message (pb != nil)")
+		}
 		return nil
 	}
 
-	debug.Msg("mysqlXRows.readMsgIfNecessary: read NEW MESSAGE")
+	if rows.log().on(LogTrace) {
+		rows.log().printf(LogTrace, "mysqlXRows.readMsgIfNecessary: read NEW MESSAGE")
+	}
 
 	var err error
 	rows.mc.pb, err = rows.mc.readMsg()
@@ -62,71 +76,139 @@ func (rows *mysqlXRows) readMsgIfNecessary() error {
 // Columns returns the column meta data of a row and may need to
 // read in some of the metadata messages from the network.
 func (rows *mysqlXRows) Columns() []string {
+	if rows.names != nil {
+		return rows.names
+	}
 	rows.collectColumnMetaData()
 
 	columns := make([]string, len(rows.columns))
 	for i := range rows.columns {
-		// FIXME: handle: 	if rows.mc.cfg.columnsWithAlias { ....
-		columns[i] = string(rows.columns[i].GetName())
+		columns[i] = rows.mc.cfg.columnName(rows.columns[i])
+	}
+	if rows.state != queryStateWaitingColumnMetaData {
+		rows.names = columns // the metadata is complete
 	}
 	if len(columns) == 0 {
-		debug.Msg("mysqlXRows.Columns: return empty []string with %d entries (probably due to SQL error)", len(columns))
+		if rows.log().on(LogDebug) {
+			rows.log().printf(LogDebug, "mysqlXRows.Columns: return empty []string with %d entries (probably due to SQL error)", len(columns))
+		}
 	} else {
-		debug.Msg("mysqlXRows.Columns: return %+v", columns)
+		if rows.log().on(LogDebug) {
+			rows.log().printf(LogDebug, "mysqlXRows.Columns: return %+v", columns)
+		}
 	}
 	return columns
 }
 
+// columnName returns the name of a column as Columns gives it:
+// - name, or original_name with columnsOriginalName
+// - prefixed by the table and a dot with columnsWithAlias, using
+//   original_table with columnsOriginalName
+// The original names are empty for expressions in which case the
+// alias is used.
+func (cfg *xconfig) columnName(column *Mysqlx_Resultset.ColumnMetaData) string {
+	name, table := column.GetName(), column.GetTable()
+	if cfg.columnsOriginalName {
+		if original := column.GetOriginalName(); len(original) > 0 {
+			name = original
+		}
+		if original := column.GetOriginalTable(); len(original) > 0 {
+			table = original
+		}
+	}
+	if cfg.columnsWithAlias && len(table) > 0 {
+		return string(table) + "." + string(name)
+	}
+	return string(name)
+}
+
 // we have finished with the iterator
 // - given Close can be called at any time we may have pending
 //   messages in the queue which need skipping so we really need
 //   to keep the state of where we are.
 func (rows *mysqlXRows) Close() error {
-	debug.Msg("mysqlXRows.Close: entry")
-
 	// safety checks
 	if rows == nil {
-		debug.Msg("mysqlXRows.Close: rows == nil, ignoring")
 		return nil // to avoid breakage. Fix the calling code later
 	}
+	log := rows.log()
+	if log.on(LogDebug) {
+		log.printf(LogDebug, "mysqlXRows.Close: entry")
+	}
 	if rows.mc == nil {
 		return nil // no connection information
 	}
 	if rows.mc.netConn == nil {
+		rows.hook.done(rows.mc, ErrInvalidConn)
+		rows.hook = nil
 		return ErrInvalidConn
 	}
 
 	// We may have "query packets" which have not yet been
 	// processed. If so just let them through but ignore them.
+	// An error the caller has not yet seen is returned.
+	var err error
+	suspended := false // the cursor has rows the server has not sent
 	for rows.state != queryStateDone && rows.state != queryStateError {
-		if err := rows.readMsgIfNecessary(); err != nil {
-			debug.Msg("mysqlXRows.Close: got an error trying to read rows: %v", err)
+		if err = rows.readMsgIfNecessary(); err != nil {
+			if rows.log().on(LogDebug) {
+				rows.log().printf(LogDebug, "mysqlXRows.Close: got an error trying to read rows: %v", err)
+			}
 			break
 		}
 
 		// Finish if we get an error or if the mssage type is EXECUTE_OK or ERROR
 		switch Mysqlx.ServerMessages_Type(rows.mc.pb.msgType) {
 		case Mysqlx.ServerMessages_ERROR:
-			rows.mc.processErrorMsg()
+			err = rows.mc.processErrorMsg()
+			rows.err = err
 			rows.state = queryStateError
 		case Mysqlx.ServerMessages_SQL_STMT_EXECUTE_OK:
 			rows.state = queryStateDone
+		case Mysqlx.ServerMessages_RESULTSET_FETCH_SUSPENDED:
+			// the rows not yet sent are dropped when the cursor is
+			// closed, after the StmtExecuteOk which follows
+			suspended = true
 		case Mysqlx.ServerMessages_NOTICE:
-			rows.mc.processNotice("mysqlXRows.Close")
+			if perr := rows.mc.processNotice("mysqlXRows.Close"); perr != nil {
+				err = perr
+				rows.state = queryStateError
+			}
 		default:
 			// do nothing
 		}
 		rows.mc.pb = nil
 	}
+	if rows.cursor != nil && rows.mc.netConn != nil {
+		// the server closes the cursor when it sends the last row
+		// or an error
+		rows.cursor.closed = !suspended
+		if cerr := rows.mc.closeCursor(rows.cursor); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	rows.cursor = nil
+
+	// an error from Next is the outcome of the statement too
+	hookErr := err
+	if hookErr == nil {
+		hookErr = rows.err
+	}
+	rows.hook.done(rows.mc, hookErr)
+	rows.hook = nil
 
 	// clean up
 	rows.columns = nil
+	rows.names = nil
 	rows.mc.pb = nil
+	rows.mc.collectStats()
 	rows.mc = nil
 	rows.state = queryStateStart
 
-	debug.Msg("mysqlXRows.Close: exit")
-	return nil
+	if log.on(LogDebug) {
+		log.printf(LogDebug, "mysqlXRows.Close: exit")
+	}
+	return err
 }
 
 // add the column information to the row
@@ -140,7 +222,9 @@ func (rows *mysqlXRows) addColumnMetaData() error {
 		return fmt.Errorf("error unmarshalling ColumnMetaData: %v", err)
 	}
 
-	debug.Msg("mysqlXRows.addColumnMetaData: %s", printableColumnMetaData(rows.mc.pb))
+	if rows.log().on(LogDebug) {
+		rows.log().printf(LogDebug, "mysqlXRows.addColumnMetaData: %s", printableColumnMetaData(rows.mc.pb))
+	}
 
 	rows.columns = append(rows.columns, column)
 	rows.mc.pb = nil
@@ -158,34 +242,40 @@ func processRow(rows *mysqlXRows, dest []driver.Value) error {
 	}
 	rows.mc.pb = nil // consume the message
 
-	debug.Msg("processRow: row has %d columns", len(myRow.GetField()))
+	if rows.log().on(LogTrace) {
+		rows.log().printf(LogTrace, "processRow: row has %d columns", len(myRow.GetField()))
+	}
+	if len(myRow.GetField()) != len(rows.columns) || len(dest) > len(rows.columns) {
+		return fmt.Errorf("processRow: row has %d columns, expected %d", len(myRow.GetField()), len(rows.columns))
+	}
 	// copy over data converting each type to a dest type
 	for i := range dest {
 		if dest[i], err = convertColumnData(rows.columns[i], myRow.GetField()[i]); err != nil {
 			return fmt.Errorf("processRow: failed to convert data for column %d: %v", i, err)
 		}
+		if rows.mc.cfg.convertCharset {
+			if dest[i], err = decodeText(rows.columns[i], dest[i]); err != nil {
+				return fmt.Errorf("processRow: failed to convert data for column %d: %v", i, err)
+			}
+		}
 	}
+	rows.mc.stats.rows++
 
 	return nil // no error
 }
 
 // Read a row of data from the connection until no more and then return io.EOF to indicate we have finished
 func (rows *mysqlXRows) Next(dest []driver.Value) error {
-	debug.Msg("ENTER mysqlXrows.Next()")
 	// safety checks
 	if rows == nil {
-		log.Fatal("mysqlXRows.Next: rows == nil")
+		return fmt.Errorf("mysqlXRows.Next: rows == nil")
 	}
 	if rows.mc == nil {
-		log.Fatal("mysqlXRows.Next: rows.mc == nil")
+		return fmt.Errorf("mysqlXRows.Next: rows.mc == nil")
 	}
 
-	debug.Msg("mysqlXrows.Next: entry state: %q", rows.state.String())
-
-	// Finished? Don't continue
-	if rows.state.Finished() {
-		debug.Msg("EXIT mysqlXrows.Next(): rows.state.Finished() is true, returning io.EOF")
-		return io.EOF
+	if rows.log().on(LogTrace) {
+		rows.log().printf(LogTrace, "ENTER mysqlXrows.Next() state: %q", rows.state.String())
 	}
 
 	// Have we read the column data yet? If not read it.
@@ -195,27 +285,47 @@ func (rows *mysqlXRows) Next(dest []driver.Value) error {
 		}
 	}
 
-	debug.Msg("PRELOOP mysqlXrows.Next() rows.state: %v, dest has %d elements", rows.state.String(), len(dest))
+	// Finished? Don't continue. The query may have failed, possibly
+	// while Columns() was reading the metadata.
+	if rows.state.Finished() {
+		if rows.state == queryStateError && rows.err != nil {
+			return rows.err
+		}
+		if rows.log().on(LogTrace) {
+			rows.log().printf(LogTrace, "EXIT mysqlXrows.Next(): rows.state.Finished() is true, returning io.EOF")
+		}
+		return io.EOF
+	}
+
+	if rows.log().on(LogTrace) {
+		rows.log().printf(LogTrace, "PRELOOP mysqlXrows.Next() rows.state: %v, dest has %d elements", rows.state.String(), len(dest))
+	}
 
 	// clean this logic up into a smaller more readable loop
 	done := false
 	for !done {
-		debug.Msg("LOOP mysqlXrows.Next() state: %v", rows.state.String())
+		if rows.log().on(LogTrace) {
+			rows.log().printf(LogTrace, "LOOP mysqlXrows.Next() state: %v", rows.state.String())
+		}
 
 		switch rows.state {
 		case queryStateWaitingRow:
 			{
-				debug.Msg("mysqlXrows.Next() START queryStateWaitingRow")
+				if rows.log().on(LogTrace) {
+					rows.log().printf(LogTrace, "mysqlXrows.Next() START queryStateWaitingRow")
+				}
 				// pull in a message if needed
 				if err := rows.readMsgIfNecessary(); err != nil {
-					log.Fatalf("DEBUG: mysqlXRow.Next: failed to read data if necessary")
+					return err
 				}
 
 				// check if it's a Row message!
 				switch Mysqlx.ServerMessages_Type(rows.mc.pb.msgType) {
 				case Mysqlx.ServerMessages_RESULTSET_ROW:
 					{
-						debug.Msg("mysqlXrows.Next() process ROW")
+						if rows.log().on(LogTrace) {
+							rows.log().printf(LogTrace, "mysqlXrows.Next() process ROW")
+						}
 						if err := processRow(rows, dest); err != nil {
 							return err
 						}
@@ -223,40 +333,79 @@ func (rows *mysqlXRows) Next(dest []driver.Value) error {
 					}
 				case Mysqlx.ServerMessages_NOTICE:
 					{
-						debug.Msg("mysqlXrows.Next() process NOTICE")
-						rows.mc.processNotice("mysqlXRows.Next")
+						if rows.log().on(LogTrace) {
+							rows.log().printf(LogTrace, "mysqlXrows.Next() process NOTICE")
+						}
+						if err := rows.mc.processNotice("mysqlXRows.Next"); err != nil {
+							rows.err = err
+							rows.state = queryStateError
+							return err
+						}
 					}
 				case Mysqlx.ServerMessages_RESULTSET_FETCH_DONE:
 					{
-						debug.Msg("mysqlXrows.Next() process FESULTSET_FETCH_DONE")
+						if rows.log().on(LogTrace) {
+							rows.log().printf(LogTrace, "mysqlXrows.Next() process FESULTSET_FETCH_DONE")
+						}
 						rows.state = queryStateWaitingExecuteOk
 						// done = true     SKIP to next message
 						rows.mc.pb = nil
 					}
+				case Mysqlx.ServerMessages_RESULTSET_FETCH_SUSPENDED:
+					{
+						if rows.cursor == nil {
+							rows.err = fmt.Errorf("mysqlXRows.Next received RESULTSET_FETCH_SUSPENDED without a cursor")
+							rows.state = queryStateError
+							rows.mc.pb = nil
+							return rows.err
+						}
+						if rows.log().on(LogTrace) {
+							rows.log().printf(LogTrace, "mysqlXrows.Next() process RESULTSET_FETCH_SUSPENDED: fetch more rows")
+						}
+						rows.mc.pb = nil
+						if err := rows.mc.readReply("mysqlXRows.Next", Mysqlx.ServerMessages_SQL_STMT_EXECUTE_OK); err != nil {
+							rows.err = err
+							rows.state = queryStateError
+							return err
+						}
+						if err := rows.mc.fetch(rows.cursor); err != nil {
+							rows.err = err
+							rows.state = queryStateError
+							return err
+						}
+					}
 				case Mysqlx.ServerMessages_ERROR:
 					{
 						// should treat each message
-						debug.Msg("mysqlXrows.Next() process ERROR")
-						rows.state = queryStateDone
-						done = true
-						rows.mc.pb = nil
+						if rows.log().on(LogTrace) {
+							rows.log().printf(LogTrace, "mysqlXrows.Next() process ERROR")
+						}
+						rows.err = rows.mc.processErrorMsg()
+						rows.state = queryStateError
+						return rows.err
 					}
 				default:
 					{
-						log.Fatalf("mysqlXRowx.Next received unexpected message type: %s", printableMsgTypeIn(Mysqlx.ServerMessages_Type(rows.mc.pb.msgType)))
+						rows.err = fmt.Errorf("mysqlXRows.Next received unexpected message type: %s", printableMsgTypeIn(Mysqlx.ServerMessages_Type(rows.mc.pb.msgType)))
+						rows.state = queryStateError
+						rows.mc.pb = nil
+						return rows.err
 					}
 				}
-				debug.Msg("mysqlXrows.Next() END queryStateWaitingRow")
+				if rows.log().on(LogTrace) {
+					rows.log().printf(LogTrace, "mysqlXrows.Next() END queryStateWaitingRow")
+				}
 			}
 		case queryStateDone, queryStateWaitingExecuteOk:
 			{
-				debug.Msg("mysqlXrows.Next() START queryStateWaitingExecuteOk")
+				if rows.log().on(LogTrace) {
+					rows.log().printf(LogTrace, "mysqlXrows.Next() START queryStateWaitingExecuteOk")
+				}
 				return io.EOF
 			}
 		default:
 			{
-				log.Fatalf("mysqlXRows.Next: called in unexpected state: %v", rows.state.String())
-				// otherwise assume everything is fine
+				return fmt.Errorf("mysqlXRows.Next: called in unexpected state: %v", rows.state.String())
 			}
 		}
 	}
@@ -268,14 +417,17 @@ func (rows *mysqlXRows) Next(dest []driver.Value) error {
 // - RESULTSET_COLUMN_META_DATA (expected)
 // - NOTICE (may happen, not expected)
 // - RESULTSET_ROW (expected, changes state)
+// - RESULTSET_FETCH_SUSPENDED or RESULTSET_FETCH_DONE if there are no rows (yet)
+// - SQL_STMT_EXECUTE_OK if there is no result set
 func (rows *mysqlXRows) collectColumnMetaData() error {
 	if rows == nil {
 		return fmt.Errorf("BUG: mysqlXRows.collectColumnMetaData: rows == nil")
 	}
-	debug.Msg("mysqlXRows.collectColumnMetaData: entry, rows.state: %q", rows.state.String())
+	if rows.log().on(LogDebug) {
+		rows.log().printf(LogDebug, "mysqlXRows.collectColumnMetaData: entry, rows.state: %q", rows.state.String())
+	}
 
-	for !rows.state.Finished() && rows.state != queryStateWaitingRow {
-		// debug.Msg("mysqlXRows.collectColumnMetaData: loop")
+	for rows.state == queryStateWaitingColumnMetaData {
 		if err := rows.readMsgIfNecessary(); err != nil {
 			return fmt.Errorf("DEBUG: mysqlXRows.collectColumnMetaData: failed to read data if necessary")
 		}
@@ -287,21 +439,43 @@ func (rows *mysqlXRows) collectColumnMetaData() error {
 					return fmt.Errorf("DEBUG: mysqlXRows.collectColumnMetaData: failed to addColumnMetaData: %v", err)
 				}
 			}
-		case Mysqlx.ServerMessages_RESULTSET_ROW:
+		case Mysqlx.ServerMessages_RESULTSET_ROW, Mysqlx.ServerMessages_RESULTSET_FETCH_SUSPENDED:
 			{
+				// left for Next to process
 				rows.state = queryStateWaitingRow
-				debug.Msg("mysqlXRows.collectColumnMetaData: got RESULTSET_ROW: change state to %q", rows.state.String())
+				if rows.log().on(LogDebug) {
+					rows.log().printf(LogDebug, "mysqlXRows.collectColumnMetaData: got %s: change state to %q",
+						printableMsgTypeIn(Mysqlx.ServerMessages_Type(rows.mc.pb.msgType)), rows.state.String())
+				}
+			}
+		case Mysqlx.ServerMessages_RESULTSET_FETCH_DONE:
+			{
+				rows.state = queryStateWaitingExecuteOk
+				rows.mc.pb = nil
+			}
+		case Mysqlx.ServerMessages_SQL_STMT_EXECUTE_OK:
+			{
+				rows.state = queryStateDone
+				rows.mc.pb = nil
 			}
 		case Mysqlx.ServerMessages_NOTICE:
 			{
 				// don't really expect a notice but process it
-				debug.Msg("mysqlXRows.collectColumnMetaData: got NOTICE: processing it")
-				rows.mc.processNotice("mysqlxRows.collectColumnMetaData")
+				if rows.log().on(LogDebug) {
+					rows.log().printf(LogDebug, "mysqlXRows.collectColumnMetaData: got NOTICE: processing it")
+				}
+				if err := rows.mc.processNotice("mysqlxRows.collectColumnMetaData"); err != nil {
+					rows.err = err
+					rows.state = queryStateError
+					return err
+				}
 			}
 		case Mysqlx.ServerMessages_ERROR:
 			{
-				debug.Msg("mysqlXRows.collectColumnMetaData: got ERROR: process it and change state to queryStateDone")
-				rows.mc.processErrorMsg()
+				if rows.log().on(LogDebug) {
+					rows.log().printf(LogDebug, "mysqlXRows.collectColumnMetaData: got ERROR: process it and change state to queryStateError")
+				}
+				rows.err = rows.mc.processErrorMsg()
 				rows.state = queryStateError
 			}
 		default:
@@ -314,5 +488,36 @@ func (rows *mysqlXRows) collectColumnMetaData() error {
 			}
 		}
 	}
+	if rows.query != "" && rows.state != queryStateWaitingColumnMetaData {
+		rows.useMetadataCache()
+	}
 	return nil
 }
+
+// useMetadataCache gives compact metadata the column names kept for
+// the statement or keeps the names of full metadata, see metadata.go
+func (rows *mysqlXRows) useMetadataCache() {
+	query := rows.query
+	rows.query = ""
+	if rows.state == queryStateError {
+		return
+	}
+	if !rows.compact {
+		if len(rows.columns) > 0 {
+			rows.mc.cacheMetadata(query, rows.columns)
+		}
+		return
+	}
+
+	full := rows.mc.cachedMetadata(query)
+	if len(full) != len(rows.columns) {
+		if rows.log().on(LogDebug) {
+			rows.log().printf(LogDebug, "mysqlXRows.useMetadataCache: %d columns sent, %d kept: forgetting the column names of %q", len(rows.columns), len(full), query)
+		}
+		rows.mc.forgetMetadata(query)
+		return
+	}
+	for i := range rows.columns {
+		addNames(rows.columns[i], full[i])
+	}
+}
@@ -0,0 +1,81 @@
+// Go driver for MySQL X Protocol
+//
+// Copyright 2016 Simon J Mudd.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+//
+// MySQL X protocol authentication using SHA256_MEMORY method
+
+package mysql
+
+import (
+	"crypto/sha256"
+	"fmt"
+)
+
+// SHA256Memory manages the SHA256_MEMORY authentication protocol which
+// is used with caching_sha2_password accounts once the server has
+// cached the password.
+type SHA256Memory struct {
+	dbname   string
+	name     string
+	username string
+	password string
+}
+
+// NewSHA256Memory returns a pointer to an initialised SHA256Memory struct
+func NewSHA256Memory(dbname, username, password string) *SHA256Memory {
+	if username == "" {
+		return nil
+	}
+
+	m := SHA256Memory{
+		name:     "SHA256_MEMORY",
+		username: username,
+		password: password,
+		dbname:   dbname,
+	}
+
+	return &m
+}
+
+// Name returns the name of the authentication method
+func (p *SHA256Memory) Name() string {
+	return p.name
+}
+
+// GetInitialAuthData returns any initial authentication data
+func (p *SHA256Memory) GetInitialAuthData() []byte {
+	return nil
+}
+
+// XOR(SHA256(password), SHA256(SHA256(SHA256(password)), nonce))
+func (p *SHA256Memory) scramble(nonce []byte) []byte {
+	buf1 := sha256.Sum256([]byte(p.password))
+	buf2 := sha256.Sum256(buf1[:])
+
+	s := sha256.New()
+	s.Write(buf2[:])
+	s.Write(nonce)
+	tmpBuffer := s.Sum(nil)
+
+	return xor(buf1[:], tmpBuffer)
+}
+
+// GetNextAuthData returns data db + name + encrypted hash
+func (p *SHA256Memory) GetNextAuthData(serverData []byte) ([]byte, error) {
+	if len(serverData) != 20 {
+		return nil, fmt.Errorf("Nonce had invalid length - expected 20 bytes, got %d", len(serverData))
+	}
+
+	retval := p.dbname + "\x00" + p.username + "\x00"
+
+	// an empty password is sent as an empty hash
+	if len(p.password) == 0 {
+		return []byte(retval), nil
+	}
+
+	return []byte(retval + fmt.Sprintf("%x", p.scramble(serverData))), nil
+}
@@ -22,7 +22,7 @@ queryStateStart
          V        V                  |
 queryStateWaitingForColumnMetaData ------[E/*]-----+
          |                                         |
-        [C]       +-------[C/N]------+             |
+        [C]       +------[C/N/U]-----+             |
          |        |                  |             |
          V        V                  |             V
 queryStateWaitingRow --------------------[E/*]---->|
@@ -42,6 +42,7 @@ Events:
 [E] Receive ERROR
 [F] Receive RESULTSET_FETCH_DONE
 [N] Receive NOTICE
+[U] Receive RESULTSET_FETCH_SUSPENDED and SQL_STMT_EXECUTE_OK, send CURSOR_FETCH
 
 
 */
@@ -0,0 +1,256 @@
+// Go driver for MySQL X Protocol
+//
+// Copyright 2016 Simon J Mudd.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+import (
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx"
+)
+
+// MessageStats counts the frames of one message type
+type MessageStats struct {
+	Frames uint64
+	Bytes  uint64 // including the 5 byte header, 0 for frames sent inside COMPRESSION frames
+}
+
+// Stats holds the counters of a connection, see XConn.Stats, or the
+// sum of the counters of several connections. Maps are nil if there
+// is nothing in them.
+type Stats struct {
+	Connects    uint64                  // connections made, 1 for a single connection
+	ConnectTime time.Duration           // time taken to connect, dial and handshake included
+	AuthTime    time.Duration           // time taken to authenticate
+	In          map[string]MessageStats // frames received by message type, e.g. RESULTSET_ROW
+	Out         map[string]MessageStats // frames sent by message type, e.g. SQL_STMT_EXECUTE
+	Statements  uint64                  // statements and CRUD operations sent
+	Rows        uint64                  // rows decoded
+	Notices     map[string]uint64       // notices received by type, e.g. Warning
+	Errors      map[uint32]uint64       // errors sent by the server by error code
+}
+
+// BytesIn returns the number of bytes received
+func (s *Stats) BytesIn() uint64 {
+	return sumBytes(s.In)
+}
+
+// BytesOut returns the number of bytes sent
+func (s *Stats) BytesOut() uint64 {
+	return sumBytes(s.Out)
+}
+
+func sumBytes(m map[string]MessageStats) uint64 {
+	var n uint64
+	for _, ms := range m {
+		n += ms.Bytes
+	}
+	return n
+}
+
+// Add adds the counters of other to s
+func (s *Stats) Add(other *Stats) {
+	s.Connects += other.Connects
+	s.ConnectTime += other.ConnectTime
+	s.AuthTime += other.AuthTime
+	s.Statements += other.Statements
+	s.Rows += other.Rows
+	for name, ms := range other.In {
+		s.In = addMessageStats(s.In, name, ms)
+	}
+	for name, ms := range other.Out {
+		s.Out = addMessageStats(s.Out, name, ms)
+	}
+	for name, n := range other.Notices {
+		if s.Notices == nil {
+			s.Notices = make(map[string]uint64)
+		}
+		s.Notices[name] += n
+	}
+	for code, n := range other.Errors {
+		if s.Errors == nil {
+			s.Errors = make(map[uint32]uint64)
+		}
+		s.Errors[code] += n
+	}
+}
+
+func addMessageStats(m map[string]MessageStats, name string, ms MessageStats) map[string]MessageStats {
+	if m == nil {
+		m = make(map[string]MessageStats)
+	}
+	sum := m[name]
+	sum.Frames += ms.Frames
+	sum.Bytes += ms.Bytes
+	m[name] = sum
+	return m
+}
+
+// StatsCollector receives the counters of every connection. Collect
+// is called with what a connection has counted since it last called
+// it, once connected, when a statement's rows are closed and when the
+// connection is closed, so adding up what it is given keeps a running
+// total. It is called from the goroutine using the connection so must
+// be safe for concurrent use and should return quickly. The
+// expvarstats package has one which publishes the totals with expvar.
+type StatsCollector interface {
+	Collect(delta *Stats)
+}
+
+var (
+	statsCollectorMu sync.RWMutex
+	statsCollector   StatsCollector
+)
+
+// SetStatsCollector sets the collector told of the counters of all
+// connections. nil, the default, stops collection.
+func SetStatsCollector(c StatsCollector) {
+	statsCollectorMu.Lock()
+	defer statsCollectorMu.Unlock()
+	statsCollector = c
+}
+
+func getStatsCollector() StatsCollector {
+	statsCollectorMu.RLock()
+	defer statsCollectorMu.RUnlock()
+	return statsCollector
+}
+
+// connStats are the counters kept by a connection. Frames are counted
+// in arrays indexed by message type so that counting never allocates.
+type connStats struct {
+	connects    uint64
+	connectTime time.Duration
+	authTime    time.Duration
+	in, out     [256]MessageStats
+	statements  uint64
+	rows        uint64
+	notices     map[string]uint64
+	errors      map[uint32]uint64
+}
+
+// frameIn counts a frame received, decoding the code of an error
+func (cs *connStats) frameIn(msgType int, payload []byte) {
+	cs.in[msgType].Bytes += uint64(5 + len(payload))
+	cs.messageIn(msgType, payload)
+}
+
+// messageIn counts a frame received inside a COMPRESSION frame, whose
+// bytes are counted instead
+func (cs *connStats) messageIn(msgType int, payload []byte) {
+	cs.in[msgType].Frames++
+	if msgType == int(Mysqlx.ServerMessages_ERROR) {
+		e := new(Mysqlx.Error)
+		if err := proto.Unmarshal(payload, e); err == nil {
+			if cs.errors == nil {
+				cs.errors = make(map[uint32]uint64)
+			}
+			cs.errors[e.GetCode()]++
+		}
+	}
+}
+
+// frameOut counts a frame sent, and the statement it holds
+func (cs *connStats) frameOut(msgType int, payload []byte) {
+	cs.out[msgType].Bytes += uint64(5 + len(payload))
+	cs.messageOut(msgType)
+}
+
+// messageOut counts a frame sent inside a COMPRESSION frame, whose
+// bytes are counted instead
+func (cs *connStats) messageOut(msgType int) {
+	cs.out[msgType].Frames++
+	switch Mysqlx.ClientMessages_Type(msgType) {
+	case Mysqlx.ClientMessages_SQL_STMT_EXECUTE,
+		Mysqlx.ClientMessages_CURSOR_OPEN,
+		Mysqlx.ClientMessages_CRUD_FIND,
+		Mysqlx.ClientMessages_CRUD_INSERT,
+		Mysqlx.ClientMessages_CRUD_UPDATE,
+		Mysqlx.ClientMessages_CRUD_DELETE:
+		cs.statements++
+	}
+}
+
+func (cs *connStats) notice(noticeType uint32) {
+	if cs.notices == nil {
+		cs.notices = make(map[string]uint64)
+	}
+	cs.notices[noticeTypeToName(noticeType)]++
+}
+
+// since returns the counters of cs less those of before as Stats
+func (cs *connStats) since(before *connStats) *Stats {
+	s := &Stats{
+		Connects:    cs.connects - before.connects,
+		ConnectTime: cs.connectTime - before.connectTime,
+		AuthTime:    cs.authTime - before.authTime,
+		Statements:  cs.statements - before.statements,
+		Rows:        cs.rows - before.rows,
+	}
+	for t := range cs.in {
+		if ms := cs.in[t]; ms.Frames > before.in[t].Frames {
+			ms.Frames -= before.in[t].Frames
+			ms.Bytes -= before.in[t].Bytes
+			s.In = addMessageStats(s.In, Mysqlx.ServerMessages_Type(t).String(), ms)
+		}
+		if ms := cs.out[t]; ms.Frames > before.out[t].Frames {
+			ms.Frames -= before.out[t].Frames
+			ms.Bytes -= before.out[t].Bytes
+			s.Out = addMessageStats(s.Out, Mysqlx.ClientMessages_Type(t).String(), ms)
+		}
+	}
+	for name, n := range cs.notices {
+		if n > before.notices[name] {
+			if s.Notices == nil {
+				s.Notices = make(map[string]uint64)
+			}
+			s.Notices[name] = n - before.notices[name]
+		}
+	}
+	for code, n := range cs.errors {
+		if n > before.errors[code] {
+			if s.Errors == nil {
+				s.Errors = make(map[uint32]uint64)
+			}
+			s.Errors[code] = n - before.errors[code]
+		}
+	}
+	return s
+}
+
+// clone returns a copy of cs which does not share its maps
+func (cs *connStats) clone() connStats {
+	cp := *cs
+	if cs.notices != nil {
+		cp.notices = make(map[string]uint64, len(cs.notices))
+		for name, n := range cs.notices {
+			cp.notices[name] = n
+		}
+	}
+	if cs.errors != nil {
+		cp.errors = make(map[uint32]uint64, len(cs.errors))
+		for code, n := range cs.errors {
+			cp.errors[code] = n
+		}
+	}
+	return cp
+}
+
+// collectStats passes what has been counted since the last call to
+// the collector, if there is one
+func (mc *mysqlXConn) collectStats() {
+	c := getStatsCollector()
+	if c == nil {
+		return
+	}
+	c.Collect(mc.stats.since(&mc.collected))
+	mc.collected = mc.stats.clone()
+}
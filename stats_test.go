@@ -0,0 +1,112 @@
+// Go driver for MySQL X Protocol
+//
+// Copyright 2016 Simon J Mudd.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Resultset"
+	"github.com/sjmudd/go-mysqlx-driver/mysqlxtest"
+)
+
+// sumCollector adds up what it is given
+type sumCollector struct {
+	mu    sync.Mutex
+	total Stats
+	calls int
+}
+
+func (c *sumCollector) Collect(delta *Stats) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.total.Add(delta)
+	c.calls++
+}
+
+// the counters of a connection and what the collector is given add up
+func TestStats(t *testing.T) {
+	collector := &sumCollector{}
+	SetStatsCollector(collector)
+	defer SetStatsCollector(nil)
+
+	s := mysqlxtest.NewServer(fakeUser, fakePasswd)
+	defer s.Close()
+	s.Handle("SELECT 1", &mysqlxtest.Result{
+		Columns: []mysqlxtest.Column{{Name: "1", Type: Mysqlx_Resultset.ColumnMetaData_SINT}},
+		Rows:    [][]interface{}{{1}, {2}},
+		Notices: []mysqlxtest.Notice{mysqlxtest.WarningNotice(1265, "Data truncated")},
+	})
+	s.Handle("SELECT * FROM missing", &mysqlxtest.Result{
+		Err: &mysqlxtest.Error{Code: 1146, SQLState: "42S02", Msg: "Table 'test.missing' doesn't exist"},
+	})
+
+	db := fakeDB(t, s, "MYSQL41")
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("TestStats: Conn failed: %v", err)
+	}
+	rows, err := conn.QueryContext(context.Background(), "SELECT 1")
+	if err != nil {
+		t.Fatalf("TestStats: Query failed: %v", err)
+	}
+	for rows.Next() {
+	}
+	rows.Close()
+	if _, err := conn.ExecContext(context.Background(), "SELECT * FROM missing"); err == nil {
+		t.Fatal("TestStats: Exec gives no error")
+	}
+
+	var stats Stats
+	conn.Raw(func(driverConn interface{}) error {
+		stats = driverConn.(XConn).Stats()
+		return nil
+	})
+	conn.Close()
+	db.Close()
+
+	if stats.Connects != 1 || stats.ConnectTime <= 0 || stats.AuthTime <= 0 || stats.AuthTime > stats.ConnectTime {
+		t.Errorf("TestStats: connects: %d, connect time: %v, auth time: %v", stats.Connects, stats.ConnectTime, stats.AuthTime)
+	}
+	if stats.Statements != 2 || stats.Rows != 2 {
+		t.Errorf("TestStats: statements: %d, rows: %d, expected 2 and 2", stats.Statements, stats.Rows)
+	}
+	if in := stats.In["RESULTSET_ROW"]; in.Frames != 2 || in.Bytes <= 10 {
+		t.Errorf("TestStats: RESULTSET_ROW frames: %d, bytes: %d", in.Frames, in.Bytes)
+	}
+	if out := stats.Out["SQL_STMT_EXECUTE"]; out.Frames != 2 || out.Bytes != uint64(2*5+4+len("SELECT 1")+len("SELECT * FROM missing")) {
+		t.Errorf("TestStats: SQL_STMT_EXECUTE frames: %d, bytes: %d", out.Frames, out.Bytes)
+	}
+	if stats.Notices["Warning"] != 1 {
+		t.Errorf("TestStats: notices: %v", stats.Notices)
+	}
+	if !reflect.DeepEqual(stats.Errors, map[uint32]uint64{1146: 1}) {
+		t.Errorf("TestStats: errors: %v", stats.Errors)
+	}
+	if stats.BytesIn() == 0 || stats.BytesOut() == 0 {
+		t.Errorf("TestStats: bytes in: %d, out: %d", stats.BytesIn(), stats.BytesOut())
+	}
+
+	// the collector saw the statements, rows and close at least, and
+	// also what was sent after Stats was called
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	if collector.calls < 4 {
+		t.Errorf("TestStats: collector called %d times", collector.calls)
+	}
+	total := collector.total
+	if total.Connects != 1 || total.Statements != 2 || total.Rows != 2 || !reflect.DeepEqual(total.Errors, stats.Errors) {
+		t.Errorf("TestStats: collector total: %+v", total)
+	}
+	if total.Out["SESS_CLOSE"].Frames != 1 {
+		t.Errorf("TestStats: collector did not see SESS_CLOSE: %v", total.Out)
+	}
+}
@@ -0,0 +1,47 @@
+// Go driver for MySQL X Protocol
+//
+// Copyright 2016 Simon J Mudd.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+// The wire tap records every frame sent and received by the
+// connections of a connector in a transcript file, see the wiretap
+// package for the format. It is enabled with wireTap=/path/to/file
+// in the DSN or by setting Config.WireTap. The transcript can be
+// served back to the driver with mysqlxtest.NewReplayServer.
+
+import (
+	"os"
+	"sync"
+
+	"github.com/sjmudd/go-mysqlx-driver/wiretap"
+)
+
+var (
+	wireTapsMu sync.Mutex
+	wireTaps   = make(map[string]*wiretap.Recorder) // open transcripts by path
+)
+
+// openWireTap returns the recorder writing to the given file, opening
+// the file for appending if this is the first use. Files stay open
+// for the life of the process so every connector using the same path
+// shares one recorder and connection numbers do not repeat.
+func openWireTap(path string) (*wiretap.Recorder, error) {
+	wireTapsMu.Lock()
+	defer wireTapsMu.Unlock()
+
+	if rec, found := wireTaps[path]; found {
+		return rec, nil
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	rec := wiretap.NewRecorder(f)
+	wireTaps[path] = rec
+	return rec, nil
+}
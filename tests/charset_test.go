@@ -11,8 +11,6 @@ package test
 import (
 	"io"
 	"testing"
-
-	"github.com/sjmudd/go-mysqlx-driver/debug"
 )
 
 /*
@@ -57,7 +55,7 @@ func Test04CharsetConnection(t *testing.T) {
 		if err := rows.Scan(&one); err != nil {
 			t.Fatal(err)
 		}
-		debug.Msg("processed row: one: %d", one)
+		t.Logf("processed row: one: %d", one)
 		t.Logf("processed row: one: %d", one)
 	}
 
@@ -4,8 +4,6 @@ import (
 	"database/sql"
 	"io"
 	"testing"
-
-	"github.com/sjmudd/go-mysqlx-driver/debug"
 )
 
 // Test01ConnectToDB ensures that if we give a database name we end up in that db.
@@ -93,7 +91,7 @@ func Test03SimpleQuery(t *testing.T) {
 		if err := rows.Scan(&one, &two, &three); err != nil {
 			t.Fatal(err)
 		}
-		debug.Msg("processed row: one: %d, two: %q, three: %q", one, two, three)
+		t.Logf("processed row: one: %d, two: %q, three: %q", one, two, three)
 		t.Logf("processed row: one: %d, two: %q, three: %q", one, two, three)
 	}
 
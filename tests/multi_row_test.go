@@ -3,8 +3,6 @@ package test
 import (
 	"fmt"
 	"testing"
-
-	"github.com/sjmudd/go-mysqlx-driver/debug"
 )
 
 // genericRowsTest runs the query and checks we get back the expected number of rows
@@ -16,7 +14,6 @@ func genericRowsTest(name, query string, expectedRows int) error {
 		return fmt.Errorf("%s: failed: %+v", myName, err)
 	}
 
-	debug.Msg("%s: %s: %q", myName, name, query)
 	var (
 		collectedRows int
 		i             int    // integer response from the query
@@ -33,7 +30,6 @@ func genericRowsTest(name, query string, expectedRows int) error {
 			return fmt.Errorf("%s: scan failed: %+v", myName, err)
 		}
 		collectedRows++
-		debug.Msg("genericRowsTest: %s: collectedRows: %d, values: %d, %q", name, collectedRows, i, s)
 	}
 	if err := rows.Err(); err != nil {
 		return fmt.Errorf("%s rows.Err() != nil: %+v", myName, err)
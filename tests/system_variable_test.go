@@ -3,8 +3,6 @@ package test
 import (
 	"database/sql"
 	"testing"
-
-	"github.com/sjmudd/go-mysqlx-driver/debug"
 )
 
 // test getting back different types
@@ -36,7 +34,7 @@ func Test04SystemVariables(t *testing.T) {
 			t.Fatal(err)
 		}
 		t.Logf("processed row[%d]: max_allowed_packet: %d, version: %q, hostname: %q", counter, maxAllowedPacket, version, hostname)
-		debug.Msg("processed row[%d]: max_allowed_packet: %d, version: %q, hostname: %q", counter, maxAllowedPacket, version, hostname)
+		t.Logf("processed row[%d]: max_allowed_packet: %d, version: %q, hostname: %q", counter, maxAllowedPacket, version, hostname)
 	}
 	if err := rows.Err(); err != nil {
 		t.Fatal(err)
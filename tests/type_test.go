@@ -3,8 +3,6 @@ package test
 import (
 	"fmt"
 	"testing"
-
-	"github.com/sjmudd/go-mysqlx-driver/debug"
 )
 
 // test doing a query and returning the result
@@ -16,7 +14,6 @@ func testAnyNonNullType(caller string, column string, table string, myVariable i
 
 	// test all types by collecting data
 	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s IS NOT NULL", column, table, column)
-	debug.Msg("%s: query: %q", caller, query)
 	rows, err := db.Query(query)
 	if err != nil {
 		return err
@@ -25,7 +22,6 @@ func testAnyNonNullType(caller string, column string, table string, myVariable i
 		if err := rows.Scan(myVariable); err != nil {
 			return err
 		}
-		debug.Msg("processed row: %s: %v", column, myVariable)
 	}
 	if err := rows.Err(); err != nil {
 		return err
@@ -0,0 +1,67 @@
+// Go driver for MySQL X Protocol
+// Based heavily on Go MySQL Driver - A MySQL-Driver for Go's database/sql package
+//
+// Copyright 2012 The Go-MySQL-Driver Authors. All rights reserved.
+// Copyright 2016 Simon J Mudd.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx"
+)
+
+type mysqlXTx struct {
+	mc  *mysqlXConn
+	ctx context.Context // given to BeginTx, passed on to the hooks
+}
+
+// Commit the transaction
+func (tx *mysqlXTx) Commit() (err error) {
+	if tx.mc == nil || tx.mc.netConn == nil {
+		return ErrInvalidConn
+	}
+	err = tx.end("Commit", "COMMIT")
+	tx.mc = nil
+	return
+}
+
+// Rollback the transaction
+func (tx *mysqlXTx) Rollback() (err error) {
+	if tx.mc == nil || tx.mc.netConn == nil {
+		return ErrInvalidConn
+	}
+	err = tx.end("Rollback", "ROLLBACK")
+	tx.mc = nil
+	return
+}
+
+// end runs the statement ending the transaction, telling the hooks
+func (tx *mysqlXTx) end(op, query string) error {
+	qh := tx.mc.beforeQuery(tx.ctx, op, Mysqlx.ClientMessages_SQL_STMT_EXECUTE, query, nil)
+	err := tx.mc.exec(query)
+	qh.done(tx.mc, err)
+	return err
+}
+
+// isolationLevel returns the SQL name of the isolation level
+func isolationLevel(level sql.IsolationLevel) (string, error) {
+	switch level {
+	case sql.LevelReadUncommitted:
+		return "READ UNCOMMITTED", nil
+	case sql.LevelReadCommitted:
+		return "READ COMMITTED", nil
+	case sql.LevelRepeatableRead:
+		return "REPEATABLE READ", nil
+	case sql.LevelSerializable:
+		return "SERIALIZABLE", nil
+	}
+	return "", fmt.Errorf("unsupported transaction isolation level: %v", level)
+}
@@ -0,0 +1,376 @@
+// Go driver for MySQL X Protocol
+//
+// Copyright 2016 Simon J Mudd.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+// This file holds scan destinations for the column types which have
+// no exact Go equivalent. The driver decodes DECIMAL, SET and TIME from
+// the X protocol encoding to text, as the classic protocol sends them,
+// so they also scan into strings, and BIT to a uint64. The types here
+// read those values exactly: a DECIMAL never passes through a float64.
+//
+// Each type has a nullable variant, like NullTime, for columns which
+// may be NULL.
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Decimal is an exact decimal number, Unscaled × 10^-Scale, as held
+// in a DECIMAL column. A nil Unscaled is 0.
+type Decimal struct {
+	Unscaled *big.Int
+	Scale    int
+}
+
+// ParseDecimal reads a decimal number such as -12.3401
+func ParseDecimal(s string) (Decimal, error) {
+	text := s
+	negative := strings.HasPrefix(text, "-")
+	if negative || strings.HasPrefix(text, "+") {
+		text = text[1:]
+	}
+	whole, fraction := text, ""
+	if i := strings.IndexByte(text, '.'); i >= 0 {
+		whole, fraction = text[:i], text[i+1:]
+	}
+	digits := whole + fraction
+	if digits == "" || strings.TrimLeft(digits, "0123456789") != "" {
+		return Decimal{}, fmt.Errorf("ParseDecimal: invalid decimal %q", s)
+	}
+	unscaled, _ := new(big.Int).SetString(digits, 10)
+	if negative {
+		unscaled.Neg(unscaled)
+	}
+	return Decimal{Unscaled: unscaled, Scale: len(fraction)}, nil
+}
+
+// String returns the number with Scale digits after the point
+func (d Decimal) String() string {
+	unscaled := d.Unscaled
+	if unscaled == nil {
+		unscaled = new(big.Int)
+	}
+	digits := new(big.Int).Abs(unscaled).String()
+	if d.Scale <= 0 {
+		digits += strings.Repeat("0", -d.Scale)
+	} else {
+		if len(digits) <= d.Scale {
+			digits = strings.Repeat("0", d.Scale-len(digits)+1) + digits
+		}
+		digits = digits[:len(digits)-d.Scale] + "." + digits[len(digits)-d.Scale:]
+	}
+	if unscaled.Sign() < 0 {
+		return "-" + digits
+	}
+	return digits
+}
+
+// Rat returns the number as a big.Rat
+func (d Decimal) Rat() *big.Rat {
+	r := new(big.Rat)
+	if d.Unscaled != nil {
+		r.SetInt(d.Unscaled)
+	}
+	if d.Scale < 0 {
+		scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(-d.Scale)), nil)
+		return r.Mul(r, new(big.Rat).SetInt(scale))
+	}
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(d.Scale)), nil)
+	return r.Quo(r, new(big.Rat).SetInt(scale))
+}
+
+// Scan implements the Scanner interface. The value must be the text
+// of a number, int64, uint64 or float64.
+func (d *Decimal) Scan(value interface{}) (err error) {
+	switch v := value.(type) {
+	case []byte:
+		*d, err = ParseDecimal(string(v))
+		return err
+	case string:
+		*d, err = ParseDecimal(v)
+		return err
+	case int64:
+		*d = Decimal{Unscaled: big.NewInt(v)}
+		return nil
+	case uint64:
+		*d = Decimal{Unscaled: new(big.Int).SetUint64(v)}
+		return nil
+	case float64:
+		*d, err = ParseDecimal(strconv.FormatFloat(v, 'f', -1, 64))
+		return err
+	}
+	return fmt.Errorf("Can't convert %T to Decimal", value)
+}
+
+// Value implements the driver Valuer interface, sending the number as
+// text which the server converts exactly
+func (d Decimal) Value() (driver.Value, error) {
+	return d.String(), nil
+}
+
+// NullDecimal represents a Decimal that may be NULL
+type NullDecimal struct {
+	Decimal Decimal
+	Valid   bool // Valid is true if Decimal is not NULL
+}
+
+// Scan implements the Scanner interface
+func (nd *NullDecimal) Scan(value interface{}) error {
+	if value == nil {
+		nd.Decimal, nd.Valid = Decimal{}, false
+		return nil
+	}
+	err := nd.Decimal.Scan(value)
+	nd.Valid = err == nil
+	return err
+}
+
+// Value implements the driver Valuer interface
+func (nd NullDecimal) Value() (driver.Value, error) {
+	if !nd.Valid {
+		return nil, nil
+	}
+	return nd.Decimal.Value()
+}
+
+// Set holds the members of a SET column. The empty set and a set
+// holding only an empty string can not be told apart and both scan
+// as an empty Set.
+type Set []string
+
+// Scan implements the Scanner interface. The value must be the members
+// separated by commas.
+func (s *Set) Scan(value interface{}) error {
+	var text string
+	switch v := value.(type) {
+	case []byte:
+		text = string(v)
+	case string:
+		text = v
+	default:
+		return fmt.Errorf("Can't convert %T to Set", value)
+	}
+	if text == "" {
+		*s = Set{}
+		return nil
+	}
+	*s = strings.Split(text, ",")
+	return nil
+}
+
+// Value implements the driver Valuer interface
+func (s Set) Value() (driver.Value, error) {
+	return strings.Join(s, ","), nil
+}
+
+// Contains returns true if member is in the set
+func (s Set) Contains(member string) bool {
+	for _, m := range s {
+		if m == member {
+			return true
+		}
+	}
+	return false
+}
+
+// NullSet represents a Set that may be NULL
+type NullSet struct {
+	Set   Set
+	Valid bool // Valid is true if Set is not NULL
+}
+
+// Scan implements the Scanner interface
+func (ns *NullSet) Scan(value interface{}) error {
+	if value == nil {
+		ns.Set, ns.Valid = nil, false
+		return nil
+	}
+	err := ns.Set.Scan(value)
+	ns.Valid = err == nil
+	return err
+}
+
+// Value implements the driver Valuer interface
+func (ns NullSet) Value() (driver.Value, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	return ns.Set.Value()
+}
+
+// Bit holds the value of a BIT(Width) column. The width is not sent
+// with the value, so Scan leaves it as it was.
+type Bit struct {
+	Bits  uint64
+	Width int // digits shown by String, 0 for as few as needed
+}
+
+// Scan implements the Scanner interface. The value must be an int64,
+// uint64 or up to 8 bytes, most significant first.
+func (b *Bit) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case uint64:
+		b.Bits = v
+		return nil
+	case int64:
+		b.Bits = uint64(v)
+		return nil
+	case []byte:
+		if len(v) > 8 {
+			return fmt.Errorf("Can't convert %d bytes to Bit", len(v))
+		}
+		b.Bits = 0
+		for _, c := range v {
+			b.Bits = b.Bits<<8 | uint64(c)
+		}
+		return nil
+	}
+	return fmt.Errorf("Can't convert %T to Bit", value)
+}
+
+// Value implements the driver Valuer interface
+func (b Bit) Value() (driver.Value, error) {
+	return b.Bits, nil
+}
+
+// String returns the bits as a literal, e.g. b'0101'
+func (b Bit) String() string {
+	digits := strconv.FormatUint(b.Bits, 2)
+	if len(digits) < b.Width {
+		digits = strings.Repeat("0", b.Width-len(digits)) + digits
+	}
+	return "b'" + digits + "'"
+}
+
+// NullBit represents a Bit that may be NULL
+type NullBit struct {
+	Bit   Bit
+	Valid bool // Valid is true if Bit is not NULL
+}
+
+// Scan implements the Scanner interface
+func (nb *NullBit) Scan(value interface{}) error {
+	if value == nil {
+		nb.Bit.Bits, nb.Valid = 0, false
+		return nil
+	}
+	err := nb.Bit.Scan(value)
+	nb.Valid = err == nil
+	return err
+}
+
+// Value implements the driver Valuer interface
+func (nb NullBit) Value() (driver.Value, error) {
+	if !nb.Valid {
+		return nil, nil
+	}
+	return nb.Bit.Value()
+}
+
+// Duration holds the value of a TIME column, which may be negative or
+// more than a day: -838:59:59 to 838:59:59
+type Duration time.Duration
+
+// parseDuration reads a TIME, [-]h:mm:ss[.ffffff]
+func parseDuration(s string) (Duration, error) {
+	text := s
+	negative := strings.HasPrefix(text, "-")
+	if negative {
+		text = text[1:]
+	}
+	parts := strings.Split(text, ":")
+	if len(parts) != 3 || len(parts[1]) != 2 || len(parts[2]) < 2 {
+		return 0, fmt.Errorf("invalid TIME %q", s)
+	}
+	seconds, fraction := parts[2], ""
+	if i := strings.IndexByte(seconds, '.'); i >= 0 {
+		seconds, fraction = seconds[:i], seconds[i+1:]
+	}
+	if len(seconds) != 2 || len(fraction) > 9 {
+		return 0, fmt.Errorf("invalid TIME %q", s)
+	}
+	var d time.Duration
+	for _, part := range []struct {
+		text string
+		unit time.Duration
+		max  uint64
+	}{
+		{parts[0], time.Hour, 838},
+		{parts[1], time.Minute, 59},
+		{seconds, time.Second, 59},
+		{fraction + strings.Repeat("0", 9-len(fraction)), time.Nanosecond, 999999999},
+	} {
+		n, err := strconv.ParseUint(part.text, 10, 64)
+		if err != nil || n > part.max {
+			return 0, fmt.Errorf("invalid TIME %q", s)
+		}
+		d += time.Duration(n) * part.unit
+	}
+	if negative {
+		d = -d
+	}
+	return Duration(d), nil
+}
+
+// String returns the duration as a TIME, [-]hh:mm:ss[.ffffff]
+func (d Duration) String() string {
+	return formatDuration(time.Duration(d))
+}
+
+// Scan implements the Scanner interface. The value must be the text
+// of a TIME or a time.Duration.
+func (d *Duration) Scan(value interface{}) (err error) {
+	switch v := value.(type) {
+	case []byte:
+		*d, err = parseDuration(string(v))
+		return err
+	case string:
+		*d, err = parseDuration(v)
+		return err
+	case time.Duration:
+		*d = Duration(v)
+		return nil
+	}
+	return fmt.Errorf("Can't convert %T to Duration", value)
+}
+
+// Value implements the driver Valuer interface
+func (d Duration) Value() (driver.Value, error) {
+	return d.String(), nil
+}
+
+// NullDuration represents a Duration that may be NULL
+type NullDuration struct {
+	Duration Duration
+	Valid    bool // Valid is true if Duration is not NULL
+}
+
+// Scan implements the Scanner interface
+func (nd *NullDuration) Scan(value interface{}) error {
+	if value == nil {
+		nd.Duration, nd.Valid = 0, false
+		return nil
+	}
+	err := nd.Duration.Scan(value)
+	nd.Valid = err == nil
+	return err
+}
+
+// Value implements the driver Valuer interface
+func (nd NullDuration) Value() (driver.Value, error) {
+	if !nd.Valid {
+		return nil, nil
+	}
+	return nd.Duration.Value()
+}
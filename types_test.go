@@ -0,0 +1,229 @@
+// Go driver for MySQL X Protocol
+//
+// Copyright 2016 Simon J Mudd.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Resultset"
+	"github.com/sjmudd/go-mysqlx-driver/mysqlxtest"
+)
+
+// the X protocol encodings are decoded to the text the classic
+// protocol would send
+func TestDecodeNative(t *testing.T) {
+	tests := []struct {
+		fieldType Mysqlx_Resultset.ColumnMetaData_FieldType
+		digits    uint32
+		data      []byte
+		expected  string
+	}{
+		{Mysqlx_Resultset.ColumnMetaData_DECIMAL, 0, []byte{0x04, 0x12, 0x34, 0x01, 0xd0}, "-12.3401"},
+		{Mysqlx_Resultset.ColumnMetaData_DECIMAL, 0, []byte{0x02, 0x12, 0x34, 0x5c}, "123.45"},
+		{Mysqlx_Resultset.ColumnMetaData_DECIMAL, 0, []byte{0x03, 0x5c}, "0.005"},
+		{Mysqlx_Resultset.ColumnMetaData_DECIMAL, 0, []byte{0x00, 0x00, 0x7c}, "7"},
+		{Mysqlx_Resultset.ColumnMetaData_SET, 0, []byte{0x03, 'F', 'O', 'O', 0x03, 'B', 'A', 'R'}, "FOO,BAR"},
+		{Mysqlx_Resultset.ColumnMetaData_SET, 0, []byte{0x01}, ""},
+		{Mysqlx_Resultset.ColumnMetaData_SET, 0, []byte{0x00}, ""},
+		{Mysqlx_Resultset.ColumnMetaData_TIME, 0, []byte{0x00}, "00:00:00"},
+		{Mysqlx_Resultset.ColumnMetaData_TIME, 0, []byte{0x01, 0x80, 0x06, 0x3b, 0x3b}, "-768:59:59"},
+		{Mysqlx_Resultset.ColumnMetaData_TIME, 3, []byte{0x00, 0x0c, 0x22, 0x38, 0xc0, 0x9a, 0x0c}, "12:34:56.200"},
+		{Mysqlx_Resultset.ColumnMetaData_TIME, 0, []byte{0x00, 0x00, 0x00, 0x01, 0x01}, "00:00:01.000001"},
+	}
+	for _, test := range tests {
+		column := &Mysqlx_Resultset.ColumnMetaData{Type: test.fieldType.Enum(), FractionalDigits: proto.Uint32(test.digits)}
+		got, err := convertColumnData(column, test.data)
+		if err != nil {
+			t.Errorf("TestDecodeNative: %s % x: error: %v", test.fieldType, test.data, err)
+			continue
+		}
+		if b, ok := got.([]byte); !ok || string(b) != test.expected {
+			t.Errorf("TestDecodeNative: %s % x: got %q, expected %q", test.fieldType, test.data, got, test.expected)
+		}
+	}
+
+	for _, test := range []struct {
+		fieldType Mysqlx_Resultset.ColumnMetaData_FieldType
+		data      []byte
+	}{
+		{Mysqlx_Resultset.ColumnMetaData_DECIMAL, []byte{0x02}},
+		{Mysqlx_Resultset.ColumnMetaData_DECIMAL, []byte{0x02, 0x12}},
+		{Mysqlx_Resultset.ColumnMetaData_DECIMAL, []byte{0x02, 0x1a, 0xc0}},
+		{Mysqlx_Resultset.ColumnMetaData_DECIMAL, []byte{0x02, 0xc0, 0x12}},
+		{Mysqlx_Resultset.ColumnMetaData_SET, []byte{0x05, 'a'}},
+		{Mysqlx_Resultset.ColumnMetaData_TIME, []byte{0x02}},
+		{Mysqlx_Resultset.ColumnMetaData_TIME, []byte{0x00, 0x01, 0x3c}},
+		{Mysqlx_Resultset.ColumnMetaData_TIME, []byte{0x00, 0x01, 0x01, 0x01, 0x01, 0x01}},
+	} {
+		column := &Mysqlx_Resultset.ColumnMetaData{Type: test.fieldType.Enum()}
+		if got, err := convertColumnData(column, test.data); err == nil {
+			t.Errorf("TestDecodeNative: %s % x: expected an error, got %q", test.fieldType, test.data, got)
+		}
+	}
+}
+
+func TestDecimal(t *testing.T) {
+	for _, s := range []string{"0", "-12.3401", "0.005", "123456789012345678901234567890.123456789", "-0.10"} {
+		d, err := ParseDecimal(s)
+		if err != nil {
+			t.Errorf("TestDecimal: ParseDecimal(%q) failed: %v", s, err)
+			continue
+		}
+		if got := d.String(); got != s {
+			t.Errorf("TestDecimal: ParseDecimal(%q).String() = %q", s, got)
+		}
+	}
+	for _, s := range []string{"", "-", "1.2.3", "1e5", "12a"} {
+		if _, err := ParseDecimal(s); err == nil {
+			t.Errorf("TestDecimal: ParseDecimal(%q): expected an error", s)
+		}
+	}
+
+	var d Decimal
+	if err := d.Scan([]byte("19.99")); err != nil {
+		t.Fatalf("TestDecimal: Scan failed: %v", err)
+	}
+	if d.Rat().Cmp(big.NewRat(1999, 100)) != 0 {
+		t.Errorf("TestDecimal: 19.99 is %s as a big.Rat", d.Rat())
+	}
+	if v, _ := d.Value(); v != "19.99" {
+		t.Errorf("TestDecimal: Value() = %v", v)
+	}
+	if d := (Decimal{Unscaled: big.NewInt(-15), Scale: -2}); d.String() != "-1500" || d.Rat().Cmp(big.NewRat(-1500, 1)) != 0 {
+		t.Errorf("TestDecimal: negative scale: %s, %s", d, d.Rat())
+	}
+	if (Decimal{}).String() != "0" {
+		t.Errorf("TestDecimal: zero Decimal is %q", Decimal{}.String())
+	}
+
+	var nd NullDecimal
+	if err := nd.Scan(nil); err != nil || nd.Valid {
+		t.Errorf("TestDecimal: NullDecimal.Scan(nil) = %v, Valid %v", err, nd.Valid)
+	}
+	if err := nd.Scan(int64(-3)); err != nil || !nd.Valid || nd.Decimal.String() != "-3" {
+		t.Errorf("TestDecimal: NullDecimal.Scan(-3) = %v, %v", err, nd)
+	}
+}
+
+func TestSetBitDuration(t *testing.T) {
+	var s Set
+	if err := s.Scan([]byte("a,b")); err != nil || !reflect.DeepEqual(s, Set{"a", "b"}) || !s.Contains("b") || s.Contains("c") {
+		t.Errorf("TestSetBitDuration: Set.Scan(a,b) = %v, %q", err, s)
+	}
+	if err := s.Scan([]byte("")); err != nil || len(s) != 0 {
+		t.Errorf("TestSetBitDuration: Set.Scan('') = %v, %q", err, s)
+	}
+	if v, _ := (Set{"x", "y"}).Value(); v != "x,y" {
+		t.Errorf("TestSetBitDuration: Set.Value() = %v", v)
+	}
+	var ns NullSet
+	if err := ns.Scan(nil); err != nil || ns.Valid || ns.Set != nil {
+		t.Errorf("TestSetBitDuration: NullSet.Scan(nil) = %v, %v", err, ns)
+	}
+
+	b := Bit{Width: 6}
+	if err := b.Scan(uint64(5)); err != nil || b.Bits != 5 || b.String() != "b'000101'" {
+		t.Errorf("TestSetBitDuration: Bit.Scan(5) = %v, %v", err, b)
+	}
+	if err := b.Scan([]byte{0x01, 0x02}); err != nil || b.Bits != 0x0102 {
+		t.Errorf("TestSetBitDuration: Bit.Scan(0x0102) = %v, %v", err, b)
+	}
+	var nb NullBit
+	if err := nb.Scan(nil); err != nil || nb.Valid {
+		t.Errorf("TestSetBitDuration: NullBit.Scan(nil) = %v, %v", err, nb)
+	}
+
+	for text, expected := range map[string]time.Duration{
+		"00:00:00":        0,
+		"-838:59:59":      -(838*time.Hour + 59*time.Minute + 59*time.Second),
+		"12:34:56.200":    12*time.Hour + 34*time.Minute + 56*time.Second + 200*time.Millisecond,
+		"00:00:01.000001": time.Second + time.Microsecond,
+	} {
+		var d Duration
+		if err := d.Scan([]byte(text)); err != nil || time.Duration(d) != expected {
+			t.Errorf("TestSetBitDuration: Duration.Scan(%q) = %v, %v", text, err, time.Duration(d))
+		}
+	}
+	for _, text := range []string{"", "12:34", "1:2:3", "839:00:00", "00:60:00", "00:00:00.1234567890"} {
+		var d Duration
+		if err := d.Scan(text); err == nil {
+			t.Errorf("TestSetBitDuration: Duration.Scan(%q): expected an error", text)
+		}
+	}
+	if v, _ := Duration(-90 * time.Minute).Value(); v != "-01:30:00" {
+		t.Errorf("TestSetBitDuration: Duration.Value() = %v", v)
+	}
+	var nd NullDuration
+	if err := nd.Scan(nil); err != nil || nd.Valid {
+		t.Errorf("TestSetBitDuration: NullDuration.Scan(nil) = %v, %v", err, nd)
+	}
+}
+
+// columns of each type scan into the types and into strings
+func TestFakeServerScanTypes(t *testing.T) {
+	const query = "SELECT price, tags, flags, duration FROM items"
+	s := mysqlxtest.NewServer(fakeUser, fakePasswd)
+	defer s.Close()
+	s.Handle(query, &mysqlxtest.Result{
+		Columns: []mysqlxtest.Column{
+			{Name: "price", Type: Mysqlx_Resultset.ColumnMetaData_DECIMAL, Length: 10, FractionalDigits: 2},
+			{Name: "tags", Type: Mysqlx_Resultset.ColumnMetaData_SET},
+			{Name: "flags", Type: Mysqlx_Resultset.ColumnMetaData_BIT, Length: 4},
+			{Name: "duration", Type: Mysqlx_Resultset.ColumnMetaData_TIME},
+		},
+		Rows: [][]interface{}{
+			{mysqlxtest.Raw{0x02, 0x19, 0x99, 0xc0}, mysqlxtest.Raw{0x01, 'a', 0x01, 'c'}, uint64(9), mysqlxtest.Raw{0x00, 0x01, 0x1e}},
+			{nil, nil, nil, nil},
+		},
+	})
+
+	db := fakeDB(t, s, "MYSQL41")
+	defer db.Close()
+
+	var (
+		price    NullDecimal
+		tags     NullSet
+		flags    NullBit
+		duration NullDuration
+		text     string
+	)
+	rows, err := db.Query(query)
+	if err != nil {
+		t.Fatalf("TestFakeServerScanTypes: Query failed: %v", err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		t.Fatalf("TestFakeServerScanTypes: no first row: %v", rows.Err())
+	}
+	if err := rows.Scan(&price, &tags, &flags, &duration); err != nil {
+		t.Fatalf("TestFakeServerScanTypes: Scan failed: %v", err)
+	}
+	if price.Decimal.String() != "19.99" || !reflect.DeepEqual(tags.Set, Set{"a", "c"}) || flags.Bit.Bits != 9 || time.Duration(duration.Duration) != 90*time.Minute {
+		t.Errorf("TestFakeServerScanTypes: got %v %v %v %v", price, tags, flags, duration)
+	}
+	if err := rows.Scan(&text, &text, &text, &text); err != nil || text != "01:30:00" {
+		t.Errorf("TestFakeServerScanTypes: Scan into strings: %v, last %q", err, text)
+	}
+
+	if !rows.Next() {
+		t.Fatalf("TestFakeServerScanTypes: no second row: %v", rows.Err())
+	}
+	if err := rows.Scan(&price, &tags, &flags, &duration); err != nil {
+		t.Fatalf("TestFakeServerScanTypes: Scan of NULL failed: %v", err)
+	}
+	if price.Valid || tags.Valid || flags.Valid || duration.Valid {
+		t.Errorf("TestFakeServerScanTypes: NULL scanned as %v %v %v %v", price, tags, flags, duration)
+	}
+}
@@ -0,0 +1,529 @@
+// Go driver for MySQL X Protocol
+//
+// Copyright 2016 Simon J Mudd.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+// This file handles the X DevAPI URI connection string format:
+//
+//	mysqlx://[user[:password]@]host[:port][/schema][?option=value&...]
+//	mysqlx://[user[:password]@](/path/to/socket)[/schema][?option=value&...]
+//	mysqlx://[user[:password]@]%2Fpath%2Fto%2Fsocket[/schema][?option=value&...]
+//	mysqlx://[user[:password]@][host1,host2,...][/schema][?option=value&...]
+//
+// See hosts.go for the format of a list of hosts.
+//
+// User, password, host and schema may be percent-encoded. The options
+// understood are:
+//
+//	ssl-mode          DISABLED, REQUIRED, VERIFY_CA or VERIFY_IDENTITY (default: REQUIRED,
+//	                  or DISABLED for a socket or a list of hosts with one unless another
+//	                  ssl-* or tls-* option is given)
+//	ssl-ca            file with the PEM encoded CA certificate(s), implies VERIFY_CA
+//	ssl-capath        directory of files with PEM encoded CA certificates, implies VERIFY_CA
+//	ssl-cert          file with the PEM encoded client certificate
+//	ssl-key           file with the PEM encoded client key
+//	tls-versions      TLS versions allowed, TLSv1.2 and/or TLSv1.3, e.g. [TLSv1.2,TLSv1.3]
+//	tls-ciphersuites  TLSv1.2 cipher suites allowed by their IANA names
+//	auth              authentication mechanism: MYSQL41, PLAIN or SHA256_MEMORY
+//	connect-timeout   connect timeout in milliseconds for each host
+//	compression       REQUIRED or DISABLED (default), the connection failing if the server
+//	                  does not offer the algorithm so PREFERRED is not supported
+//	compression-algorithms
+//	                  algorithms in order of preference, e.g. [zstd_stream,deflate_stream],
+//	                  the first registered with RegisterCompression being asked for
+//
+// ssl-crl, ssl-crlpath and connection-attributes are not supported.
+// The driver's own DSN parameters such as getCapabilities=0 may also
+// be given, and of those run when connecting only charset, parseTime
+// and strict. Any other option is an error rather than being run as
+// SET name=value.
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	uriScheme          = "mysqlx://"
+	defaultXPort       = "33060"
+	sslModeDisabled    = "DISABLED"
+	sslModeRequired    = "REQUIRED"
+	sslModeVerifyCA    = "VERIFY_CA"
+	sslModeVerifyIdent = "VERIFY_IDENTITY"
+)
+
+var (
+	errInvalidURIAddr   = errors.New("Invalid URI: Socket path not terminated (missing closing parenthesis)")
+	errInvalidURIOption = errors.New("Invalid URI: option without a value")
+)
+
+// isURI returns true if the connection string uses the mysqlx:// URI format
+func isURI(dsn string) bool {
+	return len(dsn) >= len(uriScheme) && strings.EqualFold(dsn[:len(uriScheme)], uriScheme)
+}
+
+// uriParams are the DSN parameters a URI may give which are not mapped
+// onto the Config but run when connecting
+var uriParams = map[string]bool{
+	"charset":   true,
+	"parseTime": true,
+	"strict":    true,
+}
+
+// compressionAliases are the short names compression-algorithms may use
+var compressionAliases = map[string]string{
+	"deflate": "deflate_stream",
+	"lz4":     "lz4_message",
+	"zstd":    "zstd_stream",
+}
+
+// tlsVersions are the values of tls-versions
+var tlsVersions = map[string]uint16{
+	"TLSv1.2": tls.VersionTLS12,
+	"TLSv1.3": tls.VersionTLS13,
+}
+
+// uriTLSOptions collects the ssl-* and tls-* options as they may come
+// in any order
+type uriTLSOptions struct {
+	mode     string
+	ca       string
+	capath   string
+	cert     string
+	key      string
+	versions string
+	ciphers  string
+}
+
+// parseURI parses a mysqlx:// URI into a Config
+func parseURI(uri string) (cfg *Config, err error) {
+	cfg = &Config{
+		Loc:                time.UTC,
+		Collation:          defaultCollation,
+		UseXProtocol:       true,
+		UseGetCapabilities: true,
+		AuthMechanism:      defaultAuthMechanism,
+	}
+	rest := uri[len(uriScheme):]
+
+	// The authority ends at the first '/' or '?' which is not
+	// part of a socket path in parentheses.
+	end := len(rest)
+	depth := 0
+	for i := 0; i < len(rest) && end == len(rest); i++ {
+		switch rest[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case '/', '?':
+			if depth == 0 {
+				end = i
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, errInvalidURIAddr
+	}
+	authority := rest[:end]
+	rest = rest[end:]
+
+	// [user[:password]@]
+	if i := strings.LastIndex(authority, "@"); i >= 0 {
+		userInfo := authority[:i]
+		authority = authority[i+1:]
+
+		user, passwd := userInfo, ""
+		if j := strings.IndexByte(userInfo, ':'); j >= 0 {
+			user, passwd = userInfo[:j], userInfo[j+1:]
+		}
+		if cfg.User, err = url.PathUnescape(user); err != nil {
+			return nil, fmt.Errorf("Invalid URI: user: %v", err)
+		}
+		if cfg.Passwd, err = url.PathUnescape(passwd); err != nil {
+			return nil, fmt.Errorf("Invalid URI: password: %v", err)
+		}
+	}
+
+	if err = parseURIHost(cfg, authority); err != nil {
+		return nil, err
+	}
+
+	// [/schema]
+	if strings.HasPrefix(rest, "/") {
+		schema := rest[1:]
+		if i := strings.IndexByte(schema, '?'); i >= 0 {
+			schema = schema[:i]
+		}
+		rest = rest[1+len(schema):]
+		if cfg.DBName, err = url.PathUnescape(schema); err != nil {
+			return nil, fmt.Errorf("Invalid URI: schema: %v", err)
+		}
+	}
+
+	// [?option=value&...]
+	if err = parseURIOptions(cfg, strings.TrimPrefix(rest, "?")); err != nil {
+		return nil, err
+	}
+
+	if err = cfg.normalize(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// parseURIHost fills in the network and address from the host part of the URI
+func parseURIHost(cfg *Config, host string) error {
+	// (/path/to/socket)
+	if strings.HasPrefix(host, "(") {
+		if !strings.HasSuffix(host, ")") {
+			return errInvalidURIAddr
+		}
+		path, err := url.PathUnescape(host[1 : len(host)-1])
+		if err != nil {
+			return fmt.Errorf("Invalid URI: socket: %v", err)
+		}
+		cfg.Net, cfg.Addr = "unix", path
+		return nil
+	}
+
+	unescaped, err := url.PathUnescape(host)
+	if err != nil {
+		return fmt.Errorf("Invalid URI: host: %v", err)
+	}
+
+	// %2Fpath%2Fto%2Fsocket or ./relative/socket
+	if strings.HasPrefix(unescaped, "/") || strings.HasPrefix(unescaped, ".") {
+		cfg.Net, cfg.Addr = "unix", unescaped
+		return nil
+	}
+
+	cfg.Net = "tcp"
+	if unescaped == "" {
+		return nil // normalize provides the default address
+	}
+
+	// [host1,host2] or [(address=host1,priority=100),...]
+	if strings.HasPrefix(unescaped, "[") && strings.HasSuffix(unescaped, "]") && isHostList(unescaped) {
+		hosts, err := parseHostList(unescaped[1:len(unescaped)-1], defaultXPort)
+		if err != nil {
+			return err
+		}
+		cfg.Hosts = hosts
+		return nil
+	}
+
+	// host[:port], [ipv6][:port]
+	if h, port, err := net.SplitHostPort(unescaped); err == nil {
+		if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+			return fmt.Errorf("Invalid URI: port: %q", port)
+		}
+		cfg.Addr = net.JoinHostPort(h, port)
+		return nil
+	}
+	cfg.Addr = net.JoinHostPort(strings.Trim(unescaped, "[]"), defaultXPort)
+
+	return nil
+}
+
+// parseURIOptions handles the URI query string. Known X DevAPI options
+// are mapped onto the Config, the rest are treated as DSN parameters
+// which must be the driver's own or in uriParams.
+func parseURIOptions(cfg *Config, options string) error {
+	var tlsOptions uriTLSOptions
+	var compression, algorithms string
+	var dsnParams []string
+
+	for _, v := range strings.Split(options, "&") {
+		if v == "" {
+			continue
+		}
+		option := strings.SplitN(v, "=", 2)
+		name := strings.ToLower(option[0])
+		if len(option) != 2 {
+			return errInvalidURIOption
+		}
+		value, err := url.QueryUnescape(option[1])
+		if err != nil {
+			return fmt.Errorf("Invalid URI: option %s: %v", name, err)
+		}
+
+		switch name {
+		case "ssl-mode", "sslmode":
+			tlsOptions.mode = strings.ToUpper(value)
+		case "ssl-ca":
+			tlsOptions.ca = value
+		case "ssl-capath":
+			tlsOptions.capath = value
+		case "ssl-cert":
+			tlsOptions.cert = value
+		case "ssl-key":
+			tlsOptions.key = value
+		case "tls-versions", "tls-version":
+			tlsOptions.versions = value
+		case "tls-ciphersuites":
+			tlsOptions.ciphers = value
+		case "ssl-crl", "ssl-crlpath", "connection-attributes":
+			return fmt.Errorf("Invalid URI: option %s is not supported", name)
+		case "auth":
+			mechanism := strings.ToUpper(value)
+			if !isSupportedAuthMechanism(mechanism) {
+				return fmt.Errorf("Invalid or unsupported authentication mechanism: %s", value)
+			}
+			cfg.AuthMechanism = mechanism
+		case "compression":
+			compression = strings.ToUpper(value)
+		case "compression-algorithms":
+			algorithms = value
+		case "connect-timeout":
+			ms, err := strconv.ParseUint(value, 10, 32)
+			if err != nil {
+				return fmt.Errorf("Invalid connect-timeout value: %s", value)
+			}
+			cfg.Timeout = time.Duration(ms) * time.Millisecond
+		default:
+			dsnParams = append(dsnParams, v)
+		}
+	}
+
+	if len(dsnParams) > 0 {
+		if err := parseDSNParams(cfg, strings.Join(dsnParams, "&")); err != nil {
+			return err
+		}
+	}
+	for name := range cfg.Params {
+		if !uriParams[name] {
+			return fmt.Errorf("Invalid URI: unknown option: %s", name)
+		}
+	}
+	if err := uriCompression(cfg, compression, algorithms); err != nil {
+		return err
+	}
+
+	// explicit ssl-* options override any tls= DSN parameter and
+	// without either TLS is required unless a socket is used
+	if tlsOptions != (uriTLSOptions{}) || (cfg.TLSConfig == "" && !usesSocket(cfg)) {
+		tlsConfig, err := tlsOptions.config(cfg.Addr)
+		if err != nil {
+			return err
+		}
+		cfg.TLS, cfg.TLSConfig = tlsConfig, ""
+	}
+
+	return nil
+}
+
+// uriCompression sets the compression algorithm from the compression
+// and compression-algorithms options
+func uriCompression(cfg *Config, compression, algorithms string) error {
+	switch compression {
+	case "DISABLED":
+		cfg.Compression = ""
+		return nil
+	case "":
+		if algorithms == "" {
+			return nil
+		}
+	case "REQUIRED":
+	default:
+		return fmt.Errorf("Invalid URI: compression: %s, only REQUIRED or DISABLED are supported", compression)
+	}
+	if algorithms == "" {
+		cfg.Compression = defaultCompression
+		return nil
+	}
+	for _, algorithm := range splitURIList(algorithms) {
+		algorithm = strings.ToLower(algorithm)
+		if alias, ok := compressionAliases[algorithm]; ok {
+			algorithm = alias
+		}
+		if _, found := compressors[algorithm]; found {
+			cfg.Compression = algorithm
+			return nil
+		}
+	}
+	return fmt.Errorf("Invalid URI: compression-algorithms: none of %s is registered", algorithms)
+}
+
+// usesSocket returns true if the address or any of the hosts is a unix
+// socket, which X DevAPI clients connect to without TLS. The same TLS
+// configuration is used for every host.
+func usesSocket(cfg *Config) bool {
+	if cfg.Net == "unix" {
+		return true
+	}
+	for _, h := range cfg.Hosts {
+		if h.Net == "unix" {
+			return true
+		}
+	}
+	return false
+}
+
+// config returns the *tls.Config for the ssl-* and tls-* options, nil
+// if TLS is disabled
+func (o uriTLSOptions) config(addr string) (*tls.Config, error) {
+	// an explicit CA means the user wants it checked
+	hasCA := o.ca != "" || o.capath != ""
+	mode := o.mode
+	if mode == "" || mode == sslModeRequired && hasCA {
+		if hasCA {
+			mode = sslModeVerifyCA
+		} else {
+			mode = sslModeRequired
+		}
+	}
+
+	tlsConfig := &tls.Config{}
+	switch mode {
+	case sslModeDisabled:
+		return nil, nil
+	case sslModeRequired:
+		tlsConfig.InsecureSkipVerify = true
+	case sslModeVerifyCA, sslModeVerifyIdent:
+		if hasCA {
+			roots, err := o.roots()
+			if err != nil {
+				return nil, err
+			}
+			tlsConfig.RootCAs = roots
+		}
+		if mode == sslModeVerifyCA {
+			// check the chain but not the host name
+			tlsConfig.InsecureSkipVerify = true
+			tlsConfig.VerifyPeerCertificate = verifyCertificateChain(tlsConfig.RootCAs)
+		} else if host, _, err := net.SplitHostPort(addr); err == nil {
+			tlsConfig.ServerName = host
+		}
+	default:
+		return nil, fmt.Errorf("Invalid URI: unknown ssl-mode: %s", mode)
+	}
+
+	if o.cert != "" || o.key != "" {
+		cert, err := tls.LoadX509KeyPair(o.cert, o.key)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid URI: ssl-cert/ssl-key: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if o.versions != "" {
+		for _, name := range splitURIList(o.versions) {
+			version, ok := tlsVersions[name]
+			if !ok {
+				return nil, fmt.Errorf("Invalid URI: tls-versions: unsupported version %s", name)
+			}
+			if tlsConfig.MinVersion == 0 || version < tlsConfig.MinVersion {
+				tlsConfig.MinVersion = version
+			}
+			if version > tlsConfig.MaxVersion {
+				tlsConfig.MaxVersion = version
+			}
+		}
+	}
+	if o.ciphers != "" {
+		ids := make(map[string]uint16)
+		for _, suite := range tls.CipherSuites() {
+			ids[suite.Name] = suite.ID
+		}
+		for _, name := range splitURIList(o.ciphers) {
+			id, ok := ids[name]
+			if !ok {
+				return nil, fmt.Errorf("Invalid URI: tls-ciphersuites: unsupported cipher suite %s", name)
+			}
+			tlsConfig.CipherSuites = append(tlsConfig.CipherSuites, id)
+		}
+	}
+
+	return tlsConfig, nil
+}
+
+// roots returns the CA certificates of ssl-ca and those of the files
+// in ssl-capath
+func (o uriTLSOptions) roots() (*x509.CertPool, error) {
+	roots := x509.NewCertPool()
+	if o.ca != "" {
+		pem, err := ioutil.ReadFile(o.ca)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid URI: ssl-ca: %v", err)
+		}
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("Invalid URI: ssl-ca: no certificates found in %s", o.ca)
+		}
+	}
+	if o.capath != "" {
+		files, err := ioutil.ReadDir(o.capath)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid URI: ssl-capath: %v", err)
+		}
+		found := false
+		for _, f := range files {
+			if f.IsDir() {
+				continue
+			}
+			pem, err := ioutil.ReadFile(filepath.Join(o.capath, f.Name()))
+			if err != nil {
+				return nil, fmt.Errorf("Invalid URI: ssl-capath: %v", err)
+			}
+			// files other than certificates, such as CRLs, are skipped
+			found = roots.AppendCertsFromPEM(pem) || found
+		}
+		if !found {
+			return nil, fmt.Errorf("Invalid URI: ssl-capath: no certificates found in %s", o.capath)
+		}
+	}
+	return roots, nil
+}
+
+// splitURIList splits an option value such as [TLSv1.2,TLSv1.3], the
+// brackets being optional
+func splitURIList(value string) []string {
+	var list []string
+	for _, v := range strings.Split(strings.Trim(value, "[]"), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// verifyCertificateChain returns a function which checks the server
+// certificate is signed by one of the given roots, ignoring the host name.
+func verifyCertificateChain(roots *x509.CertPool) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return errors.New("server sent no certificates")
+		}
+		certs := make([]*x509.Certificate, len(rawCerts))
+		for i := range rawCerts {
+			cert, err := x509.ParseCertificate(rawCerts[i])
+			if err != nil {
+				return err
+			}
+			certs[i] = cert
+		}
+		opts := x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: x509.NewCertPool(),
+		}
+		for _, cert := range certs[1:] {
+			opts.Intermediates.AddCert(cert)
+		}
+		_, err := certs[0].Verify(opts)
+		return err
+	}
+}
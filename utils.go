@@ -18,6 +18,7 @@ import (
 	"io"
 	"net"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -72,12 +73,20 @@ func DeregisterTLSConfig(key string) {
 	delete(tlsConfigRegister, key)
 }
 
-// parseDSN parses the DSN string to a config
-func parseDSN(dsn string) (cfg *config, err error) {
+// ParseDSN parses the DSN string to a Config. Both the classic
+// user:pass@tcp(host:port)/db?params form and X DevAPI mysqlx:// URIs
+// are accepted.
+func ParseDSN(dsn string) (cfg *Config, err error) {
+	if isURI(dsn) {
+		return parseURI(dsn)
+	}
+
 	// New config with some default values
-	cfg = &config{
-		loc:       time.UTC,
-		collation: defaultCollation,
+	cfg = &Config{
+		Loc:                time.UTC,
+		Collation:          defaultCollation,
+		UseGetCapabilities: true,
+		AuthMechanism:      defaultAuthMechanism,
 	}
 
 	// [user[:password]@][net[(addr)]]/dbname[?param1=value1&paramN=valueN]
@@ -98,11 +107,11 @@ func parseDSN(dsn string) (cfg *config, err error) {
 						// Find the first ':' in dsn[:j]
 						for k = 0; k < j; k++ {
 							if dsn[k] == ':' {
-								cfg.passwd = dsn[k+1 : j]
+								cfg.Passwd = dsn[k+1 : j]
 								break
 							}
 						}
-						cfg.user = dsn[:k]
+						cfg.User = dsn[:k]
 
 						break
 					}
@@ -119,11 +128,11 @@ func parseDSN(dsn string) (cfg *config, err error) {
 							}
 							return nil, errInvalidDSNAddr
 						}
-						cfg.addr = dsn[k+1 : i-1]
+						cfg.Addr = dsn[k+1 : i-1]
 						break
 					}
 				}
-				cfg.net = dsn[j+1 : k]
+				cfg.Net = dsn[j+1 : k]
 			}
 
 			// dbname[?param1=value1&...&paramN=valueN]
@@ -136,7 +145,7 @@ func parseDSN(dsn string) (cfg *config, err error) {
 					break
 				}
 			}
-			cfg.dbname = dsn[i+1 : j]
+			cfg.DBName = dsn[i+1 : j]
 
 			break
 		}
@@ -146,30 +155,8 @@ func parseDSN(dsn string) (cfg *config, err error) {
 		return nil, errInvalidDSNNoSlash
 	}
 
-	if cfg.interpolateParams && unsafeCollations[cfg.collation] {
-		return nil, errInvalidDSNUnsafeCollation
-	}
-
-	// Set default network if empty
-	if cfg.net == "" {
-		cfg.net = "tcp"
-	}
-
-	// Set default address if empty
-	if cfg.addr == "" {
-		switch cfg.net {
-		case "tcp":
-			if cfg.useXProtocol {
-				cfg.addr = "127.0.0.1:33060" // Xprotocol default port 33060
-				//				fmt.Printf("DEBUG: cfg.addr=%s\n", cfg.addr)
-			} else {
-				cfg.addr = "127.0.0.1:3306"
-			}
-		case "unix":
-			cfg.addr = "/tmp/mysql.sock"
-		default:
-			return nil, errors.New("Default addr for network '" + cfg.net + "' unknown")
-		}
+	if err = cfg.normalize(); err != nil {
+		return nil, err
 	}
 
 	return
@@ -177,7 +164,7 @@ func parseDSN(dsn string) (cfg *config, err error) {
 
 // parseDSNParams parses the DSN "query string"
 // Values must be url.QueryEscape'ed
-func parseDSNParams(cfg *config, params string) (err error) {
+func parseDSNParams(cfg *Config, params string) (err error) {
 	for _, v := range strings.Split(params, "&") {
 		param := strings.SplitN(v, "=", 2)
 		if len(param) != 2 {
@@ -190,7 +177,7 @@ func parseDSNParams(cfg *config, params string) (err error) {
 		// Enable client side placeholder substitution
 		case "interpolateParams":
 			var isBool bool
-			cfg.interpolateParams, isBool = readBool(value)
+			cfg.InterpolateParams, isBool = readBool(value)
 			if !isBool {
 				return fmt.Errorf("Invalid Bool value: %s", value)
 			}
@@ -198,7 +185,7 @@ func parseDSNParams(cfg *config, params string) (err error) {
 		// Disable INFILE whitelist / enable all files
 		case "allowAllFiles":
 			var isBool bool
-			cfg.allowAllFiles, isBool = readBool(value)
+			cfg.AllowAllFiles, isBool = readBool(value)
 			if !isBool {
 				return fmt.Errorf("Invalid Bool value: %s", value)
 			}
@@ -206,7 +193,7 @@ func parseDSNParams(cfg *config, params string) (err error) {
 		// Use cleartext authentication mode (MySQL 5.5.10+)
 		case "allowCleartextPasswords":
 			var isBool bool
-			cfg.allowCleartextPasswords, isBool = readBool(value)
+			cfg.AllowCleartextPasswords, isBool = readBool(value)
 			if !isBool {
 				return fmt.Errorf("Invalid Bool value: %s", value)
 			}
@@ -221,29 +208,69 @@ func parseDSNParams(cfg *config, params string) (err error) {
 				err = errors.New("unknown collation")
 				return
 			}
-			cfg.collation = collation
+			cfg.Collation = collation
 			break
 
 		case "columnsWithAlias":
 			var isBool bool
-			cfg.columnsWithAlias, isBool = readBool(value)
+			cfg.ColumnsWithAlias, isBool = readBool(value)
 			if !isBool {
 				return fmt.Errorf("Invalid Bool value: %s", value)
 			}
 
+		// Name columns as in their table, not by their alias
+		case "columnsOriginalName":
+			var isBool bool
+			if cfg.ColumnsOriginalName, isBool = readBool(value); !isBool {
+				return fmt.Errorf("Invalid Bool value: %s", value)
+			}
+
+		// Column types only for statements whose column names are known
+		case "compactMetadata":
+			var isBool bool
+			if cfg.CompactMetadata, isBool = readBool(value); !isBool {
+				return fmt.Errorf("Invalid Bool value: %s", value)
+			}
+
+		// Compression: true for deflate_stream or the algorithm to use
+		case "compress":
+			if compress, isBool := readBool(value); isBool {
+				cfg.Compression = ""
+				if compress {
+					cfg.Compression = defaultCompression
+				}
+			} else if _, found := compressors[value]; found {
+				cfg.Compression = value
+			} else {
+				return fmt.Errorf("Unknown compression algorithm: %s", value)
+			}
+
+		// Rows to fetch at a time through a server side cursor
+		case "fetchSize":
+			if cfg.FetchSize, err = strconv.ParseUint(value, 10, 64); err != nil {
+				return fmt.Errorf("Invalid fetchSize value: %s", value)
+			}
+
+		// Convert text to and from the character set of the collation
+		case "convertCharset":
+			var isBool bool
+			if cfg.ConvertCharset, isBool = readBool(value); !isBool {
+				return fmt.Errorf("Invalid Bool value: %s", value)
+			}
+
 		// Time Location
 		case "loc":
 			if value, err = url.QueryUnescape(value); err != nil {
 				return
 			}
-			cfg.loc, err = time.LoadLocation(value)
+			cfg.Loc, err = time.LoadLocation(value)
 			if err != nil {
 				return
 			}
 
 		// Dial Timeout
 		case "timeout":
-			cfg.timeout, err = time.ParseDuration(value)
+			cfg.Timeout, err = time.ParseDuration(value)
 			if err != nil {
 				return
 			}
@@ -253,20 +280,25 @@ func parseDSNParams(cfg *config, params string) (err error) {
 			boolValue, isBool := readBool(value)
 			if isBool {
 				if boolValue {
-					cfg.tls = &tls.Config{}
+					cfg.TLSConfig = "true"
+					cfg.TLS = &tls.Config{}
+				} else {
+					cfg.TLSConfig = "false"
 				}
 			} else {
 				if strings.ToLower(value) == "skip-verify" {
-					cfg.tls = &tls.Config{InsecureSkipVerify: true}
+					cfg.TLSConfig = value
+					cfg.TLS = &tls.Config{InsecureSkipVerify: true}
 				} else if tlsConfig, ok := tlsConfigRegister[value]; ok {
 					if len(tlsConfig.ServerName) == 0 && !tlsConfig.InsecureSkipVerify {
-						host, _, err := net.SplitHostPort(cfg.addr)
+						host, _, err := net.SplitHostPort(cfg.Addr)
 						if err == nil {
 							tlsConfig.ServerName = host
 						}
 					}
 
-					cfg.tls = tlsConfig
+					cfg.TLSConfig = value
+					cfg.TLS = tlsConfig
 				} else {
 					return fmt.Errorf("Invalid value / unknown config name: %s", value)
 				}
@@ -275,18 +307,67 @@ func parseDSNParams(cfg *config, params string) (err error) {
 		// xprotocol usage?
 		case "xprotocol":
 			var isBool bool
-			if cfg.useXProtocol, isBool = readBool(value); !isBool {
+			if cfg.UseXProtocol, isBool = readBool(value); !isBool {
+				return fmt.Errorf("Invalid Bool value: %s", value)
+			}
+			//			fmt.Printf("DEBUG: cfg.UseXProtocol=%v\n", cfg.UseXProtocol)
+
+		// Try hosts of the same priority in a random order
+		case "randomizeHosts":
+			var isBool bool
+			if cfg.RandomizeHosts, isBool = readBool(value); !isBool {
+				return fmt.Errorf("Invalid Bool value: %s", value)
+			}
+
+		// Send read only transactions to a secondary?
+		case "routeReadOnly":
+			var isBool bool
+			if cfg.RouteReadOnly, isBool = readBool(value); !isBool {
+				return fmt.Errorf("Invalid Bool value: %s", value)
+			}
+
+		// Send queries outside a transaction to a secondary?
+		case "routeQueries":
+			var isBool bool
+			if cfg.RouteQueries, isBool = readBool(value); !isBool {
 				return fmt.Errorf("Invalid Bool value: %s", value)
 			}
-			//			fmt.Printf("DEBUG: cfg.useXProtocol=%v\n", cfg.useXProtocol)
+
+		// Record the frames of every connection in a transcript file
+		case "wireTap":
+			if value, err = url.QueryUnescape(value); err != nil {
+				return
+			}
+			cfg.WireTap = value
+
+		// Most verbose level of driver messages to log
+		case "logLevel":
+			if cfg.LogLevel, err = ParseLogLevel(value); err != nil {
+				return
+			}
+
+		// Send CapabilitiesGet to find out what the server supports?
+		case "getCapabilities":
+			var isBool bool
+			if cfg.UseGetCapabilities, isBool = readBool(value); !isBool {
+				return fmt.Errorf("Invalid Bool value: %s", value)
+			}
+
+		// X protocol authentication mechanism
+		case "authMechanism":
+			mechanism := strings.ToUpper(value)
+			if !isSupportedAuthMechanism(mechanism) {
+				return fmt.Errorf("Invalid or unsupported authentication mechanism: %s", value)
+			}
+			cfg.AuthMechanism = mechanism
 
 		default:
 			// lazy init
-			if cfg.params == nil {
-				cfg.params = make(map[string]string)
+			if cfg.Params == nil {
+				cfg.Params = make(map[string]string)
 			}
 
-			if cfg.params[param[0]], err = url.QueryUnescape(value); err != nil {
+			if cfg.Params[param[0]], err = url.QueryUnescape(value); err != nil {
 				return
 			}
 		}
@@ -0,0 +1,272 @@
+// Go driver for MySQL X Protocol
+//
+// Copyright 2016 Simon J Mudd.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package wiretap records the X protocol frames sent over a connection
+// so a session can be examined or replayed later.
+//
+// A transcript is a text file with one frame per line:
+//
+//	# mysqlx wiretap v1
+//	<time> <conn> <direction> <type> <name> <payload>
+//
+// where
+//
+//	time       RFC 3339 timestamp with nanoseconds, when the frame was complete
+//	conn       number of the connection within the transcript, from 1
+//	direction  C>S for client to server, S>C for server to client
+//	type       message type number
+//	name       message type name, e.g. SQL_STMT_EXECUTE, or ? if unknown
+//	payload    hex encoded protobuf payload, - if empty
+//
+// Lines starting with # and empty lines are ignored. For example:
+//
+//	2016-09-01T10:00:00.000000001Z 1 C>S 1 CON_CAPABILITIES_GET -
+//	2016-09-01T10:00:00.000000002Z 1 S>C 2 CONN_CAPABILITIES 0a1d0a1961...
+//
+// Frames are recorded after TLS is removed so the transcript may hold
+// passwords and data and should be handled with care.
+package wiretap
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx"
+)
+
+// Header is the first line of a transcript
+const Header = "# mysqlx wiretap v1"
+
+// Direction is the direction a frame was sent in
+type Direction int
+
+// The two directions
+const (
+	ClientToServer Direction = iota
+	ServerToClient
+)
+
+func (d Direction) String() string {
+	if d == ClientToServer {
+		return "C>S"
+	}
+	return "S>C"
+}
+
+// Record is a single frame
+type Record struct {
+	Time      time.Time
+	Conn      uint64
+	Direction Direction
+	Type      int
+	Payload   []byte
+}
+
+// TypeName returns the name of the message type
+func (r Record) TypeName() string {
+	var names map[int32]string
+	if r.Direction == ClientToServer {
+		names = Mysqlx.ClientMessages_Type_name
+	} else {
+		names = Mysqlx.ServerMessages_Type_name
+	}
+	if name, found := names[int32(r.Type)]; found {
+		return name
+	}
+	return "?"
+}
+
+// String returns the record as a transcript line without the newline
+func (r Record) String() string {
+	payload := "-"
+	if len(r.Payload) > 0 {
+		payload = hex.EncodeToString(r.Payload)
+	}
+	return fmt.Sprintf("%s %d %s %d %s %s",
+		r.Time.UTC().Format(time.RFC3339Nano),
+		r.Conn,
+		r.Direction,
+		r.Type,
+		r.TypeName(),
+		payload)
+}
+
+// Frame returns the record as it was sent on the wire
+func (r Record) Frame() []byte {
+	data := make([]byte, 5+len(r.Payload))
+	binary.LittleEndian.PutUint32(data, uint32(len(r.Payload)+1))
+	data[4] = byte(r.Type)
+	copy(data[5:], r.Payload)
+	return data
+}
+
+// ParseRecord parses a single transcript line
+func ParseRecord(line string) (Record, error) {
+	var r Record
+	fields := strings.Fields(line)
+	if len(fields) != 6 {
+		return r, fmt.Errorf("wiretap: expected 6 fields, got %d: %q", len(fields), line)
+	}
+
+	var err error
+	if r.Time, err = time.Parse(time.RFC3339Nano, fields[0]); err != nil {
+		return r, fmt.Errorf("wiretap: invalid time: %v", err)
+	}
+	if r.Conn, err = strconv.ParseUint(fields[1], 10, 64); err != nil {
+		return r, fmt.Errorf("wiretap: invalid connection: %q", fields[1])
+	}
+	switch fields[2] {
+	case "C>S":
+		r.Direction = ClientToServer
+	case "S>C":
+		r.Direction = ServerToClient
+	default:
+		return r, fmt.Errorf("wiretap: invalid direction: %q", fields[2])
+	}
+	if r.Type, err = strconv.Atoi(fields[3]); err != nil || r.Type < 0 || r.Type > 255 {
+		return r, fmt.Errorf("wiretap: invalid message type: %q", fields[3])
+	}
+	if fields[5] != "-" {
+		if r.Payload, err = hex.DecodeString(fields[5]); err != nil {
+			return r, fmt.Errorf("wiretap: invalid payload: %v", err)
+		}
+	}
+	return r, nil
+}
+
+// ReadAll reads all the records of a transcript
+func ReadAll(rd io.Reader) ([]Record, error) {
+	var records []Record
+	scanner := bufio.NewScanner(rd)
+	scanner.Buffer(nil, 1<<30)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		r, err := ParseRecord(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		records = append(records, r)
+	}
+	return records, scanner.Err()
+}
+
+// Recorder writes the frames of one or more connections to a transcript
+type Recorder struct {
+	mu       sync.Mutex
+	w        io.Writer
+	started  bool
+	lastConn uint64
+	err      error
+}
+
+// NewRecorder returns a Recorder writing to w
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{w: w}
+}
+
+// Err returns the first error writing the transcript, if any
+func (rec *Recorder) Err() error {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return rec.err
+}
+
+// write adds a record to the transcript. Errors are remembered but
+// do not affect the connection being recorded.
+func (rec *Recorder) write(r Record) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if rec.err != nil {
+		return
+	}
+	if !rec.started {
+		rec.started = true
+		if _, rec.err = io.WriteString(rec.w, Header+"\n"); rec.err != nil {
+			return
+		}
+	}
+	_, rec.err = io.WriteString(rec.w, r.String()+"\n")
+}
+
+// Conn returns a net.Conn which records the frames sent and received
+// on c as a new connection in the transcript
+func (rec *Recorder) Conn(c net.Conn) *Conn {
+	rec.mu.Lock()
+	rec.lastConn++
+	id := rec.lastConn
+	rec.mu.Unlock()
+
+	return &Conn{
+		Conn: c,
+		rec:  rec,
+		id:   id,
+		in:   framer{direction: ServerToClient},
+		out:  framer{direction: ClientToServer},
+	}
+}
+
+// Conn is a net.Conn seen from the client side whose frames are
+// recorded. The underlying Conn may be replaced, e.g. by a TLS
+// connection, without starting a new connection in the transcript.
+type Conn struct {
+	net.Conn
+	rec *Recorder
+	id  uint64
+	in  framer
+	out framer
+}
+
+// Read reads from the connection and records any complete frames
+func (c *Conn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.in.add(b[:n], c)
+	return n, err
+}
+
+// Write writes to the connection and records any complete frames
+func (c *Conn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	c.out.add(b[:n], c)
+	return n, err
+}
+
+// framer collects the bytes sent in one direction into frames
+type framer struct {
+	direction Direction
+	buf       []byte
+}
+
+// add appends the data and records any complete frames
+func (f *framer) add(data []byte, c *Conn) {
+	f.buf = append(f.buf, data...)
+	for len(f.buf) >= 5 {
+		length := int(binary.LittleEndian.Uint32(f.buf))
+		if length < 1 {
+			// not a valid frame, skip the header
+			f.buf = f.buf[4:]
+			continue
+		}
+		if len(f.buf) < 4+length {
+			return
+		}
+		payload := append([]byte(nil), f.buf[5:4+length]...)
+		c.rec.write(Record{Time: time.Now(), Conn: c.id, Direction: f.direction, Type: int(f.buf[4]), Payload: payload})
+		f.buf = f.buf[4+length:]
+	}
+}
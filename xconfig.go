@@ -3,6 +3,8 @@ package mysql
 import (
 	"crypto/tls"
 	"time"
+
+	"github.com/sjmudd/go-mysqlx-driver/wiretap"
 )
 
 type xconfig struct {
@@ -19,27 +21,43 @@ type xconfig struct {
 	allowAllFiles           bool
 	allowCleartextPasswords bool
 	columnsWithAlias        bool
+	columnsOriginalName     bool   // name columns by original_name and original_table
+	convertCharset          bool   // convert text to and from the character set of the collation
+	compression             string // compression algorithm to negotiate, "" for none
+	fetchSize               uint64 // rows fetched at a time through a cursor, 0 for no cursor
+	compactMetadata         bool   // ask for compact metadata for statements run before
 	interpolateParams       bool
-	useXProtocol            bool // use X protocol rather than native protocol
-	useGetCapabilities      bool // for X protocol, do we send a GetCapabilities message to query server capabilities?  default: true
+	useXProtocol            bool   // use X protocol rather than native protocol
+	useGetCapabilities      bool   // for X protocol, do we send a GetCapabilities message to query server capabilities?  default: true
+	authMechanism           string // authentication mechanism to use, assumed to be supported if useGetCapabilities is false
+	dialContext             DialContextFunc
+	tap                     *wiretap.Recorder // records the frames of the connection if set
 }
 
-func NewXconfigFromConfig(cfg *config) *xconfig {
+func NewXconfigFromConfig(cfg *Config) *xconfig {
 	return &xconfig{
-		user:                    cfg.user,
-		passwd:                  cfg.passwd,
-		net:                     cfg.net,
-		addr:                    cfg.addr,
-		dbname:                  cfg.dbname,
-		params:                  cfg.params,
-		loc:                     cfg.loc,
-		tls:                     cfg.tls,
-		timeout:                 cfg.timeout,
-		collation:               cfg.collation,
-		allowAllFiles:           cfg.allowAllFiles,
-		allowCleartextPasswords: cfg.allowCleartextPasswords,
-		columnsWithAlias:        cfg.columnsWithAlias,
-		interpolateParams:       cfg.interpolateParams,
+		user:                    cfg.User,
+		passwd:                  cfg.Passwd,
+		net:                     cfg.Net,
+		addr:                    cfg.Addr,
+		dbname:                  cfg.DBName,
+		params:                  cfg.Params,
+		loc:                     cfg.Loc,
+		tls:                     cfg.TLS,
+		timeout:                 cfg.Timeout,
+		collation:               cfg.Collation,
+		allowAllFiles:           cfg.AllowAllFiles,
+		allowCleartextPasswords: cfg.AllowCleartextPasswords,
+		columnsWithAlias:        cfg.ColumnsWithAlias,
+		columnsOriginalName:     cfg.ColumnsOriginalName,
+		convertCharset:          cfg.ConvertCharset,
+		compression:             cfg.Compression,
+		fetchSize:               cfg.FetchSize,
+		compactMetadata:         cfg.CompactMetadata,
+		interpolateParams:       cfg.InterpolateParams,
 		useXProtocol:            true,
+		useGetCapabilities:      cfg.UseGetCapabilities,
+		authMechanism:           cfg.AuthMechanism,
+		dialContext:             cfg.DialContext,
 	}
 }
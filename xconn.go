@@ -0,0 +1,434 @@
+// Go driver for MySQL X Protocol
+//
+// Copyright 2016 Simon J Mudd.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx"
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Crud"
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Datatypes"
+	"github.com/sjmudd/go-mysqlx-driver/Mysqlx_Expr"
+	"github.com/sjmudd/go-mysqlx-driver/capability"
+)
+
+// XConn is implemented by the connections of this driver and gives
+// access to X protocol features which database/sql does not cover.
+// It is reached with sql.Conn.Raw:
+//
+//	conn, err := db.Conn(ctx)
+//	...
+//	err = conn.Raw(func(driverConn interface{}) error {
+//		xc := driverConn.(mysql.XConn)
+//		fmt.Println(xc.Notices())
+//		return nil
+//	})
+type XConn interface {
+	// ServerCapabilities returns the capabilities the server sent
+	// when connecting, or those assumed if they were not asked for.
+	ServerCapabilities() capability.ServerCapabilities
+
+	// Notices returns the notices received since the last statement
+	// was sent. Those sent after the rows are only seen once the rows
+	// have been read or closed.
+	Notices() []Notice
+
+	// Find returns the documents of the collection matching the
+	// criteria, see expr.go for the syntax. The schema defaults to
+	// the one connected to, an empty criteria matches every document
+	// and a limit of 0 means no limit. The rows have a single column,
+	// doc, holding each document as JSON. The criteria may hold
+	// named placeholders, such as "age > :age", whose values are
+	// given as args with sql.Named("age", 18).
+	Find(ctx context.Context, schema, collection, criteria string, limit uint64, args ...interface{}) (driver.Rows, error)
+
+	// Insert adds documents to the collection. A document given as a
+	// string, []byte or json.RawMessage is taken to be JSON, others
+	// are converted with encoding/json. Servers from 8.0.11 give an
+	// _id to documents which have none.
+	Insert(ctx context.Context, schema, collection string, docs ...interface{}) (driver.Result, error)
+
+	// Update sets members of the documents matching the criteria. set
+	// maps a document path, such as "address.city", to an expression
+	// giving its new value, such as "age + 1" or ":city". The criteria
+	// and values may share placeholders. A criteria is required, use
+	// "true" to update every document.
+	Update(ctx context.Context, schema, collection, criteria string, set map[string]string, args ...interface{}) (driver.Result, error)
+
+	// Delete removes the documents matching the criteria, at most
+	// limit of them unless it is 0. A criteria is required, use
+	// "true" to remove every document.
+	Delete(ctx context.Context, schema, collection, criteria string, limit uint64, args ...interface{}) (driver.Result, error)
+
+	// Stats returns the counters of the connection since it was
+	// made. SetStatsCollector gives those of all connections.
+	Stats() Stats
+}
+
+// ServerCapabilities implements XConn
+func (mc *mysqlXConn) ServerCapabilities() capability.ServerCapabilities {
+	return mc.capabilities
+}
+
+// Notices implements XConn
+func (mc *mysqlXConn) Notices() []Notice {
+	return append([]Notice(nil), mc.notices...)
+}
+
+// Stats implements XConn
+func (mc *mysqlXConn) Stats() Stats {
+	return *mc.stats.since(&connStats{})
+}
+
+// Find implements XConn
+func (mc *mysqlXConn) Find(ctx context.Context, schema, collection, criteria string, limit uint64, args ...interface{}) (driver.Rows, error) {
+	if mc.netConn == nil {
+		mc.log.print(ErrInvalidConn)
+		return nil, driver.ErrBadConn
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if schema == "" {
+		schema = mc.cfg.dbname
+	}
+	find := &Mysqlx_Crud.Find{
+		Collection: &Mysqlx_Crud.Collection{Name: proto.String(collection), Schema: proto.String(schema)},
+		DataModel:  Mysqlx_Crud.DataModel_DOCUMENT.Enum(),
+	}
+	var placeholders []string
+	if criteria != "" {
+		expr, names, err := parseExprPlaceholders(criteria, true, nil)
+		if err != nil {
+			return nil, err
+		}
+		find.Criteria, placeholders = expr, names
+	}
+	scalars, values, err := mc.crudArgs(placeholders, args)
+	if err != nil {
+		return nil, fmt.Errorf("mysqlXConn.Find: %v", err)
+	}
+	find.Args = scalars
+	if limit > 0 {
+		find.Limit = &Mysqlx_Crud.Limit{RowCount: proto.Uint64(limit)}
+	}
+
+	if mc.log.on(LogDebug) {
+		mc.log.printf(LogDebug, "mysqlXConn.Find(%s)", find.String())
+	}
+	pb := &netProtobuf{msgType: int(Mysqlx.ClientMessages_CRUD_FIND)}
+	if pb.payload, err = proto.Marshal(find); err != nil {
+		return nil, fmt.Errorf("mysqlXConn.Find: failed to marshal Find: %v", err)
+	}
+	mc.notices = nil
+	qh := mc.beforeQuery(ctx, "Find", Mysqlx.ClientMessages_CRUD_FIND, findStatement(schema, collection, criteria, limit), values)
+	if err := mc.writeProtobufPacket(pb); err != nil {
+		qh.done(mc, err)
+		return nil, err
+	}
+
+	return &mysqlXRows{
+		mc:    mc,
+		state: queryStateWaitingColumnMetaData,
+		hook:  qh,
+	}, nil
+}
+
+// crudArgs returns the args of a CRUD message, given with sql.Named,
+// in the order of the placeholders of its expressions, as sent and as
+// given to the hooks
+func (mc *mysqlXConn) crudArgs(placeholders []string, args []interface{}) ([]*Mysqlx_Datatypes.Scalar, []driver.Value, error) {
+	named := make(map[string]interface{}, len(args))
+	for _, arg := range args {
+		na, ok := arg.(sql.NamedArg)
+		if !ok || na.Name == "" {
+			return nil, nil, fmt.Errorf("args must be given with sql.Named, got %T", arg)
+		}
+		named[na.Name] = na.Value
+	}
+
+	var (
+		scalars []*Mysqlx_Datatypes.Scalar
+		values  []driver.Value
+	)
+	for _, name := range placeholders {
+		arg, ok := named[name]
+		if !ok {
+			return nil, nil, fmt.Errorf("no arg given for :%s", name)
+		}
+		delete(named, name)
+		v, err := argValue(arg)
+		if err != nil {
+			return nil, nil, fmt.Errorf("arg %s: %v", name, err)
+		}
+		s, err := mc.argScalar(v)
+		if err != nil {
+			return nil, nil, fmt.Errorf("arg %s: %v", name, err)
+		}
+		scalars = append(scalars, s)
+		values = append(values, v)
+	}
+	for name := range named {
+		return nil, nil, fmt.Errorf("arg %s is not used", name)
+	}
+	return scalars, values, nil
+}
+
+// findStatement describes a Find for the hooks in the style of the
+// mysql shell, e.g. test.people.find("age > 30").limit(10)
+func findStatement(schema, collection, criteria string, limit uint64) string {
+	s := schema + "." + collection + ".find(" + strconv.Quote(criteria) + ")"
+	if limit > 0 {
+		s += ".limit(" + strconv.FormatUint(limit, 10) + ")"
+	}
+	return s
+}
+
+// Insert implements XConn
+func (mc *mysqlXConn) Insert(ctx context.Context, schema, collection string, docs ...interface{}) (driver.Result, error) {
+	if len(docs) == 0 {
+		return nil, fmt.Errorf("mysqlXConn.Insert: no documents given")
+	}
+	if schema == "" {
+		schema = mc.cfg.dbname
+	}
+	insert := &Mysqlx_Crud.Insert{
+		Collection: &Mysqlx_Crud.Collection{Name: proto.String(collection), Schema: proto.String(schema)},
+		DataModel:  Mysqlx_Crud.DataModel_DOCUMENT.Enum(),
+	}
+	values := make([]driver.Value, len(docs))
+	for i, doc := range docs {
+		var raw json.RawMessage
+		switch doc := doc.(type) {
+		case string:
+			raw = json.RawMessage(doc)
+		case []byte:
+			raw = json.RawMessage(doc)
+		case json.RawMessage:
+			raw = doc
+		default:
+			b, err := json.Marshal(doc)
+			if err != nil {
+				return nil, fmt.Errorf("mysqlXConn.Insert: document %d: %v", i+1, err)
+			}
+			raw = b
+		}
+		if !json.Valid(raw) {
+			return nil, fmt.Errorf("mysqlXConn.Insert: document %d is not valid JSON", i+1)
+		}
+		s, err := mc.argScalar(raw)
+		if err != nil {
+			return nil, fmt.Errorf("mysqlXConn.Insert: document %d: %v", i+1, err)
+		}
+		insert.Row = append(insert.Row, &Mysqlx_Crud.Insert_TypedRow{
+			Field: []*Mysqlx_Expr.Expr{{Type: Mysqlx_Expr.Expr_LITERAL.Enum(), Literal: s}},
+		})
+		values[i] = raw
+	}
+
+	return mc.crudExec(ctx, "Insert", Mysqlx.ClientMessages_CRUD_INSERT, insert, schema+"."+collection+".add()", values)
+}
+
+// Update implements XConn
+func (mc *mysqlXConn) Update(ctx context.Context, schema, collection, criteria string, set map[string]string, args ...interface{}) (driver.Result, error) {
+	if criteria == "" {
+		return nil, fmt.Errorf("mysqlXConn.Update: no criteria given, use \"true\" to update every document")
+	}
+	if len(set) == 0 {
+		return nil, fmt.Errorf("mysqlXConn.Update: nothing to set")
+	}
+	if schema == "" {
+		schema = mc.cfg.dbname
+	}
+	update := &Mysqlx_Crud.Update{
+		Collection: &Mysqlx_Crud.Collection{Name: proto.String(collection), Schema: proto.String(schema)},
+		DataModel:  Mysqlx_Crud.DataModel_DOCUMENT.Enum(),
+	}
+	expr, placeholders, err := parseExprPlaceholders(criteria, true, nil)
+	if err != nil {
+		return nil, err
+	}
+	update.Criteria = expr
+
+	// the paths are sorted so the operations are always sent in the same order
+	paths := make([]string, 0, len(set))
+	for path := range set {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	statement := schema + "." + collection + ".modify(" + strconv.Quote(criteria) + ")"
+	for _, path := range paths {
+		source, err := parseExpr(path, true)
+		if err != nil {
+			return nil, fmt.Errorf("mysqlXConn.Update: path %q: %v", path, err)
+		}
+		if source.GetType() != Mysqlx_Expr.Expr_IDENT {
+			return nil, fmt.Errorf("mysqlXConn.Update: %q is not a document path", path)
+		}
+		var value *Mysqlx_Expr.Expr
+		if value, placeholders, err = parseExprPlaceholders(set[path], true, placeholders); err != nil {
+			return nil, fmt.Errorf("mysqlXConn.Update: value of %s: %v", path, err)
+		}
+		update.Operation = append(update.Operation, &Mysqlx_Crud.UpdateOperation{
+			Source:    source.Identifier,
+			Operation: Mysqlx_Crud.UpdateOperation_ITEM_SET.Enum(),
+			Value:     value,
+		})
+		statement += ".set(" + strconv.Quote(path) + ", " + strconv.Quote(set[path]) + ")"
+	}
+	scalars, values, err := mc.crudArgs(placeholders, args)
+	if err != nil {
+		return nil, fmt.Errorf("mysqlXConn.Update: %v", err)
+	}
+	update.Args = scalars
+
+	return mc.crudExec(ctx, "Update", Mysqlx.ClientMessages_CRUD_UPDATE, update, statement, values)
+}
+
+// Delete implements XConn
+func (mc *mysqlXConn) Delete(ctx context.Context, schema, collection, criteria string, limit uint64, args ...interface{}) (driver.Result, error) {
+	if criteria == "" {
+		return nil, fmt.Errorf("mysqlXConn.Delete: no criteria given, use \"true\" to remove every document")
+	}
+	if schema == "" {
+		schema = mc.cfg.dbname
+	}
+	del := &Mysqlx_Crud.Delete{
+		Collection: &Mysqlx_Crud.Collection{Name: proto.String(collection), Schema: proto.String(schema)},
+		DataModel:  Mysqlx_Crud.DataModel_DOCUMENT.Enum(),
+	}
+	expr, placeholders, err := parseExprPlaceholders(criteria, true, nil)
+	if err != nil {
+		return nil, err
+	}
+	del.Criteria = expr
+	scalars, values, err := mc.crudArgs(placeholders, args)
+	if err != nil {
+		return nil, fmt.Errorf("mysqlXConn.Delete: %v", err)
+	}
+	del.Args = scalars
+	statement := schema + "." + collection + ".remove(" + strconv.Quote(criteria) + ")"
+	if limit > 0 {
+		del.Limit = &Mysqlx_Crud.Limit{RowCount: proto.Uint64(limit)}
+		statement += ".limit(" + strconv.FormatUint(limit, 10) + ")"
+	}
+
+	return mc.crudExec(ctx, "Delete", Mysqlx.ClientMessages_CRUD_DELETE, del, statement, values)
+}
+
+// crudExec sends a CRUD message which returns no rows and returns the
+// rows it affected, taken from the notices as for Exec
+func (mc *mysqlXConn) crudExec(ctx context.Context, op string, msgType Mysqlx.ClientMessages_Type, msg proto.Message, statement string, values []driver.Value) (driver.Result, error) {
+	if mc.netConn == nil {
+		mc.log.print(ErrInvalidConn)
+		return nil, driver.ErrBadConn
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if mc.log.on(LogDebug) {
+		mc.log.printf(LogDebug, "mysqlXConn.%s(%s)", op, proto.CompactTextString(msg))
+	}
+	pb := &netProtobuf{msgType: int(msgType)}
+	var err error
+	if pb.payload, err = proto.Marshal(msg); err != nil {
+		return nil, fmt.Errorf("mysqlXConn.%s: failed to marshal %s: %v", op, op, err)
+	}
+	mc.notices = nil
+	mc.affectedRows, mc.insertID = 0, 0
+	qh := mc.beforeQuery(ctx, op, msgType, statement, values)
+	err = mc.writeProtobufPacket(pb)
+	if err == nil {
+		rows := &mysqlXRows{mc: mc, state: queryStateWaitingColumnMetaData}
+		err = rows.Close()
+	}
+	if qh != nil {
+		qh.info.RowsAffected = int64(mc.affectedRows)
+	}
+	qh.done(mc, err)
+	if err != nil {
+		return nil, err
+	}
+	return &mysqlResult{affectedRows: int64(mc.affectedRows)}, nil
+}
+
+// ServerCapabilities implements XConn for the primary
+func (rc *routedConn) ServerCapabilities() capability.ServerCapabilities {
+	mc, err := rc.conn(context.Background(), false)
+	if err != nil {
+		return nil
+	}
+	return mc.ServerCapabilities()
+}
+
+// Notices implements XConn for the connection used last
+func (rc *routedConn) Notices() []Notice {
+	if rc.last == nil {
+		return nil
+	}
+	return rc.last.Notices()
+}
+
+// Stats implements XConn, adding up those of the primary and secondary
+func (rc *routedConn) Stats() Stats {
+	var s Stats
+	for _, mc := range []*mysqlXConn{rc.primary, rc.secondary} {
+		if mc != nil {
+			mcStats := mc.Stats()
+			s.Add(&mcStats)
+		}
+	}
+	return s
+}
+
+// Find implements XConn, going to a secondary if routeQueries is set
+func (rc *routedConn) Find(ctx context.Context, schema, collection, criteria string, limit uint64, args ...interface{}) (driver.Rows, error) {
+	mc, err := rc.conn(ctx, rc.c.cfg.RouteQueries)
+	if err != nil {
+		return nil, err
+	}
+	return mc.Find(ctx, schema, collection, criteria, limit, args...)
+}
+
+// Insert implements XConn on the primary
+func (rc *routedConn) Insert(ctx context.Context, schema, collection string, docs ...interface{}) (driver.Result, error) {
+	mc, err := rc.conn(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	return mc.Insert(ctx, schema, collection, docs...)
+}
+
+// Update implements XConn on the primary
+func (rc *routedConn) Update(ctx context.Context, schema, collection, criteria string, set map[string]string, args ...interface{}) (driver.Result, error) {
+	mc, err := rc.conn(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	return mc.Update(ctx, schema, collection, criteria, set, args...)
+}
+
+// Delete implements XConn on the primary
+func (rc *routedConn) Delete(ctx context.Context, schema, collection, criteria string, limit uint64, args ...interface{}) (driver.Result, error) {
+	mc, err := rc.conn(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	return mc.Delete(ctx, schema, collection, criteria, limit, args...)
+}